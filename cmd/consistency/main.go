@@ -0,0 +1,122 @@
+// Command consistency checks read models against the event store and prints
+// a drift report.
+//
+//	go run ./cmd/consistency -type Inventory                 # check every read_inventory row
+//	go run ./cmd/consistency -type Order -sample 500         # check 500 random orders
+//	go run ./cmd/consistency -type Order -ids id1,id2 -repair
+//
+// Each aggregate is replayed from the event store and compared field by
+// field with its read model. With -repair, drifted read models are replaced
+// with the ones expected from their aggregates. It exits with status 1 if
+// drift was found and not repaired, so it can be scheduled nightly. It uses
+// the same environment variables as the API server to select the event
+// store and read database.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strings"
+
+	"github.com/example/ec-event-driven/internal/cmdutil"
+	"github.com/example/ec-event-driven/internal/domain/aggregate"
+	_ "github.com/example/ec-event-driven/internal/domain/all" // Registers the event types
+	"github.com/example/ec-event-driven/internal/drift"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+)
+
+func main() {
+	aggregateType := flag.String("type", "", "aggregate type ("+strings.Join(drift.Types(), ", ")+")")
+	ids := flag.String("ids", "", "comma-separated aggregate IDs to check (default: all)")
+	sample := flag.Int("sample", 0, "check only this many randomly chosen aggregates (0: all)")
+	fromEvents := flag.Bool("from-events", false, "also check aggregates without a read model (reads the whole event store)")
+	repair := flag.Bool("repair", false, "repair drifted read models")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	if *aggregateType == "" || *sample < 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := aggregate.ConfigureSnapshotPolicies(os.Getenv); err != nil {
+		log.Fatalf("[Consistency] Invalid snapshot policy: %v", err)
+	}
+
+	ctx := context.Background()
+	db, err := store.ConnectPostgres(store.DatabaseURLFromEnv())
+	if err != nil {
+		log.Fatalf("[Consistency] Failed to connect to PostgreSQL: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	eventStore, closeStore, err := store.OpenEventStoreFromEnv(ctx, db)
+	if err != nil {
+		log.Fatalf("[Consistency] Failed to open event store: %v", err)
+	}
+	defer closeStore()
+
+	checker := drift.NewChecker(eventStore, store.NewPostgresReadStore(db))
+	checker.Repair = *repair
+
+	selected := cmdutil.ParseList(*ids)
+	if len(selected) == 0 {
+		if selected, err = checker.AggregateIDs(ctx, *aggregateType, *fromEvents); err != nil {
+			log.Fatalf("[Consistency] %v", err)
+		}
+	}
+	if *sample > 0 && *sample < len(selected) {
+		rand.Shuffle(len(selected), func(i, j int) { selected[i], selected[j] = selected[j], selected[i] })
+		selected = selected[:*sample]
+	}
+
+	report, err := checker.Check(ctx, *aggregateType, selected)
+	if err != nil {
+		log.Fatalf("[Consistency] Check failed after %d aggregates: %v", checkedCount(report), err)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatalf("[Consistency] %v", err)
+		}
+	} else {
+		printReport(report)
+	}
+
+	for _, d := range report.Drifts {
+		if !d.Repaired && !d.Lagging {
+			os.Exit(1)
+		}
+	}
+}
+
+// printReport prints one line per drift and per differing field
+func printReport(report *drift.Report) {
+	for _, d := range report.Drifts {
+		status := ""
+		switch {
+		case d.Repaired:
+			status = " (repaired)"
+		case d.Lagging:
+			status = " (lagging: projection has not applied the latest events yet)"
+		}
+		fmt.Printf("%s %s v%d: %s%s\n", d.AggregateType, d.AggregateID, d.Version, d.Kind, status)
+		for _, diff := range d.Diffs {
+			fmt.Printf("  %s: read model %v, aggregate %v\n", diff.Field, diff.ReadModel, diff.Aggregate)
+		}
+	}
+	fmt.Printf("%s: checked %d, drifted %d, repaired %d\n",
+		report.AggregateType, report.Checked, len(report.Drifts), report.Repaired())
+}
+
+func checkedCount(report *drift.Report) int {
+	if report == nil {
+		return 0
+	}
+	return report.Checked
+}
@@ -0,0 +1,65 @@
+// Command positions maintains the global positions of a DynamoDB event store.
+//
+//	go run ./cmd/positions backfill  # give positions to events written before positions existed
+//	go run ./cmd/positions sequence  # assign positions to the queued events once
+//
+// Positions are assigned by the sequencer running in the API server; until
+// backfill has run on a table with older events, ReadAll and the sequencer
+// fail with store.ErrPositionsNotBackfilled. It uses the same environment
+// variables as the API server to reach DynamoDB.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s backfill|sequence\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	es, err := store.NewDynamoEventStoreFromEnv(ctx)
+	if err != nil {
+		log.Fatalf("[Positions] %v", err)
+	}
+	sequencer := store.NewDynamoSequencer(es)
+
+	switch flag.Arg(0) {
+	case "backfill":
+		n, err := sequencer.Backfill(ctx)
+		if err != nil {
+			log.Fatalf("[Positions] Backfill failed after %d events: %v", n, err)
+		}
+		log.Printf("[Positions] Backfilled %d events", n)
+		fallthrough // Sequence the events appended during the backfill
+	case "sequence":
+		total := 0
+		for {
+			n, err := sequencer.SequenceOnce(ctx)
+			total += n
+			if err != nil {
+				log.Fatalf("[Positions] Sequencing failed after %d events: %v", total, err)
+			}
+			if n == 0 {
+				break
+			}
+		}
+		log.Printf("[Positions] Assigned positions to %d queued events", total)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,122 @@
+// Command replay rebuilds read model tables from the event store.
+//
+//	go run ./cmd/replay                          # rebuild all rebuildable projections
+//	go run ./cmd/replay -projections inventory   # rebuild read_inventory only
+//
+// Events are projected into empty shadow tables in a separate schema, which
+// then replace the live tables in one transaction. The live projector keeps
+// running meanwhile; it is only paused while the shadow tables catch up with
+// its checkpoints and are swapped in. It uses the same environment variables
+// as the API server to select the event store and read database.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/example/ec-event-driven/internal/cmdutil"
+	_ "github.com/example/ec-event-driven/internal/domain/all" // Registers the event types
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/example/ec-event-driven/internal/projection"
+)
+
+func main() {
+	projections := flag.String("projections", strings.Join(projection.RebuildableProjections, ","),
+		"comma-separated projections to rebuild ("+strings.Join(projection.RebuildableProjections, ", ")+")")
+	schema := flag.String("schema", "replay_shadow", "schema holding the shadow tables during the rebuild")
+	progress := flag.Int("progress", 1000, "log progress every N events")
+	flag.Parse()
+
+	names := cmdutil.ParseList(*projections)
+	selected, err := projection.Default.Select(names)
+	if err != nil {
+		log.Fatalf("[Replay] %v", err)
+	}
+
+	ctx := context.Background()
+	databaseURL := store.DatabaseURLFromEnv()
+	db, err := store.ConnectPostgres(databaseURL)
+	if err != nil {
+		log.Fatalf("[Replay] Failed to connect to PostgreSQL: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	eventStore, closeStore, err := store.OpenEventStoreFromEnv(ctx, db)
+	if err != nil {
+		log.Fatalf("[Replay] Failed to open event store: %v", err)
+	}
+	defer closeStore()
+
+	shadow, err := store.NewShadowTables(db, *schema, names)
+	if err != nil {
+		log.Fatalf("[Replay] %v", err)
+	}
+	if err := rebuild(ctx, eventStore, shadow, databaseURL, *schema, selected, *progress); err != nil {
+		if dropErr := shadow.Drop(ctx); dropErr != nil {
+			log.Printf("[Replay] Failed to drop shadow tables: %v", dropErr)
+		}
+		log.Fatalf("[Replay] Rebuild failed: %v", err)
+	}
+}
+
+// rebuild fills the shadow tables from the event store and swaps them in
+func rebuild(
+	ctx context.Context,
+	eventStore store.EventStoreInterface,
+	shadow *store.ShadowTables,
+	databaseURL, schema string,
+	projections []projection.Projection,
+	progress int,
+) error {
+	start := time.Now()
+	if err := shadow.Create(ctx); err != nil {
+		return err
+	}
+	log.Printf("[Replay] Created shadow tables %v in schema %s", shadow.Tables(), schema)
+
+	// Unqualified table names resolve to the shadow tables first, then to the
+	// live tables of read models that are not rebuilt
+	shadowURL, err := store.WithSearchPath(databaseURL, schema)
+	if err != nil {
+		return err
+	}
+	shadowDB, err := store.ConnectPostgres(shadowURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to shadow tables: %w", err)
+	}
+	defer func() { _ = shadowDB.Close() }()
+
+	rebuilder, err := projection.NewRebuilder(eventStore, store.NewPostgresReadStore(shadowDB), projections)
+	if err != nil {
+		return err
+	}
+	rebuilder.ProgressInterval = progress
+
+	checkpoints, err := shadow.Checkpoints(ctx, rebuilder.Names())
+	if err != nil {
+		return err
+	}
+	n, err := rebuilder.Replay(ctx, checkpoints)
+	if err != nil {
+		return err
+	}
+	log.Printf("[Replay] Replayed %d events in %s", n, time.Since(start).Round(time.Millisecond))
+
+	err = shadow.Swap(ctx, rebuilder.Names(), func(checkpoints store.ProjectionCheckpoints) error {
+		n, err := rebuilder.CatchUp(ctx, checkpoints)
+		if err != nil {
+			return err
+		}
+		log.Printf("[Replay] Caught up %d events projected during the rebuild", n)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("[Replay] Swapped in rebuilt tables %v in %s", shadow.Tables(), time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,63 @@
+// Command snapshots purges or regenerates the snapshots of an aggregate type.
+//
+//	go run ./cmd/snapshots -type Cart purge       # delete all Cart snapshots
+//	go run ./cmd/snapshots -type Cart regenerate  # rebuild Cart snapshots from events
+//
+// It uses the same environment variables as the API server to select the event store.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/example/ec-event-driven/internal/domain/aggregate"
+	_ "github.com/example/ec-event-driven/internal/domain/all" // Registers the aggregate types
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+)
+
+func main() {
+	aggregateType := flag.String("type", "", "aggregate type ("+strings.Join(aggregate.RegisteredTypes(), ", ")+")")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -type <AggregateType> purge|regenerate\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 || !slices.Contains(aggregate.RegisteredTypes(), *aggregateType) {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := aggregate.ConfigureSnapshotPolicies(os.Getenv); err != nil {
+		log.Fatalf("[Snapshots] Invalid snapshot policy: %v", err)
+	}
+
+	ctx := context.Background()
+	eventStore, closeStore, err := store.OpenEventStoreFromEnv(ctx, nil)
+	if err != nil {
+		log.Fatalf("[Snapshots] Failed to open event store: %v", err)
+	}
+	defer closeStore()
+
+	switch flag.Arg(0) {
+	case "purge":
+		n, err := aggregate.PurgeSnapshots(ctx, eventStore, *aggregateType)
+		if err != nil {
+			log.Fatalf("[Snapshots] Purge failed after %d snapshots: %v", n, err)
+		}
+		log.Printf("[Snapshots] Purged %d %s snapshots", n, *aggregateType)
+	case "regenerate":
+		n, err := aggregate.RegenerateSnapshots(ctx, eventStore, *aggregateType)
+		if err != nil {
+			log.Fatalf("[Snapshots] Regenerate failed after %d snapshots: %v", n, err)
+		}
+		log.Printf("[Snapshots] Regenerated %d %s snapshots", n, *aggregateType)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
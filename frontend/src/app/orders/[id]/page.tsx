@@ -174,6 +174,16 @@ export default function OrderDetailPage() {
               <div className="text-sm text-gray-500 dark:text-gray-400">
                 注文日: {formatDate(order.created_at)}
               </div>
+              {order.shipped_at && (
+                <div className="text-sm text-gray-500 dark:text-gray-400">
+                  発送日: {formatDate(order.shipped_at)}
+                </div>
+              )}
+              {order.tracking_number && (
+                <div className="text-sm text-gray-500 dark:text-gray-400">
+                  配送業者: {order.carrier} / 追跡番号: <span className="font-mono">{order.tracking_number}</span>
+                </div>
+              )}
             </div>
             <div className="text-right">
               {getStatusBadge(order.status)}
@@ -18,8 +18,13 @@ import type {
 
 const API_BASE_URL = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080';
 
+const CONSISTENCY_TOKEN_HEADER = 'X-Consistency-Token';
+const CONSISTENCY_STALE_HEADER = 'X-Consistency-Stale';
+
 class ApiClient {
   private baseUrl: string;
+  // Token of the last command's writes, sent with queries until one reflects them
+  private consistencyToken: string | null = null;
 
   constructor(baseUrl: string) {
     this.baseUrl = baseUrl;
@@ -30,17 +35,31 @@ class ApiClient {
     options: RequestInit = {}
   ): Promise<T> {
     const url = `${this.baseUrl}${endpoint}`;
+    const isQuery = !options.method || options.method === 'GET';
+    const sentToken = isQuery ? this.consistencyToken : null;
     const config: RequestInit = {
       ...options,
       credentials: 'include', // Include cookies for authentication
       headers: {
         'Content-Type': 'application/json',
+        ...(sentToken ? { [CONSISTENCY_TOKEN_HEADER]: sentToken } : {}),
         ...options.headers,
       },
     };
 
     const response = await fetch(url, config);
 
+    const token = response.headers.get(CONSISTENCY_TOKEN_HEADER);
+    if (token) {
+      this.consistencyToken = token;
+    } else if (isQuery && response.ok && !response.headers.get(CONSISTENCY_STALE_HEADER)) {
+      this.consistencyToken = null;
+    } else if (sentToken && response.status === 400) {
+      // Tokens are signed for the user they were issued to, so a token from
+      // before logging in as someone else is rejected
+      this.consistencyToken = null;
+    }
+
     if (!response.ok) {
       const error = await response.json().catch(() => ({ error: 'An error occurred' }));
       throw new Error(error.error || `HTTP error! status: ${response.status}`);
@@ -224,6 +243,13 @@ class ApiClient {
     });
   }
 
+  async payOrder(id: string, paymentMethod?: string): Promise<{ order_id: string; status: string }> {
+    return this.request<{ order_id: string; status: string }>(`/orders/${id}/pay`, {
+      method: 'POST',
+      body: JSON.stringify({ payment_method: paymentMethod }),
+    });
+  }
+
   async cancelOrder(id: string): Promise<MessageResponse> {
     return this.request<MessageResponse>(`/orders/${id}/cancel`, {
       method: 'POST',
@@ -234,6 +260,13 @@ class ApiClient {
   async getAllOrders(): Promise<Order[]> {
     return this.request<Order[]>('/api/admin/orders');
   }
+
+  async shipOrder(id: string, carrier: string, trackingNumber: string): Promise<{ order_id: string; status: string }> {
+    return this.request<{ order_id: string; status: string }>(`/api/admin/orders/${id}/ship`, {
+      method: 'POST',
+      body: JSON.stringify({ carrier, tracking_number: trackingNumber }),
+    });
+  }
 }
 
 export const api = new ApiClient(API_BASE_URL);
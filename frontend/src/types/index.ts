@@ -110,6 +110,9 @@ export interface Order {
   items: OrderItem[];
   total: number;
   status: 'pending' | 'paid' | 'shipped' | 'cancelled';
+  carrier?: string;
+  tracking_number?: string;
+  shipped_at?: string;
   created_at: string;
   updated_at: string;
 }
@@ -16,25 +16,64 @@ resource "aws_dynamodb_table" "events" {
   }
 
   attribute {
-    name = "gsi1pk"
-    type = "S"
+    name = "position_bucket"
+    type = "N"
   }
 
   attribute {
-    name = "created_at"
-    type = "S"
+    name = "position"
+    type = "N"
   }
 
+  attribute {
+    name = "sequence_shard"
+    type = "N"
+  }
+
+  attribute {
+    name = "sequence_key"
+    type = "N"
+  }
+
+  # Events in global position order, 1000 positions per partition
   global_secondary_index {
-    name            = "GSI1"
-    hash_key        = "gsi1pk"
-    range_key       = "created_at"
+    name            = "PositionIndex"
+    hash_key        = "position_bucket"
+    range_key       = "position"
     projection_type = "ALL"
   }
 
+  # Events waiting for the sequencer to assign their position, 16 partitions
+  global_secondary_index {
+    name            = "SequenceIndex"
+    hash_key        = "sequence_shard"
+    range_key       = "sequence_key"
+    projection_type = "KEYS_ONLY"
+  }
+
   tags = local.common_tags
 }
 
+# Position counter of a new table: it has no events written before positions
+# were assigned, so it needs no backfill
+resource "aws_dynamodb_table_item" "events_position_counter" {
+  table_name = aws_dynamodb_table.events.name
+  hash_key   = aws_dynamodb_table.events.hash_key
+  range_key  = aws_dynamodb_table.events.range_key
+
+  item = jsonencode({
+    aggregate_id  = { S = "$position" }
+    version       = { N = "0" }
+    last_position = { N = "0" }
+    backfilled    = { BOOL = true }
+  })
+
+  # The sequencer advances last_position
+  lifecycle {
+    ignore_changes = [item]
+  }
+}
+
 # DynamoDB Snapshots Table
 resource "aws_dynamodb_table" "snapshots" {
   name         = "${local.name_prefix}-snapshots"
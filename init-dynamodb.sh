@@ -17,7 +17,8 @@ echo "DynamoDB Local is ready!"
 
 echo "Creating DynamoDB table: ${TABLE_NAME}"
 
-# Create the events table with GSI for GetAllEvents
+# Create the events table with the position index for ReadAll and the
+# sequence index of the events waiting for their position
 aws dynamodb create-table \
     --endpoint-url "${ENDPOINT}" \
     --region "${REGION}" \
@@ -25,21 +26,32 @@ aws dynamodb create-table \
     --attribute-definitions \
         AttributeName=aggregate_id,AttributeType=S \
         AttributeName=version,AttributeType=N \
-        AttributeName=gsi1pk,AttributeType=S \
-        AttributeName=created_at,AttributeType=S \
+        AttributeName=position_bucket,AttributeType=N \
+        AttributeName=position,AttributeType=N \
+        AttributeName=sequence_shard,AttributeType=N \
+        AttributeName=sequence_key,AttributeType=N \
     --key-schema \
         AttributeName=aggregate_id,KeyType=HASH \
         AttributeName=version,KeyType=RANGE \
     --global-secondary-indexes \
         '[
             {
-                "IndexName": "GSI1",
+                "IndexName": "PositionIndex",
                 "KeySchema": [
-                    {"AttributeName": "gsi1pk", "KeyType": "HASH"},
-                    {"AttributeName": "created_at", "KeyType": "RANGE"}
+                    {"AttributeName": "position_bucket", "KeyType": "HASH"},
+                    {"AttributeName": "position", "KeyType": "RANGE"}
                 ],
                 "Projection": {"ProjectionType": "ALL"},
                 "ProvisionedThroughput": {"ReadCapacityUnits": 5, "WriteCapacityUnits": 5}
+            },
+            {
+                "IndexName": "SequenceIndex",
+                "KeySchema": [
+                    {"AttributeName": "sequence_shard", "KeyType": "HASH"},
+                    {"AttributeName": "sequence_key", "KeyType": "RANGE"}
+                ],
+                "Projection": {"ProjectionType": "KEYS_ONLY"},
+                "ProvisionedThroughput": {"ReadCapacityUnits": 5, "WriteCapacityUnits": 5}
             }
         ]' \
     --provisioned-throughput ReadCapacityUnits=5,WriteCapacityUnits=5 \
@@ -47,6 +59,7 @@ aws dynamodb create-table \
 
 if [ $? -eq 0 ]; then
     echo "Table '${TABLE_NAME}' created successfully!"
+    TABLE_CREATED=1
 else
     echo "Table already exists or creation failed. Checking status..."
 fi
@@ -60,6 +73,17 @@ aws dynamodb wait table-exists \
 
 echo "Table is ready!"
 
+# A new table has no events without positions, so it needs no backfill
+if [ -n "${TABLE_CREATED}" ]; then
+    aws dynamodb put-item \
+        --endpoint-url "${ENDPOINT}" \
+        --region "${REGION}" \
+        --table-name "${TABLE_NAME}" \
+        --item '{"aggregate_id": {"S": "$position"}, "version": {"N": "0"}, "last_position": {"N": "0"}, "backfilled": {"BOOL": true}}' \
+        --condition-expression "attribute_not_exists(aggregate_id)" \
+        --no-cli-pager 2>/dev/null
+fi
+
 # Show events table info
 aws dynamodb describe-table \
     --endpoint-url "${ENDPOINT}" \
@@ -7,15 +7,54 @@ CREATE TABLE IF NOT EXISTS events (
     data JSONB NOT NULL,
     version INT NOT NULL,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    -- Correlation, causation, actor, source IP and schema version
+    metadata JSONB NOT NULL DEFAULT '{}',
+    -- Global commit order, assigned from event_position on append
+    position BIGINT NOT NULL UNIQUE,
 
     -- Ensure events are appended in order per aggregate
     UNIQUE (aggregate_id, version)
 );
 
+-- Last assigned global position (single row). Appends lock this row until
+-- they commit, so positions become visible in order and without gaps.
+CREATE TABLE IF NOT EXISTS event_position (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    last_position BIGINT NOT NULL
+);
+
+INSERT INTO event_position (id, last_position) VALUES (TRUE, 0) ON CONFLICT DO NOTHING;
+
+-- Databases created before event metadata and global positions: add the
+-- columns, number the existing events in the order they were appended and
+-- continue the counter after them
+ALTER TABLE events ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE events ADD COLUMN IF NOT EXISTS position BIGINT;
+
+UPDATE events SET position = numbered.position
+FROM (
+    SELECT id, (SELECT COALESCE(MAX(position), 0) FROM events)
+        + ROW_NUMBER() OVER (ORDER BY created_at, aggregate_id, version) AS position
+    FROM events
+    WHERE position IS NULL
+) AS numbered
+WHERE events.id = numbered.id;
+
+ALTER TABLE events ALTER COLUMN position SET NOT NULL;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'events'::regclass AND conname = 'events_position_key') THEN
+        ALTER TABLE events ADD CONSTRAINT events_position_key UNIQUE (position);
+    END IF;
+END $$;
+
+UPDATE event_position SET last_position = GREATEST(last_position, (SELECT COALESCE(MAX(position), 0) FROM events));
+
 -- Index for querying events by aggregate
-CREATE INDEX idx_events_aggregate_id ON events(aggregate_id);
-CREATE INDEX idx_events_aggregate_type ON events(aggregate_type);
-CREATE INDEX idx_events_created_at ON events(created_at);
+CREATE INDEX IF NOT EXISTS idx_events_aggregate_id ON events(aggregate_id);
+CREATE INDEX IF NOT EXISTS idx_events_aggregate_type ON events(aggregate_type);
+CREATE INDEX IF NOT EXISTS idx_events_created_at ON events(created_at);
 
 -- Optimistic locking: ensure version is sequential
 CREATE OR REPLACE FUNCTION check_event_version()
@@ -34,11 +73,24 @@ BEGIN
 END;
 $$ LANGUAGE plpgsql;
 
+DROP TRIGGER IF EXISTS ensure_event_version ON events;
 CREATE TRIGGER ensure_event_version
     BEFORE INSERT ON events
     FOR EACH ROW
     EXECUTE FUNCTION check_event_version();
 
+-- Snapshots table (used when EVENT_STORE=postgres)
+CREATE TABLE IF NOT EXISTS snapshots (
+    aggregate_id VARCHAR(255) PRIMARY KEY,
+    aggregate_type VARCHAR(100) NOT NULL,
+    version INT NOT NULL,
+    state JSONB NOT NULL,
+    schema_version INT NOT NULL DEFAULT 0, -- 0: written before snapshot schemas were versioned
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_snapshots_aggregate_type ON snapshots(aggregate_type);
+
 -- ============================================
 -- Read Model Tables (Query Side)
 -- ============================================
@@ -87,7 +139,7 @@ CREATE TABLE IF NOT EXISTS read_carts (
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
-CREATE INDEX idx_read_carts_user_id ON read_carts(user_id);
+CREATE INDEX IF NOT EXISTS idx_read_carts_user_id ON read_carts(user_id);
 
 -- Orders read model
 CREATE TABLE IF NOT EXISTS read_orders (
@@ -96,12 +148,20 @@ CREATE TABLE IF NOT EXISTS read_orders (
     items JSONB NOT NULL DEFAULT '[]',
     total INT NOT NULL,
     status VARCHAR(50) NOT NULL DEFAULT 'pending',
+    carrier VARCHAR(100) NOT NULL DEFAULT '',
+    tracking_number VARCHAR(255) NOT NULL DEFAULT '',
+    shipped_at TIMESTAMP WITH TIME ZONE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
-CREATE INDEX idx_read_orders_user_id ON read_orders(user_id);
-CREATE INDEX idx_read_orders_status ON read_orders(status);
+-- Databases created before orders had tracking details
+ALTER TABLE read_orders ADD COLUMN IF NOT EXISTS carrier VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE read_orders ADD COLUMN IF NOT EXISTS tracking_number VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE read_orders ADD COLUMN IF NOT EXISTS shipped_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_read_orders_user_id ON read_orders(user_id);
+CREATE INDEX IF NOT EXISTS idx_read_orders_status ON read_orders(status);
 
 -- Inventory read model
 CREATE TABLE IF NOT EXISTS read_inventory (
@@ -124,8 +184,8 @@ CREATE TABLE IF NOT EXISTS read_users (
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
-CREATE INDEX idx_read_users_email ON read_users(email);
-CREATE INDEX idx_read_users_role ON read_users(role);
+CREATE INDEX IF NOT EXISTS idx_read_users_email ON read_users(email);
+CREATE INDEX IF NOT EXISTS idx_read_users_role ON read_users(role);
 
 -- User sessions table
 CREATE TABLE IF NOT EXISTS user_sessions (
@@ -138,8 +198,8 @@ CREATE TABLE IF NOT EXISTS user_sessions (
     user_agent TEXT
 );
 
-CREATE INDEX idx_user_sessions_user_id ON user_sessions(user_id);
-CREATE INDEX idx_user_sessions_expires ON user_sessions(expires_at);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user_id ON user_sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_expires ON user_sessions(expires_at);
 
 -- Categories read model
 CREATE TABLE IF NOT EXISTS read_categories (
@@ -154,9 +214,9 @@ CREATE TABLE IF NOT EXISTS read_categories (
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
-CREATE INDEX idx_read_categories_parent ON read_categories(parent_id);
-CREATE INDEX idx_read_categories_slug ON read_categories(slug);
-CREATE INDEX idx_read_categories_sort ON read_categories(sort_order);
+CREATE INDEX IF NOT EXISTS idx_read_categories_parent ON read_categories(parent_id);
+CREATE INDEX IF NOT EXISTS idx_read_categories_slug ON read_categories(slug);
+CREATE INDEX IF NOT EXISTS idx_read_categories_sort ON read_categories(sort_order);
 
 -- Product-Category relationship (many-to-many)
 CREATE TABLE IF NOT EXISTS product_categories (
@@ -165,8 +225,53 @@ CREATE TABLE IF NOT EXISTS product_categories (
     PRIMARY KEY (product_id, category_id)
 );
 
-CREATE INDEX idx_product_categories_product ON product_categories(product_id);
-CREATE INDEX idx_product_categories_category ON product_categories(category_id);
+CREATE INDEX IF NOT EXISTS idx_product_categories_product ON product_categories(product_id);
+CREATE INDEX IF NOT EXISTS idx_product_categories_category ON product_categories(category_id);
+
+-- Last event version applied to the read models per aggregate and projection.
+-- Updated in the same transaction as the read model writes so that
+-- redelivered events are skipped.
+CREATE TABLE IF NOT EXISTS projection_checkpoints (
+    projection VARCHAR(100) NOT NULL,
+    aggregate_id VARCHAR(255) NOT NULL,
+    last_version INT NOT NULL,
+    last_event_id VARCHAR(255) NOT NULL,
+    last_position BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (projection, aggregate_id)
+);
+
+-- Stream records that the projector or notifier gave up on (dead letters).
+-- A row is 'retrying' while Kinesis still retries the record, 'dead' once
+-- it failed too often or can never succeed, and 'redriven' or 'discarded'
+-- after an admin dealt with it.
+CREATE TABLE IF NOT EXISTS dead_letter_events (
+    id UUID PRIMARY KEY,
+    consumer VARCHAR(50) NOT NULL,
+    source_id VARCHAR(255) NOT NULL, -- Kinesis sequence number, or 'position-N' from the in-process event bus
+    event_id VARCHAR(255) NOT NULL DEFAULT '',
+    aggregate_id VARCHAR(255) NOT NULL DEFAULT '',
+    event_type VARCHAR(100) NOT NULL DEFAULT '',
+    payload_format VARCHAR(20) NOT NULL, -- 'event' (store.Event JSON) or 'kinesis' (raw record data)
+    payload BYTEA NOT NULL,
+    error TEXT NOT NULL,
+    attempts INT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    first_failed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_failed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (consumer, source_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_dead_letter_events_status ON dead_letter_events(status, last_failed_at);
+
+-- Position of the last event each in-process event bus subscriber processed
+-- (EVENT_BUS=local), so that it resumes from there after a restart.
+CREATE TABLE IF NOT EXISTS event_bus_positions (
+    subscriber VARCHAR(100) PRIMARY KEY,
+    position BIGINT NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
 
 -- ============================================
 -- Initial Admin User
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/example/ec-event-driven/internal/deadletter"
+	"github.com/example/ec-event-driven/internal/domain/aggregate"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+)
+
+// redactedEventFields are removed from event data before it is returned to admins
+var redactedEventFields = []string{"password_hash"}
+
+// AdminHandlers handles admin-only inspection and maintenance requests
+type AdminHandlers struct {
+	eventStore  store.EventStoreInterface
+	deadLetters *deadletter.Service
+}
+
+// NewAdminHandlers creates a new AdminHandlers instance
+func NewAdminHandlers(eventStore store.EventStoreInterface, deadLetters *deadletter.Service) *AdminHandlers {
+	return &AdminHandlers{eventStore: eventStore, deadLetters: deadLetters}
+}
+
+// AggregateAtResponse is an aggregate's state at a point in its history
+type AggregateAtResponse struct {
+	Type    string         `json:"type"`
+	ID      string         `json:"id"`
+	AsOf    string         `json:"as_of,omitempty"`
+	Version int            `json:"version"`
+	State   any            `json:"state"`
+	Events  []AppliedEvent `json:"events"`
+}
+
+// AppliedEvent is an event that was applied to build an aggregate's state
+type AppliedEvent struct {
+	ID        string              `json:"id"`
+	EventType string              `json:"event_type"`
+	Version   int                 `json:"version"`
+	Data      json.RawMessage     `json:"data"`
+	Metadata  store.EventMetadata `json:"metadata"`
+	Timestamp time.Time           `json:"timestamp"`
+}
+
+// GetAggregateAt handles GET /api/admin/aggregates/{type}/{id}?as_of=<version|RFC3339>
+func (h *AdminHandlers) GetAggregateAt(w http.ResponseWriter, r *http.Request) {
+	aggregateType, id, ok := strings.Cut(extractPathParam(r.URL.Path, "/api/admin/aggregates/"), "/")
+	if !ok || aggregateType == "" || id == "" || strings.Contains(id, "/") {
+		respondJSONError(w, "Path must be /api/admin/aggregates/{type}/{id}", http.StatusNotFound)
+		return
+	}
+
+	asOfParam := r.URL.Query().Get("as_of")
+	asOf, err := aggregate.ParseAsOf(asOfParam)
+	if err != nil {
+		respondJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	agg, events, err := aggregate.LoadRegisteredAt(r.Context(), h.eventStore, aggregateType, id, asOf)
+	if err != nil {
+		if errors.Is(err, aggregate.ErrUnknownAggregateType) {
+			respondJSONError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Printf("[API] Error loading %s %s as of %q: %v", aggregateType, id, asOfParam, err)
+		respondJSONError(w, "Failed to load aggregate", http.StatusInternalServerError)
+		return
+	}
+	if len(events) == 0 {
+		respondJSONError(w, "Aggregate not found at the requested point", http.StatusNotFound)
+		return
+	}
+
+	applied := make([]AppliedEvent, 0, len(events))
+	for _, event := range events {
+		applied = append(applied, AppliedEvent{
+			ID:        event.ID,
+			EventType: event.EventType,
+			Version:   event.Version,
+			Data:      redactEventData(event.Data),
+			Metadata:  event.Metadata,
+			Timestamp: event.Timestamp,
+		})
+	}
+
+	respondJSON(w, http.StatusOK, AggregateAtResponse{
+		Type:    aggregateType,
+		ID:      id,
+		AsOf:    asOfParam,
+		Version: agg.GetVersion(),
+		State:   agg,
+		Events:  applied,
+	})
+}
+
+// redactEventData removes sensitive fields from event data
+func redactEventData(data json.RawMessage) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return data
+	}
+	redacted := false
+	for _, name := range redactedEventFields {
+		if _, ok := fields[name]; ok {
+			delete(fields, name)
+			redacted = true
+		}
+	}
+	if !redacted {
+		return data
+	}
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return data
+	}
+	return out
+}
@@ -200,18 +200,16 @@ func (h *AuthHandlers) Refresh(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate session exists and is not expired
-	sessionData, exists, err := h.readStore.Get("sessions", sessionCookie.Value)
+	session, exists, err := h.readStore.ReadModels().Sessions.Get(r.Context(), sessionCookie.Value)
 	if err != nil || !exists {
 		h.clearAuthCookies(w)
 		respondJSONError(w, "Session not found", http.StatusUnauthorized)
 		return
 	}
 
-	session := sessionData.(*readmodel.SessionReadModel)
-
 	// Check session expiration
 	if time.Now().After(session.ExpiresAt) {
-		_ = h.readStore.Delete("sessions", sessionCookie.Value)
+		_ = h.readStore.ReadModels().Sessions.Delete(r.Context(), sessionCookie.Value)
 		h.clearAuthCookies(w)
 		respondJSONError(w, "Session expired", http.StatusUnauthorized)
 		return
@@ -225,14 +223,13 @@ func (h *AuthHandlers) Refresh(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user
-	userData, exists, err := h.readStore.Get("users", userID)
+	userModel, exists, err := h.readStore.ReadModels().Users.Get(r.Context(), userID)
 	if err != nil || !exists {
 		h.clearAuthCookies(w)
 		respondJSONError(w, "User not found", http.StatusUnauthorized)
 		return
 	}
 
-	userModel := userData.(*readmodel.UserReadModel)
 	if !userModel.IsActive {
 		h.clearAuthCookies(w)
 		respondJSONError(w, "Account is deactivated", http.StatusForbidden)
@@ -240,7 +237,7 @@ func (h *AuthHandlers) Refresh(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Delete old session
-	_ = h.readStore.Delete("sessions", sessionCookie.Value)
+	_ = h.readStore.ReadModels().Sessions.Delete(r.Context(), sessionCookie.Value)
 
 	// Generate new tokens (this will create a new session)
 	h.setAuthCookies(w, userModel.ID, userModel.Email, userModel.Role, r)
@@ -258,14 +255,12 @@ func (h *AuthHandlers) Me(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userData, exists, err := h.readStore.Get("users", claims.UserID)
+	userModel, exists, err := h.readStore.ReadModels().Users.Get(r.Context(), claims.UserID)
 	if err != nil || !exists {
 		respondJSONError(w, "User not found", http.StatusNotFound)
 		return
 	}
 
-	userModel := userData.(*readmodel.UserReadModel)
-
 	respondJSON(w, http.StatusOK, UserResponse{
 		ID:        userModel.ID,
 		Email:     userModel.Email,
@@ -293,13 +288,12 @@ func (h *AuthHandlers) ChangePassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user and verify current password
-	userData, exists, err := h.readStore.Get("users", claims.UserID)
+	userModel, exists, err := h.readStore.ReadModels().Users.Get(r.Context(), claims.UserID)
 	if err != nil || !exists {
 		respondJSONError(w, "User not found", http.StatusNotFound)
 		return
 	}
 
-	userModel := userData.(*readmodel.UserReadModel)
 	if !auth.CheckPassword(req.CurrentPassword, userModel.PasswordHash) {
 		respondJSONError(w, "Current password is incorrect", http.StatusBadRequest)
 		return
@@ -333,7 +327,7 @@ func (h *AuthHandlers) setAuthCookies(w http.ResponseWriter, userID, email, role
 	sessionID := uuid.New().String()
 
 	// Store session with hashed refresh token
-	_ = h.readStore.Set("sessions", sessionID, &readmodel.SessionReadModel{
+	_ = h.readStore.ReadModels().Sessions.Upsert(r.Context(), sessionID, &readmodel.SessionReadModel{
 		ID:               sessionID,
 		UserID:           userID,
 		RefreshTokenHash: hashToken(refreshToken),
@@ -9,7 +9,6 @@ import (
 
 	"github.com/example/ec-event-driven/internal/domain/category"
 	"github.com/example/ec-event-driven/internal/infrastructure/store"
-	"github.com/example/ec-event-driven/internal/readmodel"
 )
 
 // CategoryHandlers handles category-related HTTP requests
@@ -48,7 +47,7 @@ type CategoryResponse struct {
 
 // ListCategories returns all categories
 func (h *CategoryHandlers) ListCategories(w http.ResponseWriter, r *http.Request) {
-	allCategories, err := h.readStore.GetAll("categories")
+	allCategories, err := h.readStore.ReadModels().Categories.List(r.Context())
 	if err != nil {
 		log.Printf("[API] Error getting categories: %v", err)
 		respondJSONError(w, "Failed to fetch categories", http.StatusInternalServerError)
@@ -60,8 +59,7 @@ func (h *CategoryHandlers) ListCategories(w http.ResponseWriter, r *http.Request
 	var rootCategories []CategoryResponse
 
 	// First pass: create all category responses
-	for _, c := range allCategories {
-		cat := c.(*readmodel.CategoryReadModel)
+	for _, cat := range allCategories {
 		categoryMap[cat.ID] = &CategoryResponse{
 			ID:          cat.ID,
 			Name:        cat.Name,
@@ -74,8 +72,7 @@ func (h *CategoryHandlers) ListCategories(w http.ResponseWriter, r *http.Request
 	}
 
 	// Second pass: build tree structure
-	for _, c := range allCategories {
-		cat := c.(*readmodel.CategoryReadModel)
+	for _, cat := range allCategories {
 		if cat.ParentID == "" {
 			rootCategories = append(rootCategories, *categoryMap[cat.ID])
 		} else if parent, exists := categoryMap[cat.ParentID]; exists {
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/example/ec-event-driven/internal/deadletter"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+)
+
+const deadLettersPath = "/api/admin/dead-letters"
+
+// DeadLetterResponse is a dead-letter entry with its original payload
+type DeadLetterResponse struct {
+	deadletter.Entry
+	Payload any `json:"payload"`
+}
+
+// ListDeadLetters handles GET /api/admin/dead-letters?consumer=&status=&limit=
+// Entries are listed without their payload; status defaults to "dead".
+func (h *AdminHandlers) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := deadletter.Filter{
+		Consumer: q.Get("consumer"),
+		Status:   deadletter.Status(q.Get("status")),
+		Limit:    100,
+	}
+	if filter.Status == "" {
+		filter.Status = deadletter.StatusDead
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 1000 {
+			respondJSONError(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := h.deadLetters.List(r.Context(), filter)
+	if err != nil {
+		log.Printf("[API] Error listing dead letters: %v", err)
+		respondJSONError(w, "Failed to list dead letters", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []deadletter.Entry{}
+	}
+	respondJSON(w, http.StatusOK, entries)
+}
+
+// DeadLetter handles the routes under /api/admin/dead-letters/{id}:
+// GET inspects an entry, POST .../redrive and POST .../discard resolve it
+func (h *AdminHandlers) DeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(extractPathParam(r.URL.Path, deadLettersPath+"/"), "/")
+	if id == "" {
+		respondJSONError(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+
+	var entry *deadletter.Entry
+	var err error
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		entry, err = h.deadLetters.Get(r.Context(), id)
+	case action == "redrive" && r.Method == http.MethodPost:
+		entry, err = h.deadLetters.Redrive(r.Context(), id)
+	case action == "discard" && r.Method == http.MethodPost:
+		entry, err = h.deadLetters.Discard(r.Context(), id)
+	case action == "" || action == "redrive" || action == "discard":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	default:
+		respondJSONError(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+
+	var redriveErr *deadletter.RedriveError
+	switch {
+	case errors.Is(err, deadletter.ErrNotFound):
+		respondJSONError(w, "Dead letter not found", http.StatusNotFound)
+	case errors.Is(err, deadletter.ErrNotRedrivable):
+		respondJSONError(w, "Only dead entries can be redriven or discarded", http.StatusConflict)
+	case errors.Is(err, deadletter.ErrEarlierVersionDead), errors.Is(err, deadletter.ErrAlreadyApplied):
+		respondJSONError(w, err.Error(), http.StatusConflict)
+	case errors.As(err, &redriveErr):
+		respondJSONError(w, redriveErr.Error(), http.StatusUnprocessableEntity)
+	case err != nil:
+		log.Printf("[API] Error handling dead letter %s: %v", id, err)
+		respondJSONError(w, "Failed to handle dead letter", http.StatusInternalServerError)
+	default:
+		respondJSON(w, http.StatusOK, DeadLetterResponse{Entry: *entry, Payload: deadLetterPayload(entry)})
+	}
+}
+
+// deadLetterPayload decodes an entry's payload for display, redacting event data
+func deadLetterPayload(entry *deadletter.Entry) any {
+	if entry.PayloadFormat == deadletter.FormatEvent {
+		var event store.Event
+		if err := json.Unmarshal(entry.Payload, &event); err == nil {
+			event.Data = redactEventData(event.Data)
+			return event
+		}
+	}
+	if json.Valid(entry.Payload) {
+		return json.RawMessage(entry.Payload)
+	}
+	return string(entry.Payload)
+}
@@ -2,24 +2,41 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"log"
 	"net/http"
 	"strings"
 
 	"github.com/example/ec-event-driven/internal/api/middleware"
 	"github.com/example/ec-event-driven/internal/command"
+	"github.com/example/ec-event-driven/internal/domain/inventory"
+	"github.com/example/ec-event-driven/internal/domain/order"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/example/ec-event-driven/internal/payment"
 	"github.com/example/ec-event-driven/internal/query"
 )
 
+const (
+	// ConsistencyTokenHeader carries the consistency token of a command's
+	// writes, from the command's response to the queries that should reflect them
+	ConsistencyTokenHeader = "X-Consistency-Token"
+	// ConsistencyStaleHeader marks query responses that may not reflect the
+	// writes of the request's consistency token yet
+	ConsistencyStaleHeader = "X-Consistency-Stale"
+)
+
 type Handlers struct {
 	cmdHandler   *command.Handler
 	queryHandler *query.Handler
+	tokenSigner  *store.ConsistencyTokenSigner
 }
 
-func NewHandlers(cmdHandler *command.Handler, queryHandler *query.Handler) *Handlers {
+func NewHandlers(cmdHandler *command.Handler, queryHandler *query.Handler, tokenSigner *store.ConsistencyTokenSigner) *Handlers {
 	return &Handlers{
 		cmdHandler:   cmdHandler,
 		queryHandler: queryHandler,
+		tokenSigner:  tokenSigner,
 	}
 }
 
@@ -42,18 +59,26 @@ func (h *Handlers) CreateProduct(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handlers) GetProducts(w http.ResponseWriter, r *http.Request) {
-	products := h.queryHandler.ListProducts()
-	respondJSON(w, http.StatusOK, products)
+	status, ok := h.awaitConsistency(w, r)
+	if !ok {
+		return
+	}
+	products := h.queryHandler.ListProducts(r.Context())
+	respondJSON(w, status, products)
 }
 
 func (h *Handlers) GetProduct(w http.ResponseWriter, r *http.Request) {
+	status, ok := h.awaitConsistency(w, r)
+	if !ok {
+		return
+	}
 	id := extractPathParam(r.URL.Path, "/products/")
-	product, ok := h.queryHandler.GetProduct(id)
+	product, ok := h.queryHandler.GetProduct(r.Context(), id)
 	if !ok {
 		respondJSONError(w, "Product not found", http.StatusNotFound)
 		return
 	}
-	respondJSON(w, http.StatusOK, product)
+	respondJSON(w, status, product)
 }
 
 func (h *Handlers) UpdateProduct(w http.ResponseWriter, r *http.Request) {
@@ -108,12 +133,14 @@ func (h *Handlers) AddToCart(w http.ResponseWriter, r *http.Request) {
 		ProductID: req.ProductID,
 		Quantity:  req.Quantity,
 	}
-	if err := h.cmdHandler.AddToCart(r.Context(), cmd); err != nil {
+	token, err := h.cmdHandler.AddToCart(r.Context(), cmd)
+	if err != nil {
 		log.Printf("[API] AddToCart error: %v", err)
 		respondJSONError(w, "Failed to add item to cart", http.StatusInternalServerError)
 		return
 	}
 
+	h.setConsistencyToken(w, r, token)
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -128,11 +155,13 @@ func (h *Handlers) RemoveFromCart(w http.ResponseWriter, r *http.Request) {
 		UserID:    userID,
 		ProductID: productID,
 	}
-	if err := h.cmdHandler.RemoveFromCart(r.Context(), cmd); err != nil {
+	token, err := h.cmdHandler.RemoveFromCart(r.Context(), cmd)
+	if err != nil {
 		respondJSONError(w, "Failed to remove item from cart", http.StatusInternalServerError)
 		return
 	}
 
+	h.setConsistencyToken(w, r, token)
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -142,8 +171,12 @@ func (h *Handlers) GetCart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cart, _ := h.queryHandler.GetCart(userID)
-	respondJSON(w, http.StatusOK, cart)
+	status, ok := h.awaitConsistency(w, r)
+	if !ok {
+		return
+	}
+	cart, _ := h.queryHandler.GetCart(r.Context(), userID)
+	respondJSON(w, status, cart)
 }
 
 // Order Handlers
@@ -155,12 +188,13 @@ func (h *Handlers) PlaceOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	cmd := command.PlaceOrder{UserID: userID}
-	order, err := h.cmdHandler.PlaceOrder(r.Context(), cmd)
+	order, token, err := h.cmdHandler.PlaceOrder(r.Context(), cmd)
 	if err != nil {
 		respondJSONError(w, "Failed to place order", http.StatusBadRequest)
 		return
 	}
 
+	h.setConsistencyToken(w, r, token)
 	respondJSON(w, http.StatusCreated, order)
 }
 
@@ -169,8 +203,12 @@ func (h *Handlers) GetOrders(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
-	orders := h.queryHandler.ListOrdersByUser(userID)
-	respondJSON(w, http.StatusOK, orders)
+	status, ok := h.awaitConsistency(w, r)
+	if !ok {
+		return
+	}
+	orders := h.queryHandler.ListOrdersByUser(r.Context(), userID)
+	respondJSON(w, status, orders)
 }
 
 func (h *Handlers) GetOrder(w http.ResponseWriter, r *http.Request) {
@@ -178,7 +216,11 @@ func (h *Handlers) GetOrder(w http.ResponseWriter, r *http.Request) {
 	// Remove /cancel suffix if present
 	id = strings.TrimSuffix(id, "/cancel")
 
-	order, ok := h.queryHandler.GetOrder(id)
+	status, ok := h.awaitConsistency(w, r)
+	if !ok {
+		return
+	}
+	order, ok := h.queryHandler.GetOrder(r.Context(), id)
 	if !ok {
 		respondJSONError(w, "Order not found", http.StatusNotFound)
 		return
@@ -191,48 +233,140 @@ func (h *Handlers) GetOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondJSON(w, http.StatusOK, order)
+	respondJSON(w, status, order)
 }
 
 func (h *Handlers) CancelOrder(w http.ResponseWriter, r *http.Request) {
-	path := strings.TrimPrefix(r.URL.Path, "/orders/")
-	id := strings.TrimSuffix(path, "/cancel")
-
-	// Authorization check: user can only cancel their own orders (admins can cancel all)
-	order, ok := h.queryHandler.GetOrder(id)
+	userID, ok := requireUserID(w, r)
 	if !ok {
-		respondJSONError(w, "Order not found", http.StatusNotFound)
-		return
-	}
-
-	userID := getUserID(r)
-	if order.UserID != userID && !isAdmin(r) {
-		respondJSONError(w, "Forbidden", http.StatusForbidden)
 		return
 	}
+	path := strings.TrimPrefix(r.URL.Path, "/orders/")
+	id := strings.TrimSuffix(path, "/cancel")
 
 	var req struct {
 		Reason string `json:"reason"`
 	}
 	_ = json.NewDecoder(r.Body).Decode(&req)
 
+	// Authorization check: user can only cancel their own orders (admins can cancel all).
+	// The command checks the owner on the order itself, so an order placed
+	// just before can be cancelled before the read model has caught up.
 	cmd := command.CancelOrder{
 		OrderID: id,
+		UserID:  userID,
 		Reason:  req.Reason,
 	}
-	if err := h.cmdHandler.CancelOrder(r.Context(), cmd); err != nil {
+	if isAdmin(r) {
+		cmd.UserID = ""
+	}
+	err := h.cmdHandler.CancelOrder(r.Context(), cmd)
+	switch {
+	case errors.Is(err, order.ErrOrderNotFound):
+		respondJSONError(w, "Order not found", http.StatusNotFound)
+	case err != nil:
 		respondJSONError(w, "Failed to cancel order", http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (h *Handlers) PayOrder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
 		return
 	}
+	path := strings.TrimPrefix(r.URL.Path, "/orders/")
+	id := strings.TrimSuffix(path, "/pay")
 
-	w.WriteHeader(http.StatusOK)
+	var req struct {
+		PaymentMethod string `json:"payment_method"`
+	}
+	// The body is optional, as is the payment method
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Authorization check: user can only pay their own orders (admins can pay all).
+	// The command checks the owner on the order itself, so an order placed
+	// just before can be paid before the read model has caught up.
+	cmd := command.PayOrder{
+		OrderID:       id,
+		UserID:        userID,
+		PaymentMethod: req.PaymentMethod,
+	}
+	if isAdmin(r) {
+		cmd.UserID = ""
+	}
+	token, err := h.cmdHandler.PayOrder(r.Context(), cmd)
+	switch {
+	case errors.Is(err, order.ErrOrderNotFound):
+		respondJSONError(w, "Order not found", http.StatusNotFound)
+	case errors.Is(err, payment.ErrDeclined):
+		respondJSONError(w, "Payment declined", http.StatusPaymentRequired)
+	case errors.Is(err, order.ErrOrderAlreadyPaid), errors.Is(err, order.ErrOrderCancelled), errors.Is(err, order.ErrInvalidStatus):
+		respondJSONError(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		log.Printf("[API] PayOrder error: %v", err)
+		respondJSONError(w, "Failed to pay order", http.StatusInternalServerError)
+	default:
+		h.setConsistencyToken(w, r, token)
+		respondJSON(w, http.StatusOK, map[string]string{"order_id": id, "status": string(order.StatusPaid)})
+	}
 }
 
 // Admin Handlers
 
 func (h *Handlers) GetAllOrders(w http.ResponseWriter, r *http.Request) {
-	orders := h.queryHandler.ListAllOrders()
-	respondJSON(w, http.StatusOK, orders)
+	status, ok := h.awaitConsistency(w, r)
+	if !ok {
+		return
+	}
+	orders := h.queryHandler.ListAllOrders(r.Context())
+	respondJSON(w, status, orders)
+}
+
+// ShipOrder handles POST /api/admin/orders/{id}/ship
+func (h *Handlers) ShipOrder(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/admin/orders/")
+	id := strings.TrimSuffix(path, "/ship")
+
+	var req struct {
+		Carrier        string `json:"carrier"`
+		TrackingNumber string `json:"tracking_number"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	cmd := command.ShipOrder{
+		OrderID:        id,
+		Carrier:        req.Carrier,
+		TrackingNumber: req.TrackingNumber,
+	}
+	shipped, token, err := h.cmdHandler.ShipOrder(r.Context(), cmd)
+	switch {
+	case errors.Is(err, order.ErrOrderNotFound):
+		respondJSONError(w, "Order not found", http.StatusNotFound)
+	case errors.Is(err, order.ErrMissingTracking):
+		respondJSONError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, order.ErrOrderNotPaid), errors.Is(err, order.ErrOrderCancelled), errors.Is(err, order.ErrInvalidStatus),
+		errors.Is(err, inventory.ErrReservationNotFound), errors.Is(err, inventory.ErrReservationExceeded):
+		respondJSONError(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		log.Printf("[API] ShipOrder error: %v", err)
+		respondJSONError(w, "Failed to ship order", http.StatusInternalServerError)
+	default:
+		h.setConsistencyToken(w, r, token)
+		respondJSON(w, http.StatusOK, map[string]string{
+			"order_id":        id,
+			"status":          string(order.StatusShipped),
+			"carrier":         shipped.Shipment.Carrier,
+			"tracking_number": shipped.Shipment.TrackingNumber,
+		})
+	}
 }
 
 // Helper functions
@@ -243,6 +377,36 @@ func respondJSON(w http.ResponseWriter, status int, data any) {
 	_ = json.NewEncoder(w).Encode(data)
 }
 
+// setConsistencyToken returns the consistency token of a command's writes to
+// the client, signed for the requesting user
+func (h *Handlers) setConsistencyToken(w http.ResponseWriter, r *http.Request, token store.ConsistencyToken) {
+	if len(token) > 0 {
+		w.Header().Set(ConsistencyTokenHeader, h.tokenSigner.Sign(token, getUserID(r)))
+	}
+}
+
+// awaitConsistency waits until the read models reflect the request's
+// consistency token, if any, and returns the status to answer the query with:
+// 200, or 202 with the stale marker if they did not catch up in time. It
+// reports false after rejecting an invalid token, including tokens that were
+// issued to another user.
+func (h *Handlers) awaitConsistency(w http.ResponseWriter, r *http.Request) (int, bool) {
+	header := r.Header.Get(ConsistencyTokenHeader)
+	if header == "" {
+		return http.StatusOK, true
+	}
+	token, err := h.tokenSigner.Verify(header, getUserID(r))
+	if err != nil {
+		respondJSONError(w, "Invalid consistency token", http.StatusBadRequest)
+		return 0, false
+	}
+	if h.queryHandler.WaitFor(r.Context(), token) {
+		return http.StatusOK, true
+	}
+	w.Header().Set(ConsistencyStaleHeader, "true")
+	return http.StatusAccepted, true
+}
+
 func extractPathParam(path, prefix string) string {
 	return strings.TrimPrefix(path, prefix)
 }
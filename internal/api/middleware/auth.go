@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/example/ec-event-driven/internal/auth"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
 )
 
 // respondError writes a JSON error response
@@ -52,6 +53,7 @@ func AuthMiddleware(jwtService *auth.JWTService) func(http.Handler) http.Handler
 			}
 
 			ctx := context.WithValue(r.Context(), UserContextKey, claims)
+			ctx = store.WithActor(ctx, claims.UserID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -64,6 +66,7 @@ func OptionalAuthMiddleware(jwtService *auth.JWTService) func(http.Handler) http
 			if tokenString := ExtractToken(r); tokenString != "" {
 				if claims, err := jwtService.ValidateAccessToken(tokenString); err == nil {
 					ctx := context.WithValue(r.Context(), UserContextKey, claims)
+					ctx = store.WithActor(ctx, claims.UserID)
 					r = r.WithContext(ctx)
 				}
 			}
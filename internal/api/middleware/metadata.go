@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/google/uuid"
+)
+
+const (
+	// CorrelationIDHeader carries the correlation ID shared by all events of a request
+	CorrelationIDHeader = "X-Correlation-ID"
+	// RequestIDHeader identifies a single request; used as the causation ID of its events
+	RequestIDHeader = "X-Request-ID"
+)
+
+// EventMetadataMiddleware adds event metadata (correlation ID, causation ID and
+// source IP) to the request context so that events appended while handling the
+// request record where they came from. The actor is added by the auth middlewares.
+func EventMetadataMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" {
+			requestID = uuid.New().String()
+		}
+		correlationID := r.Header.Get(CorrelationIDHeader)
+		if correlationID == "" {
+			correlationID = requestID
+		}
+
+		w.Header().Set(CorrelationIDHeader, correlationID)
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ctx := store.WithMetadata(r.Context(), store.EventMetadata{
+			CorrelationID: correlationID,
+			CausationID:   requestID,
+			SourceIP:      remoteIP(r),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// remoteIP returns the IP part of the request's remote address
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
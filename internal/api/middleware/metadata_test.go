@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventMetadataMiddleware_UsesRequestHeaders(t *testing.T) {
+	var captured store.EventMetadata
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured = store.MetadataFromContext(r.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/orders", nil)
+	req.RemoteAddr = "203.0.113.7:54321"
+	req.Header.Set(CorrelationIDHeader, "corr-123")
+	req.Header.Set(RequestIDHeader, "req-456")
+	rec := httptest.NewRecorder()
+
+	EventMetadataMiddleware(handler).ServeHTTP(rec, req)
+
+	assert.Equal(t, "corr-123", captured.CorrelationID)
+	assert.Equal(t, "req-456", captured.CausationID)
+	assert.Equal(t, "203.0.113.7", captured.SourceIP)
+	assert.Equal(t, "corr-123", rec.Header().Get(CorrelationIDHeader))
+	assert.Equal(t, "req-456", rec.Header().Get(RequestIDHeader))
+}
+
+func TestEventMetadataMiddleware_GeneratesIDs(t *testing.T) {
+	var captured store.EventMetadata
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured = store.MetadataFromContext(r.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/orders", nil)
+	rec := httptest.NewRecorder()
+
+	EventMetadataMiddleware(handler).ServeHTTP(rec, req)
+
+	require.NotEmpty(t, captured.CausationID)
+	// Without an incoming correlation ID the request starts a new correlation
+	assert.Equal(t, captured.CausationID, captured.CorrelationID)
+	assert.Equal(t, captured.CorrelationID, rec.Header().Get(CorrelationIDHeader))
+}
+
+func TestEventMetadataMiddleware_AuthAddsActor(t *testing.T) {
+	jwtService := newTestJWTService()
+	token, _, err := jwtService.GenerateAccessToken("user-123", "test@example.com", "customer")
+	require.NoError(t, err)
+
+	var captured store.EventMetadata
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured = store.MetadataFromContext(r.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(CorrelationIDHeader, "corr-123")
+	rec := httptest.NewRecorder()
+
+	EventMetadataMiddleware(AuthMiddleware(jwtService)(handler)).ServeHTTP(rec, req)
+
+	assert.Equal(t, "user-123", captured.ActorUserID)
+	assert.Equal(t, "corr-123", captured.CorrelationID)
+}
@@ -36,6 +36,7 @@ type RouterConfig struct {
 	Handlers         *Handlers
 	AuthHandlers     *AuthHandlers
 	CategoryHandlers *CategoryHandlers
+	AdminHandlers    *AdminHandlers
 	JWTService       *auth.JWTService
 }
 
@@ -190,6 +191,8 @@ func NewRouter(config RouterConfig) http.Handler {
 			switch {
 			case strings.HasSuffix(path, "/cancel") && r.Method == http.MethodPost:
 				config.Handlers.CancelOrder(w, r)
+			case strings.HasSuffix(path, "/pay") && r.Method == http.MethodPost:
+				config.Handlers.PayOrder(w, r)
 			case r.Method == http.MethodGet:
 				config.Handlers.GetOrder(w, r)
 			default:
@@ -266,7 +269,52 @@ func NewRouter(config RouterConfig) http.Handler {
 		),
 	))
 
-	return withCORS(withBodyLimit(withLogging(mux)))
+	mux.Handle("/api/admin/orders/", middleware.AuthMiddleware(config.JWTService)(
+		middleware.RequireRole("admin")(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/ship") && r.Method == http.MethodPost:
+					config.Handlers.ShipOrder(w, r)
+				case strings.HasSuffix(r.URL.Path, "/ship"):
+					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				default:
+					http.NotFound(w, r)
+				}
+			}),
+		),
+	))
+
+	mux.Handle("/api/admin/aggregates/", middleware.AuthMiddleware(config.JWTService)(
+		middleware.RequireRole("admin")(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					config.AdminHandlers.GetAggregateAt(w, r)
+				} else {
+					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				}
+			}),
+		),
+	))
+
+	mux.Handle("/api/admin/dead-letters", middleware.AuthMiddleware(config.JWTService)(
+		middleware.RequireRole("admin")(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					config.AdminHandlers.ListDeadLetters(w, r)
+				} else {
+					http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				}
+			}),
+		),
+	))
+
+	mux.Handle("/api/admin/dead-letters/", middleware.AuthMiddleware(config.JWTService)(
+		middleware.RequireRole("admin")(
+			http.HandlerFunc(config.AdminHandlers.DeadLetter),
+		),
+	))
+
+	return withCORS(withBodyLimit(withLogging(middleware.EventMetadataMiddleware(mux))))
 }
 
 func withLogging(next http.Handler) http.Handler {
@@ -297,7 +345,8 @@ func withCORS(next http.Handler) http.Handler {
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
 			w.Header().Set("Access-Control-Allow-Origin", origin)
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-User-ID")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-User-ID, X-Correlation-ID, X-Request-ID, X-Consistency-Token")
+			w.Header().Set("Access-Control-Expose-Headers", "X-Correlation-ID, X-Request-ID, X-Consistency-Token, X-Consistency-Stale")
 		}
 
 		if r.Method == http.MethodOptions {
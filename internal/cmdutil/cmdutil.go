@@ -0,0 +1,27 @@
+// Package cmdutil holds the flag and environment helpers shared by the commands
+// under cmd/.
+package cmdutil
+
+import (
+	"os"
+	"strings"
+)
+
+// Getenv returns the environment variable, or defaultValue when it is not set
+func Getenv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// ParseList splits a comma-separated flag value, dropping empty entries
+func ParseList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
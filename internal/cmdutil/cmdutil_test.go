@@ -0,0 +1,18 @@
+package cmdutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseList(t *testing.T) {
+	assert.Equal(t, []string{"products", "orders"}, ParseList(" products, ,orders,"))
+	assert.Empty(t, ParseList(""))
+}
+
+func TestGetenv(t *testing.T) {
+	t.Setenv("CMDUTIL_TEST", "set")
+	assert.Equal(t, "set", Getenv("CMDUTIL_TEST", "default"))
+	assert.Equal(t, "default", Getenv("CMDUTIL_TEST_UNSET", "default"))
+}
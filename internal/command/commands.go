@@ -42,5 +42,18 @@ type PlaceOrder struct {
 
 type CancelOrder struct {
 	OrderID string `json:"order_id"`
+	UserID  string `json:"user_id"` // If set, the order must belong to this user
 	Reason  string `json:"reason"`
 }
+
+type ShipOrder struct {
+	OrderID        string `json:"order_id"`
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"tracking_number"`
+}
+
+type PayOrder struct {
+	OrderID       string `json:"order_id"`
+	UserID        string `json:"user_id"` // If set, the order must belong to this user
+	PaymentMethod string `json:"payment_method"`
+}
@@ -2,38 +2,50 @@ package command
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"slices"
 
+	"github.com/example/ec-event-driven/internal/domain/aggregate"
 	"github.com/example/ec-event-driven/internal/domain/cart"
 	"github.com/example/ec-event-driven/internal/domain/inventory"
 	"github.com/example/ec-event-driven/internal/domain/order"
 	"github.com/example/ec-event-driven/internal/domain/product"
 	"github.com/example/ec-event-driven/internal/infrastructure/store"
-	"github.com/example/ec-event-driven/internal/readmodel"
+	"github.com/example/ec-event-driven/internal/payment"
+	"github.com/google/uuid"
 )
 
+// Handler handles commands. Commands are decided from aggregates loaded from
+// the event store, never from read models, which may lag behind the events.
 type Handler struct {
+	eventStore   store.EventStoreInterface
 	productSvc   *product.Service
 	cartSvc      *cart.Service
 	orderSvc     *order.Service
 	inventorySvc *inventory.Service
-	readStore    store.ReadStoreInterface
+	payments     payment.Gateway
+	retryPolicy  RetryPolicy
 }
 
 func NewHandler(
+	eventStore store.EventStoreInterface,
 	productSvc *product.Service,
 	cartSvc *cart.Service,
 	orderSvc *order.Service,
 	inventorySvc *inventory.Service,
-	readStore store.ReadStoreInterface,
+	payments payment.Gateway,
 ) *Handler {
 	return &Handler{
+		eventStore:   eventStore,
 		productSvc:   productSvc,
 		cartSvc:      cartSvc,
 		orderSvc:     orderSvc,
 		inventorySvc: inventorySvc,
-		readStore:    readStore,
+		payments:     payments,
+		retryPolicy:  DefaultRetryPolicy,
 	}
 }
 
@@ -46,7 +58,9 @@ func (h *Handler) CreateProduct(ctx context.Context, cmd CreateProduct) (*produc
 	}
 
 	// 2. Initialize inventory (emits StockAdded event)
-	if err := h.inventorySvc.AddStock(ctx, p.ID, cmd.Stock); err != nil {
+	if err := h.retryPolicy.Do(ctx, func() error {
+		return h.inventorySvc.AddStock(ctx, p.ID, cmd.Stock)
+	}); err != nil {
 		return nil, err
 	}
 
@@ -56,138 +70,327 @@ func (h *Handler) CreateProduct(ctx context.Context, cmd CreateProduct) (*produc
 
 // UpdateProduct updates a product
 func (h *Handler) UpdateProduct(ctx context.Context, cmd UpdateProduct) error {
-	return h.productSvc.Update(ctx, cmd.ProductID, cmd.Name, cmd.Description, cmd.Price)
+	return h.retryPolicy.Do(ctx, func() error {
+		return h.productSvc.Update(ctx, cmd.ProductID, cmd.Name, cmd.Description, cmd.Price)
+	})
 }
 
 // DeleteProduct deletes a product
 func (h *Handler) DeleteProduct(ctx context.Context, cmd DeleteProduct) error {
-	return h.productSvc.Delete(ctx, cmd.ProductID)
+	return h.retryPolicy.Do(ctx, func() error {
+		return h.productSvc.Delete(ctx, cmd.ProductID)
+	})
 }
 
-// AddToCart adds an item to cart
-func (h *Handler) AddToCart(ctx context.Context, cmd AddToCart) error {
-	// Get product price from read store
-	p, ok, err := h.readStore.Get("products", cmd.ProductID)
+// AddToCart adds an item to cart and returns the consistency token of the change
+func (h *Handler) AddToCart(ctx context.Context, cmd AddToCart) (store.ConsistencyToken, error) {
+	// Get the current product price
+	prod, err := h.productSvc.Get(ctx, cmd.ProductID)
 	if err != nil {
-		log.Printf("[Command] Error getting product %s: %v", cmd.ProductID, err)
-		return product.ErrProductNotFound
-	}
-	if !ok {
-		return product.ErrProductNotFound
+		return nil, err
 	}
-	prod := p.(*readmodel.ProductReadModel)
 
 	// Emit ItemAddedToCart event
-	return h.cartSvc.AddItem(ctx, cmd.UserID, cmd.ProductID, cmd.Quantity, prod.Price)
+	var stored *store.Event
+	err = h.retryPolicy.Do(ctx, func() error {
+		var err error
+		stored, err = h.cartSvc.AddItem(ctx, cmd.UserID, cmd.ProductID, cmd.Quantity, prod.Price)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return store.TokenFor(*stored), nil
 }
 
-// RemoveFromCart removes an item from cart
-func (h *Handler) RemoveFromCart(ctx context.Context, cmd RemoveFromCart) error {
-	return h.cartSvc.RemoveItem(ctx, cmd.UserID, cmd.ProductID)
+// RemoveFromCart removes an item from cart and returns the consistency token of the change
+func (h *Handler) RemoveFromCart(ctx context.Context, cmd RemoveFromCart) (store.ConsistencyToken, error) {
+	var stored *store.Event
+	err := h.retryPolicy.Do(ctx, func() error {
+		var err error
+		stored, err = h.cartSvc.RemoveItem(ctx, cmd.UserID, cmd.ProductID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return store.TokenFor(*stored), nil
 }
 
 // ClearCart clears all items from cart
 func (h *Handler) ClearCart(ctx context.Context, cmd ClearCart) error {
-	return h.cartSvc.Clear(ctx, cmd.UserID)
+	return h.retryPolicy.Do(ctx, func() error {
+		return h.cartSvc.Clear(ctx, cmd.UserID)
+	})
 }
 
-// PlaceOrder creates an order from cart with stock validation and compensating transactions
-func (h *Handler) PlaceOrder(ctx context.Context, cmd PlaceOrder) (*order.Order, error) {
-	// Get cart from read store
-	cartID := cart.GetCartID(cmd.UserID)
-	c, ok, err := h.readStore.Get("carts", cartID)
-	if err != nil {
-		log.Printf("[Command] Error getting cart %s: %v", cartID, err)
-		return nil, order.ErrEmptyOrder
-	}
-	if !ok || len(c.(*readmodel.CartReadModel).Items) == 0 {
-		return nil, order.ErrEmptyOrder
-	}
-	cartModel := c.(*readmodel.CartReadModel)
-
-	// Convert cart items to order items
-	var items []order.OrderItem
-	for _, item := range cartModel.Items {
-		items = append(items, order.OrderItem{
-			ProductID: item.ProductID,
-			Name:      item.Name,
-			Quantity:  item.Quantity,
-			Price:     item.Price,
-		})
-	}
-
-	// Validate stock availability for all items before placing order
-	for _, item := range items {
-		inv, ok, err := h.readStore.Get("inventory", item.ProductID)
+// PlaceOrder creates an order from cart with stock validation, reserving stock
+// atomically. The consistency token covers the order, the reservations and the cleared cart.
+func (h *Handler) PlaceOrder(ctx context.Context, cmd PlaceOrder) (*order.Order, store.ConsistencyToken, error) {
+	// Place order, reserve inventory for each item and clear the cart in one
+	// atomic batch (OrderPlaced, StockReserved..., CartCleared) so that a
+	// failure can never leave reservations without an order or vice versa.
+	// The cart and inventories are loaded on every attempt and the batch is
+	// appended with their versions, so a concurrent change is retried with
+	// the new state instead of being overwritten.
+	var placed *order.Order
+	var token store.ConsistencyToken
+	err := h.retryPolicy.Do(ctx, func() error {
+		c, err := h.cartSvc.Get(ctx, cmd.UserID)
 		if err != nil {
-			log.Printf("[Command] Error getting inventory for product %s: %v", item.ProductID, err)
-			return nil, fmt.Errorf("inventory not found for product %s", item.ProductID)
+			return err
 		}
-		if !ok {
-			return nil, fmt.Errorf("inventory not found for product %s", item.ProductID)
+		if len(c.Items) == 0 {
+			return order.ErrEmptyOrder
 		}
-		invModel := inv.(*readmodel.InventoryReadModel)
-		if invModel.AvailableStock < item.Quantity {
-			return nil, fmt.Errorf("%w: product %s has only %d available, requested %d",
-				inventory.ErrInsufficientStock, item.ProductID, invModel.AvailableStock, item.Quantity)
+
+		// Convert cart items to order items in a stable order
+		var items []order.OrderItem
+		for _, productID := range slices.Sorted(maps.Keys(c.Items)) {
+			item := c.Items[productID]
+			p, err := h.productSvc.Get(ctx, productID)
+			if err != nil {
+				return fmt.Errorf("product %s: %w", productID, err)
+			}
+			items = append(items, order.OrderItem{
+				ProductID: productID,
+				Name:      p.Name,
+				Quantity:  item.Quantity,
+				Price:     item.Price,
+			})
 		}
-	}
 
-	// Place order (emits OrderPlaced event)
-	o, err := h.orderSvc.Place(ctx, cmd.UserID, items)
-	if err != nil {
-		return nil, err
-	}
+		o, placeEvent, err := h.orderSvc.PreparePlace(cmd.UserID, items)
+		if err != nil {
+			return err
+		}
+		events := []store.PendingEvent{placeEvent}
+		touched := []aggregate.Aggregate{o}
+		aggregateTypes := []string{order.AggregateType}
 
-	// Reserve inventory for each item (emits StockReserved events)
-	// Track successfully reserved items for potential rollback
-	var reservedItems []order.OrderItem
-	for _, item := range items {
-		if err := h.inventorySvc.Reserve(ctx, item.ProductID, o.ID, item.Quantity); err != nil {
-			// Compensating transaction: release already reserved inventory
-			for _, reserved := range reservedItems {
-				if releaseErr := h.inventorySvc.Release(ctx, reserved.ProductID, o.ID, reserved.Quantity); releaseErr != nil {
-					log.Printf("[PlaceOrder] Failed to release inventory for product %s: %v", reserved.ProductID, releaseErr)
-				}
+		for _, item := range items {
+			inv, reserveEvent, err := h.inventorySvc.PrepareReserve(ctx, item.ProductID, o.ID, item.Quantity)
+			if err != nil {
+				return fmt.Errorf("failed to reserve inventory for product %s: %w", item.ProductID, err)
 			}
-			// Cancel the order
-			if cancelErr := h.orderSvc.Cancel(ctx, o.ID, "inventory reservation failed"); cancelErr != nil {
-				log.Printf("[PlaceOrder] Failed to cancel order %s: %v", o.ID, cancelErr)
+			events = append(events, reserveEvent)
+			touched = append(touched, inv)
+			aggregateTypes = append(aggregateTypes, inventory.AggregateType)
+		}
+
+		events = append(events, c.Clear())
+		touched = append(touched, c)
+		aggregateTypes = append(aggregateTypes, cart.AggregateType)
+
+		stored, err := h.eventStore.AppendBatch(ctx, events)
+		if err != nil {
+			return err
+		}
+
+		for i, agg := range touched {
+			if err := aggregate.MaybeCreateSnapshot(ctx, h.eventStore, agg, aggregateTypes[i]); err != nil {
+				log.Printf("[PlaceOrder] Failed to create snapshot for %s %s: %v", aggregateTypes[i], agg.GetID(), err)
 			}
-			return nil, fmt.Errorf("failed to reserve inventory for product %s: %w", item.ProductID, err)
 		}
-		reservedItems = append(reservedItems, item)
-	}
 
-	// Clear cart (emits CartCleared event)
-	// This is not critical - if it fails, user can manually clear
-	if err := h.cartSvc.Clear(ctx, cmd.UserID); err != nil {
-		log.Printf("[PlaceOrder] Failed to clear cart for user %s: %v", cmd.UserID, err)
+		placed = o
+		token = store.TokenFor(stored...)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return o, nil
+	return placed, token, nil
 }
 
-// CancelOrder cancels an order
+// CancelOrder cancels an order, releases its reserved inventory and refunds
+// its captured payment
 func (h *Handler) CancelOrder(ctx context.Context, cmd CancelOrder) error {
-	// Get order from read store to release inventory
-	o, ok, err := h.readStore.Get("orders", cmd.OrderID)
+	o, err := h.orderSvc.Get(ctx, cmd.OrderID)
 	if err != nil {
-		log.Printf("[Command] Error getting order %s: %v", cmd.OrderID, err)
-		return order.ErrOrderNotFound
+		return err
 	}
-	if !ok {
+	if cmd.UserID != "" && o.UserID != cmd.UserID {
 		return order.ErrOrderNotFound
 	}
-	orderModel := o.(*readmodel.OrderReadModel)
+	// Check before releasing, so that stock is never released for an order that stays
+	if !o.CanTransitionTo(order.StatusCancelled) {
+		return o.TransitionError(order.StatusCancelled)
+	}
 
-	// Release inventory (emits StockReleased events)
-	for _, item := range orderModel.Items {
-		if err := h.inventorySvc.Release(ctx, item.ProductID, cmd.OrderID, item.Quantity); err != nil {
+	// Release inventory (emits StockReleased events). Reservations released by
+	// an earlier attempt that failed to cancel the order are skipped.
+	for _, item := range o.Items {
+		err := h.retryPolicy.Do(ctx, func() error {
+			return h.inventorySvc.Release(ctx, item.ProductID, cmd.OrderID, item.Quantity)
+		})
+		if err != nil && !errors.Is(err, inventory.ErrReservationNotFound) {
+			return err
+		}
+	}
+
+	// Refund the rest of the captured payment (emits PaymentRefunded event)
+	if p := o.Payment; p != nil && p.Amount > p.Refunded {
+		if p.Provider != h.payments.Name() {
+			return fmt.Errorf("cannot refund payment %s of order %s: it was made with %s, not %s",
+				p.CaptureID, o.ID, p.Provider, h.payments.Name())
+		}
+		if err := h.refund(ctx, o.ID, p.CaptureID, p.Amount-p.Refunded); err != nil {
 			return err
 		}
 	}
 
 	// Cancel order (emits OrderCancelled event)
-	return h.orderSvc.Cancel(ctx, cmd.OrderID, cmd.Reason)
+	return h.retryPolicy.Do(ctx, func() error {
+		return h.orderSvc.Cancel(ctx, cmd.OrderID, cmd.Reason)
+	})
+}
+
+// ShipOrder ships a paid order with its tracking details and deducts the
+// stock reserved for it. It returns the shipped order and a consistency token
+// that covers the order and the inventories.
+func (h *Handler) ShipOrder(ctx context.Context, cmd ShipOrder) (*order.Order, store.ConsistencyToken, error) {
+	// Ship the order and deduct its reservations in one atomic batch
+	// (OrderShipped, StockDeducted...), so that an order never ships without
+	// its stock being deducted or vice versa. The order and inventories are
+	// loaded on every attempt and appended with their versions.
+	var shipped *order.Order
+	var token store.ConsistencyToken
+	err := h.retryPolicy.Do(ctx, func() error {
+		o, shipEvent, err := h.orderSvc.PrepareShip(ctx, cmd.OrderID, cmd.Carrier, cmd.TrackingNumber)
+		if err != nil {
+			return err
+		}
+		events := []store.PendingEvent{shipEvent}
+		touched := []aggregate.Aggregate{o}
+		aggregateTypes := []string{order.AggregateType}
+
+		for _, item := range o.Items {
+			inv, deductEvent, err := h.inventorySvc.PrepareDeduct(ctx, item.ProductID, o.ID, item.Quantity)
+			if err != nil {
+				return fmt.Errorf("failed to deduct inventory for product %s: %w", item.ProductID, err)
+			}
+			events = append(events, deductEvent)
+			touched = append(touched, inv)
+			aggregateTypes = append(aggregateTypes, inventory.AggregateType)
+		}
+
+		stored, err := h.eventStore.AppendBatch(ctx, events)
+		if err != nil {
+			return err
+		}
+
+		for i, agg := range touched {
+			if err := aggregate.MaybeCreateSnapshot(ctx, h.eventStore, agg, aggregateTypes[i]); err != nil {
+				log.Printf("[ShipOrder] Failed to create snapshot for %s %s: %v", aggregateTypes[i], agg.GetID(), err)
+			}
+		}
+
+		shipped, token = o, store.TokenFor(stored...)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return shipped, token, nil
+}
+
+// PayOrder charges a pending order for its total through the payment gateway
+// and returns the consistency token of the order. The attempt is recorded on
+// the order before the gateway is called, followed by either the failure or
+// OrderPaid with the captured payment.
+func (h *Handler) PayOrder(ctx context.Context, cmd PayOrder) (store.ConsistencyToken, error) {
+	o, err := h.orderSvc.Get(ctx, cmd.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.UserID != "" && o.UserID != cmd.UserID {
+		return nil, order.ErrOrderNotFound
+	}
+
+	attemptID := uuid.New().String()
+	provider := h.payments.Name()
+	err = h.retryPolicy.Do(ctx, func() error {
+		var err error
+		o, err = h.orderSvc.StartPayment(ctx, cmd.OrderID, attemptID, provider)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := h.payments.Authorize(ctx, payment.AuthorizeRequest{
+		OrderID:        o.ID,
+		Amount:         o.Total,
+		Method:         cmd.PaymentMethod,
+		IdempotencyKey: attemptID,
+	})
+	if err != nil {
+		return nil, h.failPayment(ctx, o.ID, attemptID, "authorize", err)
+	}
+	capture, err := h.payments.Capture(ctx, auth.ID, auth.Amount)
+	if err != nil {
+		// Release the hold on the customer's funds rather than let it expire
+		if voidErr := h.payments.Void(ctx, auth.ID); voidErr != nil {
+			log.Printf("[Command] Failed to void authorization %s of order %s: %v", auth.ID, o.ID, voidErr)
+		}
+		return nil, h.failPayment(ctx, o.ID, attemptID, "capture", err)
+	}
+
+	paid := order.OrderPaid{
+		AttemptID:       attemptID,
+		Provider:        provider,
+		AuthorizationID: auth.ID,
+		CaptureID:       capture.ID,
+		Amount:          capture.Amount,
+	}
+	err = h.retryPolicy.Do(ctx, func() error {
+		var err error
+		o, err = h.orderSvc.CompletePayment(ctx, cmd.OrderID, paid)
+		return err
+	})
+	if err != nil {
+		// The order was charged but cannot be paid (e.g. it was cancelled or
+		// paid by another attempt meanwhile), so the money is given back
+		if refundErr := h.refund(ctx, cmd.OrderID, capture.ID, capture.Amount); refundErr != nil {
+			log.Printf("[Command] Failed to refund payment %s of order %s: %v", capture.ID, cmd.OrderID, refundErr)
+		}
+		return nil, err
+	}
+
+	return store.ConsistencyToken{o.ID: o.Version}, nil
+}
+
+// failPayment records a failed payment attempt and returns the gateway error
+func (h *Handler) failPayment(ctx context.Context, orderID, attemptID, stage string, cause error) error {
+	err := h.retryPolicy.Do(ctx, func() error {
+		return h.orderSvc.FailPayment(ctx, orderID, order.PaymentFailed{
+			AttemptID: attemptID,
+			Provider:  h.payments.Name(),
+			Stage:     stage,
+			Reason:    cause.Error(),
+		})
+	})
+	if err != nil {
+		log.Printf("[Command] Failed to record payment failure of order %s: %v", orderID, err)
+	}
+	return fmt.Errorf("payment %s failed: %w", stage, cause)
+}
+
+// refund refunds an amount of a captured payment and records the refund on the order
+func (h *Handler) refund(ctx context.Context, orderID, captureID string, amount int) error {
+	r, err := h.payments.Refund(ctx, captureID, amount)
+	if err != nil {
+		return fmt.Errorf("failed to refund payment %s: %w", captureID, err)
+	}
+	return h.retryPolicy.Do(ctx, func() error {
+		return h.orderSvc.RecordRefund(ctx, orderID, order.PaymentRefunded{
+			Provider:  h.payments.Name(),
+			CaptureID: r.CaptureID,
+			RefundID:  r.ID,
+			Amount:    r.Amount,
+		})
+	})
 }
@@ -2,6 +2,7 @@ package command
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"testing"
 
@@ -11,22 +12,50 @@ import (
 	"github.com/example/ec-event-driven/internal/domain/product"
 	"github.com/example/ec-event-driven/internal/infrastructure/store"
 	"github.com/example/ec-event-driven/internal/infrastructure/store/mocks"
-	"github.com/example/ec-event-driven/internal/query"
+	"github.com/example/ec-event-driven/internal/payment"
+	"github.com/example/ec-event-driven/internal/projection"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func newTestHandler() (*Handler, *mocks.MockEventStore, *mocks.MockReadStore) {
+func newTestHandler() (*Handler, *mocks.MockEventStore) {
 	eventStore := mocks.NewMockEventStore()
-	readStore := mocks.NewMockReadStore()
 
 	productSvc := product.NewService(eventStore)
 	cartSvc := cart.NewService(eventStore)
 	orderSvc := order.NewService(eventStore)
 	inventorySvc := inventory.NewService(eventStore)
 
-	handler := NewHandler(productSvc, cartSvc, orderSvc, inventorySvc, readStore)
-	return handler, eventStore, readStore
+	handler := NewHandler(eventStore, productSvc, cartSvc, orderSvc, inventorySvc, payment.NewFakeGateway())
+	return handler, eventStore
+}
+
+// addProduct stores a product with its initial stock
+func addProduct(eventStore *mocks.MockEventStore, productID, name string, price, stock int) {
+	_ = eventStore.AddEvent(productID, product.AggregateType, product.EventProductCreated, product.ProductCreated{
+		ProductID: productID, Name: name, Price: price, Stock: stock,
+	})
+	_ = eventStore.AddEvent(productID, inventory.AggregateType, inventory.EventStockAdded, inventory.StockAdded{
+		ProductID: productID, Quantity: stock,
+	})
+}
+
+// reserve stores stock of a product reserved for an order
+func reserve(eventStore *mocks.MockEventStore, productID, orderID string, quantity int) {
+	_ = eventStore.AddEvent(productID, inventory.AggregateType, inventory.EventStockAdded, inventory.StockAdded{
+		ProductID: productID, Quantity: quantity,
+	})
+	_ = eventStore.AddEvent(productID, inventory.AggregateType, inventory.EventStockReserved, inventory.StockReserved{
+		ProductID: productID, OrderID: orderID, Quantity: quantity,
+	})
+}
+
+// addCartItem stores an item added to the user's cart
+func addCartItem(eventStore *mocks.MockEventStore, userID, productID string, quantity, price int) {
+	cartID := cart.GetCartID(userID)
+	_ = eventStore.AddEvent(cartID, cart.AggregateType, cart.EventItemAdded, cart.ItemAddedToCart{
+		CartID: cartID, UserID: userID, ProductID: productID, Quantity: quantity, Price: price,
+	})
 }
 
 // ============================================
@@ -34,7 +63,7 @@ func newTestHandler() (*Handler, *mocks.MockEventStore, *mocks.MockReadStore) {
 // ============================================
 
 func TestHandler_CreateProduct_Success(t *testing.T) {
-	handler, eventStore, _ := newTestHandler()
+	handler, eventStore := newTestHandler()
 	ctx := context.Background()
 
 	cmd := CreateProduct{
@@ -59,7 +88,7 @@ func TestHandler_CreateProduct_Success(t *testing.T) {
 }
 
 func TestHandler_CreateProduct_InvalidName(t *testing.T) {
-	handler, _, _ := newTestHandler()
+	handler, _ := newTestHandler()
 	ctx := context.Background()
 
 	cmd := CreateProduct{
@@ -76,7 +105,7 @@ func TestHandler_CreateProduct_InvalidName(t *testing.T) {
 }
 
 func TestHandler_CreateProduct_InvalidPrice(t *testing.T) {
-	handler, _, _ := newTestHandler()
+	handler, _ := newTestHandler()
 	ctx := context.Background()
 
 	cmd := CreateProduct{
@@ -97,7 +126,7 @@ func TestHandler_CreateProduct_InvalidPrice(t *testing.T) {
 // ============================================
 
 func TestHandler_UpdateProduct_Success(t *testing.T) {
-	handler, eventStore, _ := newTestHandler()
+	handler, eventStore := newTestHandler()
 	ctx := context.Background()
 
 	productID := "prod-123"
@@ -118,7 +147,7 @@ func TestHandler_UpdateProduct_Success(t *testing.T) {
 }
 
 func TestHandler_UpdateProduct_NotFound(t *testing.T) {
-	handler, _, _ := newTestHandler()
+	handler, _ := newTestHandler()
 	ctx := context.Background()
 
 	cmd := UpdateProduct{
@@ -138,7 +167,7 @@ func TestHandler_UpdateProduct_NotFound(t *testing.T) {
 // ============================================
 
 func TestHandler_DeleteProduct_Success(t *testing.T) {
-	handler, eventStore, _ := newTestHandler()
+	handler, eventStore := newTestHandler()
 	ctx := context.Background()
 
 	productID := "prod-123"
@@ -157,15 +186,10 @@ func TestHandler_DeleteProduct_Success(t *testing.T) {
 // ============================================
 
 func TestHandler_AddToCart_Success(t *testing.T) {
-	handler, eventStore, readStore := newTestHandler()
+	handler, eventStore := newTestHandler()
 	ctx := context.Background()
 
-	// Set up product in read store
-	readStore.SetData("products", "prod-123", &query.ProductReadModel{
-		ID:    "prod-123",
-		Name:  "Test Product",
-		Price: 1000,
-	})
+	addProduct(eventStore, "prod-123", "Test Product", 1000, 10)
 
 	cmd := AddToCart{
 		UserID:    "user-123",
@@ -173,15 +197,17 @@ func TestHandler_AddToCart_Success(t *testing.T) {
 		Quantity:  2,
 	}
 
-	err := handler.AddToCart(ctx, cmd)
+	token, err := handler.AddToCart(ctx, cmd)
 
 	require.NoError(t, err)
 	assert.Len(t, eventStore.AppendCalls, 1)
 	assert.Equal(t, cart.EventItemAdded, eventStore.AppendCalls[0].EventType)
+	assert.Equal(t, 1000, eventStore.AppendCalls[0].Data.(cart.ItemAddedToCart).Price)
+	assert.Equal(t, store.ConsistencyToken{cart.GetCartID("user-123"): 1}, token)
 }
 
 func TestHandler_AddToCart_ProductNotFound(t *testing.T) {
-	handler, _, _ := newTestHandler()
+	handler, _ := newTestHandler()
 	ctx := context.Background()
 
 	cmd := AddToCart{
@@ -190,9 +216,22 @@ func TestHandler_AddToCart_ProductNotFound(t *testing.T) {
 		Quantity:  2,
 	}
 
-	err := handler.AddToCart(ctx, cmd)
+	_, err := handler.AddToCart(ctx, cmd)
+
+	assert.ErrorIs(t, err, product.ErrProductNotFound)
+}
+
+func TestHandler_AddToCart_DeletedProduct(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	ctx := context.Background()
+
+	addProduct(eventStore, "prod-123", "Test Product", 1000, 10)
+	_ = eventStore.AddEvent("prod-123", product.AggregateType, product.EventProductDeleted, product.ProductDeleted{ProductID: "prod-123"})
+
+	_, err := handler.AddToCart(ctx, AddToCart{UserID: "user-123", ProductID: "prod-123", Quantity: 1})
 
 	assert.ErrorIs(t, err, product.ErrProductNotFound)
+	assert.Empty(t, eventStore.AppendCalls)
 }
 
 // ============================================
@@ -200,7 +239,7 @@ func TestHandler_AddToCart_ProductNotFound(t *testing.T) {
 // ============================================
 
 func TestHandler_RemoveFromCart_Success(t *testing.T) {
-	handler, eventStore, _ := newTestHandler()
+	handler, eventStore := newTestHandler()
 	ctx := context.Background()
 
 	cmd := RemoveFromCart{
@@ -208,7 +247,7 @@ func TestHandler_RemoveFromCart_Success(t *testing.T) {
 		ProductID: "prod-123",
 	}
 
-	err := handler.RemoveFromCart(ctx, cmd)
+	_, err := handler.RemoveFromCart(ctx, cmd)
 
 	require.NoError(t, err)
 	assert.Len(t, eventStore.AppendCalls, 1)
@@ -220,7 +259,7 @@ func TestHandler_RemoveFromCart_Success(t *testing.T) {
 // ============================================
 
 func TestHandler_ClearCart_Success(t *testing.T) {
-	handler, eventStore, _ := newTestHandler()
+	handler, eventStore := newTestHandler()
 	ctx := context.Background()
 
 	cmd := ClearCart{UserID: "user-123"}
@@ -237,46 +276,34 @@ func TestHandler_ClearCart_Success(t *testing.T) {
 // ============================================
 
 func TestHandler_PlaceOrder_Success(t *testing.T) {
-	handler, eventStore, readStore := newTestHandler()
+	handler, eventStore := newTestHandler()
 	ctx := context.Background()
 
 	userID := "user-123"
-	cartID := cart.GetCartID(userID)
-
-	// Set up cart with items
-	readStore.SetData("carts", cartID, &query.CartReadModel{
-		ID:     cartID,
-		UserID: userID,
-		Items: []query.CartItemReadModel{
-			{ProductID: "prod-1", Quantity: 2, Price: 1000},
-			{ProductID: "prod-2", Quantity: 1, Price: 2000},
-		},
-		Total: 4000,
-	})
 
-	// Set up inventory for both products
-	readStore.SetData("inventory", "prod-1", &query.InventoryReadModel{
-		ProductID:      "prod-1",
-		TotalStock:     100,
-		ReservedStock:  0,
-		AvailableStock: 100,
-	})
-	readStore.SetData("inventory", "prod-2", &query.InventoryReadModel{
-		ProductID:      "prod-2",
-		TotalStock:     50,
-		ReservedStock:  0,
-		AvailableStock: 50,
-	})
+	// Set up products with stock and a cart with both of them
+	addProduct(eventStore, "prod-1", "Widget", 1000, 100)
+	addProduct(eventStore, "prod-2", "Gadget", 2000, 50)
+	addCartItem(eventStore, userID, "prod-1", 2, 1000)
+	addCartItem(eventStore, userID, "prod-2", 1, 2000)
 
 	cmd := PlaceOrder{UserID: userID}
 
-	o, err := handler.PlaceOrder(ctx, cmd)
+	o, token, err := handler.PlaceOrder(ctx, cmd)
 
 	require.NoError(t, err)
 	assert.NotEmpty(t, o.ID)
 	assert.Equal(t, userID, o.UserID)
 	assert.Equal(t, 4000, o.Total)
 	assert.Equal(t, order.StatusPending, o.Status)
+	assert.Equal(t, []order.OrderItem{
+		{ProductID: "prod-1", Name: "Widget", Quantity: 2, Price: 1000},
+		{ProductID: "prod-2", Name: "Gadget", Quantity: 1, Price: 2000},
+	}, o.Items)
+
+	// The token covers the order, both inventories and the cart
+	assert.Len(t, token, 4)
+	assert.Equal(t, 1, token[o.ID])
 
 	// Should have events: OrderPlaced + 2x StockReserved + CartCleared
 	assert.Len(t, eventStore.AppendCalls, 4)
@@ -284,156 +311,148 @@ func TestHandler_PlaceOrder_Success(t *testing.T) {
 	assert.Equal(t, inventory.EventStockReserved, eventStore.AppendCalls[1].EventType)
 	assert.Equal(t, inventory.EventStockReserved, eventStore.AppendCalls[2].EventType)
 	assert.Equal(t, cart.EventCartCleared, eventStore.AppendCalls[3].EventType)
+	assert.Equal(t, 2, eventStore.AppendCalls[3].ExpectedVersion)
 }
 
 func TestHandler_PlaceOrder_EmptyCart(t *testing.T) {
-	handler, _, readStore := newTestHandler()
+	handler, eventStore := newTestHandler()
 	ctx := context.Background()
 
 	userID := "user-123"
 	cartID := cart.GetCartID(userID)
 
-	// Set up empty cart
-	readStore.SetData("carts", cartID, &query.CartReadModel{
-		ID:     cartID,
-		UserID: userID,
-		Items:  []query.CartItemReadModel{},
-		Total:  0,
+	// Set up a cart that was emptied
+	addProduct(eventStore, "prod-1", "Widget", 1000, 100)
+	addCartItem(eventStore, userID, "prod-1", 1, 1000)
+	_ = eventStore.AddEvent(cartID, cart.AggregateType, cart.EventItemRemoved, cart.ItemRemovedFromCart{
+		CartID: cartID, UserID: userID, ProductID: "prod-1",
 	})
 
 	cmd := PlaceOrder{UserID: userID}
 
-	o, err := handler.PlaceOrder(ctx, cmd)
+	o, _, err := handler.PlaceOrder(ctx, cmd)
 
 	assert.ErrorIs(t, err, order.ErrEmptyOrder)
 	assert.Nil(t, o)
+	assert.Empty(t, eventStore.AppendCalls)
 }
 
 func TestHandler_PlaceOrder_CartNotFound(t *testing.T) {
-	handler, _, _ := newTestHandler()
+	handler, _ := newTestHandler()
 	ctx := context.Background()
 
 	cmd := PlaceOrder{UserID: "user-with-no-cart"}
 
-	o, err := handler.PlaceOrder(ctx, cmd)
+	o, _, err := handler.PlaceOrder(ctx, cmd)
 
 	assert.ErrorIs(t, err, order.ErrEmptyOrder)
 	assert.Nil(t, o)
 }
 
 func TestHandler_PlaceOrder_InsufficientStock(t *testing.T) {
-	handler, _, readStore := newTestHandler()
+	handler, eventStore := newTestHandler()
 	ctx := context.Background()
 
 	userID := "user-123"
-	cartID := cart.GetCartID(userID)
 
-	// Set up cart with items
-	readStore.SetData("carts", cartID, &query.CartReadModel{
-		ID:     cartID,
-		UserID: userID,
-		Items: []query.CartItemReadModel{
-			{ProductID: "prod-1", Quantity: 100, Price: 1000}, // Requesting 100
-		},
-		Total: 100000,
-	})
-
-	// Set up inventory with insufficient stock
-	readStore.SetData("inventory", "prod-1", &query.InventoryReadModel{
-		ProductID:      "prod-1",
-		TotalStock:     50,
-		ReservedStock:  30,
-		AvailableStock: 20, // Only 20 available, requesting 100
+	// 50 in stock, 30 of them reserved by another order
+	addProduct(eventStore, "prod-1", "Widget", 1000, 50)
+	_ = eventStore.AddEvent("prod-1", inventory.AggregateType, inventory.EventStockReserved, inventory.StockReserved{
+		ProductID: "prod-1", OrderID: "order-other", Quantity: 30,
 	})
+	addCartItem(eventStore, userID, "prod-1", 100, 1000) // Requesting 100
 
 	cmd := PlaceOrder{UserID: userID}
 
-	o, err := handler.PlaceOrder(ctx, cmd)
+	o, _, err := handler.PlaceOrder(ctx, cmd)
 
 	assert.ErrorIs(t, err, inventory.ErrInsufficientStock)
+	assert.Contains(t, err.Error(), "only 20 available")
 	assert.Nil(t, o)
+	assert.Empty(t, eventStore.AppendCalls)
 }
 
 // ============================================
-// Place Order - Compensating Transaction Tests
+// Place Order - Atomic Batch Tests
 // ============================================
 
-func TestHandler_PlaceOrder_CompensatingTransaction(t *testing.T) {
-	eventStore := mocks.NewMockEventStore()
-	readStore := mocks.NewMockReadStore()
-
-	productSvc := product.NewService(eventStore)
-	cartSvc := cart.NewService(eventStore)
-	orderSvc := order.NewService(eventStore)
-	inventorySvc := inventory.NewService(eventStore)
+// setUpTwoItemCart stores two products with enough stock and a cart with both
+func setUpTwoItemCart(eventStore *mocks.MockEventStore, userID string) {
+	addProduct(eventStore, "prod-1", "Widget", 1000, 100)
+	addProduct(eventStore, "prod-2", "Gadget", 2000, 50)
+	addCartItem(eventStore, userID, "prod-1", 2, 1000)
+	addCartItem(eventStore, userID, "prod-2", 1, 2000)
+}
 
-	handler := NewHandler(productSvc, cartSvc, orderSvc, inventorySvc, readStore)
+func TestHandler_PlaceOrder_StoresAllEventsAtomically(t *testing.T) {
+	handler, eventStore := newTestHandler()
 	ctx := context.Background()
 
 	userID := "user-123"
-	cartID := cart.GetCartID(userID)
+	setUpTwoItemCart(eventStore, userID)
 
-	// Set up cart with 2 items
-	readStore.SetData("carts", cartID, &query.CartReadModel{
-		ID:     cartID,
-		UserID: userID,
-		Items: []query.CartItemReadModel{
-			{ProductID: "prod-1", Quantity: 2, Price: 1000},
-			{ProductID: "prod-2", Quantity: 1, Price: 2000},
-		},
-		Total: 4000,
-	})
+	o, _, err := handler.PlaceOrder(ctx, PlaceOrder{UserID: userID})
 
-	// Set up inventory for first product (will succeed)
-	readStore.SetData("inventory", "prod-1", &query.InventoryReadModel{
-		ProductID:      "prod-1",
-		TotalStock:     100,
-		AvailableStock: 100,
-	})
-	// Set up inventory for second product (will succeed in validation, fail in reserve)
-	readStore.SetData("inventory", "prod-2", &query.InventoryReadModel{
-		ProductID:      "prod-2",
-		TotalStock:     50,
-		AvailableStock: 50,
-	})
+	require.NoError(t, err)
+	assert.Len(t, eventStore.GetEvents(o.ID), 1)
+	assert.Len(t, eventStore.GetEvents("prod-1"), 3)
+	assert.Len(t, eventStore.GetEvents("prod-2"), 3)
+	assert.Len(t, eventStore.GetEvents(cart.GetCartID(userID)), 3)
+}
 
-	// Configure event store to fail on second reserve
-	callCount := 0
+func TestHandler_PlaceOrder_FailureStoresNothing(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	ctx := context.Background()
+
+	userID := "user-123"
+	setUpTwoItemCart(eventStore, userID)
+	stored := len(eventStore.GetAllEvents())
+
+	// Another request reserves prod-2 after PlaceOrder has loaded it, on every attempt
 	eventStore.AppendCallback = func(ctx context.Context, aggregateID, aggregateType, eventType string, data any) (*store.Event, error) {
-		callCount++
-		// Fail on 3rd call (which should be the second StockReserved)
-		if callCount == 3 {
-			return nil, errors.New("simulated database error")
+		if aggregateID == "prod-2" {
+			return nil, store.ErrConcurrencyConflict
 		}
-		return nil, nil
+		return &store.Event{AggregateID: aggregateID, EventType: eventType}, nil
 	}
 
-	cmd := PlaceOrder{UserID: userID}
+	o, _, err := handler.PlaceOrder(ctx, PlaceOrder{UserID: userID})
 
-	o, err := handler.PlaceOrder(ctx, cmd)
-
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, store.ErrConcurrencyConflict)
 	assert.Nil(t, o)
 
-	// Verify compensating transactions were attempted
-	// Should have: OrderPlaced (1) + StockReserved (2, fails) + StockReleased (3) + OrderCancelled (4)
-	// Note: The actual number of calls depends on exact implementation
-	assert.True(t, len(eventStore.AppendCalls) >= 3, "Expected at least 3 append calls for compensating transaction")
-
-	// Check that release was attempted for the first product
-	hasRelease := false
-	hasCancellation := false
+	// Nothing from the failed batches was stored and no compensation was needed
+	assert.Len(t, eventStore.GetAllEvents(), stored)
 	for _, call := range eventStore.AppendCalls {
-		if call.EventType == inventory.EventStockReleased {
-			hasRelease = true
-		}
-		if call.EventType == order.EventOrderCancelled {
-			hasCancellation = true
+		assert.NotEqual(t, inventory.EventStockReleased, call.EventType)
+		assert.NotEqual(t, order.EventOrderCancelled, call.EventType)
+	}
+}
+
+func TestHandler_PlaceOrder_RetriesBatchOnConcurrencyConflict(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	handler.retryPolicy = RetryPolicy{MaxAttempts: 3}
+	ctx := context.Background()
+
+	userID := "user-123"
+	setUpTwoItemCart(eventStore, userID)
+
+	// Fail the first batch on prod-1, then let the retry through
+	conflicted := false
+	eventStore.AppendCallback = func(ctx context.Context, aggregateID, aggregateType, eventType string, data any) (*store.Event, error) {
+		if aggregateID == "prod-1" && !conflicted {
+			conflicted = true
+			return nil, store.ErrConcurrencyConflict
 		}
+		return nil, nil
 	}
 
-	// At least release should be attempted
-	assert.True(t, hasRelease || hasCancellation, "Expected compensating transaction (release or cancellation)")
+	o, _, err := handler.PlaceOrder(ctx, PlaceOrder{UserID: userID})
+
+	require.NoError(t, err)
+	assert.NotEmpty(t, o.ID)
+	// First batch stopped at prod-1 (2 calls), the retry recorded all 4 events
+	assert.Len(t, eventStore.AppendCalls, 6)
 }
 
 // ============================================
@@ -441,7 +460,7 @@ func TestHandler_PlaceOrder_CompensatingTransaction(t *testing.T) {
 // ============================================
 
 func TestHandler_CancelOrder_Success(t *testing.T) {
-	handler, eventStore, readStore := newTestHandler()
+	handler, eventStore := newTestHandler()
 	ctx := context.Background()
 
 	orderID := "order-123"
@@ -454,16 +473,7 @@ func TestHandler_CancelOrder_Success(t *testing.T) {
 			{ProductID: "prod-1", Quantity: 2, Price: 1000},
 		},
 	})
-
-	// Set up order in read store
-	readStore.SetData("orders", orderID, &query.OrderReadModel{
-		ID:     orderID,
-		UserID: "user-123",
-		Items: []query.OrderItemReadModel{
-			{ProductID: "prod-1", Quantity: 2, Price: 1000},
-		},
-		Status: "pending",
-	})
+	reserve(eventStore, "prod-1", orderID, 2)
 
 	cmd := CancelOrder{
 		OrderID: orderID,
@@ -490,7 +500,7 @@ func TestHandler_CancelOrder_Success(t *testing.T) {
 }
 
 func TestHandler_CancelOrder_OrderNotFound(t *testing.T) {
-	handler, _, _ := newTestHandler()
+	handler, _ := newTestHandler()
 	ctx := context.Background()
 
 	cmd := CancelOrder{
@@ -503,23 +513,34 @@ func TestHandler_CancelOrder_OrderNotFound(t *testing.T) {
 	assert.ErrorIs(t, err, order.ErrOrderNotFound)
 }
 
+func TestHandler_CancelOrder_OtherUsersOrder(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	ctx := context.Background()
+	placeOrder(eventStore, "order-123", "user-123", 3000)
+
+	err := handler.CancelOrder(ctx, CancelOrder{OrderID: "order-123", UserID: "user-456"})
+
+	assert.ErrorIs(t, err, order.ErrOrderNotFound)
+	assert.Empty(t, eventStore.AppendCalls)
+
+	// The owner can cancel it without waiting for the read model
+	require.NoError(t, handler.CancelOrder(ctx, CancelOrder{OrderID: "order-123", UserID: "user-123"}))
+}
+
 func TestHandler_CancelOrder_AlreadyShipped(t *testing.T) {
-	handler, eventStore, readStore := newTestHandler()
+	handler, eventStore := newTestHandler()
 	ctx := context.Background()
 
 	orderID := "order-123"
 
 	// Set up order in shipped state
-	_ = eventStore.AddEvent(orderID, order.AggregateType, order.EventOrderPlaced, order.OrderPlaced{OrderID: orderID})
+	_ = eventStore.AddEvent(orderID, order.AggregateType, order.EventOrderPlaced, order.OrderPlaced{
+		OrderID: orderID,
+		Items:   []order.OrderItem{{ProductID: "prod-1", Quantity: 1}},
+	})
 	_ = eventStore.AddEvent(orderID, order.AggregateType, order.EventOrderPaid, order.OrderPaid{OrderID: orderID})
 	_ = eventStore.AddEvent(orderID, order.AggregateType, order.EventOrderShipped, order.OrderShipped{OrderID: orderID})
 
-	readStore.SetData("orders", orderID, &query.OrderReadModel{
-		ID:     orderID,
-		Items:  []query.OrderItemReadModel{{ProductID: "prod-1", Quantity: 1}},
-		Status: "shipped",
-	})
-
 	cmd := CancelOrder{
 		OrderID: orderID,
 		Reason:  "too late",
@@ -528,6 +549,272 @@ func TestHandler_CancelOrder_AlreadyShipped(t *testing.T) {
 	err := handler.CancelOrder(ctx, cmd)
 
 	assert.ErrorIs(t, err, order.ErrOrderShipped)
+	// Stock of a shipped order is not released
+	assert.Empty(t, eventStore.AppendCalls)
+}
+
+func TestHandler_CancelOrder_SkipsReleasedReservations(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	ctx := context.Background()
+
+	orderID := "order-123"
+	_ = eventStore.AddEvent(orderID, order.AggregateType, order.EventOrderPlaced, order.OrderPlaced{
+		OrderID: orderID,
+		Items: []order.OrderItem{
+			{ProductID: "prod-1", Quantity: 2},
+			{ProductID: "prod-2", Quantity: 3},
+		},
+	})
+	reserve(eventStore, "prod-1", orderID, 2)
+	reserve(eventStore, "prod-2", orderID, 3)
+	// An earlier attempt released prod-1 and then failed
+	_ = eventStore.AddEvent("prod-1", inventory.AggregateType, inventory.EventStockReleased, inventory.StockReleased{
+		ProductID: "prod-1", OrderID: orderID, Quantity: 2,
+	})
+
+	err := handler.CancelOrder(ctx, CancelOrder{OrderID: orderID, Reason: "retry"})
+
+	require.NoError(t, err)
+	require.Len(t, eventStore.AppendCalls, 2)
+	assert.Equal(t, "prod-2", eventStore.AppendCalls[0].AggregateID)
+	assert.Equal(t, inventory.EventStockReleased, eventStore.AppendCalls[0].EventType)
+	assert.Equal(t, order.EventOrderCancelled, eventStore.AppendCalls[1].EventType)
+}
+
+// ============================================
+// Pay Order Tests
+// ============================================
+
+// placeOrder stores a pending order of a user for a total, with its stock reserved
+func placeOrder(eventStore *mocks.MockEventStore, orderID, userID string, total int) {
+	_ = eventStore.AddEvent(orderID, order.AggregateType, order.EventOrderPlaced, order.OrderPlaced{
+		OrderID: orderID,
+		UserID:  userID,
+		Items:   []order.OrderItem{{ProductID: "prod-1", Quantity: 1, Price: total}},
+		Total:   total,
+	})
+	reserve(eventStore, "prod-1", orderID, 1)
+}
+
+// eventTypes returns the types of the events stored for an aggregate
+func eventTypes(eventStore *mocks.MockEventStore, aggregateID string) []string {
+	var types []string
+	for _, event := range eventStore.GetEvents(aggregateID) {
+		types = append(types, event.EventType)
+	}
+	return types
+}
+
+func TestHandler_PayOrder_Success(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	ctx := context.Background()
+	placeOrder(eventStore, "order-123", "user-123", 3000)
+
+	token, err := handler.PayOrder(ctx, PayOrder{OrderID: "order-123", UserID: "user-123", PaymentMethod: "card"})
+
+	require.NoError(t, err)
+	assert.Equal(t, store.ConsistencyToken{"order-123": 3}, token)
+	assert.Equal(t, []string{order.EventOrderPlaced, order.EventPaymentAttempted, order.EventOrderPaid}, eventTypes(eventStore, "order-123"))
+
+	attempt := eventStore.AppendCalls[0].Data.(order.PaymentAttempted)
+	assert.Equal(t, 3000, attempt.Amount)
+	assert.Equal(t, payment.FakeProvider, attempt.Provider)
+	paid := eventStore.AppendCalls[1].Data.(order.OrderPaid)
+	assert.Equal(t, attempt.AttemptID, paid.AttemptID)
+	assert.Equal(t, "fake_cap_"+attempt.AttemptID, paid.CaptureID)
+	assert.Equal(t, 3000, paid.Amount)
+
+	o, err := handler.orderSvc.Get(ctx, "order-123")
+	require.NoError(t, err)
+	assert.Equal(t, order.StatusPaid, o.Status)
+	assert.Equal(t, &order.Payment{Provider: payment.FakeProvider, CaptureID: paid.CaptureID, Amount: 3000}, o.Payment)
+}
+
+func TestHandler_PayOrder_RecordsFailures(t *testing.T) {
+	for method, stage := range map[string]string{
+		payment.FakeMethodDeclined:      "authorize",
+		payment.FakeMethodCaptureFailed: "capture",
+	} {
+		t.Run(method, func(t *testing.T) {
+			handler, eventStore := newTestHandler()
+			ctx := context.Background()
+			placeOrder(eventStore, "order-123", "user-123", 3000)
+
+			token, err := handler.PayOrder(ctx, PayOrder{OrderID: "order-123", UserID: "user-123", PaymentMethod: method})
+
+			assert.ErrorIs(t, err, payment.ErrDeclined)
+			assert.Nil(t, token)
+			assert.Equal(t, []string{order.EventOrderPlaced, order.EventPaymentAttempted, order.EventPaymentFailed}, eventTypes(eventStore, "order-123"))
+			failure := eventStore.AppendCalls[1].Data.(order.PaymentFailed)
+			assert.Equal(t, stage, failure.Stage)
+			assert.Contains(t, failure.Reason, "declined")
+
+			// The order stays pending and can be paid again
+			o, err := handler.orderSvc.Get(ctx, "order-123")
+			require.NoError(t, err)
+			assert.Equal(t, order.StatusPending, o.Status)
+			assert.Equal(t, 1, o.PaymentAttempts)
+			_, err = handler.PayOrder(ctx, PayOrder{OrderID: "order-123", UserID: "user-123"})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestHandler_PayOrder_VoidsAuthorizationOnCaptureFailure(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	gateway := payment.NewFakeGateway()
+	handler.payments = gateway
+	ctx := context.Background()
+	placeOrder(eventStore, "order-123", "user-123", 3000)
+
+	_, err := handler.PayOrder(ctx, PayOrder{OrderID: "order-123", PaymentMethod: payment.FakeMethodCaptureFailed})
+
+	assert.ErrorIs(t, err, payment.ErrDeclined)
+	attempt := eventStore.AppendCalls[0].Data.(order.PaymentAttempted)
+	assert.True(t, gateway.Voided("fake_auth_"+attempt.AttemptID))
+}
+
+func TestHandler_PayOrder_OtherUsersOrder(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	ctx := context.Background()
+	placeOrder(eventStore, "order-123", "user-123", 3000)
+
+	_, err := handler.PayOrder(ctx, PayOrder{OrderID: "order-123", UserID: "user-456"})
+
+	assert.ErrorIs(t, err, order.ErrOrderNotFound)
+	assert.Empty(t, eventStore.AppendCalls)
+}
+
+func TestHandler_PayOrder_AlreadyPaid(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	ctx := context.Background()
+	placeOrder(eventStore, "order-123", "user-123", 3000)
+	_, err := handler.PayOrder(ctx, PayOrder{OrderID: "order-123"})
+	require.NoError(t, err)
+
+	_, err = handler.PayOrder(ctx, PayOrder{OrderID: "order-123"})
+
+	assert.ErrorIs(t, err, order.ErrOrderAlreadyPaid)
+	assert.Len(t, eventStore.AppendCalls, 2)
+}
+
+// cancellingGateway cancels the order while its payment is captured
+type cancellingGateway struct {
+	*payment.FakeGateway
+	eventStore *mocks.MockEventStore
+	orderID    string
+}
+
+func (g cancellingGateway) Capture(ctx context.Context, authorizationID string, amount int) (*payment.Capture, error) {
+	_ = g.eventStore.AddEvent(g.orderID, order.AggregateType, order.EventOrderCancelled, order.OrderCancelled{OrderID: g.orderID})
+	return g.FakeGateway.Capture(ctx, authorizationID, amount)
+}
+
+func TestHandler_PayOrder_RefundsWhenOrderCannotBePaid(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	handler.payments = cancellingGateway{FakeGateway: payment.NewFakeGateway(), eventStore: eventStore, orderID: "order-123"}
+	ctx := context.Background()
+	placeOrder(eventStore, "order-123", "user-123", 3000)
+
+	_, err := handler.PayOrder(ctx, PayOrder{OrderID: "order-123"})
+
+	assert.ErrorIs(t, err, order.ErrOrderCancelled)
+	assert.Equal(t, []string{
+		order.EventOrderPlaced, order.EventPaymentAttempted, order.EventOrderCancelled, order.EventPaymentRefunded,
+	}, eventTypes(eventStore, "order-123"))
+	refund := eventStore.AppendCalls[len(eventStore.AppendCalls)-1].Data.(order.PaymentRefunded)
+	assert.Equal(t, 3000, refund.Amount)
+}
+
+func TestHandler_CancelOrder_RefundsPaidOrder(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	ctx := context.Background()
+	placeOrder(eventStore, "order-123", "user-123", 3000)
+	_, err := handler.PayOrder(ctx, PayOrder{OrderID: "order-123"})
+	require.NoError(t, err)
+
+	require.NoError(t, handler.CancelOrder(ctx, CancelOrder{OrderID: "order-123", Reason: "changed mind"}))
+
+	assert.Equal(t, []string{
+		order.EventOrderPlaced, order.EventPaymentAttempted, order.EventOrderPaid, order.EventPaymentRefunded, order.EventOrderCancelled,
+	}, eventTypes(eventStore, "order-123"))
+	o, err := handler.orderSvc.Get(ctx, "order-123")
+	require.NoError(t, err)
+	assert.Equal(t, 3000, o.Payment.Refunded)
+}
+
+// ============================================
+// Ship Order Tests
+// ============================================
+
+func TestHandler_ShipOrder_Success(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	ctx := context.Background()
+	placeOrder(eventStore, "order-123", "user-123", 3000)
+	_, err := handler.PayOrder(ctx, PayOrder{OrderID: "order-123"})
+	require.NoError(t, err)
+
+	o, token, err := handler.ShipOrder(ctx, ShipOrder{OrderID: "order-123", Carrier: " yamato ", TrackingNumber: "1234-5678-9012\n"})
+
+	require.NoError(t, err)
+	assert.Equal(t, store.ConsistencyToken{"order-123": 4, "prod-1": 3}, token)
+	require.NotNil(t, o.Shipment)
+	assert.Equal(t, "yamato", o.Shipment.Carrier, "the shipped order has the trimmed tracking details")
+	assert.Equal(t, "1234-5678-9012", o.Shipment.TrackingNumber)
+	assert.Equal(t, []string{inventory.EventStockAdded, inventory.EventStockReserved, inventory.EventStockDeducted}, eventTypes(eventStore, "prod-1"))
+	shipped := eventStore.AppendCalls[2].Data.(order.OrderShipped)
+	assert.Equal(t, "yamato", shipped.Carrier)
+	assert.Equal(t, "1234-5678-9012", shipped.TrackingNumber)
+	deducted := eventStore.AppendCalls[3].Data.(inventory.StockDeducted)
+	assert.Equal(t, inventory.StockDeducted{ProductID: "prod-1", OrderID: "order-123", Quantity: 1, DeductedAt: deducted.DeductedAt}, deducted)
+
+	o, err = handler.orderSvc.Get(ctx, "order-123")
+	require.NoError(t, err)
+	assert.Equal(t, order.StatusShipped, o.Status)
+}
+
+func TestHandler_ShipOrder_Unpaid(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	ctx := context.Background()
+	placeOrder(eventStore, "order-123", "user-123", 3000)
+
+	_, _, err := handler.ShipOrder(ctx, ShipOrder{OrderID: "order-123", Carrier: "yamato", TrackingNumber: "1234-5678-9012"})
+
+	assert.ErrorIs(t, err, order.ErrOrderNotPaid)
+	assert.Empty(t, eventStore.AppendCalls)
+}
+
+func TestHandler_ShipOrder_MissingTracking(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	ctx := context.Background()
+	placeOrder(eventStore, "order-123", "user-123", 3000)
+	_ = eventStore.AddEvent("order-123", order.AggregateType, order.EventOrderPaid, order.OrderPaid{OrderID: "order-123"})
+
+	_, _, err := handler.ShipOrder(ctx, ShipOrder{OrderID: "order-123", Carrier: "yamato"})
+
+	assert.ErrorIs(t, err, order.ErrMissingTracking)
+	assert.Empty(t, eventStore.AppendCalls)
+}
+
+func TestHandler_ShipOrder_NothingStoredWithoutReservation(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	ctx := context.Background()
+	_ = eventStore.AddEvent("order-123", order.AggregateType, order.EventOrderPlaced, order.OrderPlaced{
+		OrderID: "order-123",
+		Items: []order.OrderItem{
+			{ProductID: "prod-1", Quantity: 1, Price: 1000},
+			{ProductID: "prod-2", Quantity: 2, Price: 1000},
+		},
+		Total: 3000,
+	})
+	_ = eventStore.AddEvent("order-123", order.AggregateType, order.EventOrderPaid, order.OrderPaid{OrderID: "order-123"})
+	reserve(eventStore, "prod-1", "order-123", 1)
+	reserve(eventStore, "prod-2", "order-456", 2)
+
+	_, _, err := handler.ShipOrder(ctx, ShipOrder{OrderID: "order-123", Carrier: "yamato", TrackingNumber: "1234-5678-9012"})
+
+	assert.ErrorIs(t, err, inventory.ErrReservationNotFound)
+	assert.Empty(t, eventStore.AppendCalls, "the order does not ship and no stock is deducted")
 }
 
 // ============================================
@@ -535,7 +822,7 @@ func TestHandler_CancelOrder_AlreadyShipped(t *testing.T) {
 // ============================================
 
 func TestHandler_CreateProduct_ZeroStock(t *testing.T) {
-	handler, eventStore, _ := newTestHandler()
+	handler, eventStore := newTestHandler()
 	ctx := context.Background()
 
 	cmd := CreateProduct{
@@ -558,66 +845,41 @@ func TestHandler_CreateProduct_ZeroStock(t *testing.T) {
 // Additional PlaceOrder Tests
 // ============================================
 
-func TestHandler_PlaceOrder_InventoryNotFound(t *testing.T) {
-	handler, _, readStore := newTestHandler()
+func TestHandler_PlaceOrder_ProductNotFound(t *testing.T) {
+	handler, eventStore := newTestHandler()
 	ctx := context.Background()
 
 	userID := "user-123"
-	cartID := cart.GetCartID(userID)
-
-	readStore.SetData("carts", cartID, &query.CartReadModel{
-		ID:     cartID,
-		UserID: userID,
-		Items: []query.CartItemReadModel{
-			{ProductID: "prod-no-inventory", Quantity: 1, Price: 1000},
-		},
-		Total: 1000,
-	})
-	// No inventory data set for prod-no-inventory
+	addCartItem(eventStore, userID, "prod-unknown", 1, 1000)
 
 	cmd := PlaceOrder{UserID: userID}
 
-	o, err := handler.PlaceOrder(ctx, cmd)
+	o, _, err := handler.PlaceOrder(ctx, cmd)
 
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "inventory not found")
+	assert.ErrorIs(t, err, product.ErrProductNotFound)
+	assert.Contains(t, err.Error(), "prod-unknown")
 	assert.Nil(t, o)
+	assert.Empty(t, eventStore.AppendCalls)
 }
 
 func TestHandler_PlaceOrder_MultipleItemsOneInsufficientStock(t *testing.T) {
-	handler, _, readStore := newTestHandler()
+	handler, eventStore := newTestHandler()
 	ctx := context.Background()
 
 	userID := "user-123"
-	cartID := cart.GetCartID(userID)
 
-	readStore.SetData("carts", cartID, &query.CartReadModel{
-		ID:     cartID,
-		UserID: userID,
-		Items: []query.CartItemReadModel{
-			{ProductID: "prod-1", Quantity: 5, Price: 1000},
-			{ProductID: "prod-2", Quantity: 100, Price: 2000}, // This one has insufficient stock
-		},
-		Total: 205000,
-	})
-
-	readStore.SetData("inventory", "prod-1", &query.InventoryReadModel{
-		ProductID:      "prod-1",
-		TotalStock:     100,
-		AvailableStock: 100,
-	})
-	readStore.SetData("inventory", "prod-2", &query.InventoryReadModel{
-		ProductID:      "prod-2",
-		TotalStock:     50,
-		AvailableStock: 50, // Only 50 available, requesting 100
-	})
+	addProduct(eventStore, "prod-1", "Widget", 1000, 100)
+	addProduct(eventStore, "prod-2", "Gadget", 2000, 50) // Only 50 available
+	addCartItem(eventStore, userID, "prod-1", 5, 1000)
+	addCartItem(eventStore, userID, "prod-2", 100, 2000) // This one has insufficient stock
 
 	cmd := PlaceOrder{UserID: userID}
 
-	o, err := handler.PlaceOrder(ctx, cmd)
+	o, _, err := handler.PlaceOrder(ctx, cmd)
 
 	assert.ErrorIs(t, err, inventory.ErrInsufficientStock)
 	assert.Nil(t, o)
+	assert.Empty(t, eventStore.AppendCalls)
 }
 
 // ============================================
@@ -625,7 +887,7 @@ func TestHandler_PlaceOrder_MultipleItemsOneInsufficientStock(t *testing.T) {
 // ============================================
 
 func TestHandler_CancelOrder_MultipleItems(t *testing.T) {
-	handler, eventStore, readStore := newTestHandler()
+	handler, eventStore := newTestHandler()
 	ctx := context.Background()
 
 	orderID := "order-123"
@@ -637,16 +899,8 @@ func TestHandler_CancelOrder_MultipleItems(t *testing.T) {
 			{ProductID: "prod-2", Quantity: 3},
 		},
 	})
-
-	readStore.SetData("orders", orderID, &query.OrderReadModel{
-		ID:     orderID,
-		UserID: "user-123",
-		Items: []query.OrderItemReadModel{
-			{ProductID: "prod-1", Quantity: 2, Price: 1000},
-			{ProductID: "prod-2", Quantity: 3, Price: 500},
-		},
-		Status: "pending",
-	})
+	reserve(eventStore, "prod-1", orderID, 2)
+	reserve(eventStore, "prod-2", orderID, 3)
 
 	cmd := CancelOrder{
 		OrderID: orderID,
@@ -671,3 +925,175 @@ func TestHandler_CancelOrder_MultipleItems(t *testing.T) {
 	assert.Equal(t, 2, releaseCount)
 	assert.Equal(t, 1, cancelCount)
 }
+
+// ============================================
+// Concurrency Conflict Retry Tests
+// ============================================
+
+func TestHandler_ClearCart_RetriesOnConcurrencyConflict(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	handler.retryPolicy = RetryPolicy{MaxAttempts: 3}
+	ctx := context.Background()
+
+	// Fail the first append as if another request had modified the cart
+	callCount := 0
+	eventStore.AppendCallback = func(ctx context.Context, aggregateID, aggregateType, eventType string, data any) (*store.Event, error) {
+		callCount++
+		if callCount == 1 {
+			return nil, store.ErrConcurrencyConflict
+		}
+		return nil, nil
+	}
+
+	err := handler.ClearCart(ctx, ClearCart{UserID: "user-123"})
+
+	require.NoError(t, err)
+	assert.Len(t, eventStore.AppendCalls, 2)
+}
+
+func TestHandler_ClearCart_GivesUpAfterMaxAttempts(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	handler.retryPolicy = RetryPolicy{MaxAttempts: 3}
+	ctx := context.Background()
+
+	eventStore.AppendErr = store.ErrConcurrencyConflict
+
+	err := handler.ClearCart(ctx, ClearCart{UserID: "user-123"})
+
+	assert.ErrorIs(t, err, store.ErrConcurrencyConflict)
+	assert.Len(t, eventStore.AppendCalls, 3)
+}
+
+func TestHandler_ClearCart_DoesNotRetryOtherErrors(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	handler.retryPolicy = RetryPolicy{MaxAttempts: 3}
+	ctx := context.Background()
+
+	eventStore.AppendErr = errors.New("simulated database error")
+
+	err := handler.ClearCart(ctx, ClearCart{UserID: "user-123"})
+
+	assert.Error(t, err)
+	assert.Len(t, eventStore.AppendCalls, 1)
+}
+
+func TestHandler_AddToCart_PassesLoadedVersion(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	ctx := context.Background()
+
+	addProduct(eventStore, "prod-123", "Test Product", 1000, 10)
+	addCartItem(eventStore, "user-123", "prod-123", 1, 1000)
+
+	_, err := handler.AddToCart(ctx, AddToCart{UserID: "user-123", ProductID: "prod-123", Quantity: 1})
+
+	require.NoError(t, err)
+	require.Len(t, eventStore.AppendCalls, 1)
+	assert.Equal(t, 1, eventStore.AppendCalls[0].ExpectedVersion)
+}
+
+// ============================================
+// Projection Lag Tests
+// ============================================
+
+// project applies the stored events that the read store has not seen yet, as the projector does
+func project(t *testing.T, eventStore *mocks.MockEventStore, readStore *mocks.MockReadStore) {
+	t.Helper()
+	projector := projection.NewProjector(readStore)
+	for _, event := range eventStore.GetAllEvents() {
+		value, err := json.Marshal(event)
+		require.NoError(t, err)
+		require.NoError(t, projector.HandleEvent(context.Background(), []byte(event.AggregateID), value))
+	}
+}
+
+func TestHandler_PlaceOrder_DoesNotOversellWhenProjectionLags(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	readStore := mocks.NewMockReadStore()
+	ctx := context.Background()
+
+	addProduct(eventStore, "prod-1", "Widget", 1000, 5)
+	addCartItem(eventStore, "user-1", "prod-1", 5, 1000)
+	addCartItem(eventStore, "user-2", "prod-1", 5, 1000)
+	project(t, eventStore, readStore)
+
+	_, _, err := handler.PlaceOrder(ctx, PlaceOrder{UserID: "user-1"})
+	require.NoError(t, err)
+
+	// The projector has not applied the reservation yet
+	inv, ok, err := readStore.ReadModels().Inventory.Get(ctx, "prod-1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 5, inv.AvailableStock)
+
+	o, _, err := handler.PlaceOrder(ctx, PlaceOrder{UserID: "user-2"})
+
+	assert.ErrorIs(t, err, inventory.ErrInsufficientStock)
+	assert.Nil(t, o)
+}
+
+func TestHandler_PlaceOrder_UsesCurrentCartWhenProjectionLags(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	readStore := mocks.NewMockReadStore()
+	ctx := context.Background()
+
+	addProduct(eventStore, "prod-1", "Widget", 1000, 10)
+	addProduct(eventStore, "prod-2", "Gadget", 2000, 10)
+	addCartItem(eventStore, "user-1", "prod-1", 1, 1000)
+	project(t, eventStore, readStore)
+
+	// Added after the last projection
+	addCartItem(eventStore, "user-1", "prod-2", 1, 2000)
+	c, _, err := readStore.ReadModels().Carts.Get(ctx, cart.GetCartID("user-1"))
+	require.NoError(t, err)
+	require.Len(t, c.Items, 1)
+
+	o, _, err := handler.PlaceOrder(ctx, PlaceOrder{UserID: "user-1"})
+	require.NoError(t, err)
+	assert.Len(t, o.Items, 2)
+	assert.Equal(t, 3000, o.Total)
+
+	// The cart was cleared by the order, although its read model still shows items
+	o, _, err = handler.PlaceOrder(ctx, PlaceOrder{UserID: "user-1"})
+	assert.ErrorIs(t, err, order.ErrEmptyOrder)
+	assert.Nil(t, o)
+}
+
+func TestHandler_CancelOrder_ReleasesStockWhenProjectionLags(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	readStore := mocks.NewMockReadStore()
+	ctx := context.Background()
+
+	addProduct(eventStore, "prod-1", "Widget", 1000, 10)
+	addCartItem(eventStore, "user-1", "prod-1", 3, 1000)
+	project(t, eventStore, readStore)
+
+	o, _, err := handler.PlaceOrder(ctx, PlaceOrder{UserID: "user-1"})
+	require.NoError(t, err)
+
+	// The order has no read model yet
+	_, ok, err := readStore.ReadModels().Orders.Get(ctx, o.ID)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, handler.CancelOrder(ctx, CancelOrder{OrderID: o.ID, Reason: "changed mind"}))
+
+	last := eventStore.AppendCalls[len(eventStore.AppendCalls)-2]
+	require.Equal(t, inventory.EventStockReleased, last.EventType)
+	assert.Equal(t, 3, last.Data.(inventory.StockReleased).Quantity)
+}
+
+func TestHandler_AddToCart_UsesCurrentPriceWhenProjectionLags(t *testing.T) {
+	handler, eventStore := newTestHandler()
+	readStore := mocks.NewMockReadStore()
+	ctx := context.Background()
+
+	addProduct(eventStore, "prod-1", "Widget", 1000, 10)
+	project(t, eventStore, readStore)
+	require.NoError(t, handler.UpdateProduct(ctx, UpdateProduct{ProductID: "prod-1", Name: "Widget", Price: 1500}))
+
+	_, err := handler.AddToCart(ctx, AddToCart{UserID: "user-1", ProductID: "prod-1", Quantity: 1})
+
+	require.NoError(t, err)
+	added := eventStore.AppendCalls[len(eventStore.AppendCalls)-1]
+	assert.Equal(t, 1500, added.Data.(cart.ItemAddedToCart).Price)
+}
@@ -0,0 +1,44 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+)
+
+// RetryPolicy bounds how often a command step is retried after a concurrency conflict
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts including the first one
+	Backoff     time.Duration // Delay before the first retry, doubled after each attempt
+}
+
+// DefaultRetryPolicy is used by NewHandler
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     20 * time.Millisecond,
+}
+
+// Do runs fn, retrying it while it fails with store.ErrConcurrencyConflict.
+// fn must reload the aggregate on each call so retries see the latest version.
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	backoff := p.Backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || !errors.Is(err, store.ErrConcurrencyConflict) || attempt >= p.MaxAttempts {
+			return err
+		}
+
+		log.Printf("[Command] Concurrency conflict (attempt %d/%d), retrying: %v", attempt, p.MaxAttempts, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,233 @@
+// Package consumer processes the Kinesis batches of the event stream for the
+// Lambda consumers (the projector and the notifier).
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/example/ec-event-driven/internal/deadletter"
+	"github.com/example/ec-event-driven/internal/infrastructure/kinesis"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+)
+
+// ErrVersionGap is returned for an event whose aggregate is missing earlier
+// versions, e.g. because an earlier event was dead-lettered and has not been
+// redriven or discarded yet
+var ErrVersionGap = errors.New("event version gap")
+
+// UnitOfWorkFunc calls fn with a handler whose changes are committed together
+// if fn returns nil, such as a projector bound to one read store transaction
+type UnitOfWorkFunc func(ctx context.Context, fn func(handler deadletter.EventHandler) error) error
+
+// CheckpointFunc returns the last applied version of each of the given
+// aggregates. Aggregates that were never applied are omitted.
+type CheckpointFunc func(ctx context.Context, aggregateIDs []string) (map[string]int, error)
+
+// BatchProcessor passes the records of a Kinesis batch to an event handler
+// while keeping the events of each aggregate in order.
+//
+// Once an event fails, the later events of its aggregate in the batch are
+// not processed, while the events of other aggregates still are. The first
+// failed record is reported as the batch item failure, so Kinesis retries the
+// shard from it; events after it that were processed are redelivered and
+// must be handled idempotently. Events that fail too often or can never be
+// processed are dead-lettered by the guard and no longer block their aggregate.
+type BatchProcessor struct {
+	name    string // Used as the log prefix
+	handler deadletter.EventHandler
+	guard   *deadletter.Guard
+
+	// Checkpoints, if set, is used to detect version gaps: an event is only
+	// handled right after the last applied version of its aggregate and
+	// fails with ErrVersionGap otherwise.
+	Checkpoints CheckpointFunc
+
+	// UnitOfWork, if set, handles the whole batch in one unit of work. The
+	// events of the batch are still handled one by one, but their changes
+	// are committed once at the end; if that fails, the whole batch is retried.
+	UnitOfWork UnitOfWorkFunc
+}
+
+// NewBatchProcessor creates a BatchProcessor that logs as name
+func NewBatchProcessor(name string, handler deadletter.EventHandler, guard *deadletter.Guard) *BatchProcessor {
+	return &BatchProcessor{name: name, handler: handler, guard: guard}
+}
+
+// record is a Kinesis record with its decoded event
+type record struct {
+	events.KinesisEventRecord
+	event     *store.Event // Nil for records that are not events
+	eventJSON []byte
+}
+
+// Process handles a Kinesis batch and reports the record to retry it from, if any
+func (p *BatchProcessor) Process(ctx context.Context, kinesisEvent events.KinesisEvent) events.KinesisEventResponse {
+	log.Printf("[%s] Received %d records", p.name, len(kinesisEvent.Records))
+
+	// Records from an undecodable one that could not be dead-lettered are
+	// retried, as their aggregates are unknown
+	records, stopAt := p.decode(ctx, kinesisEvent.Records)
+	checkpoints, err := p.checkpoints(ctx, records)
+	if err != nil {
+		log.Printf("[%s] %v, retrying the batch", p.name, err)
+		return failureResponse(kinesisEvent.Records[0].Kinesis.SequenceNumber)
+	}
+
+	var result batchResult
+	if p.UnitOfWork == nil {
+		result = p.handleAll(ctx, p.handler, records, checkpoints)
+	} else {
+		err := p.UnitOfWork(ctx, func(handler deadletter.EventHandler) error {
+			result = p.handleAll(ctx, handler, records, checkpoints)
+			return nil
+		})
+		if err != nil {
+			log.Printf("[%s] Failed to commit the batch, retrying it: %v", p.name, err)
+			return failureResponse(kinesisEvent.Records[0].Kinesis.SequenceNumber)
+		}
+	}
+
+	if len(result.succeeded) > 0 {
+		if err := p.guard.Succeeded(ctx, result.succeeded...); err != nil {
+			log.Printf("[%s] Failed to clear failed attempts of processed records: %v", p.name, err)
+		}
+	}
+
+	log.Printf("[%s] Processed %d/%d records (%d failed aggregates, %d records held back)",
+		p.name, result.processed, len(kinesisEvent.Records), result.failedAggregates, result.skipped)
+
+	retryFrom := stopAt
+	if result.firstFailure != nil {
+		retryFrom = &result.firstFailure.KinesisEventRecord
+	}
+	if retryFrom == nil {
+		return events.KinesisEventResponse{}
+	}
+	log.Printf("[%s] Retrying from record %s", p.name, retryFrom.Kinesis.SequenceNumber)
+	return failureResponse(retryFrom.Kinesis.SequenceNumber)
+}
+
+// batchResult is the outcome of handling the events of a batch
+type batchResult struct {
+	succeeded        []events.KinesisEventRecord
+	firstFailure     *record
+	processed        int
+	skipped          int
+	failedAggregates int
+}
+
+// handleAll passes the events to handler in order. Once an event fails, the
+// later events of its aggregate are skipped.
+func (p *BatchProcessor) handleAll(ctx context.Context, handler deadletter.EventHandler, records []record, checkpoints map[string]int) batchResult {
+	var result batchResult
+	failed := make(map[string]bool) // aggregate ID -> an earlier event failed
+	for i := range records {
+		r := &records[i]
+		if r.event == nil {
+			result.succeeded = append(result.succeeded, r.KinesisEventRecord)
+			continue
+		}
+		aggregateID := r.event.AggregateID
+		if failed[aggregateID] {
+			result.skipped++
+			continue
+		}
+
+		err := p.handle(ctx, handler, r, checkpoints)
+		if err == nil {
+			if checkpoints != nil && r.event.Version > checkpoints[aggregateID] {
+				checkpoints[aggregateID] = r.event.Version
+			}
+			result.succeeded = append(result.succeeded, r.KinesisEventRecord)
+			result.processed++
+			continue
+		}
+
+		log.Printf("[%s] Failed to process event %s (%s) of aggregate %s: %v",
+			p.name, r.event.ID, r.event.EventType, aggregateID, err)
+		deadLettered, dlErr := p.guard.Failed(ctx, r.KinesisEventRecord, r.event, r.eventJSON, err)
+		if dlErr != nil {
+			log.Printf("[%s] Failed to record failure of record %s: %v", p.name, r.Kinesis.SequenceNumber, dlErr)
+		} else if deadLettered {
+			continue
+		}
+		failed[aggregateID] = true
+		if result.firstFailure == nil {
+			result.firstFailure = r
+		}
+	}
+	result.failedAggregates = len(failed)
+	return result
+}
+
+// decode converts the records to events, dead-lettering those that cannot be
+// converted. If dead-lettering fails, it returns the records before that one
+// along with it.
+func (p *BatchProcessor) decode(ctx context.Context, kinesisRecords []events.KinesisEventRecord) ([]record, *events.KinesisEventRecord) {
+	records := make([]record, 0, len(kinesisRecords))
+	for _, kr := range kinesisRecords {
+		r := record{KinesisEventRecord: kr}
+		event, err := kinesis.ConvertFromKinesisRecord(kr)
+		if err == nil && event != nil {
+			r.event = event
+			r.eventJSON, err = json.Marshal(event)
+			if err != nil {
+				r.event = nil
+			}
+		}
+		if err != nil {
+			// A malformed record fails the same way on every retry
+			log.Printf("[%s] Failed to convert record %s: %v", p.name, kr.Kinesis.SequenceNumber, err)
+			if dlErr := p.guard.Undecodable(ctx, kr, err); dlErr != nil {
+				log.Printf("[%s] Failed to dead-letter record %s: %v", p.name, kr.Kinesis.SequenceNumber, dlErr)
+				return records, &kr
+			}
+		}
+		// Non-INSERT records (e.g., MODIFY, REMOVE) and dead-lettered ones are skipped
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// checkpoints loads the checkpoints of the batch's aggregates if gap detection is enabled
+func (p *BatchProcessor) checkpoints(ctx context.Context, records []record) (map[string]int, error) {
+	if p.Checkpoints == nil {
+		return nil, nil
+	}
+	seen := make(map[string]bool)
+	var aggregateIDs []string
+	for _, r := range records {
+		if r.event != nil && !seen[r.event.AggregateID] {
+			seen[r.event.AggregateID] = true
+			aggregateIDs = append(aggregateIDs, r.event.AggregateID)
+		}
+	}
+	if len(aggregateIDs) == 0 {
+		return map[string]int{}, nil
+	}
+	checkpoints, err := p.Checkpoints(ctx, aggregateIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
+
+// handle checks the event for a version gap and passes it to the handler
+func (p *BatchProcessor) handle(ctx context.Context, handler deadletter.EventHandler, r *record, checkpoints map[string]int) error {
+	if last, ok := checkpoints[r.event.AggregateID]; ok && r.event.Version > last+1 {
+		return fmt.Errorf("%w: aggregate %s is at version %d, got version %d",
+			ErrVersionGap, r.event.AggregateID, last, r.event.Version)
+	}
+	return handler.HandleEvent(ctx, []byte(r.event.AggregateID), r.eventJSON)
+}
+
+func failureResponse(sequenceNumber string) events.KinesisEventResponse {
+	return events.KinesisEventResponse{
+		BatchItemFailures: []events.KinesisBatchItemFailure{{ItemIdentifier: sequenceNumber}},
+	}
+}
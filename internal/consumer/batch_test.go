@@ -0,0 +1,353 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/example/ec-event-driven/internal/deadletter"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHandler records the events it handles and fails the ones in errs
+type fakeHandler struct {
+	handled []string
+	errs    map[string]error // event ID -> error
+}
+
+func (h *fakeHandler) HandleEvent(_ context.Context, _, value []byte) error {
+	var event store.Event
+	if err := json.Unmarshal(value, &event); err != nil {
+		return err
+	}
+	if err := h.errs[event.ID]; err != nil {
+		return err
+	}
+	h.handled = append(h.handled, event.ID)
+	return nil
+}
+
+// failingStore is a dead-letter store that cannot record failures
+type failingStore struct {
+	*deadletter.MemoryStore
+}
+
+func (s failingStore) RecordFailure(context.Context, deadletter.Failure, int) (*deadletter.Entry, error) {
+	return nil, errors.New("database unavailable")
+}
+
+// streamRecord builds the Kinesis record of a DynamoDB stream INSERT whose
+// event ID is "<aggregateID>-v<version>"
+func streamRecord(seq, aggregateID string, version int) events.KinesisEventRecord {
+	data := fmt.Sprintf(`{"eventName":"INSERT","dynamodb":{"NewImage":{
+		"id":{"S":"%s-v%d"},"aggregate_id":{"S":"%s"},"aggregate_type":{"S":"Order"},
+		"event_type":{"S":"OrderPlaced"},"data":{"S":"{}"},"version":{"N":"%d"}}}}`,
+		aggregateID, version, aggregateID, version)
+	return events.KinesisEventRecord{Kinesis: events.KinesisRecord{SequenceNumber: seq, Data: []byte(data)}}
+}
+
+func batch(records ...events.KinesisEventRecord) events.KinesisEvent {
+	return events.KinesisEvent{Records: records}
+}
+
+func failedItems(resp events.KinesisEventResponse) []string {
+	var ids []string
+	for _, f := range resp.BatchItemFailures {
+		ids = append(ids, f.ItemIdentifier)
+	}
+	return ids
+}
+
+func TestBatchProcessor_AllSucceed(t *testing.T) {
+	handler := &fakeHandler{}
+	p := NewBatchProcessor("Test", handler, deadletter.NewGuard(deadletter.ConsumerProjector, deadletter.NewMemoryStore()))
+
+	resp := p.Process(context.Background(), batch(
+		streamRecord("1", "order-1", 1),
+		streamRecord("2", "order-2", 1),
+		streamRecord("3", "order-1", 2),
+	))
+
+	assert.Empty(t, resp.BatchItemFailures)
+	assert.Equal(t, []string{"order-1-v1", "order-2-v1", "order-1-v2"}, handler.handled)
+}
+
+func TestBatchProcessor_HoldsBackFailedAggregate(t *testing.T) {
+	handler := &fakeHandler{errs: map[string]error{"order-1-v1": errors.New("timeout")}}
+	s := deadletter.NewMemoryStore()
+	p := NewBatchProcessor("Test", handler, deadletter.NewGuard(deadletter.ConsumerProjector, s))
+
+	resp := p.Process(context.Background(), batch(
+		streamRecord("1", "order-1", 1), // e.g. OrderPlaced
+		streamRecord("2", "order-2", 1),
+		streamRecord("3", "order-1", 2), // e.g. OrderCancelled
+		streamRecord("4", "order-2", 2),
+	))
+
+	// The later event of order-1 must not overtake the failed one, while
+	// order-2 is unaffected
+	assert.Equal(t, []string{"order-2-v1", "order-2-v2"}, handler.handled)
+	assert.Equal(t, []string{"1"}, failedItems(resp))
+	entries, _ := s.List(context.Background(), deadletter.Filter{Status: deadletter.StatusRetrying})
+	require.Len(t, entries, 1)
+	assert.Equal(t, "1", entries[0].SourceID)
+}
+
+func TestBatchProcessor_ReportsFirstFailedRecord(t *testing.T) {
+	handler := &fakeHandler{errs: map[string]error{
+		"order-2-v1": errors.New("timeout"),
+		"order-1-v2": errors.New("timeout"),
+	}}
+	p := NewBatchProcessor("Test", handler, deadletter.NewGuard(deadletter.ConsumerProjector, deadletter.NewMemoryStore()))
+
+	resp := p.Process(context.Background(), batch(
+		streamRecord("1", "order-1", 1),
+		streamRecord("2", "order-2", 1),
+		streamRecord("3", "order-1", 2),
+	))
+
+	// Kinesis retries from the lowest reported sequence number, so only the
+	// first failure is reported
+	assert.Equal(t, []string{"2"}, failedItems(resp))
+	assert.Equal(t, []string{"order-1-v1"}, handler.handled)
+}
+
+func TestBatchProcessor_DeadLetteredEventDoesNotBlockAggregate(t *testing.T) {
+	poisonErr := errors.New("malformed event")
+	handler := &fakeHandler{errs: map[string]error{"order-1-v1": poisonErr}}
+	s := deadletter.NewMemoryStore()
+	guard := deadletter.NewGuard(deadletter.ConsumerNotifier, s)
+	guard.IsPoison = func(err error) bool { return errors.Is(err, poisonErr) }
+	p := NewBatchProcessor("Test", handler, guard)
+
+	resp := p.Process(context.Background(), batch(
+		streamRecord("1", "order-1", 1),
+		streamRecord("2", "order-1", 2),
+	))
+
+	assert.Empty(t, resp.BatchItemFailures)
+	assert.Equal(t, []string{"order-1-v2"}, handler.handled)
+	entries, _ := s.List(context.Background(), deadletter.Filter{Status: deadletter.StatusDead})
+	require.Len(t, entries, 1)
+	assert.Equal(t, "order-1-v1", entries[0].EventID)
+}
+
+func TestBatchProcessor_DetectsVersionGap(t *testing.T) {
+	handler := &fakeHandler{}
+	s := deadletter.NewMemoryStore()
+	p := NewBatchProcessor("Test", handler, deadletter.NewGuard(deadletter.ConsumerProjector, s))
+	p.Checkpoints = func(_ context.Context, aggregateIDs []string) (map[string]int, error) {
+		assert.ElementsMatch(t, []string{"order-1", "order-2", "order-3"}, aggregateIDs)
+		return map[string]int{"order-1": 1, "order-2": 1}, nil
+	}
+
+	resp := p.Process(context.Background(), batch(
+		streamRecord("1", "order-1", 2),
+		streamRecord("2", "order-2", 3), // order-2 v2 is missing
+		streamRecord("3", "order-1", 3),
+		streamRecord("4", "order-3", 1),
+		streamRecord("5", "order-2", 4),
+	))
+
+	assert.Equal(t, []string{"order-1-v2", "order-1-v3", "order-3-v1"}, handler.handled)
+	assert.Equal(t, []string{"2"}, failedItems(resp))
+	entries, _ := s.List(context.Background(), deadletter.Filter{Status: deadletter.StatusRetrying})
+	require.Len(t, entries, 1)
+	assert.Contains(t, entries[0].Error, ErrVersionGap.Error())
+}
+
+func TestBatchProcessor_RedeliveredEventsAreNotGaps(t *testing.T) {
+	handler := &fakeHandler{}
+	p := NewBatchProcessor("Test", handler, deadletter.NewGuard(deadletter.ConsumerProjector, deadletter.NewMemoryStore()))
+	p.Checkpoints = func(context.Context, []string) (map[string]int, error) {
+		return map[string]int{"order-1": 2}, nil
+	}
+
+	resp := p.Process(context.Background(), batch(
+		streamRecord("1", "order-1", 2),
+		streamRecord("2", "order-1", 3),
+	))
+
+	// The handler skips the already applied version itself
+	assert.Empty(t, resp.BatchItemFailures)
+	assert.Equal(t, []string{"order-1-v2", "order-1-v3"}, handler.handled)
+}
+
+func TestBatchProcessor_CheckpointErrorRetriesBatch(t *testing.T) {
+	handler := &fakeHandler{}
+	p := NewBatchProcessor("Test", handler, deadletter.NewGuard(deadletter.ConsumerProjector, deadletter.NewMemoryStore()))
+	p.Checkpoints = func(context.Context, []string) (map[string]int, error) {
+		return nil, errors.New("read store unavailable")
+	}
+
+	resp := p.Process(context.Background(), batch(
+		streamRecord("1", "order-1", 1),
+		streamRecord("2", "order-2", 1),
+	))
+
+	assert.Equal(t, []string{"1"}, failedItems(resp))
+	assert.Empty(t, handler.handled)
+}
+
+func TestBatchProcessor_UndecodableRecord(t *testing.T) {
+	undecodable := events.KinesisEventRecord{Kinesis: events.KinesisRecord{SequenceNumber: "2", Data: []byte("not json")}}
+
+	t.Run("dead-lettered and skipped", func(t *testing.T) {
+		handler := &fakeHandler{}
+		s := deadletter.NewMemoryStore()
+		p := NewBatchProcessor("Test", handler, deadletter.NewGuard(deadletter.ConsumerNotifier, s))
+
+		resp := p.Process(context.Background(), batch(streamRecord("1", "order-1", 1), undecodable, streamRecord("3", "order-2", 1)))
+
+		assert.Empty(t, resp.BatchItemFailures)
+		assert.Equal(t, []string{"order-1-v1", "order-2-v1"}, handler.handled)
+		entries, _ := s.List(context.Background(), deadletter.Filter{Status: deadletter.StatusDead})
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("retried when it cannot be dead-lettered", func(t *testing.T) {
+		handler := &fakeHandler{}
+		p := NewBatchProcessor("Test", handler,
+			deadletter.NewGuard(deadletter.ConsumerNotifier, failingStore{deadletter.NewMemoryStore()}))
+
+		resp := p.Process(context.Background(), batch(streamRecord("1", "order-1", 1), undecodable, streamRecord("3", "order-2", 1)))
+
+		// The aggregates of the records after it are unknown
+		assert.Equal(t, []string{"2"}, failedItems(resp))
+		assert.Equal(t, []string{"order-1-v1"}, handler.handled)
+	})
+}
+
+func TestBatchProcessor_SucceededRecordsForgetFailedAttempts(t *testing.T) {
+	handler := &fakeHandler{errs: map[string]error{"order-2-v1": errors.New("timeout")}}
+	s := deadletter.NewMemoryStore()
+	p := NewBatchProcessor("Test", handler, deadletter.NewGuard(deadletter.ConsumerProjector, s))
+	records := batch(streamRecord("1", "order-1", 1), streamRecord("2", "order-2", 1))
+
+	p.Process(context.Background(), records)
+	entries, _ := s.List(context.Background(), deadletter.Filter{})
+	require.Len(t, entries, 1)
+
+	delete(handler.errs, "order-2-v1")
+	resp := p.Process(context.Background(), records)
+
+	assert.Empty(t, resp.BatchItemFailures)
+	entries, _ = s.List(context.Background(), deadletter.Filter{})
+	assert.Empty(t, entries)
+}
+
+// fakeUnitOfWork records the events handled in each unit of work and fails
+// the commit with commitErr
+type fakeUnitOfWork struct {
+	committed [][]string
+	commitErr error
+}
+
+func (u *fakeUnitOfWork) run(ctx context.Context, fn func(handler deadletter.EventHandler) error) error {
+	tx := &fakeHandler{errs: map[string]error{"order-2-v1": errors.New("timeout")}}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if u.commitErr != nil {
+		return u.commitErr
+	}
+	u.committed = append(u.committed, tx.handled)
+	return nil
+}
+
+func TestBatchProcessor_UnitOfWork(t *testing.T) {
+	uow := &fakeUnitOfWork{}
+	p := NewBatchProcessor("Test", &fakeHandler{}, deadletter.NewGuard(deadletter.ConsumerProjector, deadletter.NewMemoryStore()))
+	p.UnitOfWork = uow.run
+
+	resp := p.Process(context.Background(), batch(
+		streamRecord("1", "order-1", 1),
+		streamRecord("2", "order-2", 1),
+		streamRecord("3", "order-1", 2),
+		streamRecord("4", "order-2", 2),
+	))
+
+	// The batch is committed once, without the failed aggregate's events
+	assert.Equal(t, [][]string{{"order-1-v1", "order-1-v2"}}, uow.committed)
+	assert.Equal(t, []string{"2"}, failedItems(resp))
+}
+
+func TestBatchProcessor_UnitOfWorkCommitErrorRetriesBatch(t *testing.T) {
+	uow := &fakeUnitOfWork{commitErr: errors.New("connection reset")}
+	s := deadletter.NewMemoryStore()
+	p := NewBatchProcessor("Test", &fakeHandler{}, deadletter.NewGuard(deadletter.ConsumerProjector, s))
+	p.UnitOfWork = uow.run
+
+	resp := p.Process(context.Background(), batch(
+		streamRecord("1", "order-1", 1),
+		streamRecord("2", "order-2", 1),
+	))
+
+	assert.Empty(t, uow.committed)
+	assert.Equal(t, []string{"1"}, failedItems(resp))
+}
+
+// checkpointingHandler is a fakeHandler that tracks the last handled or
+// skipped version of each aggregate, like the projector
+type checkpointingHandler struct {
+	fakeHandler
+	checkpoints map[string]int
+}
+
+func (h *checkpointingHandler) HandleEvent(ctx context.Context, key, value []byte) error {
+	if err := h.fakeHandler.HandleEvent(ctx, key, value); err != nil {
+		return err
+	}
+	return h.SkipEvent(ctx, key, value)
+}
+
+func (h *checkpointingHandler) SkipEvent(_ context.Context, _, value []byte) error {
+	var event store.Event
+	if err := json.Unmarshal(value, &event); err != nil {
+		return err
+	}
+	h.checkpoints[event.AggregateID] = max(h.checkpoints[event.AggregateID], event.Version)
+	return nil
+}
+
+func (h *checkpointingHandler) Checkpoints(_ context.Context, aggregateIDs []string) (map[string]int, error) {
+	checkpoints := make(map[string]int)
+	for _, id := range aggregateIDs {
+		if v, ok := h.checkpoints[id]; ok {
+			checkpoints[id] = v
+		}
+	}
+	return checkpoints, nil
+}
+
+func TestBatchProcessor_DiscardedEventIsNotAGap(t *testing.T) {
+	ctx := context.Background()
+	poisonErr := errors.New("malformed event")
+	handler := &checkpointingHandler{
+		fakeHandler: fakeHandler{errs: map[string]error{"order-1-v2": poisonErr}},
+		checkpoints: map[string]int{},
+	}
+	s := deadletter.NewMemoryStore()
+	guard := deadletter.NewGuard(deadletter.ConsumerProjector, s)
+	guard.IsPoison = func(err error) bool { return errors.Is(err, poisonErr) }
+	p := NewBatchProcessor("Test", handler, guard)
+	p.Checkpoints = handler.Checkpoints
+
+	p.Process(ctx, batch(streamRecord("1", "order-1", 1), streamRecord("2", "order-1", 2)))
+	dead, _ := s.List(ctx, deadletter.Filter{Status: deadletter.StatusDead})
+	require.Len(t, dead, 1)
+
+	_, err := deadletter.NewService(s, map[string]deadletter.EventHandler{deadletter.ConsumerProjector: handler}).
+		Discard(ctx, dead[0].ID)
+	require.NoError(t, err)
+
+	resp := p.Process(ctx, batch(streamRecord("3", "order-1", 3)))
+
+	assert.Empty(t, resp.BatchItemFailures)
+	assert.Equal(t, []string{"order-1-v1", "order-1-v3"}, handler.handled)
+}
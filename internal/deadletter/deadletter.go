@@ -0,0 +1,117 @@
+// Package deadletter keeps the stream records that an event consumer (the
+// projector or the notifier) gave up on, so that admins can inspect them and
+// redrive or discard them instead of Kinesis retrying them until they expire.
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Consumers whose failed events are dead-lettered
+const (
+	ConsumerProjector = "projector"
+	ConsumerNotifier  = "notifier"
+)
+
+// Status is the state of a dead-letter entry
+type Status string
+
+const (
+	// StatusRetrying entries have failed fewer than the maximum number of
+	// attempts and are still being retried by Kinesis
+	StatusRetrying Status = "retrying"
+	// StatusDead entries were given up on and wait for an admin
+	StatusDead Status = "dead"
+	// StatusRedriven entries were processed successfully by a redrive
+	StatusRedriven Status = "redriven"
+	// StatusDiscarded entries were dropped by an admin
+	StatusDiscarded Status = "discarded"
+)
+
+// PayloadFormat tells how an entry's payload is encoded
+type PayloadFormat string
+
+const (
+	// FormatEvent payloads are a JSON-encoded store.Event
+	FormatEvent PayloadFormat = "event"
+	// FormatKinesis payloads are the raw Kinesis record data (a DynamoDB
+	// stream record) that could not be converted to an event
+	FormatKinesis PayloadFormat = "kinesis"
+)
+
+// DefaultMaxAttempts is the number of failed attempts after which an event is dead-lettered
+const DefaultMaxAttempts = 3
+
+var (
+	ErrNotFound        = errors.New("dead-letter entry not found")
+	ErrNotRedrivable   = errors.New("dead-letter entry cannot be redriven or discarded")
+	ErrUnknownConsumer = errors.New("unknown consumer")
+	// ErrEarlierVersionDead is returned when redriving or skipping an event
+	// while an earlier event of its aggregate is dead-lettered for the same consumer
+	ErrEarlierVersionDead = errors.New("an earlier event of the aggregate is dead-lettered: redrive or discard it first")
+	// ErrAlreadyApplied is returned when the consumer skipped a redriven event
+	// because it had already applied it or a later version of its aggregate
+	ErrAlreadyApplied = errors.New("event was already applied by the consumer: discard the entry")
+)
+
+// Entry is a stream record that a consumer failed to process
+type Entry struct {
+	ID            string        `json:"id"`
+	Consumer      string        `json:"consumer"`
+	SourceID      string        `json:"source_id"` // Kinesis sequence number, or "position-N" from the event bus
+	EventID       string        `json:"event_id,omitempty"`
+	AggregateID   string        `json:"aggregate_id,omitempty"`
+	EventType     string        `json:"event_type,omitempty"`
+	PayloadFormat PayloadFormat `json:"payload_format"`
+	Payload       []byte        `json:"-"`
+	Error         string        `json:"error"`
+	Attempts      int           `json:"attempts"`
+	Status        Status        `json:"status"`
+	FirstFailedAt time.Time     `json:"first_failed_at"`
+	LastFailedAt  time.Time     `json:"last_failed_at"`
+	ResolvedAt    *time.Time    `json:"resolved_at,omitempty"`
+}
+
+// Failure is a failed attempt to process a stream record
+type Failure struct {
+	Consumer      string
+	SourceID      string
+	EventID       string
+	AggregateID   string
+	EventType     string
+	PayloadFormat PayloadFormat
+	Payload       []byte
+	Err           error
+	// Poison failures are dead-lettered on the first attempt
+	Poison bool
+}
+
+// Filter selects entries to list. Zero values match everything.
+type Filter struct {
+	Consumer    string
+	Status      Status
+	AggregateID string
+	Limit       int
+}
+
+// Store persists dead-letter entries
+type Store interface {
+	// RecordFailure counts a failed attempt of f's record and returns its
+	// entry. The entry becomes StatusDead once the record is poison or has
+	// failed maxAttempts times, and is StatusRetrying before that.
+	RecordFailure(ctx context.Context, f Failure, maxAttempts int) (*Entry, error)
+	// Resolve forgets the failed attempts of records that were since processed.
+	// Entries that are no longer retrying are kept.
+	Resolve(ctx context.Context, consumer string, sourceIDs ...string) error
+	// List returns matching entries, most recently failed first
+	List(ctx context.Context, filter Filter) ([]Entry, error)
+	// Get returns an entry by ID, or ErrNotFound
+	Get(ctx context.Context, id string) (*Entry, error)
+	// MarkResolved sets the status of a dead entry to StatusRedriven or
+	// StatusDiscarded. It returns ErrNotRedrivable if the entry is not dead.
+	MarkResolved(ctx context.Context, id string, status Status) error
+	// RecordRedriveFailure counts a failed redrive of a dead entry
+	RecordRedriveFailure(ctx context.Context, id string, err error) error
+}
@@ -0,0 +1,106 @@
+package deadletter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+)
+
+// Guard dead-letters the Kinesis records a consumer keeps failing on, so
+// that they stop blocking the shard
+type Guard struct {
+	Consumer    string
+	Store       Store
+	MaxAttempts int
+	// IsPoison reports errors that will fail on every attempt, which are
+	// dead-lettered immediately (nil: every error is retried MaxAttempts times)
+	IsPoison func(error) bool
+}
+
+// NewGuard creates a Guard for consumer with DefaultMaxAttempts
+func NewGuard(consumer string, s Store) *Guard {
+	return &Guard{Consumer: consumer, Store: s, MaxAttempts: DefaultMaxAttempts}
+}
+
+// MaxAttemptsFromEnv reads the maximum number of attempts from
+// DEAD_LETTER_MAX_ATTEMPTS, defaulting to DefaultMaxAttempts
+func MaxAttemptsFromEnv(getenv func(string) string) (int, error) {
+	v := getenv("DEAD_LETTER_MAX_ATTEMPTS")
+	if v == "" {
+		return DefaultMaxAttempts, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid DEAD_LETTER_MAX_ATTEMPTS %q (expected a positive integer)", v)
+	}
+	return n, nil
+}
+
+// Undecodable dead-letters a record that could not be converted to an event.
+// It returns an error only if the record could not be stored, in which case
+// it should be retried.
+func (g *Guard) Undecodable(ctx context.Context, record events.KinesisEventRecord, err error) error {
+	entry, storeErr := g.Store.RecordFailure(ctx, Failure{
+		Consumer:      g.Consumer,
+		SourceID:      record.Kinesis.SequenceNumber,
+		PayloadFormat: FormatKinesis,
+		Payload:       record.Kinesis.Data,
+		Err:           err,
+		Poison:        true,
+	}, g.MaxAttempts)
+	if storeErr != nil {
+		return storeErr
+	}
+	log.Printf("[DeadLetter] %s: dead-lettered undecodable record %s as %s: %v", g.Consumer, entry.SourceID, entry.ID, err)
+	return nil
+}
+
+// Failed counts a failed attempt to process event and reports whether it was
+// dead-lettered, in which case the consumer should skip it. Otherwise the
+// record should be retried.
+func (g *Guard) Failed(ctx context.Context, record events.KinesisEventRecord, event *store.Event, eventJSON []byte, err error) (bool, error) {
+	return g.FailedEvent(ctx, record.Kinesis.SequenceNumber, event, eventJSON, err)
+}
+
+// FailedEvent is Failed for an event identified in its source by sourceID,
+// for consumers that are not fed by Kinesis
+func (g *Guard) FailedEvent(ctx context.Context, sourceID string, event *store.Event, eventJSON []byte, err error) (bool, error) {
+	entry, storeErr := g.Store.RecordFailure(ctx, Failure{
+		Consumer:      g.Consumer,
+		SourceID:      sourceID,
+		EventID:       event.ID,
+		AggregateID:   event.AggregateID,
+		EventType:     event.EventType,
+		PayloadFormat: FormatEvent,
+		Payload:       eventJSON,
+		Err:           err,
+		Poison:        g.IsPoison != nil && g.IsPoison(err),
+	}, g.MaxAttempts)
+	if storeErr != nil {
+		return false, storeErr
+	}
+	if entry.Status != StatusDead {
+		return false, nil
+	}
+	log.Printf("[DeadLetter] %s: dead-lettered event %s (%s) after %d attempts as %s: %v",
+		g.Consumer, event.ID, event.EventType, entry.Attempts, entry.ID, err)
+	return true, nil
+}
+
+// Succeeded forgets the failed attempts of records that have now been processed
+func (g *Guard) Succeeded(ctx context.Context, records ...events.KinesisEventRecord) error {
+	sourceIDs := make([]string, len(records))
+	for i, record := range records {
+		sourceIDs[i] = record.Kinesis.SequenceNumber
+	}
+	return g.Resolved(ctx, sourceIDs...)
+}
+
+// Resolved is Succeeded for events identified in their source by sourceIDs
+func (g *Guard) Resolved(ctx context.Context, sourceIDs ...string) error {
+	return g.Store.Resolve(ctx, g.Consumer, sourceIDs...)
+}
@@ -0,0 +1,120 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func kinesisRecord(sequenceNumber string, data []byte) events.KinesisEventRecord {
+	return events.KinesisEventRecord{Kinesis: events.KinesisRecord{SequenceNumber: sequenceNumber, Data: data}}
+}
+
+func testEvent() (*store.Event, []byte) {
+	event := &store.Event{
+		ID:            "event-1",
+		AggregateID:   "order-1",
+		AggregateType: "Order",
+		EventType:     "OrderPlaced",
+		Data:          json.RawMessage(`{"order_id":"order-1"}`),
+		Version:       1,
+	}
+	eventJSON, _ := json.Marshal(event)
+	return event, eventJSON
+}
+
+func TestGuard_DeadLettersAfterMaxAttempts(t *testing.T) {
+	s := NewMemoryStore()
+	guard := NewGuard(ConsumerProjector, s)
+	ctx := context.Background()
+	record := kinesisRecord("seq-1", nil)
+	event, eventJSON := testEvent()
+	handlerErr := errors.New("read store unavailable")
+
+	for range DefaultMaxAttempts - 1 {
+		deadLettered, err := guard.Failed(ctx, record, event, eventJSON, handlerErr)
+		require.NoError(t, err)
+		assert.False(t, deadLettered)
+	}
+	deadLettered, err := guard.Failed(ctx, record, event, eventJSON, handlerErr)
+	require.NoError(t, err)
+	assert.True(t, deadLettered)
+
+	entries, _ := s.List(ctx, Filter{Status: StatusDead})
+	require.Len(t, entries, 1)
+	assert.Equal(t, DefaultMaxAttempts, entries[0].Attempts)
+	assert.Equal(t, "event-1", entries[0].EventID)
+	assert.Equal(t, "order-1", entries[0].AggregateID)
+	assert.Equal(t, FormatEvent, entries[0].PayloadFormat)
+	assert.JSONEq(t, string(eventJSON), string(entries[0].Payload))
+	assert.Equal(t, handlerErr.Error(), entries[0].Error)
+}
+
+func TestGuard_PoisonIsDeadLetteredImmediately(t *testing.T) {
+	s := NewMemoryStore()
+	guard := NewGuard(ConsumerProjector, s)
+	poisonErr := errors.New("malformed event")
+	guard.IsPoison = func(err error) bool { return errors.Is(err, poisonErr) }
+	event, eventJSON := testEvent()
+
+	deadLettered, err := guard.Failed(context.Background(), kinesisRecord("seq-1", nil), event, eventJSON, poisonErr)
+
+	require.NoError(t, err)
+	assert.True(t, deadLettered)
+}
+
+func TestGuard_Undecodable(t *testing.T) {
+	s := NewMemoryStore()
+	guard := NewGuard(ConsumerNotifier, s)
+	ctx := context.Background()
+
+	err := guard.Undecodable(ctx, kinesisRecord("seq-1", []byte("not json")), errors.New("invalid character"))
+
+	require.NoError(t, err)
+	entries, _ := s.List(ctx, Filter{Consumer: ConsumerNotifier, Status: StatusDead})
+	require.Len(t, entries, 1)
+	assert.Equal(t, FormatKinesis, entries[0].PayloadFormat)
+	assert.Equal(t, []byte("not json"), entries[0].Payload)
+	assert.Equal(t, 1, entries[0].Attempts)
+}
+
+func TestGuard_SucceededForgetsRetryingRecord(t *testing.T) {
+	s := NewMemoryStore()
+	guard := NewGuard(ConsumerProjector, s)
+	ctx := context.Background()
+	record := kinesisRecord("seq-1", nil)
+	event, eventJSON := testEvent()
+
+	_, err := guard.Failed(ctx, record, event, eventJSON, errors.New("timeout"))
+	require.NoError(t, err)
+	require.NoError(t, guard.Succeeded(ctx, record, kinesisRecord("seq-2", nil)))
+
+	entries, _ := s.List(ctx, Filter{})
+	assert.Empty(t, entries)
+}
+
+func TestMaxAttemptsFromEnv(t *testing.T) {
+	env := map[string]string{}
+	getenv := func(key string) string { return env[key] }
+
+	n, err := MaxAttemptsFromEnv(getenv)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultMaxAttempts, n)
+
+	env["DEAD_LETTER_MAX_ATTEMPTS"] = "5"
+	n, err = MaxAttemptsFromEnv(getenv)
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+
+	for _, invalid := range []string{"0", "-1", "three"} {
+		env["DEAD_LETTER_MAX_ATTEMPTS"] = invalid
+		_, err = MaxAttemptsFromEnv(getenv)
+		assert.Error(t, err, invalid)
+	}
+}
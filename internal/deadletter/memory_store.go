@@ -0,0 +1,147 @@
+package deadletter
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore is an in-memory Store
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*Entry // id -> entry
+}
+
+// NewMemoryStore creates a new MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*Entry)}
+}
+
+func (s *MemoryStore) RecordFailure(_ context.Context, f Failure, maxAttempts int) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	entry := s.findLocked(f.Consumer, f.SourceID)
+	if entry == nil {
+		entry = &Entry{
+			ID:            uuid.New().String(),
+			Consumer:      f.Consumer,
+			SourceID:      f.SourceID,
+			EventID:       f.EventID,
+			AggregateID:   f.AggregateID,
+			EventType:     f.EventType,
+			PayloadFormat: f.PayloadFormat,
+			Payload:       f.Payload,
+			FirstFailedAt: now,
+		}
+		s.entries[entry.ID] = entry
+	}
+	entry.Error = errorString(f.Err)
+	entry.Attempts++
+	entry.LastFailedAt = now
+	if f.Poison || entry.Attempts >= maxAttempts {
+		entry.Status = StatusDead
+	} else {
+		entry.Status = StatusRetrying
+	}
+	copied := *entry
+	return &copied, nil
+}
+
+func (s *MemoryStore) Resolve(_ context.Context, consumer string, sourceIDs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sourceID := range sourceIDs {
+		if entry := s.findLocked(consumer, sourceID); entry != nil && entry.Status == StatusRetrying {
+			delete(s.entries, entry.ID)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) List(_ context.Context, filter Filter) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []Entry
+	for _, entry := range s.entries {
+		if filter.Consumer != "" && entry.Consumer != filter.Consumer {
+			continue
+		}
+		if filter.Status != "" && entry.Status != filter.Status {
+			continue
+		}
+		if filter.AggregateID != "" && entry.AggregateID != filter.AggregateID {
+			continue
+		}
+		entries = append(entries, *entry)
+	}
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return b.LastFailedAt.Compare(a.LastFailedAt)
+	})
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+	return entries, nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, id string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *entry
+	return &copied, nil
+}
+
+func (s *MemoryStore) MarkResolved(_ context.Context, id string, status Status) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, err := s.deadLocked(id)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	entry.Status = status
+	entry.ResolvedAt = &now
+	return nil
+}
+
+func (s *MemoryStore) RecordRedriveFailure(_ context.Context, id string, redriveErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, err := s.deadLocked(id)
+	if err != nil {
+		return err
+	}
+	entry.Error = errorString(redriveErr)
+	entry.Attempts++
+	entry.LastFailedAt = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) findLocked(consumer, sourceID string) *Entry {
+	for _, entry := range s.entries {
+		if entry.Consumer == consumer && entry.SourceID == sourceID {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) deadLocked(id string) (*Entry, error) {
+	entry, ok := s.entries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if entry.Status != StatusDead {
+		return nil, ErrNotRedrivable
+	}
+	return entry, nil
+}
@@ -0,0 +1,182 @@
+package deadletter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// PostgresStore stores dead-letter entries in the dead_letter_events table
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a new PostgresStore
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+const entryColumns = `id, consumer, source_id, event_id, aggregate_id, event_type, payload_format, payload,
+	error, attempts, status, first_failed_at, last_failed_at, resolved_at`
+
+// RecordFailure inserts or updates the entry of f's record in one statement,
+// so concurrent attempts are counted correctly
+func (s *PostgresStore) RecordFailure(ctx context.Context, f Failure, maxAttempts int) (*Entry, error) {
+	row := s.db.QueryRowContext(ctx, `
+		INSERT INTO dead_letter_events (id, consumer, source_id, event_id, aggregate_id, event_type,
+			payload_format, payload, error, attempts, status, first_failed_at, last_failed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 1,
+			CASE WHEN $10 OR 1 >= $11 THEN 'dead' ELSE 'retrying' END, NOW(), NOW())
+		ON CONFLICT (consumer, source_id) DO UPDATE SET
+			error = EXCLUDED.error,
+			attempts = dead_letter_events.attempts + 1,
+			status = CASE WHEN $10 OR dead_letter_events.attempts + 1 >= $11 THEN 'dead' ELSE 'retrying' END,
+			last_failed_at = EXCLUDED.last_failed_at
+		RETURNING `+entryColumns,
+		uuid.New().String(), f.Consumer, f.SourceID, f.EventID, f.AggregateID, f.EventType,
+		string(f.PayloadFormat), f.Payload, errorString(f.Err), f.Poison, maxAttempts)
+	entry, err := scanEntry(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record failure of %s record %s: %w", f.Consumer, f.SourceID, err)
+	}
+	return entry, nil
+}
+
+// Resolve deletes the retrying entries of records
+func (s *PostgresStore) Resolve(ctx context.Context, consumer string, sourceIDs ...string) error {
+	if len(sourceIDs) == 0 {
+		return nil
+	}
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM dead_letter_events WHERE consumer = $1 AND source_id = ANY($2) AND status = 'retrying'
+	`, consumer, pq.Array(sourceIDs))
+	if err != nil {
+		return fmt.Errorf("failed to resolve %d %s records: %w", len(sourceIDs), consumer, err)
+	}
+	return nil
+}
+
+// List returns matching entries, most recently failed first
+func (s *PostgresStore) List(ctx context.Context, filter Filter) ([]Entry, error) {
+	var conditions []string
+	var args []any
+	if filter.Consumer != "" {
+		args = append(args, filter.Consumer)
+		conditions = append(conditions, fmt.Sprintf("consumer = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, string(filter.Status))
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.AggregateID != "" {
+		args = append(args, filter.AggregateID)
+		conditions = append(conditions, fmt.Sprintf("aggregate_id = $%d", len(args)))
+	}
+	query := "SELECT " + entryColumns + " FROM dead_letter_events"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY last_failed_at DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead-letter entries: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []Entry
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dead-letter entry: %w", err)
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
+
+// Get returns an entry by ID
+func (s *PostgresStore) Get(ctx context.Context, id string) (*Entry, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrNotFound
+	}
+	row := s.db.QueryRowContext(ctx, "SELECT "+entryColumns+" FROM dead_letter_events WHERE id = $1", id)
+	entry, err := scanEntry(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead-letter entry %s: %w", id, err)
+	}
+	return entry, nil
+}
+
+// MarkResolved sets the status of a dead entry
+func (s *PostgresStore) MarkResolved(ctx context.Context, id string, status Status) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE dead_letter_events SET status = $2, resolved_at = NOW() WHERE id = $1 AND status = 'dead'
+	`, id, string(status))
+	if err != nil {
+		return fmt.Errorf("failed to update dead-letter entry %s: %w", id, err)
+	}
+	return s.checkUpdated(ctx, id, result)
+}
+
+// RecordRedriveFailure counts a failed redrive of a dead entry
+func (s *PostgresStore) RecordRedriveFailure(ctx context.Context, id string, redriveErr error) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE dead_letter_events SET error = $2, attempts = attempts + 1, last_failed_at = NOW()
+		WHERE id = $1 AND status = 'dead'
+	`, id, errorString(redriveErr))
+	if err != nil {
+		return fmt.Errorf("failed to update dead-letter entry %s: %w", id, err)
+	}
+	return s.checkUpdated(ctx, id, result)
+}
+
+// checkUpdated tells apart a missing entry and one that is not dead when an update matched no rows
+func (s *PostgresStore) checkUpdated(ctx context.Context, id string, result sql.Result) error {
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return ErrNotRedrivable
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEntry(row rowScanner) (*Entry, error) {
+	var e Entry
+	var format, status string
+	var resolvedAt sql.NullTime
+	if err := row.Scan(&e.ID, &e.Consumer, &e.SourceID, &e.EventID, &e.AggregateID, &e.EventType,
+		&format, &e.Payload, &e.Error, &e.Attempts, &status, &e.FirstFailedAt, &e.LastFailedAt, &resolvedAt); err != nil {
+		return nil, err
+	}
+	e.PayloadFormat = PayloadFormat(format)
+	e.Status = Status(status)
+	if resolvedAt.Valid {
+		e.ResolvedAt = &resolvedAt.Time
+	}
+	return &e, nil
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
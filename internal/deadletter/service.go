@@ -0,0 +1,212 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/example/ec-event-driven/internal/infrastructure/kinesis"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+)
+
+// EventHandler processes a JSON-encoded event, like Projector.HandleEvent
+// and notification.Handler.HandleEvent
+type EventHandler interface {
+	HandleEvent(ctx context.Context, key, value []byte) error
+}
+
+// ApplyingHandler is an EventHandler that reports whether it applied an event
+// or skipped it as already applied, like Projector.ApplyEvent
+type ApplyingHandler interface {
+	EventHandler
+	ApplyEvent(ctx context.Context, key, value []byte) (bool, error)
+}
+
+// SkippingHandler is an EventHandler that tracks the last version it handled
+// of each aggregate and can move past an event without handling it, like
+// Projector.SkipEvent. Discarding an entry skips its event, so that the later
+// events of the aggregate are not held back as version gaps.
+type SkippingHandler interface {
+	EventHandler
+	SkipEvent(ctx context.Context, key, value []byte) error
+}
+
+// Service lets admins inspect, redrive and discard dead-letter entries
+type Service struct {
+	store    Store
+	handlers map[string]EventHandler // consumer -> handler
+}
+
+// NewService creates a Service that redrives the entries of each consumer through its handler
+func NewService(s Store, handlers map[string]EventHandler) *Service {
+	return &Service{store: s, handlers: handlers}
+}
+
+// List returns matching entries, most recently failed first
+func (s *Service) List(ctx context.Context, filter Filter) ([]Entry, error) {
+	return s.store.List(ctx, filter)
+}
+
+// Get returns an entry by ID
+func (s *Service) Get(ctx context.Context, id string) (*Entry, error) {
+	return s.store.Get(ctx, id)
+}
+
+// Redrive runs a dead entry's event through its consumer's handler again.
+// On success the entry becomes StatusRedriven; on failure it stays dead with
+// the new error and the handler's error is returned. The dead events of an
+// aggregate must be redriven oldest version first (ErrEarlierVersionDead), and
+// an event the handler skips as already applied stays dead (ErrAlreadyApplied).
+func (s *Service) Redrive(ctx context.Context, id string) (*Entry, error) {
+	entry, err := s.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Status != StatusDead {
+		return nil, ErrNotRedrivable
+	}
+	handler, ok := s.handlers[entry.Consumer]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownConsumer, entry.Consumer)
+	}
+
+	event, err := entryEvent(entry)
+	if err == nil {
+		err = s.checkOldestDead(ctx, entry, event)
+		if errors.Is(err, ErrEarlierVersionDead) {
+			return nil, err
+		}
+	}
+	var applied bool
+	if err == nil {
+		applied, err = apply(ctx, handler, event)
+	}
+	if err != nil {
+		log.Printf("[DeadLetter] Redrive of %s failed: %v", entry.ID, err)
+		if recordErr := s.store.RecordRedriveFailure(ctx, entry.ID, err); recordErr != nil {
+			return nil, errors.Join(err, recordErr)
+		}
+		return nil, &RedriveError{Err: err}
+	}
+	if !applied {
+		log.Printf("[DeadLetter] Redrive of %s skipped: %s event %s was already applied", entry.ID, entry.Consumer, event.ID)
+		return nil, ErrAlreadyApplied
+	}
+	if err := s.store.MarkResolved(ctx, entry.ID, StatusRedriven); err != nil {
+		return nil, err
+	}
+	log.Printf("[DeadLetter] Redrove %s event %s (%s)", entry.Consumer, entry.EventID, entry.ID)
+	return s.store.Get(ctx, entry.ID)
+}
+
+// Discard drops a dead entry without processing it. If the consumer's
+// handler is a SkippingHandler, the event is skipped first, which like a
+// redrive requires the earlier dead events of its aggregate to be resolved.
+func (s *Service) Discard(ctx context.Context, id string) (*Entry, error) {
+	entry, err := s.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Status != StatusDead {
+		return nil, ErrNotRedrivable
+	}
+	if skipping, ok := s.handlers[entry.Consumer].(SkippingHandler); ok {
+		// An undecodable entry has no aggregate version to skip
+		if event, err := entryEvent(entry); err == nil {
+			if err := s.checkOldestDead(ctx, entry, event); err != nil {
+				return nil, err
+			}
+			if err := skip(ctx, skipping, event); err != nil {
+				return nil, fmt.Errorf("failed to skip event %s: %w", event.ID, err)
+			}
+		}
+	}
+	if err := s.store.MarkResolved(ctx, id, StatusDiscarded); err != nil {
+		return nil, err
+	}
+	log.Printf("[DeadLetter] Discarded %s event %s (%s)", entry.Consumer, entry.EventID, entry.ID)
+	return s.store.Get(ctx, id)
+}
+
+// RedriveError is returned by Redrive when the consumer's handler failed again
+type RedriveError struct {
+	Err error
+}
+
+func (e *RedriveError) Error() string { return "redrive failed: " + e.Err.Error() }
+func (e *RedriveError) Unwrap() error { return e.Err }
+
+// checkOldestDead fails with ErrEarlierVersionDead if another dead entry of
+// the consumer holds an earlier version of the event's aggregate: the
+// consumer applies the events of an aggregate in version order, so redriving
+// a later one first would skip or reorder the earlier one
+func (s *Service) checkOldestDead(ctx context.Context, entry *Entry, event *store.Event) error {
+	if event.AggregateID == "" || event.Version <= 0 {
+		return nil
+	}
+	dead, err := s.store.List(ctx, Filter{Consumer: entry.Consumer, Status: StatusDead, AggregateID: event.AggregateID})
+	if err != nil {
+		return err
+	}
+	for _, other := range dead {
+		if other.ID == entry.ID {
+			continue
+		}
+		otherEvent, err := entryEvent(&other)
+		if err != nil {
+			continue // Undecodable entries have no version to order by
+		}
+		if otherEvent.Version < event.Version {
+			return fmt.Errorf("%w: %s v%d (%s)", ErrEarlierVersionDead, otherEvent.AggregateID, otherEvent.Version, other.ID)
+		}
+	}
+	return nil
+}
+
+// entryEvent decodes an entry's event, converting raw Kinesis data first
+func entryEvent(entry *Entry) (*store.Event, error) {
+	if entry.PayloadFormat == FormatKinesis {
+		record := events.KinesisEventRecord{Kinesis: events.KinesisRecord{Data: entry.Payload}}
+		event, err := kinesis.ConvertFromKinesisRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		if event == nil {
+			return nil, errors.New("record does not contain an event")
+		}
+		return event, nil
+	}
+	var event store.Event
+	if err := json.Unmarshal(entry.Payload, &event); err != nil {
+		return nil, err
+	}
+	if event.AggregateID == "" {
+		event.AggregateID = entry.AggregateID
+	}
+	return &event, nil
+}
+
+// skip passes an event to handler's SkipEvent
+func skip(ctx context.Context, handler SkippingHandler, event *store.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return handler.SkipEvent(ctx, []byte(event.AggregateID), payload)
+}
+
+// apply passes an event to handler and reports whether it was applied.
+// Handlers that cannot tell are assumed to have applied it.
+func apply(ctx context.Context, handler EventHandler, event *store.Event) (bool, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return false, err
+	}
+	if applying, ok := handler.(ApplyingHandler); ok {
+		return applying.ApplyEvent(ctx, []byte(event.AggregateID), payload)
+	}
+	return true, handler.HandleEvent(ctx, []byte(event.AggregateID), payload)
+}
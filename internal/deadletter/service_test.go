@@ -0,0 +1,263 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingHandler records the events it is given and fails with err
+type recordingHandler struct {
+	keys   []string
+	events []store.Event
+	err    error
+}
+
+func (h *recordingHandler) HandleEvent(_ context.Context, key, value []byte) error {
+	var event store.Event
+	if err := json.Unmarshal(value, &event); err != nil {
+		return err
+	}
+	h.keys = append(h.keys, string(key))
+	h.events = append(h.events, event)
+	return h.err
+}
+
+// deadLetter stores a dead entry for the test event and returns its ID
+func deadLetter(t *testing.T, s Store) string {
+	t.Helper()
+	event, eventJSON := testEvent()
+	entry, err := s.RecordFailure(context.Background(), Failure{
+		Consumer:      ConsumerProjector,
+		SourceID:      "seq-1",
+		EventID:       event.ID,
+		AggregateID:   event.AggregateID,
+		EventType:     event.EventType,
+		PayloadFormat: FormatEvent,
+		Payload:       eventJSON,
+		Err:           errors.New("read store unavailable"),
+		Poison:        true,
+	}, DefaultMaxAttempts)
+	require.NoError(t, err)
+	return entry.ID
+}
+
+func TestService_Redrive(t *testing.T) {
+	s := NewMemoryStore()
+	handler := &recordingHandler{}
+	svc := NewService(s, map[string]EventHandler{ConsumerProjector: handler})
+	id := deadLetter(t, s)
+
+	entry, err := svc.Redrive(context.Background(), id)
+
+	require.NoError(t, err)
+	assert.Equal(t, StatusRedriven, entry.Status)
+	assert.NotNil(t, entry.ResolvedAt)
+	require.Len(t, handler.events, 1)
+	assert.Equal(t, "event-1", handler.events[0].ID)
+	assert.Equal(t, []string{"order-1"}, handler.keys)
+
+	// A redriven entry cannot be redriven again
+	_, err = svc.Redrive(context.Background(), id)
+	assert.ErrorIs(t, err, ErrNotRedrivable)
+}
+
+func TestService_RedriveFailureKeepsEntryDead(t *testing.T) {
+	s := NewMemoryStore()
+	handler := &recordingHandler{err: errors.New("still broken")}
+	svc := NewService(s, map[string]EventHandler{ConsumerProjector: handler})
+	id := deadLetter(t, s)
+
+	_, err := svc.Redrive(context.Background(), id)
+
+	var redriveErr *RedriveError
+	require.ErrorAs(t, err, &redriveErr)
+	assert.ErrorIs(t, err, handler.err)
+	entry, _ := s.Get(context.Background(), id)
+	assert.Equal(t, StatusDead, entry.Status)
+	assert.Equal(t, 2, entry.Attempts)
+	assert.Equal(t, "still broken", entry.Error)
+}
+
+func TestService_RedriveKinesisPayload(t *testing.T) {
+	s := NewMemoryStore()
+	handler := &recordingHandler{}
+	svc := NewService(s, map[string]EventHandler{ConsumerNotifier: handler})
+	ctx := context.Background()
+
+	// A stream record that failed to convert before the adapter was fixed
+	data := []byte(`{"eventName":"INSERT","dynamodb":{"NewImage":{
+		"id":{"S":"event-9"},"aggregate_id":{"S":"order-9"},"aggregate_type":{"S":"Order"},
+		"event_type":{"S":"OrderPlaced"},"data":{"S":"{}"},"version":{"N":"1"}}}}`)
+	require.NoError(t, NewGuard(ConsumerNotifier, s).Undecodable(ctx, kinesisRecord("seq-9", data), errors.New("adapter bug")))
+	entries, _ := s.List(ctx, Filter{Status: StatusDead})
+	require.Len(t, entries, 1)
+
+	_, err := svc.Redrive(ctx, entries[0].ID)
+
+	require.NoError(t, err)
+	require.Len(t, handler.events, 1)
+	assert.Equal(t, "event-9", handler.events[0].ID)
+	assert.Equal(t, []string{"order-9"}, handler.keys)
+}
+
+func TestService_Discard(t *testing.T) {
+	s := NewMemoryStore()
+	handler := &recordingHandler{}
+	svc := NewService(s, map[string]EventHandler{ConsumerProjector: handler})
+	id := deadLetter(t, s)
+
+	entry, err := svc.Discard(context.Background(), id)
+
+	require.NoError(t, err)
+	assert.Equal(t, StatusDiscarded, entry.Status)
+	assert.Empty(t, handler.events)
+
+	_, err = svc.Discard(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestService_RedriveUnknownConsumer(t *testing.T) {
+	s := NewMemoryStore()
+	svc := NewService(s, map[string]EventHandler{})
+	id := deadLetter(t, s)
+
+	_, err := svc.Redrive(context.Background(), id)
+
+	assert.ErrorIs(t, err, ErrUnknownConsumer)
+}
+
+// applyingHandler is a recordingHandler that reports whether it applied the event
+type applyingHandler struct {
+	recordingHandler
+	applied bool
+}
+
+func (h *applyingHandler) ApplyEvent(ctx context.Context, key, value []byte) (bool, error) {
+	if err := h.HandleEvent(ctx, key, value); err != nil {
+		return false, err
+	}
+	return h.applied, nil
+}
+
+// deadLetterVersion stores a dead entry for version of the test event's aggregate
+func deadLetterVersion(t *testing.T, s Store, version int) string {
+	t.Helper()
+	event, _ := testEvent()
+	event.ID = fmt.Sprintf("event-%d", version)
+	event.Version = version
+	eventJSON, _ := json.Marshal(event)
+	entry, err := s.RecordFailure(context.Background(), Failure{
+		Consumer:      ConsumerProjector,
+		SourceID:      fmt.Sprintf("seq-%d", version),
+		EventID:       event.ID,
+		AggregateID:   event.AggregateID,
+		EventType:     event.EventType,
+		PayloadFormat: FormatEvent,
+		Payload:       eventJSON,
+		Err:           errors.New("read store unavailable"),
+		Poison:        true,
+	}, DefaultMaxAttempts)
+	require.NoError(t, err)
+	return entry.ID
+}
+
+func TestService_RedriveRequiresOldestDeadVersion(t *testing.T) {
+	s := NewMemoryStore()
+	handler := &recordingHandler{}
+	svc := NewService(s, map[string]EventHandler{ConsumerProjector: handler})
+	ctx := context.Background()
+	first := deadLetterVersion(t, s, 2)
+	second := deadLetterVersion(t, s, 3)
+
+	_, err := svc.Redrive(ctx, second)
+
+	assert.ErrorIs(t, err, ErrEarlierVersionDead)
+	assert.Empty(t, handler.events)
+	entry, _ := s.Get(ctx, second)
+	assert.Equal(t, StatusDead, entry.Status)
+	assert.Equal(t, 1, entry.Attempts, "a rejected redrive is not an attempt")
+
+	// Oldest first succeeds
+	_, err = svc.Redrive(ctx, first)
+	require.NoError(t, err)
+	_, err = svc.Redrive(ctx, second)
+	require.NoError(t, err)
+	require.Len(t, handler.events, 2)
+	assert.Equal(t, 2, handler.events[0].Version)
+	assert.Equal(t, 3, handler.events[1].Version)
+}
+
+func TestService_RedriveSkippedEventStaysDead(t *testing.T) {
+	s := NewMemoryStore()
+	handler := &applyingHandler{applied: false}
+	svc := NewService(s, map[string]EventHandler{ConsumerProjector: handler})
+	id := deadLetter(t, s)
+
+	_, err := svc.Redrive(context.Background(), id)
+
+	assert.ErrorIs(t, err, ErrAlreadyApplied)
+	require.Len(t, handler.events, 1)
+	entry, _ := s.Get(context.Background(), id)
+	assert.Equal(t, StatusDead, entry.Status)
+
+	// It can still be discarded
+	_, err = svc.Discard(context.Background(), id)
+	require.NoError(t, err)
+}
+
+func TestService_RedriveAppliedEvent(t *testing.T) {
+	s := NewMemoryStore()
+	handler := &applyingHandler{applied: true}
+	svc := NewService(s, map[string]EventHandler{ConsumerProjector: handler})
+	id := deadLetter(t, s)
+
+	entry, err := svc.Redrive(context.Background(), id)
+
+	require.NoError(t, err)
+	assert.Equal(t, StatusRedriven, entry.Status)
+}
+
+// skippingHandler is a recordingHandler that records the events it skips
+type skippingHandler struct {
+	recordingHandler
+	skipped []string
+}
+
+func (h *skippingHandler) SkipEvent(_ context.Context, _, value []byte) error {
+	var event store.Event
+	if err := json.Unmarshal(value, &event); err != nil {
+		return err
+	}
+	h.skipped = append(h.skipped, event.ID)
+	return nil
+}
+
+func TestService_DiscardSkipsEvent(t *testing.T) {
+	s := NewMemoryStore()
+	handler := &skippingHandler{}
+	svc := NewService(s, map[string]EventHandler{ConsumerProjector: handler})
+	ctx := context.Background()
+	first := deadLetterVersion(t, s, 2)
+	second := deadLetterVersion(t, s, 3)
+
+	// Skipping version 3 would also skip the dead version 2
+	_, err := svc.Discard(ctx, second)
+	assert.ErrorIs(t, err, ErrEarlierVersionDead)
+	assert.Empty(t, handler.skipped)
+
+	entry, err := svc.Discard(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, StatusDiscarded, entry.Status)
+	assert.Equal(t, []string{"event-2"}, handler.skipped)
+	assert.Empty(t, handler.events, "not handled")
+
+	_, err = svc.Discard(ctx, first)
+	assert.ErrorIs(t, err, ErrNotRedrivable)
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"time"
+	"log"
 
 	"github.com/example/ec-event-driven/internal/infrastructure/store"
 )
@@ -18,7 +18,9 @@ type Aggregate interface {
 }
 
 // LoadAggregate loads an aggregate by replaying events, using snapshot if available
-// Returns the aggregate, a boolean indicating if data was found, and any error
+// Returns the aggregate, a boolean indicating if data was found, and any error.
+// Snapshots that the aggregate type's policy no longer accepts (outdated schema
+// or snapshots disabled) are ignored; outdated ones are rebuilt from the events.
 func LoadAggregate[T Aggregate](
 	ctx context.Context,
 	eventStore store.EventStoreInterface,
@@ -33,55 +35,71 @@ func LoadAggregate[T Aggregate](
 		return zero, false, fmt.Errorf("failed to get snapshot: %w", err)
 	}
 
-	var events []store.Event
+	fromVersion := 0
+	rebuildType := "" // Set when an outdated snapshot must be rebuilt
 	if snapshot != nil {
-		if err := json.Unmarshal(snapshot.State, agg); err != nil {
-			var zero T
-			return zero, false, fmt.Errorf("failed to unmarshal snapshot: %w", err)
+		policy := SnapshotPolicyFor(snapshot.AggregateType)
+		if policy.usable(snapshot) {
+			if err := json.Unmarshal(snapshot.State, agg); err != nil {
+				var zero T
+				return zero, false, fmt.Errorf("failed to unmarshal snapshot: %w", err)
+			}
+			agg.SetVersion(snapshot.Version)
+			fromVersion = snapshot.Version
+		} else {
+			if !policy.Disabled {
+				log.Printf("[Snapshot] Ignoring outdated %s snapshot of %s (schema v%d, current v%d)",
+					snapshot.AggregateType, id, snapshot.SchemaVersion, policy.SchemaVersion)
+				rebuildType = snapshot.AggregateType
+			}
+			snapshot = nil
 		}
-		events = eventStore.GetEventsFromVersion(ctx, id, snapshot.Version)
-	} else {
-		events = eventStore.GetEvents(id)
 	}
 
 	// Check if any data was found
-	hasData := snapshot != nil || len(events) > 0
+	hasData := snapshot != nil
 
-	for _, event := range events {
-		if err := agg.ApplyEvent(event); err != nil {
-			var zero T
-			return zero, false, fmt.Errorf("failed to apply event: %w", err)
+	replayed, err := replay(ctx, eventStore, id, agg, fromVersion)
+	if err != nil {
+		var zero T
+		return zero, false, err
+	}
+	hasData = hasData || replayed
+
+	if rebuildType != "" && agg.GetVersion() > 0 {
+		if err := saveSnapshot(ctx, eventStore, agg, rebuildType, SnapshotPolicyFor(rebuildType)); err != nil {
+			log.Printf("[Snapshot] Failed to rebuild snapshot of %s: %v", id, err)
 		}
 	}
 
 	return agg, hasData, nil
 }
 
-// MaybeCreateSnapshot creates a snapshot if the threshold is exceeded
-func MaybeCreateSnapshot(
-	ctx context.Context,
-	eventStore store.EventStoreInterface,
-	agg Aggregate,
-	aggregateType string,
-) error {
-	version := agg.GetVersion()
-	if version > 0 && version%store.SnapshotThreshold == 0 {
-		state, err := json.Marshal(agg)
+// replay applies the events of an aggregate after fromVersion and reports
+// whether there were any. A read error aborts the replay so that a partially
+// read stream is never mistaken for the aggregate's current state.
+func replay(ctx context.Context, eventStore store.EventStoreInterface, id string, agg Aggregate, fromVersion int) (bool, error) {
+	found := false
+	for event, err := range eventStore.ReadStream(ctx, id, fromVersion) {
 		if err != nil {
-			return fmt.Errorf("failed to marshal aggregate state: %w", err)
+			return false, fmt.Errorf("failed to read events for %s: %w", id, err)
 		}
-
-		snapshot := &store.Snapshot{
-			AggregateID:   agg.GetID(),
-			AggregateType: aggregateType,
-			Version:       version,
-			State:         state,
-			CreatedAt:     time.Now(),
+		if err := agg.ApplyEvent(event); err != nil {
+			return false, fmt.Errorf("failed to apply event: %w", err)
 		}
+		found = true
+	}
+	return found, nil
+}
 
-		if err := eventStore.SaveSnapshot(ctx, snapshot); err != nil {
-			return fmt.Errorf("failed to save snapshot: %w", err)
+// CurrentVersion returns the version of the aggregate's last event, or 0 if it has none
+func CurrentVersion(ctx context.Context, eventStore store.EventStoreInterface, id string) (int, error) {
+	version := 0
+	for event, err := range eventStore.ReadStream(ctx, id, 0) {
+		if err != nil {
+			return 0, fmt.Errorf("failed to read events for %s: %w", id, err)
 		}
+		version = event.Version
 	}
-	return nil
+	return version, nil
 }
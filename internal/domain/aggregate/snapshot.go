@@ -0,0 +1,264 @@
+package aggregate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+)
+
+var (
+	// ErrSnapshotsDisabled is returned when regenerating snapshots of a type whose policy disables them
+	ErrSnapshotsDisabled = errors.New("snapshots are disabled")
+	// ErrUnknownAggregateType is returned for aggregate types that were never registered
+	ErrUnknownAggregateType = errors.New("unknown aggregate type")
+)
+
+// SnapshotPolicy decides when snapshots of an aggregate type are taken and
+// which snapshots are still usable
+type SnapshotPolicy struct {
+	Disabled    bool          // Never take snapshots and ignore existing ones
+	EveryEvents int           // Take a snapshot when the version is a multiple of EveryEvents (0: not by count)
+	MaxAge      time.Duration // Take a snapshot when the latest one is older than MaxAge (0: not by age)
+	// SchemaVersion is the version of the aggregate's serialized state. Bump it
+	// whenever the aggregate struct changes; older snapshots are then ignored
+	// and rebuilt from events.
+	SchemaVersion int
+}
+
+// DefaultSnapshotPolicy applies to aggregate types without a registered policy
+var DefaultSnapshotPolicy = SnapshotPolicy{EveryEvents: store.SnapshotThreshold, SchemaVersion: 1}
+
+type registration struct {
+	newAggregate func(id string) Aggregate
+	policy       SnapshotPolicy
+}
+
+var (
+	registryMu    sync.RWMutex
+	registrations = make(map[string]registration)
+)
+
+// Register registers an aggregate type with its constructor and snapshot policy.
+// Domain packages call it in init.
+func Register(aggregateType string, newAggregate func(id string) Aggregate, policy SnapshotPolicy) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registrations[aggregateType]; exists {
+		panic("aggregate: duplicate registration of " + aggregateType)
+	}
+	registrations[aggregateType] = registration{newAggregate: newAggregate, policy: policy}
+}
+
+// RegisteredTypes returns the registered aggregate types in name order
+func RegisteredTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	types := make([]string, 0, len(registrations))
+	for aggregateType := range registrations {
+		types = append(types, aggregateType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// SnapshotPolicyFor returns the snapshot policy of an aggregate type
+func SnapshotPolicyFor(aggregateType string) SnapshotPolicy {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if reg, ok := registrations[aggregateType]; ok {
+		return reg.policy
+	}
+	return DefaultSnapshotPolicy
+}
+
+// SetSnapshotPolicy replaces the snapshot policy of a registered aggregate type
+func SetSnapshotPolicy(aggregateType string, policy SnapshotPolicy) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	reg, ok := registrations[aggregateType]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownAggregateType, aggregateType)
+	}
+	reg.policy = policy
+	registrations[aggregateType] = reg
+	return nil
+}
+
+// ParseSnapshotPolicy parses a policy such as "every=20", "age=1h",
+// "every=50,age=24h" or "disabled". The schema version is not configurable.
+func ParseSnapshotPolicy(s string) (SnapshotPolicy, error) {
+	var policy SnapshotPolicy
+	if strings.TrimSpace(s) == "disabled" {
+		policy.Disabled = true
+		return policy, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return SnapshotPolicy{}, fmt.Errorf("invalid snapshot policy %q", s)
+		}
+		switch key {
+		case "every":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return SnapshotPolicy{}, fmt.Errorf("invalid snapshot event count %q", value)
+			}
+			policy.EveryEvents = n
+		case "age":
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return SnapshotPolicy{}, fmt.Errorf("invalid snapshot age %q", value)
+			}
+			policy.MaxAge = d
+		default:
+			return SnapshotPolicy{}, fmt.Errorf("unknown snapshot policy option %q", key)
+		}
+	}
+	return policy, nil
+}
+
+// ConfigureSnapshotPolicies overrides the policy of each registered aggregate
+// type from the SNAPSHOT_POLICY_<TYPE> variable (e.g. SNAPSHOT_POLICY_ORDER=every=20)
+func ConfigureSnapshotPolicies(getenv func(string) string) error {
+	for _, aggregateType := range RegisteredTypes() {
+		value := getenv("SNAPSHOT_POLICY_" + strings.ToUpper(aggregateType))
+		if value == "" {
+			continue
+		}
+		policy, err := ParseSnapshotPolicy(value)
+		if err != nil {
+			return fmt.Errorf("%s snapshot policy: %w", aggregateType, err)
+		}
+		policy.SchemaVersion = SnapshotPolicyFor(aggregateType).SchemaVersion
+		if err := SetSnapshotPolicy(aggregateType, policy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// usable reports whether a stored snapshot may be used to load an aggregate
+func (p SnapshotPolicy) usable(snapshot *store.Snapshot) bool {
+	return !p.Disabled && snapshot.SchemaVersion == p.SchemaVersion
+}
+
+// MaybeCreateSnapshot creates a snapshot if the aggregate type's policy calls for one
+func MaybeCreateSnapshot(
+	ctx context.Context,
+	eventStore store.EventStoreInterface,
+	agg Aggregate,
+	aggregateType string,
+) error {
+	policy := SnapshotPolicyFor(aggregateType)
+	version := agg.GetVersion()
+	if policy.Disabled || version <= 0 {
+		return nil
+	}
+
+	due := policy.EveryEvents > 0 && version%policy.EveryEvents == 0
+	if !due && policy.MaxAge > 0 {
+		latest, err := eventStore.GetSnapshot(ctx, agg.GetID())
+		if err != nil {
+			return fmt.Errorf("failed to get snapshot: %w", err)
+		}
+		due = latest == nil || !policy.usable(latest) ||
+			(latest.Version < version && time.Since(latest.CreatedAt) >= policy.MaxAge)
+	}
+	if !due {
+		return nil
+	}
+	return saveSnapshot(ctx, eventStore, agg, aggregateType, policy)
+}
+
+func saveSnapshot(ctx context.Context, eventStore store.EventStoreInterface, agg Aggregate, aggregateType string, policy SnapshotPolicy) error {
+	state, err := json.Marshal(agg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal aggregate state: %w", err)
+	}
+
+	snapshot := &store.Snapshot{
+		AggregateID:   agg.GetID(),
+		AggregateType: aggregateType,
+		Version:       agg.GetVersion(),
+		State:         state,
+		SchemaVersion: policy.SchemaVersion,
+		CreatedAt:     time.Now(),
+	}
+
+	if err := eventStore.SaveSnapshot(ctx, snapshot); err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	return nil
+}
+
+// PurgeSnapshots deletes all snapshots of an aggregate type and returns how many were deleted
+func PurgeSnapshots(ctx context.Context, admin store.SnapshotAdmin, aggregateType string) (int, error) {
+	// Collect first so that deleting does not disturb the listing
+	var ids []string
+	for snapshot, err := range admin.ListSnapshots(ctx, aggregateType) {
+		if err != nil {
+			return 0, err
+		}
+		ids = append(ids, snapshot.AggregateID)
+	}
+
+	for i, id := range ids {
+		if err := admin.DeleteSnapshot(ctx, id); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
+// RegenerateSnapshots rebuilds every aggregate of a type from its events and
+// stores a fresh snapshot of it. It returns how many snapshots were written.
+func RegenerateSnapshots(ctx context.Context, eventStore store.EventStoreInterface, aggregateType string) (int, error) {
+	registryMu.RLock()
+	reg, ok := registrations[aggregateType]
+	registryMu.RUnlock()
+	if !ok {
+		return 0, fmt.Errorf("%w %q", ErrUnknownAggregateType, aggregateType)
+	}
+	if reg.policy.Disabled {
+		return 0, fmt.Errorf("%w for %s", ErrSnapshotsDisabled, aggregateType)
+	}
+
+	var ids []string
+	seen := make(map[string]bool)
+	for event, err := range eventStore.ReadAll(ctx, 0) {
+		if err != nil {
+			return 0, err
+		}
+		if event.AggregateType == aggregateType && !seen[event.AggregateID] {
+			seen[event.AggregateID] = true
+			ids = append(ids, event.AggregateID)
+		}
+	}
+
+	written := 0
+	for _, id := range ids {
+		agg := reg.newAggregate(id)
+		if _, err := replay(ctx, eventStore, id, agg, 0); err != nil {
+			return written, err
+		}
+		if agg.GetVersion() == 0 {
+			continue
+		}
+		if err := saveSnapshot(ctx, eventStore, agg, aggregateType, reg.policy); err != nil {
+			return written, err
+		}
+		written++
+	}
+	log.Printf("[Snapshot] Regenerated %d %s snapshots", written, aggregateType)
+	return written, nil
+}
@@ -0,0 +1,256 @@
+package aggregate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/example/ec-event-driven/internal/infrastructure/store/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// counter is a minimal aggregate whose state is the number of applied events
+type counter struct {
+	ID      string `json:"id"`
+	Count   int    `json:"count"`
+	Version int    `json:"version"`
+}
+
+func (c *counter) GetID() string    { return c.ID }
+func (c *counter) GetVersion() int  { return c.Version }
+func (c *counter) SetVersion(v int) { c.Version = v }
+func (c *counter) ApplyEvent(event store.Event) error {
+	c.Count++
+	c.Version = event.Version
+	return nil
+}
+
+// registerCounter registers a fresh aggregate type for one test and restores the registry afterwards
+func registerCounter(t *testing.T, policy SnapshotPolicy) string {
+	t.Helper()
+	aggregateType := "Counter" + t.Name()
+	Register(aggregateType, func(id string) Aggregate { return &counter{ID: id} }, policy)
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registrations, aggregateType)
+		registryMu.Unlock()
+	})
+	return aggregateType
+}
+
+func addCounterEvents(t *testing.T, eventStore *mocks.MockEventStore, aggregateType, id string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		require.NoError(t, eventStore.AddEvent(id, aggregateType, "Incremented", struct{}{}))
+	}
+}
+
+func loadCounter(t *testing.T, eventStore *mocks.MockEventStore, id string) *counter {
+	t.Helper()
+	c, found, err := LoadAggregate(context.Background(), eventStore, id, func() *counter { return &counter{ID: id} })
+	require.NoError(t, err)
+	require.True(t, found)
+	return c
+}
+
+func TestParseSnapshotPolicy(t *testing.T) {
+	tests := []struct {
+		input string
+		want  SnapshotPolicy
+	}{
+		{"every=20", SnapshotPolicy{EveryEvents: 20}},
+		{"age=1h", SnapshotPolicy{MaxAge: time.Hour}},
+		{"every=50, age=24h", SnapshotPolicy{EveryEvents: 50, MaxAge: 24 * time.Hour}},
+		{"disabled", SnapshotPolicy{Disabled: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSnapshotPolicy(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	for _, invalid := range []string{"", "every=0", "every=x", "age=-1h", "often=1"} {
+		_, err := ParseSnapshotPolicy(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestConfigureSnapshotPolicies_KeepsSchemaVersion(t *testing.T) {
+	aggregateType := registerCounter(t, SnapshotPolicy{EveryEvents: 10, SchemaVersion: 3})
+
+	err := ConfigureSnapshotPolicies(func(key string) string {
+		if key == "SNAPSHOT_POLICY_"+strings.ToUpper(aggregateType) {
+			return "age=30m"
+		}
+		return ""
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, SnapshotPolicy{MaxAge: 30 * time.Minute, SchemaVersion: 3}, SnapshotPolicyFor(aggregateType))
+}
+
+func TestMaybeCreateSnapshot_EveryEvents(t *testing.T) {
+	aggregateType := registerCounter(t, SnapshotPolicy{EveryEvents: 3, SchemaVersion: 2})
+	eventStore := mocks.NewMockEventStore()
+	ctx := context.Background()
+
+	require.NoError(t, MaybeCreateSnapshot(ctx, eventStore, &counter{ID: "c-1", Version: 2}, aggregateType))
+	assert.Empty(t, eventStore.SaveSnapshotCalls)
+
+	require.NoError(t, MaybeCreateSnapshot(ctx, eventStore, &counter{ID: "c-1", Version: 3}, aggregateType))
+	require.Len(t, eventStore.SaveSnapshotCalls, 1)
+	assert.Equal(t, 3, eventStore.SaveSnapshotCalls[0].Snapshot.Version)
+	assert.Equal(t, 2, eventStore.SaveSnapshotCalls[0].Snapshot.SchemaVersion)
+}
+
+func TestMaybeCreateSnapshot_MaxAge(t *testing.T) {
+	aggregateType := registerCounter(t, SnapshotPolicy{MaxAge: time.Hour, SchemaVersion: 1})
+	eventStore := mocks.NewMockEventStore()
+	ctx := context.Background()
+
+	// No snapshot yet
+	require.NoError(t, MaybeCreateSnapshot(ctx, eventStore, &counter{ID: "c-1", Version: 1}, aggregateType))
+	require.Len(t, eventStore.SaveSnapshotCalls, 1)
+
+	// The latest snapshot is recent
+	require.NoError(t, MaybeCreateSnapshot(ctx, eventStore, &counter{ID: "c-1", Version: 2}, aggregateType))
+	assert.Len(t, eventStore.SaveSnapshotCalls, 1)
+
+	// The latest snapshot is older than MaxAge
+	eventStore.SetSnapshot(&store.Snapshot{
+		AggregateID: "c-1", AggregateType: aggregateType, Version: 1, SchemaVersion: 1,
+		State: json.RawMessage(`{}`), CreatedAt: time.Now().Add(-2 * time.Hour),
+	})
+	require.NoError(t, MaybeCreateSnapshot(ctx, eventStore, &counter{ID: "c-1", Version: 3}, aggregateType))
+	assert.Len(t, eventStore.SaveSnapshotCalls, 2)
+}
+
+func TestMaybeCreateSnapshot_Disabled(t *testing.T) {
+	aggregateType := registerCounter(t, SnapshotPolicy{Disabled: true, EveryEvents: 1, SchemaVersion: 1})
+	eventStore := mocks.NewMockEventStore()
+
+	require.NoError(t, MaybeCreateSnapshot(context.Background(), eventStore, &counter{ID: "c-1", Version: 1}, aggregateType))
+
+	assert.Empty(t, eventStore.SaveSnapshotCalls)
+}
+
+func TestLoadAggregate_UsesCurrentSnapshot(t *testing.T) {
+	aggregateType := registerCounter(t, SnapshotPolicy{EveryEvents: 10, SchemaVersion: 2})
+	eventStore := mocks.NewMockEventStore()
+	addCounterEvents(t, eventStore, aggregateType, "c-1", 4)
+	eventStore.SetSnapshot(&store.Snapshot{
+		AggregateID: "c-1", AggregateType: aggregateType, Version: 3, SchemaVersion: 2,
+		State: json.RawMessage(`{"id":"c-1","count":100}`),
+	})
+
+	c := loadCounter(t, eventStore, "c-1")
+
+	// Snapshot state plus the one event after it
+	assert.Equal(t, 101, c.Count)
+	assert.Equal(t, 4, c.Version)
+	assert.Empty(t, eventStore.SaveSnapshotCalls)
+}
+
+func TestLoadAggregate_RebuildsOutdatedSnapshot(t *testing.T) {
+	aggregateType := registerCounter(t, SnapshotPolicy{EveryEvents: 10, SchemaVersion: 2})
+	eventStore := mocks.NewMockEventStore()
+	addCounterEvents(t, eventStore, aggregateType, "c-1", 4)
+	eventStore.SetSnapshot(&store.Snapshot{
+		AggregateID: "c-1", AggregateType: aggregateType, Version: 3, SchemaVersion: 1,
+		State: json.RawMessage(`{"id":"c-1","count":100}`),
+	})
+
+	c := loadCounter(t, eventStore, "c-1")
+
+	// The outdated snapshot is ignored and the aggregate is replayed from the start
+	assert.Equal(t, 4, c.Count)
+	assert.Equal(t, 4, c.Version)
+
+	// ... and a snapshot with the current schema replaces it
+	snapshot, err := eventStore.GetSnapshot(context.Background(), "c-1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, snapshot.SchemaVersion)
+	assert.Equal(t, 4, snapshot.Version)
+	assert.JSONEq(t, `{"id":"c-1","count":4,"version":4}`, string(snapshot.State))
+}
+
+func TestLoadAggregate_IgnoresSnapshotsWhenDisabled(t *testing.T) {
+	aggregateType := registerCounter(t, SnapshotPolicy{Disabled: true, SchemaVersion: 1})
+	eventStore := mocks.NewMockEventStore()
+	addCounterEvents(t, eventStore, aggregateType, "c-1", 2)
+	eventStore.SetSnapshot(&store.Snapshot{
+		AggregateID: "c-1", AggregateType: aggregateType, Version: 2, SchemaVersion: 1,
+		State: json.RawMessage(`{"id":"c-1","count":100}`),
+	})
+
+	c := loadCounter(t, eventStore, "c-1")
+
+	assert.Equal(t, 2, c.Count)
+	assert.Empty(t, eventStore.SaveSnapshotCalls)
+}
+
+func TestPurgeSnapshots(t *testing.T) {
+	aggregateType := registerCounter(t, SnapshotPolicy{EveryEvents: 10, SchemaVersion: 1})
+	eventStore := mocks.NewMockEventStore()
+	for _, snapshot := range []*store.Snapshot{
+		{AggregateID: "c-1", AggregateType: aggregateType},
+		{AggregateID: "c-2", AggregateType: aggregateType},
+		{AggregateID: "other-1", AggregateType: "Other"},
+	} {
+		eventStore.SetSnapshot(snapshot)
+	}
+
+	n, err := PurgeSnapshots(context.Background(), eventStore, aggregateType)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	remaining, _ := eventStore.GetSnapshot(context.Background(), "c-1")
+	assert.Nil(t, remaining)
+	other, _ := eventStore.GetSnapshot(context.Background(), "other-1")
+	assert.NotNil(t, other)
+}
+
+func TestRegenerateSnapshots(t *testing.T) {
+	aggregateType := registerCounter(t, SnapshotPolicy{EveryEvents: 10, SchemaVersion: 2})
+	eventStore := mocks.NewMockEventStore()
+	addCounterEvents(t, eventStore, aggregateType, "c-1", 3)
+	addCounterEvents(t, eventStore, aggregateType, "c-2", 5)
+	addCounterEvents(t, eventStore, "Other", "other-1", 1)
+	// A stale snapshot is overwritten, not used
+	eventStore.SetSnapshot(&store.Snapshot{
+		AggregateID: "c-1", AggregateType: aggregateType, Version: 3, SchemaVersion: 1,
+		State: json.RawMessage(`{"id":"c-1","count":100}`),
+	})
+
+	n, err := RegenerateSnapshots(context.Background(), eventStore, aggregateType)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	for id, count := range map[string]int{"c-1": 3, "c-2": 5} {
+		snapshot, err := eventStore.GetSnapshot(context.Background(), id)
+		require.NoError(t, err)
+		assert.Equal(t, 2, snapshot.SchemaVersion)
+		assert.Equal(t, count, snapshot.Version)
+		assert.JSONEq(t, fmt.Sprintf(`{"id":%q,"count":%d,"version":%d}`, id, count, count), string(snapshot.State))
+	}
+	other, _ := eventStore.GetSnapshot(context.Background(), "other-1")
+	assert.Nil(t, other)
+}
+
+func TestRegenerateSnapshots_Errors(t *testing.T) {
+	eventStore := mocks.NewMockEventStore()
+
+	_, err := RegenerateSnapshots(context.Background(), eventStore, "Unregistered")
+	assert.ErrorContains(t, err, "unknown aggregate type")
+
+	aggregateType := registerCounter(t, SnapshotPolicy{Disabled: true})
+	_, err = RegenerateSnapshots(context.Background(), eventStore, aggregateType)
+	assert.ErrorIs(t, err, ErrSnapshotsDisabled)
+}
@@ -0,0 +1,119 @@
+package aggregate
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+)
+
+// AsOf selects a point in an aggregate's history. A zero AsOf selects the latest state.
+type AsOf struct {
+	Version int       // Include events up to this version (0: no version limit)
+	Time    time.Time // Include events recorded at or before this time (zero: no time limit)
+}
+
+// ParseAsOf parses an as-of value: an integer is a version, an RFC 3339
+// timestamp is a point in time and an empty string means the latest state
+func ParseAsOf(s string) (AsOf, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return AsOf{}, nil
+	}
+	if version, err := strconv.Atoi(s); err == nil {
+		if version <= 0 {
+			return AsOf{}, fmt.Errorf("invalid as-of version %d", version)
+		}
+		return AsOf{Version: version}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return AsOf{}, fmt.Errorf("invalid as-of %q (expected a version or an RFC 3339 timestamp)", s)
+	}
+	return AsOf{Time: t}, nil
+}
+
+// includes reports whether event happened at or before the as-of point
+func (a AsOf) includes(event store.Event) bool {
+	if a.Version > 0 && event.Version > a.Version {
+		return false
+	}
+	if !a.Time.IsZero() && event.Timestamp.After(a.Time) {
+		return false
+	}
+	return true
+}
+
+// LoadAggregateAt rebuilds an aggregate as it was at asOf and returns it with
+// the events applied. Snapshots are never used, so the result only depends on
+// the event history. No events means the aggregate did not exist yet.
+func LoadAggregateAt[T Aggregate](
+	ctx context.Context,
+	eventStore store.EventStoreInterface,
+	id string,
+	asOf AsOf,
+	newAggregate func() T,
+) (T, []store.Event, error) {
+	agg := newAggregate()
+	events, err := replayUntil(ctx, eventStore, id, agg, asOf)
+	if err != nil {
+		var zero T
+		return zero, nil, err
+	}
+	return agg, events, nil
+}
+
+// LoadRegisteredAt is LoadAggregateAt for an aggregate type registered with
+// Register. The type name is matched case-insensitively.
+func LoadRegisteredAt(
+	ctx context.Context,
+	eventStore store.EventStoreInterface,
+	aggregateType string,
+	id string,
+	asOf AsOf,
+) (Aggregate, []store.Event, error) {
+	reg, ok := lookupRegistration(aggregateType)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w %q", ErrUnknownAggregateType, aggregateType)
+	}
+	agg := reg.newAggregate(id)
+	events, err := replayUntil(ctx, eventStore, id, agg, asOf)
+	if err != nil {
+		return nil, nil, err
+	}
+	return agg, events, nil
+}
+
+// lookupRegistration finds a registered aggregate type ignoring case
+func lookupRegistration(aggregateType string) (registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for name, reg := range registrations {
+		if strings.EqualFold(name, aggregateType) {
+			return reg, true
+		}
+	}
+	return registration{}, false
+}
+
+// replayUntil applies the events of an aggregate up to asOf and returns them.
+// Events are read in version order, so reading stops at the first one after asOf.
+func replayUntil(ctx context.Context, eventStore store.EventStoreInterface, id string, agg Aggregate, asOf AsOf) ([]store.Event, error) {
+	var applied []store.Event
+	for event, err := range eventStore.ReadStream(ctx, id, 0) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to read events for %s: %w", id, err)
+		}
+		if !asOf.includes(event) {
+			break
+		}
+		if err := agg.ApplyEvent(event); err != nil {
+			return nil, fmt.Errorf("failed to apply event %d: %w", event.Version, err)
+		}
+		applied = append(applied, event)
+	}
+	return applied, nil
+}
@@ -0,0 +1,140 @@
+package aggregate
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/example/ec-event-driven/internal/infrastructure/store/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var historyStart = time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+
+// setCounterHistory stores n events recorded one hour apart starting at historyStart
+func setCounterHistory(eventStore *mocks.MockEventStore, aggregateType, id string, n int) {
+	events := make([]store.Event, n)
+	for i := range events {
+		events[i] = store.Event{
+			ID:            id + "-event",
+			AggregateID:   id,
+			AggregateType: aggregateType,
+			EventType:     "Incremented",
+			Data:          []byte(`{}`),
+			Timestamp:     historyStart.Add(time.Duration(i) * time.Hour),
+			Version:       i + 1,
+		}
+	}
+	eventStore.SetEvents(id, events)
+}
+
+func TestParseAsOf(t *testing.T) {
+	tests := []struct {
+		input string
+		want  AsOf
+	}{
+		{"", AsOf{}},
+		{"3", AsOf{Version: 3}},
+		{"2025-01-01T10:30:00Z", AsOf{Time: historyStart.Add(90 * time.Minute)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseAsOf(tt.input)
+			require.NoError(t, err)
+			assert.True(t, tt.want.Time.Equal(got.Time))
+			assert.Equal(t, tt.want.Version, got.Version)
+		})
+	}
+
+	for _, invalid := range []string{"0", "-2", "yesterday", "2025-01-01"} {
+		_, err := ParseAsOf(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestLoadAggregateAt_Version(t *testing.T) {
+	eventStore := mocks.NewMockEventStore()
+	setCounterHistory(eventStore, "Counter", "counter-1", 5)
+
+	c, events, err := LoadAggregateAt(context.Background(), eventStore, "counter-1", AsOf{Version: 3},
+		func() *counter { return &counter{ID: "counter-1"} })
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, c.Count)
+	assert.Equal(t, 3, c.Version)
+	require.Len(t, events, 3)
+	assert.Equal(t, 3, events[2].Version)
+}
+
+func TestLoadAggregateAt_Time(t *testing.T) {
+	eventStore := mocks.NewMockEventStore()
+	setCounterHistory(eventStore, "Counter", "counter-1", 5)
+
+	// Events at 09:00, 10:00 and 11:00 happened at or before 11:00
+	c, events, err := LoadAggregateAt(context.Background(), eventStore, "counter-1", AsOf{Time: historyStart.Add(2 * time.Hour)},
+		func() *counter { return &counter{ID: "counter-1"} })
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, c.Count)
+	assert.Len(t, events, 3)
+}
+
+func TestLoadAggregateAt_IgnoresSnapshots(t *testing.T) {
+	eventStore := mocks.NewMockEventStore()
+	setCounterHistory(eventStore, "Counter", "counter-1", 5)
+	eventStore.SetSnapshot(&store.Snapshot{
+		AggregateID:   "counter-1",
+		AggregateType: "Counter",
+		Version:       4,
+		State:         []byte(`{"id":"counter-1","count":100,"version":4}`),
+		SchemaVersion: 1,
+	})
+
+	c, events, err := LoadAggregateAt(context.Background(), eventStore, "counter-1", AsOf{},
+		func() *counter { return &counter{ID: "counter-1"} })
+
+	require.NoError(t, err)
+	assert.Equal(t, 5, c.Count)
+	assert.Len(t, events, 5)
+}
+
+func TestLoadAggregateAt_BeforeFirstEvent(t *testing.T) {
+	eventStore := mocks.NewMockEventStore()
+	setCounterHistory(eventStore, "Counter", "counter-1", 2)
+
+	c, events, err := LoadAggregateAt(context.Background(), eventStore, "counter-1", AsOf{Time: historyStart.Add(-time.Minute)},
+		func() *counter { return &counter{ID: "counter-1"} })
+
+	require.NoError(t, err)
+	assert.Equal(t, 0, c.Version)
+	assert.Empty(t, events)
+}
+
+func TestLoadAggregateAt_ReadErr(t *testing.T) {
+	eventStore := mocks.NewMockEventStore()
+	eventStore.ReadErr = errors.New("dynamodb unavailable")
+
+	_, _, err := LoadAggregateAt(context.Background(), eventStore, "counter-1", AsOf{},
+		func() *counter { return &counter{ID: "counter-1"} })
+
+	assert.ErrorIs(t, err, eventStore.ReadErr)
+}
+
+func TestLoadRegisteredAt(t *testing.T) {
+	aggregateType := registerCounter(t, SnapshotPolicy{Disabled: true})
+	eventStore := mocks.NewMockEventStore()
+	setCounterHistory(eventStore, aggregateType, "counter-1", 4)
+
+	agg, events, err := LoadRegisteredAt(context.Background(), eventStore, strings.ToLower(aggregateType), "counter-1", AsOf{Version: 2})
+
+	require.NoError(t, err)
+	assert.Equal(t, &counter{ID: "counter-1", Count: 2, Version: 2}, agg)
+	assert.Len(t, events, 2)
+
+	_, _, err = LoadRegisteredAt(context.Background(), eventStore, "Spaceship", "counter-1", AsOf{})
+	assert.ErrorIs(t, err, ErrUnknownAggregateType)
+}
@@ -0,0 +1,14 @@
+// Package all registers the events and aggregate types of every domain package.
+// Consumers of the full event stream that do not use all domain packages
+// themselves, such as the notifier and the admin commands, import it for its
+// side effect.
+package all
+
+import (
+	_ "github.com/example/ec-event-driven/internal/domain/cart"
+	_ "github.com/example/ec-event-driven/internal/domain/category"
+	_ "github.com/example/ec-event-driven/internal/domain/inventory"
+	_ "github.com/example/ec-event-driven/internal/domain/order"
+	_ "github.com/example/ec-event-driven/internal/domain/product"
+	_ "github.com/example/ec-event-driven/internal/domain/user"
+)
@@ -2,17 +2,27 @@ package cart
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"log"
 	"time"
 
 	"github.com/example/ec-event-driven/internal/domain/aggregate"
+	"github.com/example/ec-event-driven/internal/eventcodec"
 	"github.com/example/ec-event-driven/internal/infrastructure/store"
 )
 
 const AggregateType = "Cart"
 
+// snapshotSchemaVersion is the version of the Cart state stored in snapshots.
+// Bump it whenever the Cart struct changes so that old snapshots are rebuilt.
+const snapshotSchemaVersion = 1
+
+func init() {
+	aggregate.Register(AggregateType, func(id string) aggregate.Aggregate {
+		return &Cart{ID: id, Items: make(map[string]CartItem)}
+	}, aggregate.SnapshotPolicy{EveryEvents: store.SnapshotThreshold, SchemaVersion: snapshotSchemaVersion})
+}
+
 var (
 	ErrInvalidQuantity = errors.New("quantity must be positive")
 	ErrInvalidProduct  = errors.New("product_id is required")
@@ -51,12 +61,12 @@ func GetCartID(userID string) string {
 
 // ApplyEvent applies a single event to the cart state (implements aggregate.Aggregate)
 func (c *Cart) ApplyEvent(event store.Event) error {
-	switch event.EventType {
-	case EventItemAdded:
-		var data ItemAddedToCart
-		if err := json.Unmarshal(event.Data, &data); err != nil {
-			return err
-		}
+	decoded, err := eventcodec.Decode(event)
+	if err != nil {
+		return err
+	}
+	switch data := decoded.(type) {
+	case ItemAddedToCart:
 		if c.Items == nil {
 			c.Items = make(map[string]CartItem)
 		}
@@ -74,17 +84,9 @@ func (c *Cart) ApplyEvent(event store.Event) error {
 				Price:     data.Price,
 			}
 		}
-	case EventItemRemoved:
-		var data ItemRemovedFromCart
-		if err := json.Unmarshal(event.Data, &data); err != nil {
-			return err
-		}
+	case ItemRemovedFromCart:
 		delete(c.Items, data.ProductID)
-	case EventCartCleared:
-		var data CartCleared
-		if err := json.Unmarshal(event.Data, &data); err != nil {
-			return err
-		}
+	case CartCleared:
 		c.Items = make(map[string]CartItem)
 	}
 	c.Version = event.Version
@@ -94,7 +96,7 @@ func (c *Cart) ApplyEvent(event store.Event) error {
 // loadCart loads a cart by replaying events, using snapshot if available
 func (s *Service) loadCart(ctx context.Context, cartID string) (*Cart, error) {
 	cart, _, err := aggregate.LoadAggregate(ctx, s.eventStore, cartID, func() *Cart {
-		return &Cart{Items: make(map[string]CartItem)}
+		return &Cart{ID: cartID, Items: make(map[string]CartItem)}
 	})
 	if err != nil {
 		return nil, err
@@ -107,25 +109,21 @@ func (s *Service) loadCart(ctx context.Context, cartID string) (*Cart, error) {
 }
 
 
-func (s *Service) AddItem(ctx context.Context, userID, productID string, quantity, price int) error {
+// AddItem adds an item to the user's cart and returns the stored event
+func (s *Service) AddItem(ctx context.Context, userID, productID string, quantity, price int) (*store.Event, error) {
 	if productID == "" {
-		return ErrInvalidProduct
+		return nil, ErrInvalidProduct
 	}
 	if quantity <= 0 {
-		return ErrInvalidQuantity
+		return nil, ErrInvalidQuantity
 	}
 
 	cartID := GetCartID(userID)
 
-	// Load current cart state for snapshot check
+	// Load current cart state for version and snapshot checks
 	cart, err := s.loadCart(ctx, cartID)
 	if err != nil {
-		// Cart doesn't exist yet, create new one
-		cart = &Cart{
-			ID:     cartID,
-			UserID: userID,
-			Items:  make(map[string]CartItem),
-		}
+		return nil, err
 	}
 
 	event := ItemAddedToCart{
@@ -137,9 +135,9 @@ func (s *Service) AddItem(ctx context.Context, userID, productID string, quantit
 		AddedAt:   time.Now(),
 	}
 
-	storedEvent, err := s.eventStore.Append(ctx, cartID, AggregateType, EventItemAdded, event)
+	storedEvent, err := s.eventStore.AppendWithExpectedVersion(ctx, cartID, AggregateType, EventItemAdded, cart.Version, event)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Update cart for snapshot check
@@ -152,24 +150,21 @@ func (s *Service) AddItem(ctx context.Context, userID, productID string, quantit
 		log.Printf("[Cart] Failed to create snapshot for cart %s: %v", cart.ID, err)
 	}
 
-	return nil
+	return storedEvent, nil
 }
 
-func (s *Service) RemoveItem(ctx context.Context, userID, productID string) error {
+// RemoveItem removes an item from the user's cart and returns the stored event
+func (s *Service) RemoveItem(ctx context.Context, userID, productID string) (*store.Event, error) {
 	if productID == "" {
-		return ErrInvalidProduct
+		return nil, ErrInvalidProduct
 	}
 
 	cartID := GetCartID(userID)
 
-	// Load current cart state for snapshot check
+	// Load current cart state for version and snapshot checks
 	cart, err := s.loadCart(ctx, cartID)
 	if err != nil {
-		cart = &Cart{
-			ID:     cartID,
-			UserID: userID,
-			Items:  make(map[string]CartItem),
-		}
+		return nil, err
 	}
 
 	event := ItemRemovedFromCart{
@@ -179,9 +174,9 @@ func (s *Service) RemoveItem(ctx context.Context, userID, productID string) erro
 		RemovedAt: time.Now(),
 	}
 
-	storedEvent, err := s.eventStore.Append(ctx, cartID, AggregateType, EventItemRemoved, event)
+	storedEvent, err := s.eventStore.AppendWithExpectedVersion(ctx, cartID, AggregateType, EventItemRemoved, cart.Version, event)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Update cart for snapshot check
@@ -194,34 +189,61 @@ func (s *Service) RemoveItem(ctx context.Context, userID, productID string) erro
 		log.Printf("[Cart] Failed to create snapshot for cart %s: %v", cart.ID, err)
 	}
 
-	return nil
+	return storedEvent, nil
 }
 
-func (s *Service) Clear(ctx context.Context, userID string) error {
-	cartID := GetCartID(userID)
+// Get loads the user's cart. A user without a cart gets an empty one.
+func (s *Service) Get(ctx context.Context, userID string) (*Cart, error) {
+	cart, err := s.loadCart(ctx, GetCartID(userID))
+	if err != nil {
+		return nil, err
+	}
+	cart.UserID = userID
+	return cart, nil
+}
 
-	// Load current cart state for snapshot check
-	cart, err := s.loadCart(ctx, cartID)
+// Clear empties the cart and returns the CartCleared event to append. The
+// cart then carries the version it has once the event is stored.
+func (c *Cart) Clear() store.PendingEvent {
+	pending := store.PendingEvent{
+		AggregateID:     c.ID,
+		AggregateType:   AggregateType,
+		EventType:       EventCartCleared,
+		ExpectedVersion: c.Version,
+		Data: CartCleared{
+			CartID:    c.ID,
+			UserID:    c.UserID,
+			ClearedAt: time.Now(),
+		},
+	}
+
+	c.Items = make(map[string]CartItem)
+	c.Version++
+
+	return pending
+}
+
+// PrepareClear loads the cart and returns the CartCleared event to append
+// together with the cart state once the event is stored
+func (s *Service) PrepareClear(ctx context.Context, userID string) (*Cart, store.PendingEvent, error) {
+	cart, err := s.Get(ctx, userID)
 	if err != nil {
-		cart = &Cart{
-			ID:     cartID,
-			UserID: userID,
-			Items:  make(map[string]CartItem),
-		}
+		return nil, store.PendingEvent{}, err
 	}
+	return cart, cart.Clear(), nil
+}
 
-	event := CartCleared{
-		CartID:    cartID,
-		UserID:    userID,
-		ClearedAt: time.Now(),
+func (s *Service) Clear(ctx context.Context, userID string) error {
+	cart, pending, err := s.PrepareClear(ctx, userID)
+	if err != nil {
+		return err
 	}
 
-	storedEvent, err := s.eventStore.Append(ctx, cartID, AggregateType, EventCartCleared, event)
+	storedEvent, err := s.eventStore.AppendWithExpectedVersion(ctx, pending.AggregateID, pending.AggregateType, pending.EventType, pending.ExpectedVersion, pending.Data)
 	if err != nil {
 		return err
 	}
 
-	// Update cart for snapshot check
 	if storedEvent != nil {
 		cart.Version = storedEvent.Version
 	}
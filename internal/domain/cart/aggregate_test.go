@@ -49,7 +49,7 @@ func TestService_AddItem_Success(t *testing.T) {
 	service, eventStore := newTestCartService()
 	ctx := context.Background()
 
-	err := service.AddItem(ctx, "user-123", "prod-456", 2, 1000)
+	_, err := service.AddItem(ctx, "user-123", "prod-456", 2, 1000)
 
 	require.NoError(t, err)
 	assert.Len(t, eventStore.AppendCalls, 1)
@@ -72,7 +72,7 @@ func TestService_AddItem_SingleQuantity(t *testing.T) {
 	service, _ := newTestCartService()
 	ctx := context.Background()
 
-	err := service.AddItem(ctx, "user-123", "prod-456", 1, 500)
+	_, err := service.AddItem(ctx, "user-123", "prod-456", 1, 500)
 
 	require.NoError(t, err)
 }
@@ -81,7 +81,7 @@ func TestService_AddItem_EmptyProductID(t *testing.T) {
 	service, eventStore := newTestCartService()
 	ctx := context.Background()
 
-	err := service.AddItem(ctx, "user-123", "", 2, 1000)
+	_, err := service.AddItem(ctx, "user-123", "", 2, 1000)
 
 	assert.ErrorIs(t, err, ErrInvalidProduct)
 	assert.Empty(t, eventStore.AppendCalls)
@@ -91,7 +91,7 @@ func TestService_AddItem_ZeroQuantity(t *testing.T) {
 	service, eventStore := newTestCartService()
 	ctx := context.Background()
 
-	err := service.AddItem(ctx, "user-123", "prod-456", 0, 1000)
+	_, err := service.AddItem(ctx, "user-123", "prod-456", 0, 1000)
 
 	assert.ErrorIs(t, err, ErrInvalidQuantity)
 	assert.Empty(t, eventStore.AppendCalls)
@@ -101,7 +101,7 @@ func TestService_AddItem_NegativeQuantity(t *testing.T) {
 	service, eventStore := newTestCartService()
 	ctx := context.Background()
 
-	err := service.AddItem(ctx, "user-123", "prod-456", -1, 1000)
+	_, err := service.AddItem(ctx, "user-123", "prod-456", -1, 1000)
 
 	assert.ErrorIs(t, err, ErrInvalidQuantity)
 	assert.Empty(t, eventStore.AppendCalls)
@@ -112,7 +112,7 @@ func TestService_AddItem_ZeroPrice(t *testing.T) {
 	ctx := context.Background()
 
 	// Zero price is allowed (free items)
-	err := service.AddItem(ctx, "user-123", "prod-456", 1, 0)
+	_, err := service.AddItem(ctx, "user-123", "prod-456", 1, 0)
 
 	require.NoError(t, err)
 }
@@ -125,7 +125,7 @@ func TestService_RemoveItem_Success(t *testing.T) {
 	service, eventStore := newTestCartService()
 	ctx := context.Background()
 
-	err := service.RemoveItem(ctx, "user-123", "prod-456")
+	_, err := service.RemoveItem(ctx, "user-123", "prod-456")
 
 	require.NoError(t, err)
 	assert.Len(t, eventStore.AppendCalls, 1)
@@ -142,7 +142,7 @@ func TestService_RemoveItem_EmptyProductID(t *testing.T) {
 	service, eventStore := newTestCartService()
 	ctx := context.Background()
 
-	err := service.RemoveItem(ctx, "user-123", "")
+	_, err := service.RemoveItem(ctx, "user-123", "")
 
 	assert.ErrorIs(t, err, ErrInvalidProduct)
 	assert.Empty(t, eventStore.AppendCalls)
@@ -189,15 +189,15 @@ func TestCartOperations_Sequence(t *testing.T) {
 	userID := "user-123"
 
 	// 1. Add first item
-	err := service.AddItem(ctx, userID, "prod-1", 2, 1000)
+	_, err := service.AddItem(ctx, userID, "prod-1", 2, 1000)
 	require.NoError(t, err)
 
 	// 2. Add second item
-	err = service.AddItem(ctx, userID, "prod-2", 1, 2000)
+	_, err = service.AddItem(ctx, userID, "prod-2", 1, 2000)
 	require.NoError(t, err)
 
 	// 3. Remove first item
-	err = service.RemoveItem(ctx, userID, "prod-1")
+	_, err = service.RemoveItem(ctx, userID, "prod-1")
 	require.NoError(t, err)
 
 	// 4. Clear cart
@@ -219,10 +219,10 @@ func TestAddMultipleItemsSameProduct(t *testing.T) {
 	userID := "user-123"
 
 	// Add same product twice
-	err := service.AddItem(ctx, userID, "prod-1", 2, 1000)
+	_, err := service.AddItem(ctx, userID, "prod-1", 2, 1000)
 	require.NoError(t, err)
 
-	err = service.AddItem(ctx, userID, "prod-1", 3, 1000)
+	_, err = service.AddItem(ctx, userID, "prod-1", 3, 1000)
 	require.NoError(t, err)
 
 	// Both events should be recorded (projection handles merging)
@@ -242,7 +242,7 @@ func TestCartService_SnapshotCreatedAtThreshold(t *testing.T) {
 
 	// Add 9 items first
 	for i := 1; i <= 9; i++ {
-		err := service.AddItem(ctx, userID, "prod-"+string(rune('0'+i)), 1, 100*i)
+		_, err := service.AddItem(ctx, userID, "prod-"+string(rune('0'+i)), 1, 100*i)
 		require.NoError(t, err)
 	}
 
@@ -250,7 +250,7 @@ func TestCartService_SnapshotCreatedAtThreshold(t *testing.T) {
 	eventStore.SaveSnapshotCalls = nil
 
 	// The 10th event should trigger a snapshot
-	err := service.AddItem(ctx, userID, "prod-10", 1, 1000)
+	_, err := service.AddItem(ctx, userID, "prod-10", 1, 1000)
 	require.NoError(t, err)
 
 	// Verify snapshot was created
@@ -282,6 +282,7 @@ func TestCartService_LoadCartFromSnapshot(t *testing.T) {
 		AggregateType: AggregateType,
 		Version:       10,
 		State:         stateJSON,
+		SchemaVersion: snapshotSchemaVersion,
 	})
 
 	// Clear the cart - this should load from snapshot first
@@ -315,6 +316,7 @@ func TestCartService_LoadCartFromSnapshotWithSubsequentEvents(t *testing.T) {
 		AggregateType: AggregateType,
 		Version:       5,
 		State:         stateJSON,
+		SchemaVersion: snapshotSchemaVersion,
 	})
 
 	// Add events after the snapshot
@@ -327,7 +329,7 @@ func TestCartService_LoadCartFromSnapshotWithSubsequentEvents(t *testing.T) {
 	})
 
 	// Add another item - this should work after loading from snapshot + events
-	err := service.AddItem(ctx, userID, "prod-3", 3, 300)
+	_, err := service.AddItem(ctx, userID, "prod-3", 3, 300)
 	require.NoError(t, err)
 
 	// Verify the add event was appended
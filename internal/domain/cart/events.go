@@ -1,6 +1,10 @@
 package cart
 
-import "time"
+import (
+	"time"
+
+	"github.com/example/ec-event-driven/internal/eventcodec"
+)
 
 const (
 	EventItemAdded   = "ItemAddedToCart"
@@ -8,6 +12,12 @@ const (
 	EventCartCleared = "CartCleared"
 )
 
+func init() {
+	eventcodec.Register[ItemAddedToCart](EventItemAdded)
+	eventcodec.Register[ItemRemovedFromCart](EventItemRemoved)
+	eventcodec.Register[CartCleared](EventCartCleared)
+}
+
 type ItemAddedToCart struct {
 	CartID    string    `json:"cart_id"`
 	UserID    string    `json:"user_id"`
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/example/ec-event-driven/internal/domain/aggregate"
 	"github.com/example/ec-event-driven/internal/infrastructure/store"
 	"github.com/google/uuid"
 )
@@ -73,7 +74,7 @@ func (s *Service) Create(ctx context.Context, name, slug, description, parentID
 		CreatedAt:   now,
 	}
 
-	_, err := s.eventStore.Append(ctx, categoryID, AggregateType, EventCategoryCreated, event)
+	_, err := s.eventStore.AppendWithExpectedVersion(ctx, categoryID, AggregateType, EventCategoryCreated, 0, event)
 	if err != nil {
 		return nil, err
 	}
@@ -96,8 +97,11 @@ func (s *Service) Update(ctx context.Context, categoryID, name, slug, descriptio
 		return ErrInvalidName
 	}
 
-	events := s.eventStore.GetEvents(categoryID)
-	if len(events) == 0 {
+	version, err := aggregate.CurrentVersion(ctx, s.eventStore, categoryID)
+	if err != nil {
+		return err
+	}
+	if version == 0 {
 		return ErrCategoryNotFound
 	}
 
@@ -119,14 +123,17 @@ func (s *Service) Update(ctx context.Context, categoryID, name, slug, descriptio
 		UpdatedAt:   time.Now(),
 	}
 
-	_, err := s.eventStore.Append(ctx, categoryID, AggregateType, EventCategoryUpdated, event)
+	_, err = s.eventStore.AppendWithExpectedVersion(ctx, categoryID, AggregateType, EventCategoryUpdated, version, event)
 	return err
 }
 
 // Delete deletes a category
 func (s *Service) Delete(ctx context.Context, categoryID string) error {
-	events := s.eventStore.GetEvents(categoryID)
-	if len(events) == 0 {
+	version, err := aggregate.CurrentVersion(ctx, s.eventStore, categoryID)
+	if err != nil {
+		return err
+	}
+	if version == 0 {
 		return ErrCategoryNotFound
 	}
 
@@ -135,7 +142,7 @@ func (s *Service) Delete(ctx context.Context, categoryID string) error {
 		DeletedAt:  time.Now(),
 	}
 
-	_, err := s.eventStore.Append(ctx, categoryID, AggregateType, EventCategoryDeleted, event)
+	_, err = s.eventStore.AppendWithExpectedVersion(ctx, categoryID, AggregateType, EventCategoryDeleted, version, event)
 	return err
 }
 
@@ -1,6 +1,10 @@
 package category
 
-import "time"
+import (
+	"time"
+
+	"github.com/example/ec-event-driven/internal/eventcodec"
+)
 
 const (
 	EventCategoryCreated = "CategoryCreated"
@@ -8,6 +12,12 @@ const (
 	EventCategoryDeleted = "CategoryDeleted"
 )
 
+func init() {
+	eventcodec.Register[CategoryCreated](EventCategoryCreated)
+	eventcodec.Register[CategoryUpdated](EventCategoryUpdated)
+	eventcodec.Register[CategoryDeleted](EventCategoryDeleted)
+}
+
 // CategoryCreated is emitted when a new category is created
 type CategoryCreated struct {
 	CategoryID  string    `json:"category_id"`
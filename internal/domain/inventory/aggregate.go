@@ -2,27 +2,44 @@ package inventory
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/example/ec-event-driven/internal/domain/aggregate"
+	"github.com/example/ec-event-driven/internal/eventcodec"
 	"github.com/example/ec-event-driven/internal/infrastructure/store"
 )
 
 const AggregateType = "Inventory"
 
+// snapshotSchemaVersion is the version of the Inventory state stored in snapshots.
+// Bump it whenever the Inventory struct changes so that old snapshots are rebuilt.
+const snapshotSchemaVersion = 3
+
+func init() {
+	aggregate.Register(AggregateType, func(id string) aggregate.Aggregate {
+		return &Inventory{ProductID: id}
+	}, aggregate.SnapshotPolicy{EveryEvents: store.SnapshotThreshold, SchemaVersion: snapshotSchemaVersion})
+}
+
 var (
-	ErrInsufficientStock = errors.New("insufficient stock")
-	ErrInvalidQuantity   = errors.New("quantity must be positive")
+	ErrInsufficientStock   = errors.New("insufficient stock")
+	ErrInvalidQuantity     = errors.New("quantity must be positive")
+	ErrReservationNotFound = errors.New("no open reservation for order")
+	ErrReservationExceeded = errors.New("quantity exceeds the order's reservation")
 )
 
 type Inventory struct {
-	ProductID     string `json:"product_id"`
-	TotalStock    int    `json:"total_stock"`
-	ReservedStock int    `json:"reserved_stock"`
-	Version       int    `json:"version"`
+	ProductID     string         `json:"product_id"`
+	TotalStock    int            `json:"total_stock"`
+	ReservedStock int            `json:"reserved_stock"`
+	Reservations  map[string]int `json:"reservations,omitempty"` // orderID -> quantity still reserved
+	// LegacyReserved is stock reserved by events written before reservations
+	// were tracked per order, which carry no order ID
+	LegacyReserved int `json:"legacy_reserved,omitempty"`
+	Version        int `json:"version"`
 }
 
 // Aggregate interface implementation
@@ -34,6 +51,64 @@ func (i *Inventory) AvailableStock() int {
 	return i.TotalStock - i.ReservedStock
 }
 
+// Reserved returns the quantity still reserved for an order
+func (i *Inventory) Reserved(orderID string) int {
+	return i.Reservations[orderID]
+}
+
+// canReserve checks that quantity is available to reserve
+func (i *Inventory) canReserve(quantity int) error {
+	if i.AvailableStock() < quantity {
+		return fmt.Errorf("%w: product %s has only %d available, requested %d",
+			ErrInsufficientStock, i.ProductID, i.AvailableStock(), quantity)
+	}
+	return nil
+}
+
+// canSettle checks that quantity of an order's reservation can be released or
+// deducted. Reservations that were fully released or deducted are settled
+// and cannot be settled again. Legacy reservations cannot be told apart by
+// order, so they are only settled when replaying legacy events.
+func (i *Inventory) canSettle(orderID string, quantity int) error {
+	reserved := i.Reserved(orderID)
+	if reserved == 0 {
+		return fmt.Errorf("%w %s on product %s", ErrReservationNotFound, orderID, i.ProductID)
+	}
+	if quantity > reserved {
+		return fmt.Errorf("%w: order %s has %d reserved on product %s, requested %d",
+			ErrReservationExceeded, orderID, reserved, i.ProductID, quantity)
+	}
+	return nil
+}
+
+func (i *Inventory) reserve(orderID string, quantity int) {
+	i.ReservedStock += quantity
+	if orderID == "" {
+		i.LegacyReserved += quantity
+		return
+	}
+	if i.Reservations == nil {
+		i.Reservations = make(map[string]int)
+	}
+	i.Reservations[orderID] += quantity
+}
+
+// settle releases quantity from the order's reservation, then from the legacy
+// reservations. Legacy histories may release or deduct more than was
+// reserved; the excess is ignored so that replaying them never leaves a
+// negative reservation.
+func (i *Inventory) settle(orderID string, quantity int) {
+	fromOrder := min(quantity, i.Reservations[orderID])
+	if remaining := i.Reservations[orderID] - fromOrder; remaining > 0 {
+		i.Reservations[orderID] = remaining
+	} else {
+		delete(i.Reservations, orderID)
+	}
+	fromLegacy := min(quantity-fromOrder, i.LegacyReserved)
+	i.LegacyReserved -= fromLegacy
+	i.ReservedStock -= fromOrder + fromLegacy
+}
+
 type Service struct {
 	eventStore store.EventStoreInterface
 }
@@ -44,42 +119,21 @@ func NewService(es store.EventStoreInterface) *Service {
 
 // ApplyEvent applies a single event to the inventory state (implements aggregate.Aggregate)
 func (i *Inventory) ApplyEvent(event store.Event) error {
-	switch event.EventType {
-	case EventStockAdded:
-		var data StockAdded
-		if err := json.Unmarshal(event.Data, &data); err != nil {
-			return err
-		}
+	decoded, err := eventcodec.Decode(event)
+	if err != nil {
+		return err
+	}
+	switch data := decoded.(type) {
+	case StockAdded:
 		i.ProductID = data.ProductID
 		i.TotalStock += data.Quantity
-	case EventStockReserved:
-		var data StockReserved
-		if err := json.Unmarshal(event.Data, &data); err != nil {
-			return err
-		}
-		i.ReservedStock += data.Quantity
-	case EventStockReleased:
-		var data StockReleased
-		if err := json.Unmarshal(event.Data, &data); err != nil {
-			return err
-		}
-		i.ReservedStock -= data.Quantity
-		if i.ReservedStock < 0 {
-			i.ReservedStock = 0
-		}
-	case EventStockDeducted:
-		var data StockDeducted
-		if err := json.Unmarshal(event.Data, &data); err != nil {
-			return err
-		}
-		i.TotalStock -= data.Quantity
-		i.ReservedStock -= data.Quantity
-		if i.TotalStock < 0 {
-			i.TotalStock = 0
-		}
-		if i.ReservedStock < 0 {
-			i.ReservedStock = 0
-		}
+	case StockReserved:
+		i.reserve(data.OrderID, data.Quantity)
+	case StockReleased:
+		i.settle(data.OrderID, data.Quantity)
+	case StockDeducted:
+		i.settle(data.OrderID, data.Quantity)
+		i.TotalStock = max(i.TotalStock-data.Quantity, 0)
 	}
 	i.Version = event.Version
 	return nil
@@ -102,10 +156,10 @@ func (s *Service) AddStock(ctx context.Context, productID string, quantity int)
 		return ErrInvalidQuantity
 	}
 
-	// Load current inventory state for snapshot check
+	// Load current inventory state for version and snapshot checks
 	inv, err := s.loadInventory(ctx, productID)
 	if err != nil {
-		inv = &Inventory{ProductID: productID}
+		return err
 	}
 
 	event := StockAdded{
@@ -114,7 +168,7 @@ func (s *Service) AddStock(ctx context.Context, productID string, quantity int)
 		AddedAt:   time.Now(),
 	}
 
-	storedEvent, err := s.eventStore.Append(ctx, productID, AggregateType, EventStockAdded, event)
+	storedEvent, err := s.eventStore.AppendWithExpectedVersion(ctx, productID, AggregateType, EventStockAdded, inv.Version, event)
 	if err != nil {
 		return err
 	}
@@ -133,15 +187,22 @@ func (s *Service) AddStock(ctx context.Context, productID string, quantity int)
 	return nil
 }
 
-func (s *Service) Reserve(ctx context.Context, productID, orderID string, quantity int) error {
+// PrepareReserve loads the inventory, checks that quantity is available and returns
+// the StockReserved event to append together with the inventory state once the
+// event is stored. The event is appended with the loaded version, so a concurrent
+// change to the inventory fails the append instead of overselling.
+func (s *Service) PrepareReserve(ctx context.Context, productID, orderID string, quantity int) (*Inventory, store.PendingEvent, error) {
 	if quantity <= 0 {
-		return ErrInvalidQuantity
+		return nil, store.PendingEvent{}, ErrInvalidQuantity
 	}
 
-	// Load current inventory state for snapshot check
+	// Load current inventory state for version and snapshot checks
 	inv, err := s.loadInventory(ctx, productID)
 	if err != nil {
-		inv = &Inventory{ProductID: productID}
+		return nil, store.PendingEvent{}, err
+	}
+	if err := inv.canReserve(quantity); err != nil {
+		return nil, store.PendingEvent{}, err
 	}
 
 	event := StockReserved{
@@ -151,13 +212,32 @@ func (s *Service) Reserve(ctx context.Context, productID, orderID string, quanti
 		ReservedAt: time.Now(),
 	}
 
-	storedEvent, err := s.eventStore.Append(ctx, productID, AggregateType, EventStockReserved, event)
+	pending := store.PendingEvent{
+		AggregateID:     productID,
+		AggregateType:   AggregateType,
+		EventType:       EventStockReserved,
+		ExpectedVersion: inv.Version,
+		Data:            event,
+	}
+
+	inv.reserve(orderID, quantity)
+	inv.Version++
+
+	return inv, pending, nil
+}
+
+// Reserve reserves quantity of the available stock for an order
+func (s *Service) Reserve(ctx context.Context, productID, orderID string, quantity int) error {
+	inv, pending, err := s.PrepareReserve(ctx, productID, orderID, quantity)
+	if err != nil {
+		return err
+	}
+
+	storedEvent, err := s.eventStore.AppendWithExpectedVersion(ctx, pending.AggregateID, pending.AggregateType, pending.EventType, pending.ExpectedVersion, pending.Data)
 	if err != nil {
 		return err
 	}
 
-	// Update inventory for snapshot check
-	inv.ReservedStock += quantity
 	if storedEvent != nil {
 		inv.Version = storedEvent.Version
 	}
@@ -170,15 +250,19 @@ func (s *Service) Reserve(ctx context.Context, productID, orderID string, quanti
 	return nil
 }
 
+// Release releases quantity of the stock reserved for an order
 func (s *Service) Release(ctx context.Context, productID, orderID string, quantity int) error {
 	if quantity <= 0 {
 		return ErrInvalidQuantity
 	}
 
-	// Load current inventory state for snapshot check
+	// Load current inventory state for version and snapshot checks
 	inv, err := s.loadInventory(ctx, productID)
 	if err != nil {
-		inv = &Inventory{ProductID: productID}
+		return err
+	}
+	if err := inv.canSettle(orderID, quantity); err != nil {
+		return err
 	}
 
 	event := StockReleased{
@@ -188,16 +272,13 @@ func (s *Service) Release(ctx context.Context, productID, orderID string, quanti
 		ReleasedAt: time.Now(),
 	}
 
-	storedEvent, err := s.eventStore.Append(ctx, productID, AggregateType, EventStockReleased, event)
+	storedEvent, err := s.eventStore.AppendWithExpectedVersion(ctx, productID, AggregateType, EventStockReleased, inv.Version, event)
 	if err != nil {
 		return err
 	}
 
 	// Update inventory for snapshot check
-	inv.ReservedStock -= quantity
-	if inv.ReservedStock < 0 {
-		inv.ReservedStock = 0
-	}
+	inv.settle(orderID, quantity)
 	if storedEvent != nil {
 		inv.Version = storedEvent.Version
 	}
@@ -210,15 +291,22 @@ func (s *Service) Release(ctx context.Context, productID, orderID string, quanti
 	return nil
 }
 
-func (s *Service) Deduct(ctx context.Context, productID, orderID string, quantity int) error {
+// PrepareDeduct loads the inventory, checks that quantity is reserved for the
+// order and returns the StockDeducted event to append together with the
+// inventory state once the event is stored. The event is appended with the
+// loaded version, so a concurrent change to the inventory fails the append.
+func (s *Service) PrepareDeduct(ctx context.Context, productID, orderID string, quantity int) (*Inventory, store.PendingEvent, error) {
 	if quantity <= 0 {
-		return ErrInvalidQuantity
+		return nil, store.PendingEvent{}, ErrInvalidQuantity
 	}
 
-	// Load current inventory state for snapshot check
+	// Load current inventory state for version and snapshot checks
 	inv, err := s.loadInventory(ctx, productID)
 	if err != nil {
-		inv = &Inventory{ProductID: productID}
+		return nil, store.PendingEvent{}, err
+	}
+	if err := inv.canSettle(orderID, quantity); err != nil {
+		return nil, store.PendingEvent{}, err
 	}
 
 	event := StockDeducted{
@@ -228,20 +316,34 @@ func (s *Service) Deduct(ctx context.Context, productID, orderID string, quantit
 		DeductedAt: time.Now(),
 	}
 
-	storedEvent, err := s.eventStore.Append(ctx, productID, AggregateType, EventStockDeducted, event)
-	if err != nil {
-		return err
+	pending := store.PendingEvent{
+		AggregateID:     productID,
+		AggregateType:   AggregateType,
+		EventType:       EventStockDeducted,
+		ExpectedVersion: inv.Version,
+		Data:            event,
 	}
 
-	// Update inventory for snapshot check
+	inv.settle(orderID, quantity)
 	inv.TotalStock -= quantity
-	inv.ReservedStock -= quantity
-	if inv.TotalStock < 0 {
-		inv.TotalStock = 0
+	inv.Version++
+
+	return inv, pending, nil
+}
+
+// Deduct removes quantity of the stock reserved for an order from the total
+// stock, e.g. when the order ships
+func (s *Service) Deduct(ctx context.Context, productID, orderID string, quantity int) error {
+	inv, pending, err := s.PrepareDeduct(ctx, productID, orderID, quantity)
+	if err != nil {
+		return err
 	}
-	if inv.ReservedStock < 0 {
-		inv.ReservedStock = 0
+
+	storedEvent, err := s.eventStore.AppendWithExpectedVersion(ctx, pending.AggregateID, pending.AggregateType, pending.EventType, pending.ExpectedVersion, pending.Data)
+	if err != nil {
+		return err
 	}
+
 	if storedEvent != nil {
 		inv.Version = storedEvent.Version
 	}
@@ -17,6 +17,12 @@ func newTestInventoryService() (*Service, *mocks.MockEventStore) {
 	return service, eventStore
 }
 
+// addStockAndReserve stores stock of a product and a reservation of part of it for an order
+func addStockAndReserve(eventStore *mocks.MockEventStore, productID string, stock int, orderID string, reserved int) {
+	_ = eventStore.AddEvent(productID, AggregateType, EventStockAdded, StockAdded{ProductID: productID, Quantity: stock})
+	_ = eventStore.AddEvent(productID, AggregateType, EventStockReserved, StockReserved{ProductID: productID, OrderID: orderID, Quantity: reserved})
+}
+
 // ============================================
 // Inventory Struct Tests
 // ============================================
@@ -104,6 +110,7 @@ func TestService_AddStock_NegativeQuantity(t *testing.T) {
 func TestService_Reserve_ValidQuantity(t *testing.T) {
 	service, eventStore := newTestInventoryService()
 	ctx := context.Background()
+	_ = eventStore.AddEvent("prod-123", AggregateType, EventStockAdded, StockAdded{ProductID: "prod-123", Quantity: 10})
 
 	err := service.Reserve(ctx, "prod-123", "order-456", 5)
 
@@ -145,6 +152,7 @@ func TestService_Reserve_NegativeQuantity(t *testing.T) {
 func TestService_Release_ValidQuantity(t *testing.T) {
 	service, eventStore := newTestInventoryService()
 	ctx := context.Background()
+	addStockAndReserve(eventStore, "prod-123", 10, "order-456", 5)
 
 	err := service.Release(ctx, "prod-123", "order-456", 5)
 
@@ -186,6 +194,7 @@ func TestService_Release_NegativeQuantity(t *testing.T) {
 func TestService_Deduct_ValidQuantity(t *testing.T) {
 	service, eventStore := newTestInventoryService()
 	ctx := context.Background()
+	addStockAndReserve(eventStore, "prod-123", 20, "order-456", 10)
 
 	err := service.Deduct(ctx, "prod-123", "order-456", 10)
 
@@ -220,6 +229,115 @@ func TestService_Deduct_NegativeQuantity(t *testing.T) {
 	assert.Empty(t, eventStore.AppendCalls)
 }
 
+// ============================================
+// Invariant Tests
+// ============================================
+
+func TestService_Reserve_InsufficientStock(t *testing.T) {
+	service, eventStore := newTestInventoryService()
+	ctx := context.Background()
+	addStockAndReserve(eventStore, "prod-123", 10, "order-1", 8)
+
+	err := service.Reserve(ctx, "prod-123", "order-2", 3)
+
+	assert.ErrorIs(t, err, ErrInsufficientStock)
+	assert.Contains(t, err.Error(), "only 2 available")
+	assert.Empty(t, eventStore.AppendCalls)
+}
+
+func TestService_Release_UnknownReservation(t *testing.T) {
+	service, eventStore := newTestInventoryService()
+	ctx := context.Background()
+	addStockAndReserve(eventStore, "prod-123", 10, "order-1", 5)
+
+	err := service.Release(ctx, "prod-123", "order-2", 1)
+
+	assert.ErrorIs(t, err, ErrReservationNotFound)
+	assert.Empty(t, eventStore.AppendCalls)
+}
+
+func TestService_Release_MoreThanReserved(t *testing.T) {
+	service, eventStore := newTestInventoryService()
+	ctx := context.Background()
+	addStockAndReserve(eventStore, "prod-123", 10, "order-1", 5)
+
+	err := service.Release(ctx, "prod-123", "order-1", 6)
+
+	assert.ErrorIs(t, err, ErrReservationExceeded)
+	assert.Empty(t, eventStore.AppendCalls)
+}
+
+func TestService_SettledReservationCannotBeSettledAgain(t *testing.T) {
+	service, eventStore := newTestInventoryService()
+	ctx := context.Background()
+	addStockAndReserve(eventStore, "prod-123", 10, "order-1", 5)
+
+	require.NoError(t, service.Deduct(ctx, "prod-123", "order-1", 5))
+
+	assert.ErrorIs(t, service.Release(ctx, "prod-123", "order-1", 5), ErrReservationNotFound)
+	assert.ErrorIs(t, service.Deduct(ctx, "prod-123", "order-1", 5), ErrReservationNotFound)
+	assert.Len(t, eventStore.AppendCalls, 1)
+}
+
+func TestInventory_ApplyEvent_TracksReservationsPerOrder(t *testing.T) {
+	service, _ := newTestInventoryService()
+	ctx := context.Background()
+	productID := "prod-123"
+
+	require.NoError(t, service.AddStock(ctx, productID, 100))
+	require.NoError(t, service.Reserve(ctx, productID, "order-1", 20))
+	require.NoError(t, service.Reserve(ctx, productID, "order-2", 10))
+	require.NoError(t, service.Release(ctx, productID, "order-1", 5))
+	require.NoError(t, service.Deduct(ctx, productID, "order-2", 10))
+
+	inv, err := service.loadInventory(ctx, productID)
+	require.NoError(t, err)
+	assert.Equal(t, 90, inv.TotalStock)
+	assert.Equal(t, 15, inv.ReservedStock)
+	assert.Equal(t, map[string]int{"order-1": 15}, inv.Reservations)
+	assert.Equal(t, 15, inv.Reserved("order-1"))
+	assert.Equal(t, 0, inv.Reserved("order-2"))
+}
+
+func TestInventory_ApplyEvent_ReplaysLegacyHistory(t *testing.T) {
+	service, eventStore := newTestInventoryService()
+	ctx := context.Background()
+	productID := "prod-123"
+
+	// Written before reservations were tracked per order: some events lack
+	// the order ID and more is released or deducted than was reserved
+	_ = eventStore.AddEvent(productID, AggregateType, EventStockAdded, StockAdded{ProductID: productID, Quantity: 10})
+	_ = eventStore.AddEvent(productID, AggregateType, EventStockReserved, StockReserved{ProductID: productID, Quantity: 4})
+	_ = eventStore.AddEvent(productID, AggregateType, EventStockReserved, StockReserved{ProductID: productID, OrderID: "order-1", Quantity: 3})
+	_ = eventStore.AddEvent(productID, AggregateType, EventStockReleased, StockReleased{ProductID: productID, Quantity: 6})
+	_ = eventStore.AddEvent(productID, AggregateType, EventStockReleased, StockReleased{ProductID: productID, OrderID: "order-2", Quantity: 2})
+	_ = eventStore.AddEvent(productID, AggregateType, EventStockDeducted, StockDeducted{ProductID: productID, OrderID: "order-1", Quantity: 3})
+	_ = eventStore.AddEvent(productID, AggregateType, EventStockDeducted, StockDeducted{ProductID: productID, Quantity: 20})
+
+	inv, err := service.loadInventory(ctx, productID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, inv.TotalStock)
+	assert.Equal(t, 0, inv.ReservedStock)
+	assert.Equal(t, 0, inv.LegacyReserved)
+	assert.Empty(t, inv.Reservations)
+	assert.Equal(t, 7, inv.Version)
+}
+
+func TestService_Release_UnknownOrderDoesNotSettleLegacyReservation(t *testing.T) {
+	service, eventStore := newTestInventoryService()
+	ctx := context.Background()
+	addStockAndReserve(eventStore, "prod-123", 10, "", 5)
+
+	assert.ErrorIs(t, service.Release(ctx, "prod-123", "order-1", 1), ErrReservationNotFound)
+	assert.ErrorIs(t, service.Deduct(ctx, "prod-123", "order-1", 1), ErrReservationNotFound)
+	assert.Empty(t, eventStore.AppendCalls)
+
+	inv, err := service.loadInventory(ctx, "prod-123")
+	require.NoError(t, err)
+	assert.Equal(t, 5, inv.ReservedStock)
+	assert.Equal(t, 5, inv.LegacyReserved)
+}
+
 // ============================================
 // Integration-like Tests
 // ============================================
@@ -303,6 +421,7 @@ func TestInventoryService_LoadInventoryFromSnapshot(t *testing.T) {
 		AggregateType: AggregateType,
 		Version:       10,
 		State:         stateJSON,
+		SchemaVersion: snapshotSchemaVersion,
 	})
 
 	// Add more stock - this should load from snapshot first
@@ -333,6 +452,7 @@ func TestInventoryService_LoadInventoryFromSnapshotWithSubsequentEvents(t *testi
 		AggregateType: AggregateType,
 		Version:       5,
 		State:         stateJSON,
+		SchemaVersion: snapshotSchemaVersion,
 	})
 
 	// Add events after the snapshot
@@ -1,6 +1,10 @@
 package inventory
 
-import "time"
+import (
+	"time"
+
+	"github.com/example/ec-event-driven/internal/eventcodec"
+)
 
 const (
 	EventStockAdded    = "StockAdded"
@@ -9,6 +13,13 @@ const (
 	EventStockDeducted = "StockDeducted"
 )
 
+func init() {
+	eventcodec.Register[StockAdded](EventStockAdded)
+	eventcodec.Register[StockReserved](EventStockReserved)
+	eventcodec.Register[StockReleased](EventStockReleased)
+	eventcodec.Register[StockDeducted](EventStockDeducted)
+}
+
 type StockAdded struct {
 	ProductID string    `json:"product_id"`
 	Quantity  int       `json:"quantity"`
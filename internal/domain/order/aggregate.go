@@ -2,19 +2,30 @@ package order
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/example/ec-event-driven/internal/domain/aggregate"
+	"github.com/example/ec-event-driven/internal/eventcodec"
 	"github.com/example/ec-event-driven/internal/infrastructure/store"
 	"github.com/google/uuid"
 )
 
 const AggregateType = "Order"
 
+// snapshotSchemaVersion is the version of the Order state stored in snapshots.
+// Bump it whenever the Order struct changes so that old snapshots are rebuilt.
+const snapshotSchemaVersion = 3
+
+func init() {
+	aggregate.Register(AggregateType, func(id string) aggregate.Aggregate {
+		return &Order{}
+	}, aggregate.SnapshotPolicy{EveryEvents: store.SnapshotThreshold, SchemaVersion: snapshotSchemaVersion})
+}
+
 type Status string
 
 const (
@@ -32,6 +43,7 @@ var (
 	ErrOrderNotPaid     = errors.New("order must be paid before shipping")
 	ErrOrderShipped     = errors.New("cannot cancel shipped order")
 	ErrOrderCancelled   = errors.New("order is already cancelled")
+	ErrMissingTracking  = errors.New("carrier and tracking number are required to ship an order")
 )
 
 // validTransitions defines allowed state transitions
@@ -56,8 +68,8 @@ func (o *Order) CanTransitionTo(target Status) bool {
 	return false
 }
 
-// transitionError returns an appropriate error for an invalid transition
-func (o *Order) transitionError(target Status) error {
+// TransitionError returns an appropriate error for an invalid transition
+func (o *Order) TransitionError(target Status) error {
 	switch {
 	case o.Status == StatusCancelled:
 		return ErrOrderCancelled
@@ -73,14 +85,32 @@ func (o *Order) transitionError(target Status) error {
 }
 
 type Order struct {
-	ID        string      `json:"id"`
-	UserID    string      `json:"user_id"`
-	Items     []OrderItem `json:"items"`
-	Total     int         `json:"total"`
-	Status    Status      `json:"status"`
-	CreatedAt time.Time   `json:"created_at"`
-	UpdatedAt time.Time   `json:"updated_at"`
-	Version   int         `json:"version"` // Current event version
+	ID              string      `json:"id"`
+	UserID          string      `json:"user_id"`
+	Items           []OrderItem `json:"items"`
+	Total           int         `json:"total"`
+	Status          Status      `json:"status"`
+	PaymentAttempts int         `json:"payment_attempts,omitempty"`
+	Payment         *Payment    `json:"payment,omitempty"`  // Captured payment
+	Shipment        *Shipment   `json:"shipment,omitempty"` // Set once shipped
+	CreatedAt       time.Time   `json:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at"`
+	Version         int         `json:"version"` // Current event version
+}
+
+// Payment is the payment captured for an order
+type Payment struct {
+	Provider  string `json:"provider"`
+	CaptureID string `json:"capture_id"`
+	Amount    int    `json:"amount"`
+	Refunded  int    `json:"refunded,omitempty"`
+}
+
+// Shipment is the tracking details of a shipped order
+type Shipment struct {
+	Carrier        string    `json:"carrier"`
+	TrackingNumber string    `json:"tracking_number"`
+	ShippedAt      time.Time `json:"shipped_at"`
 }
 
 // Aggregate interface implementation
@@ -96,32 +126,14 @@ func NewService(es store.EventStoreInterface) *Service {
 	return &Service{eventStore: es}
 }
 
-// rebuildStatus reconstructs the current order status from events
-func (s *Service) rebuildStatus(events []store.Event) Status {
-	status := StatusPending
-	for _, event := range events {
-		switch event.EventType {
-		case EventOrderPlaced:
-			status = StatusPending
-		case EventOrderPaid:
-			status = StatusPaid
-		case EventOrderShipped:
-			status = StatusShipped
-		case EventOrderCancelled:
-			status = StatusCancelled
-		}
-	}
-	return status
-}
-
 // ApplyEvent applies a single event to the order state (implements aggregate.Aggregate)
 func (o *Order) ApplyEvent(event store.Event) error {
-	switch event.EventType {
-	case EventOrderPlaced:
-		var data OrderPlaced
-		if err := json.Unmarshal(event.Data, &data); err != nil {
-			return err
-		}
+	decoded, err := eventcodec.Decode(event)
+	if err != nil {
+		return err
+	}
+	switch data := decoded.(type) {
+	case OrderPlaced:
 		o.ID = data.OrderID
 		o.UserID = data.UserID
 		o.Items = data.Items
@@ -129,25 +141,23 @@ func (o *Order) ApplyEvent(event store.Event) error {
 		o.Status = StatusPending
 		o.CreatedAt = data.PlacedAt
 		o.UpdatedAt = data.PlacedAt
-	case EventOrderPaid:
-		var data OrderPaid
-		if err := json.Unmarshal(event.Data, &data); err != nil {
-			return err
-		}
+	case OrderPaid:
 		o.Status = StatusPaid
 		o.UpdatedAt = data.PaidAt
-	case EventOrderShipped:
-		var data OrderShipped
-		if err := json.Unmarshal(event.Data, &data); err != nil {
-			return err
+		if data.CaptureID != "" {
+			o.Payment = &Payment{Provider: data.Provider, CaptureID: data.CaptureID, Amount: data.Amount}
+		}
+	case PaymentAttempted:
+		o.PaymentAttempts++
+	case PaymentRefunded:
+		if o.Payment != nil && o.Payment.CaptureID == data.CaptureID {
+			o.Payment.Refunded += data.Amount
 		}
+	case OrderShipped:
 		o.Status = StatusShipped
 		o.UpdatedAt = data.ShippedAt
-	case EventOrderCancelled:
-		var data OrderCancelled
-		if err := json.Unmarshal(event.Data, &data); err != nil {
-			return err
-		}
+		o.Shipment = &Shipment{Carrier: data.Carrier, TrackingNumber: data.TrackingNumber, ShippedAt: data.ShippedAt}
+	case OrderCancelled:
 		o.Status = StatusCancelled
 		o.UpdatedAt = data.CancelledAt
 	}
@@ -170,9 +180,17 @@ func (s *Service) loadOrder(ctx context.Context, orderID string) (*Order, error)
 }
 
 
-func (s *Service) Place(ctx context.Context, userID string, items []OrderItem) (*Order, error) {
+// Get loads an order from the event store
+func (s *Service) Get(ctx context.Context, orderID string) (*Order, error) {
+	return s.loadOrder(ctx, orderID)
+}
+
+// PreparePlace validates a new order and returns it together with the OrderPlaced
+// event to append. The returned order already carries the version it has once
+// the event is stored.
+func (s *Service) PreparePlace(userID string, items []OrderItem) (*Order, store.PendingEvent, error) {
 	if len(items) == 0 {
-		return nil, ErrEmptyOrder
+		return nil, store.PendingEvent{}, ErrEmptyOrder
 	}
 
 	orderID := uuid.New().String()
@@ -191,16 +209,6 @@ func (s *Service) Place(ctx context.Context, userID string, items []OrderItem) (
 		PlacedAt: now,
 	}
 
-	storedEvent, err := s.eventStore.Append(ctx, orderID, AggregateType, EventOrderPlaced, event)
-	if err != nil {
-		return nil, err
-	}
-
-	version := 0
-	if storedEvent != nil {
-		version = storedEvent.Version
-	}
-
 	order := &Order{
 		ID:        orderID,
 		UserID:    userID,
@@ -209,7 +217,31 @@ func (s *Service) Place(ctx context.Context, userID string, items []OrderItem) (
 		Status:    StatusPending,
 		CreatedAt: now,
 		UpdatedAt: now,
-		Version:   version,
+		Version:   1,
+	}
+
+	return order, store.PendingEvent{
+		AggregateID:     orderID,
+		AggregateType:   AggregateType,
+		EventType:       EventOrderPlaced,
+		ExpectedVersion: 0,
+		Data:            event,
+	}, nil
+}
+
+func (s *Service) Place(ctx context.Context, userID string, items []OrderItem) (*Order, error) {
+	order, pending, err := s.PreparePlace(userID, items)
+	if err != nil {
+		return nil, err
+	}
+
+	storedEvent, err := s.eventStore.AppendWithExpectedVersion(ctx, pending.AggregateID, pending.AggregateType, pending.EventType, pending.ExpectedVersion, pending.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if storedEvent != nil {
+		order.Version = storedEvent.Version
 	}
 
 	// Check if we need to create a snapshot
@@ -220,30 +252,87 @@ func (s *Service) Place(ctx context.Context, userID string, items []OrderItem) (
 	return order, nil
 }
 
-func (s *Service) Pay(ctx context.Context, orderID string) error {
+// StartPayment records an attempt to pay a pending order for its total and
+// returns the order, before the payment gateway is asked to charge it
+func (s *Service) StartPayment(ctx context.Context, orderID, attemptID, provider string) (*Order, error) {
+	order, err := s.loadOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !order.CanTransitionTo(StatusPaid) {
+		return nil, order.TransitionError(StatusPaid)
+	}
+
+	event := PaymentAttempted{
+		OrderID:     orderID,
+		AttemptID:   attemptID,
+		Provider:    provider,
+		Amount:      order.Total,
+		AttemptedAt: time.Now(),
+	}
+	if err := s.record(ctx, order, EventPaymentAttempted, event); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// FailPayment records that a payment attempt failed. The order stays pending.
+func (s *Service) FailPayment(ctx context.Context, orderID string, failure PaymentFailed) error {
 	order, err := s.loadOrder(ctx, orderID)
 	if err != nil {
 		return err
 	}
 
+	failure.OrderID = orderID
+	failure.FailedAt = time.Now()
+	return s.record(ctx, order, EventPaymentFailed, failure)
+}
+
+// CompletePayment marks a pending order as paid with the payment captured by
+// the gateway and returns the order
+func (s *Service) CompletePayment(ctx context.Context, orderID string, paid OrderPaid) (*Order, error) {
+	order, err := s.loadOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
 	if !order.CanTransitionTo(StatusPaid) {
-		return order.transitionError(StatusPaid)
+		return nil, order.TransitionError(StatusPaid)
 	}
 
-	event := OrderPaid{
-		OrderID: orderID,
-		PaidAt:  time.Now(),
+	paid.OrderID = orderID
+	paid.PaidAt = time.Now()
+	if err := s.record(ctx, order, EventOrderPaid, paid); err != nil {
+		return nil, err
 	}
+	return order, nil
+}
 
-	storedEvent, err := s.eventStore.Append(ctx, orderID, AggregateType, EventOrderPaid, event)
+// RecordRefund records that a captured payment of the order was refunded
+func (s *Service) RecordRefund(ctx context.Context, orderID string, refund PaymentRefunded) error {
+	order, err := s.loadOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	refund.OrderID = orderID
+	refund.RefundedAt = time.Now()
+	return s.record(ctx, order, EventPaymentRefunded, refund)
+}
+
+// record appends an event to a loaded order, applies it to the order and
+// creates a snapshot if one is due
+func (s *Service) record(ctx context.Context, order *Order, eventType string, data any) error {
+	storedEvent, err := s.eventStore.AppendWithExpectedVersion(ctx, order.ID, AggregateType, eventType, order.Version, data)
 	if err != nil {
 		return err
 	}
 
-	// Update order for snapshot check
-	order.Status = StatusPaid
 	if storedEvent != nil {
-		order.Version = storedEvent.Version
+		if err := order.ApplyEvent(*storedEvent); err != nil {
+			return err
+		}
 	}
 
 	// Check if we need to create a snapshot
@@ -254,28 +343,60 @@ func (s *Service) Pay(ctx context.Context, orderID string) error {
 	return nil
 }
 
-func (s *Service) Ship(ctx context.Context, orderID string) error {
+// PrepareShip loads a paid order, checks that it can ship with the given
+// tracking details and returns it together with the OrderShipped event to
+// append. The returned order already carries the state and version it has
+// once the event is stored.
+func (s *Service) PrepareShip(ctx context.Context, orderID, carrier, trackingNumber string) (*Order, store.PendingEvent, error) {
+	carrier, trackingNumber = strings.TrimSpace(carrier), strings.TrimSpace(trackingNumber)
+	if carrier == "" || trackingNumber == "" {
+		return nil, store.PendingEvent{}, ErrMissingTracking
+	}
+
 	order, err := s.loadOrder(ctx, orderID)
 	if err != nil {
-		return err
+		return nil, store.PendingEvent{}, err
 	}
 
 	if !order.CanTransitionTo(StatusShipped) {
-		return order.transitionError(StatusShipped)
+		return nil, store.PendingEvent{}, order.TransitionError(StatusShipped)
 	}
 
 	event := OrderShipped{
-		OrderID:   orderID,
-		ShippedAt: time.Now(),
+		OrderID:        orderID,
+		ShippedAt:      time.Now(),
+		Carrier:        carrier,
+		TrackingNumber: trackingNumber,
 	}
 
-	storedEvent, err := s.eventStore.Append(ctx, orderID, AggregateType, EventOrderShipped, event)
+	pending := store.PendingEvent{
+		AggregateID:     orderID,
+		AggregateType:   AggregateType,
+		EventType:       EventOrderShipped,
+		ExpectedVersion: order.Version,
+		Data:            event,
+	}
+
+	order.Status = StatusShipped
+	order.UpdatedAt = event.ShippedAt
+	order.Shipment = &Shipment{Carrier: carrier, TrackingNumber: trackingNumber, ShippedAt: event.ShippedAt}
+	order.Version++
+
+	return order, pending, nil
+}
+
+// Ship marks a paid order as shipped with its tracking details
+func (s *Service) Ship(ctx context.Context, orderID, carrier, trackingNumber string) error {
+	order, pending, err := s.PrepareShip(ctx, orderID, carrier, trackingNumber)
+	if err != nil {
+		return err
+	}
+
+	storedEvent, err := s.eventStore.AppendWithExpectedVersion(ctx, pending.AggregateID, pending.AggregateType, pending.EventType, pending.ExpectedVersion, pending.Data)
 	if err != nil {
 		return err
 	}
 
-	// Update order for snapshot check
-	order.Status = StatusShipped
 	if storedEvent != nil {
 		order.Version = storedEvent.Version
 	}
@@ -295,7 +416,7 @@ func (s *Service) Cancel(ctx context.Context, orderID, reason string) error {
 	}
 
 	if !order.CanTransitionTo(StatusCancelled) {
-		return order.transitionError(StatusCancelled)
+		return order.TransitionError(StatusCancelled)
 	}
 
 	event := OrderCancelled{
@@ -304,7 +425,7 @@ func (s *Service) Cancel(ctx context.Context, orderID, reason string) error {
 		CancelledAt: time.Now(),
 	}
 
-	storedEvent, err := s.eventStore.Append(ctx, orderID, AggregateType, EventOrderCancelled, event)
+	storedEvent, err := s.eventStore.AppendWithExpectedVersion(ctx, orderID, AggregateType, EventOrderCancelled, order.Version, event)
 	if err != nil {
 		return err
 	}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/example/ec-event-driven/internal/infrastructure/store"
 	"github.com/example/ec-event-driven/internal/infrastructure/store/mocks"
@@ -85,7 +86,16 @@ func TestService_Place_NilItems(t *testing.T) {
 // Pay Order Tests - State Transitions
 // ============================================
 
-func TestService_Pay_FromPending_Success(t *testing.T) {
+// pay runs a successful payment of an order through the service
+func pay(ctx context.Context, service *Service, orderID string) error {
+	if _, err := service.StartPayment(ctx, orderID, "attempt-1", "fake"); err != nil {
+		return err
+	}
+	_, err := service.CompletePayment(ctx, orderID, OrderPaid{AttemptID: "attempt-1", Provider: "fake", CaptureID: "cap-1"})
+	return err
+}
+
+func TestService_CompletePayment_FromPending_Success(t *testing.T) {
 	service, eventStore := newTestOrderService()
 	ctx := context.Background()
 
@@ -96,23 +106,24 @@ func TestService_Pay_FromPending_Success(t *testing.T) {
 		UserID:  "user-123",
 	})
 
-	err := service.Pay(ctx, orderID)
+	order, err := service.CompletePayment(ctx, orderID, OrderPaid{CaptureID: "cap-1"})
 
 	require.NoError(t, err)
+	assert.Equal(t, StatusPaid, order.Status)
 	assert.Len(t, eventStore.AppendCalls, 1)
 	assert.Equal(t, EventOrderPaid, eventStore.AppendCalls[0].EventType)
 }
 
-func TestService_Pay_OrderNotFound(t *testing.T) {
+func TestService_StartPayment_OrderNotFound(t *testing.T) {
 	service, _ := newTestOrderService()
 	ctx := context.Background()
 
-	err := service.Pay(ctx, "non-existent-order")
+	_, err := service.StartPayment(ctx, "non-existent-order", "attempt-1", "fake")
 
 	assert.ErrorIs(t, err, ErrOrderNotFound)
 }
 
-func TestService_Pay_AlreadyPaid(t *testing.T) {
+func TestService_StartPayment_AlreadyPaid(t *testing.T) {
 	service, eventStore := newTestOrderService()
 	ctx := context.Background()
 
@@ -120,12 +131,12 @@ func TestService_Pay_AlreadyPaid(t *testing.T) {
 	_ = eventStore.AddEvent(orderID, AggregateType, EventOrderPlaced, OrderPlaced{OrderID: orderID})
 	_ = eventStore.AddEvent(orderID, AggregateType, EventOrderPaid, OrderPaid{OrderID: orderID})
 
-	err := service.Pay(ctx, orderID)
+	_, err := service.StartPayment(ctx, orderID, "attempt-1", "fake")
 
 	assert.ErrorIs(t, err, ErrOrderAlreadyPaid)
 }
 
-func TestService_Pay_AlreadyShipped(t *testing.T) {
+func TestService_StartPayment_AlreadyShipped(t *testing.T) {
 	service, eventStore := newTestOrderService()
 	ctx := context.Background()
 
@@ -134,12 +145,52 @@ func TestService_Pay_AlreadyShipped(t *testing.T) {
 	_ = eventStore.AddEvent(orderID, AggregateType, EventOrderPaid, OrderPaid{OrderID: orderID})
 	_ = eventStore.AddEvent(orderID, AggregateType, EventOrderShipped, OrderShipped{OrderID: orderID})
 
-	err := service.Pay(ctx, orderID)
+	_, err := service.StartPayment(ctx, orderID, "attempt-1", "fake")
 
 	assert.ErrorIs(t, err, ErrOrderAlreadyPaid)
 }
 
-func TestService_Pay_Cancelled(t *testing.T) {
+// ============================================
+// Ship Order Tests - State Transitions
+// ============================================
+
+func TestService_PaymentLifecycle(t *testing.T) {
+	service, eventStore := newTestOrderService()
+	ctx := context.Background()
+
+	orderID := "order-123"
+	_ = eventStore.AddEvent(orderID, AggregateType, EventOrderPlaced, OrderPlaced{OrderID: orderID, Total: 3000})
+
+	order, err := service.StartPayment(ctx, orderID, "attempt-1", "fake")
+	require.NoError(t, err)
+	assert.Equal(t, 1, order.PaymentAttempts)
+	assert.Equal(t, 2, order.Version)
+	assert.Equal(t, PaymentAttempted{OrderID: orderID, AttemptID: "attempt-1", Provider: "fake", Amount: 3000},
+		withoutTime(eventStore.AppendCalls[0].Data.(PaymentAttempted)))
+
+	require.NoError(t, service.FailPayment(ctx, orderID, PaymentFailed{AttemptID: "attempt-1", Stage: "capture", Reason: "declined"}))
+	_, err = service.StartPayment(ctx, orderID, "attempt-2", "fake")
+	require.NoError(t, err)
+
+	order, err = service.CompletePayment(ctx, orderID, OrderPaid{AttemptID: "attempt-2", Provider: "fake", CaptureID: "cap-2", Amount: 3000})
+	require.NoError(t, err)
+	assert.Equal(t, StatusPaid, order.Status)
+	assert.Equal(t, 2, order.PaymentAttempts)
+	assert.Equal(t, &Payment{Provider: "fake", CaptureID: "cap-2", Amount: 3000}, order.Payment)
+
+	require.NoError(t, service.RecordRefund(ctx, orderID, PaymentRefunded{Provider: "fake", CaptureID: "cap-2", RefundID: "ref-1", Amount: 1000}))
+	order, err = service.Get(ctx, orderID)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, order.Payment.Refunded)
+	assert.Equal(t, 6, order.Version)
+
+	// Each payment event is appended at the version it was decided on
+	for i, call := range eventStore.AppendCalls {
+		assert.Equal(t, i+1, call.ExpectedVersion)
+	}
+}
+
+func TestService_StartPayment_NotPending(t *testing.T) {
 	service, eventStore := newTestOrderService()
 	ctx := context.Background()
 
@@ -147,14 +198,18 @@ func TestService_Pay_Cancelled(t *testing.T) {
 	_ = eventStore.AddEvent(orderID, AggregateType, EventOrderPlaced, OrderPlaced{OrderID: orderID})
 	_ = eventStore.AddEvent(orderID, AggregateType, EventOrderCancelled, OrderCancelled{OrderID: orderID})
 
-	err := service.Pay(ctx, orderID)
-
+	_, err := service.StartPayment(ctx, orderID, "attempt-1", "fake")
+	assert.ErrorIs(t, err, ErrOrderCancelled)
+	_, err = service.CompletePayment(ctx, orderID, OrderPaid{CaptureID: "cap-1"})
 	assert.ErrorIs(t, err, ErrOrderCancelled)
+	assert.Empty(t, eventStore.AppendCalls)
 }
 
-// ============================================
-// Ship Order Tests - State Transitions
-// ============================================
+// withoutTime clears the timestamp of a PaymentAttempted event for comparison
+func withoutTime(event PaymentAttempted) PaymentAttempted {
+	event.AttemptedAt = time.Time{}
+	return event
+}
 
 func TestService_Ship_FromPaid_Success(t *testing.T) {
 	service, eventStore := newTestOrderService()
@@ -164,18 +219,42 @@ func TestService_Ship_FromPaid_Success(t *testing.T) {
 	_ = eventStore.AddEvent(orderID, AggregateType, EventOrderPlaced, OrderPlaced{OrderID: orderID})
 	_ = eventStore.AddEvent(orderID, AggregateType, EventOrderPaid, OrderPaid{OrderID: orderID})
 
-	err := service.Ship(ctx, orderID)
+	err := service.Ship(ctx, orderID, "yamato", "1234-5678-9012")
 
 	require.NoError(t, err)
 	assert.Len(t, eventStore.AppendCalls, 1)
 	assert.Equal(t, EventOrderShipped, eventStore.AppendCalls[0].EventType)
+	shipped := eventStore.AppendCalls[0].Data.(OrderShipped)
+	assert.Equal(t, "yamato", shipped.Carrier)
+	assert.Equal(t, "1234-5678-9012", shipped.TrackingNumber)
+
+	order, err := service.Get(ctx, orderID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusShipped, order.Status)
+	require.NotNil(t, order.Shipment)
+	assert.Equal(t, "yamato", order.Shipment.Carrier)
+	assert.Equal(t, "1234-5678-9012", order.Shipment.TrackingNumber)
+	assert.True(t, shipped.ShippedAt.Equal(order.Shipment.ShippedAt))
+}
+
+func TestService_Ship_MissingTracking(t *testing.T) {
+	service, eventStore := newTestOrderService()
+	ctx := context.Background()
+
+	orderID := "order-123"
+	_ = eventStore.AddEvent(orderID, AggregateType, EventOrderPlaced, OrderPlaced{OrderID: orderID})
+	_ = eventStore.AddEvent(orderID, AggregateType, EventOrderPaid, OrderPaid{OrderID: orderID})
+
+	assert.ErrorIs(t, service.Ship(ctx, orderID, "", "1234-5678-9012"), ErrMissingTracking)
+	assert.ErrorIs(t, service.Ship(ctx, orderID, "yamato", "  "), ErrMissingTracking)
+	assert.Empty(t, eventStore.AppendCalls)
 }
 
 func TestService_Ship_OrderNotFound(t *testing.T) {
 	service, _ := newTestOrderService()
 	ctx := context.Background()
 
-	err := service.Ship(ctx, "non-existent-order")
+	err := service.Ship(ctx, "non-existent-order", "yamato", "1234-5678-9012")
 
 	assert.ErrorIs(t, err, ErrOrderNotFound)
 }
@@ -187,7 +266,7 @@ func TestService_Ship_FromPending(t *testing.T) {
 	orderID := "order-123"
 	_ = eventStore.AddEvent(orderID, AggregateType, EventOrderPlaced, OrderPlaced{OrderID: orderID})
 
-	err := service.Ship(ctx, orderID)
+	err := service.Ship(ctx, orderID, "yamato", "1234-5678-9012")
 
 	assert.ErrorIs(t, err, ErrOrderNotPaid)
 }
@@ -201,7 +280,7 @@ func TestService_Ship_AlreadyShipped(t *testing.T) {
 	_ = eventStore.AddEvent(orderID, AggregateType, EventOrderPaid, OrderPaid{OrderID: orderID})
 	_ = eventStore.AddEvent(orderID, AggregateType, EventOrderShipped, OrderShipped{OrderID: orderID})
 
-	err := service.Ship(ctx, orderID)
+	err := service.Ship(ctx, orderID, "yamato", "1234-5678-9012")
 
 	assert.ErrorIs(t, err, ErrInvalidStatus)
 }
@@ -214,7 +293,7 @@ func TestService_Ship_Cancelled(t *testing.T) {
 	_ = eventStore.AddEvent(orderID, AggregateType, EventOrderPlaced, OrderPlaced{OrderID: orderID})
 	_ = eventStore.AddEvent(orderID, AggregateType, EventOrderCancelled, OrderCancelled{OrderID: orderID})
 
-	err := service.Ship(ctx, orderID)
+	err := service.Ship(ctx, orderID, "yamato", "1234-5678-9012")
 
 	assert.ErrorIs(t, err, ErrOrderCancelled)
 }
@@ -293,66 +372,6 @@ func TestService_Cancel_AlreadyCancelled(t *testing.T) {
 	assert.ErrorIs(t, err, ErrOrderCancelled)
 }
 
-// ============================================
-// State Transition Matrix Tests
-// ============================================
-
-func TestRebuildStatus(t *testing.T) {
-	service, eventStore := newTestOrderService()
-	orderID := "order-123"
-
-	tests := []struct {
-		name           string
-		events         []string
-		expectedStatus Status
-	}{
-		{
-			name:           "no events (default)",
-			events:         []string{},
-			expectedStatus: StatusPending,
-		},
-		{
-			name:           "placed only",
-			events:         []string{EventOrderPlaced},
-			expectedStatus: StatusPending,
-		},
-		{
-			name:           "placed then paid",
-			events:         []string{EventOrderPlaced, EventOrderPaid},
-			expectedStatus: StatusPaid,
-		},
-		{
-			name:           "placed, paid, shipped",
-			events:         []string{EventOrderPlaced, EventOrderPaid, EventOrderShipped},
-			expectedStatus: StatusShipped,
-		},
-		{
-			name:           "placed then cancelled",
-			events:         []string{EventOrderPlaced, EventOrderCancelled},
-			expectedStatus: StatusCancelled,
-		},
-		{
-			name:           "placed, paid then cancelled",
-			events:         []string{EventOrderPlaced, EventOrderPaid, EventOrderCancelled},
-			expectedStatus: StatusCancelled,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			eventStore.Reset()
-			for _, eventType := range tt.events {
-				_ = eventStore.AddEvent(orderID, AggregateType, eventType, struct{}{})
-			}
-
-			events := eventStore.GetEvents(orderID)
-			status := service.rebuildStatus(events)
-
-			assert.Equal(t, tt.expectedStatus, status)
-		})
-	}
-}
-
 // ============================================
 // Full Order Lifecycle Test
 // ============================================
@@ -370,11 +389,11 @@ func TestOrderLifecycle_HappyPath(t *testing.T) {
 	assert.Equal(t, StatusPending, order.Status)
 
 	// 2. Pay order
-	err = service.Pay(ctx, order.ID)
+	err = pay(ctx, service, order.ID)
 	require.NoError(t, err)
 
 	// 3. Ship order
-	err = service.Ship(ctx, order.ID)
+	err = service.Ship(ctx, order.ID, "yamato", "1234-5678-9012")
 	require.NoError(t, err)
 }
 
@@ -394,7 +413,7 @@ func TestOrderLifecycle_CancelAfterPlace(t *testing.T) {
 	require.NoError(t, err)
 
 	// 3. Cannot pay cancelled order
-	err = service.Pay(ctx, order.ID)
+	err = pay(ctx, service, order.ID)
 	assert.ErrorIs(t, err, ErrOrderCancelled)
 }
 
@@ -410,7 +429,7 @@ func TestOrderLifecycle_CancelAfterPay(t *testing.T) {
 	require.NoError(t, err)
 
 	// 2. Pay order
-	err = service.Pay(ctx, order.ID)
+	err = pay(ctx, service, order.ID)
 	require.NoError(t, err)
 
 	// 3. Cancel order (refund case)
@@ -418,7 +437,7 @@ func TestOrderLifecycle_CancelAfterPay(t *testing.T) {
 	require.NoError(t, err)
 
 	// 4. Cannot ship cancelled order
-	err = service.Ship(ctx, order.ID)
+	err = service.Ship(ctx, order.ID, "yamato", "1234-5678-9012")
 	assert.ErrorIs(t, err, ErrOrderCancelled)
 }
 
@@ -442,7 +461,7 @@ func TestService_Place_EventStoreError(t *testing.T) {
 	assert.Nil(t, order)
 }
 
-func TestService_Pay_EventStoreError(t *testing.T) {
+func TestService_StartPayment_EventStoreError(t *testing.T) {
 	service, eventStore := newTestOrderService()
 	ctx := context.Background()
 
@@ -452,11 +471,60 @@ func TestService_Pay_EventStoreError(t *testing.T) {
 	// Set error for next append
 	eventStore.AppendErr = errors.New("database error")
 
-	err := service.Pay(ctx, orderID)
+	_, err := service.StartPayment(ctx, orderID, "attempt-1", "fake")
 
 	assert.Error(t, err)
 }
 
+func TestService_StartPayment_ReadErrorFailsLoudly(t *testing.T) {
+	service, eventStore := newTestOrderService()
+	ctx := context.Background()
+
+	orderID := "order-123"
+	_ = eventStore.AddEvent(orderID, AggregateType, EventOrderPlaced, OrderPlaced{OrderID: orderID})
+
+	// The stream breaks after the stored events, e.g. on a failed page fetch
+	readErr := errors.New("page read failed")
+	eventStore.ReadErr = readErr
+
+	_, err := service.StartPayment(ctx, orderID, "attempt-1", "fake")
+
+	assert.ErrorIs(t, err, readErr)
+	assert.Empty(t, eventStore.AppendCalls)
+}
+
+func TestService_LoadOrder_UpcastsStoredEvents(t *testing.T) {
+	service, eventStore := newTestOrderService()
+	ctx := context.Background()
+
+	// An OrderPlaced stored with a previous layout that named the total "amount"
+	orderID := "order-legacy"
+	eventStore.SetEvents(orderID, []store.Event{{
+		ID:            "event-1",
+		AggregateID:   orderID,
+		AggregateType: AggregateType,
+		EventType:     EventOrderPlaced,
+		Data:          json.RawMessage(`{"order_id":"order-legacy","user_id":"user-123","amount":3000}`),
+		Version:       1,
+	}})
+	eventStore.Upcasters = store.NewUpcasterRegistry()
+	eventStore.Upcasters.Register(EventOrderPlaced, 1, func(data json.RawMessage) (json.RawMessage, error) {
+		var fields map[string]any
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		fields["total"] = fields["amount"]
+		delete(fields, "amount")
+		return json.Marshal(fields)
+	})
+
+	order, err := service.loadOrder(ctx, orderID)
+
+	require.NoError(t, err)
+	assert.Equal(t, 3000, order.Total)
+	assert.Equal(t, StatusPending, order.Status)
+}
+
 func TestService_Ship_EventStoreError(t *testing.T) {
 	service, eventStore := newTestOrderService()
 	ctx := context.Background()
@@ -467,7 +535,7 @@ func TestService_Ship_EventStoreError(t *testing.T) {
 
 	eventStore.AppendErr = errors.New("database error")
 
-	err := service.Ship(ctx, orderID)
+	err := service.Ship(ctx, orderID, "yamato", "1234-5678-9012")
 
 	assert.Error(t, err)
 }
@@ -546,8 +614,8 @@ func TestService_SnapshotCreatedAtThreshold(t *testing.T) {
 		{Version: 9, EventType: EventOrderPlaced, Data: mustMarshal(OrderPlaced{OrderID: testOrderID, UserID: "user-1"})},
 	})
 
-	// The 10th event (Pay) should trigger a snapshot
-	err := service.Pay(ctx, testOrderID)
+	// The 10th event (OrderPaid) should trigger a snapshot
+	_, err := service.CompletePayment(ctx, testOrderID, OrderPaid{CaptureID: "cap-1"})
 	require.NoError(t, err)
 
 	// Verify snapshot was created
@@ -576,10 +644,11 @@ func TestService_LoadOrderFromSnapshot(t *testing.T) {
 		AggregateType: AggregateType,
 		Version:       10,
 		State:         stateJSON,
+		SchemaVersion: snapshotSchemaVersion,
 	})
 
 	// Ship the order - this should load from snapshot first
-	err := service.Ship(ctx, orderID)
+	err := service.Ship(ctx, orderID, "yamato", "1234-5678-9012")
 	require.NoError(t, err)
 
 	// Verify the ship event was appended
@@ -607,6 +676,7 @@ func TestService_LoadOrderFromSnapshotWithSubsequentEvents(t *testing.T) {
 		AggregateType: AggregateType,
 		Version:       5,
 		State:         stateJSON,
+		SchemaVersion: snapshotSchemaVersion,
 	})
 
 	// Add events after the snapshot (versions 6, 7)
@@ -615,7 +685,7 @@ func TestService_LoadOrderFromSnapshotWithSubsequentEvents(t *testing.T) {
 	})
 
 	// Try to ship - should succeed because the order is paid (from event version 6)
-	err := service.Ship(ctx, orderID)
+	err := service.Ship(ctx, orderID, "yamato", "1234-5678-9012")
 	require.NoError(t, err)
 }
 
@@ -630,7 +700,7 @@ func TestService_LoadOrderWithoutSnapshot(t *testing.T) {
 	_ = eventStore.AddEvent(orderID, AggregateType, EventOrderPaid, OrderPaid{OrderID: orderID})
 
 	// Ship the order - should work by replaying all events
-	err := service.Ship(ctx, orderID)
+	err := service.Ship(ctx, orderID, "yamato", "1234-5678-9012")
 	require.NoError(t, err)
 }
 
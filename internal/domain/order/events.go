@@ -1,14 +1,32 @@
 package order
 
-import "time"
+import (
+	"time"
+
+	"github.com/example/ec-event-driven/internal/eventcodec"
+)
 
 const (
 	EventOrderPlaced    = "OrderPlaced"
 	EventOrderPaid      = "OrderPaid"
 	EventOrderShipped   = "OrderShipped"
 	EventOrderCancelled = "OrderCancelled"
+
+	EventPaymentAttempted = "PaymentAttempted"
+	EventPaymentFailed    = "PaymentFailed"
+	EventPaymentRefunded  = "PaymentRefunded"
 )
 
+func init() {
+	eventcodec.Register[OrderPlaced](EventOrderPlaced)
+	eventcodec.Register[OrderPaid](EventOrderPaid)
+	eventcodec.Register[OrderShipped](EventOrderShipped)
+	eventcodec.Register[OrderCancelled](EventOrderCancelled)
+	eventcodec.Register[PaymentAttempted](EventPaymentAttempted)
+	eventcodec.Register[PaymentFailed](EventPaymentFailed)
+	eventcodec.Register[PaymentRefunded](EventPaymentRefunded)
+}
+
 type OrderItem struct {
 	ProductID string `json:"product_id"`
 	Name      string `json:"name"`
@@ -27,11 +45,22 @@ type OrderPlaced struct {
 type OrderPaid struct {
 	OrderID string    `json:"order_id"`
 	PaidAt  time.Time `json:"paid_at"`
+
+	// Captured payment (empty for orders paid before payments were recorded)
+	AttemptID       string `json:"attempt_id,omitempty"`
+	Provider        string `json:"provider,omitempty"`
+	AuthorizationID string `json:"authorization_id,omitempty"`
+	CaptureID       string `json:"capture_id,omitempty"`
+	Amount          int    `json:"amount,omitempty"`
 }
 
 type OrderShipped struct {
 	OrderID   string    `json:"order_id"`
 	ShippedAt time.Time `json:"shipped_at"`
+
+	// Tracking details (empty for orders shipped before they were recorded)
+	Carrier        string `json:"carrier,omitempty"`
+	TrackingNumber string `json:"tracking_number,omitempty"`
 }
 
 type OrderCancelled struct {
@@ -39,3 +68,32 @@ type OrderCancelled struct {
 	Reason      string    `json:"reason"`
 	CancelledAt time.Time `json:"cancelled_at"`
 }
+
+// PaymentAttempted is recorded before the payment gateway is asked to charge an order
+type PaymentAttempted struct {
+	OrderID     string    `json:"order_id"`
+	AttemptID   string    `json:"attempt_id"`
+	Provider    string    `json:"provider"`
+	Amount      int       `json:"amount"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// PaymentFailed is recorded when the payment gateway fails to charge an order
+type PaymentFailed struct {
+	OrderID   string    `json:"order_id"`
+	AttemptID string    `json:"attempt_id"`
+	Provider  string    `json:"provider"`
+	Stage     string    `json:"stage"` // "authorize" or "capture"
+	Reason    string    `json:"reason"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// PaymentRefunded is recorded when a captured payment is refunded
+type PaymentRefunded struct {
+	OrderID    string    `json:"order_id"`
+	Provider   string    `json:"provider"`
+	CaptureID  string    `json:"capture_id"`
+	RefundID   string    `json:"refund_id"`
+	Amount     int       `json:"amount"`
+	RefundedAt time.Time `json:"refunded_at"`
+}
@@ -3,8 +3,11 @@ package product
 import (
 	"context"
 	"errors"
+	"slices"
 	"time"
 
+	"github.com/example/ec-event-driven/internal/domain/aggregate"
+	"github.com/example/ec-event-driven/internal/eventcodec"
 	"github.com/example/ec-event-driven/internal/infrastructure/store"
 	"github.com/google/uuid"
 )
@@ -23,9 +26,65 @@ type Product struct {
 	Description string    `json:"description"`
 	Price       int       `json:"price"`
 	Stock       int       `json:"stock"`
+	ImageURL    string    `json:"image_url,omitempty"`
+	CategoryIDs []string  `json:"category_ids,omitempty"`
 	IsDeleted   bool      `json:"is_deleted,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	Version     int       `json:"version,omitempty"`
+}
+
+func init() {
+	// Products are never snapshotted: their stream is shared with the Inventory
+	// aggregate of the same ID, which owns the snapshot slot
+	aggregate.Register(AggregateType, func(id string) aggregate.Aggregate {
+		return &Product{ID: id}
+	}, aggregate.SnapshotPolicy{Disabled: true})
+}
+
+// Implement aggregate.Aggregate interface
+func (p *Product) GetID() string    { return p.ID }
+func (p *Product) GetVersion() int  { return p.Version }
+func (p *Product) SetVersion(v int) { p.Version = v }
+
+// ApplyEvent applies a single event to the product state (implements aggregate.Aggregate).
+// Inventory events on the shared stream only advance the version.
+func (p *Product) ApplyEvent(event store.Event) error {
+	decoded, err := eventcodec.Decode(event)
+	if err != nil {
+		return err
+	}
+	switch data := decoded.(type) {
+	case ProductCreated:
+		p.ID = data.ProductID
+		p.Name = data.Name
+		p.Description = data.Description
+		p.Price = data.Price
+		p.Stock = data.Stock
+		p.CreatedAt = data.CreatedAt
+		p.UpdatedAt = data.CreatedAt
+	case ProductUpdated:
+		p.Name = data.Name
+		p.Description = data.Description
+		p.Price = data.Price
+		p.UpdatedAt = data.UpdatedAt
+	case ProductDeleted:
+		p.IsDeleted = true
+		p.UpdatedAt = data.DeletedAt
+	case ProductImageUpdated:
+		p.ImageURL = data.ImageURL
+		p.UpdatedAt = data.UpdatedAt
+	case ProductCategoryAssigned:
+		if !slices.Contains(p.CategoryIDs, data.CategoryID) {
+			p.CategoryIDs = append(p.CategoryIDs, data.CategoryID)
+		}
+		p.UpdatedAt = data.AssignedAt
+	case ProductCategoryRemoved:
+		p.CategoryIDs = slices.DeleteFunc(p.CategoryIDs, func(id string) bool { return id == data.CategoryID })
+		p.UpdatedAt = data.RemovedAt
+	}
+	p.Version = event.Version
+	return nil
 }
 
 type Service struct {
@@ -36,6 +95,21 @@ func NewService(es store.EventStoreInterface) *Service {
 	return &Service{eventStore: es}
 }
 
+// Get loads a product from its events. Deleted products are not found.
+// Snapshots are not used, as the product's stream is shared with its Inventory.
+func (s *Service) Get(ctx context.Context, productID string) (*Product, error) {
+	p, _, err := aggregate.LoadAggregateAt(ctx, s.eventStore, productID, aggregate.AsOf{}, func() *Product {
+		return &Product{}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p.ID == "" || p.IsDeleted {
+		return nil, ErrProductNotFound
+	}
+	return p, nil
+}
+
 func (s *Service) Create(ctx context.Context, name, description string, price, stock int) (*Product, error) {
 	if name == "" {
 		return nil, ErrInvalidName
@@ -56,7 +130,7 @@ func (s *Service) Create(ctx context.Context, name, description string, price, s
 		CreatedAt:   now,
 	}
 
-	_, err := s.eventStore.Append(ctx, productID, AggregateType, EventProductCreated, event)
+	_, err := s.eventStore.AppendWithExpectedVersion(ctx, productID, AggregateType, EventProductCreated, 0, event)
 	if err != nil {
 		return nil, err
 	}
@@ -79,8 +153,11 @@ func (s *Service) Update(ctx context.Context, productID, name, description strin
 		return ErrInvalidPrice
 	}
 
-	events := s.eventStore.GetEvents(productID)
-	if len(events) == 0 {
+	version, err := aggregate.CurrentVersion(ctx, s.eventStore, productID)
+	if err != nil {
+		return err
+	}
+	if version == 0 {
 		return ErrProductNotFound
 	}
 
@@ -92,13 +169,16 @@ func (s *Service) Update(ctx context.Context, productID, name, description strin
 		UpdatedAt:   time.Now(),
 	}
 
-	_, err := s.eventStore.Append(ctx, productID, AggregateType, EventProductUpdated, event)
+	_, err = s.eventStore.AppendWithExpectedVersion(ctx, productID, AggregateType, EventProductUpdated, version, event)
 	return err
 }
 
 func (s *Service) Delete(ctx context.Context, productID string) error {
-	events := s.eventStore.GetEvents(productID)
-	if len(events) == 0 {
+	version, err := aggregate.CurrentVersion(ctx, s.eventStore, productID)
+	if err != nil {
+		return err
+	}
+	if version == 0 {
 		return ErrProductNotFound
 	}
 
@@ -107,6 +187,6 @@ func (s *Service) Delete(ctx context.Context, productID string) error {
 		DeletedAt: time.Now(),
 	}
 
-	_, err := s.eventStore.Append(ctx, productID, AggregateType, EventProductDeleted, event)
+	_, err = s.eventStore.AppendWithExpectedVersion(ctx, productID, AggregateType, EventProductDeleted, version, event)
 	return err
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/example/ec-event-driven/internal/domain/aggregate"
 	"github.com/example/ec-event-driven/internal/infrastructure/store/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -187,3 +188,33 @@ func TestService_Delete_NotFound(t *testing.T) {
 
 	assert.ErrorIs(t, err, ErrProductNotFound)
 }
+
+// ============================================
+// ApplyEvent Tests
+// ============================================
+
+func TestProduct_LoadAggregateAt(t *testing.T) {
+	service, eventStore := newTestProductService()
+	ctx := context.Background()
+	created, err := service.Create(ctx, "Test Product", "Description", 1000, 50)
+	require.NoError(t, err)
+	require.NoError(t, service.Update(ctx, created.ID, "Renamed Product", "Description", 1200))
+	require.NoError(t, service.Delete(ctx, created.ID))
+
+	before, events, err := aggregate.LoadAggregateAt(ctx, eventStore, created.ID, aggregate.AsOf{Version: 1},
+		func() *Product { return &Product{ID: created.ID} })
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "Test Product", before.Name)
+	assert.Equal(t, 1000, before.Price)
+	assert.Equal(t, 50, before.Stock)
+	assert.False(t, before.IsDeleted)
+
+	latest, _, err := aggregate.LoadAggregateAt(ctx, eventStore, created.ID, aggregate.AsOf{},
+		func() *Product { return &Product{ID: created.ID} })
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed Product", latest.Name)
+	assert.Equal(t, 1200, latest.Price)
+	assert.True(t, latest.IsDeleted)
+	assert.Equal(t, 3, latest.Version)
+}
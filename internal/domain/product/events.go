@@ -1,6 +1,10 @@
 package product
 
-import "time"
+import (
+	"time"
+
+	"github.com/example/ec-event-driven/internal/eventcodec"
+)
 
 const (
 	EventProductCreated          = "ProductCreated"
@@ -11,6 +15,15 @@ const (
 	EventProductImageUpdated     = "ProductImageUpdated"
 )
 
+func init() {
+	eventcodec.Register[ProductCreated](EventProductCreated)
+	eventcodec.Register[ProductUpdated](EventProductUpdated)
+	eventcodec.Register[ProductDeleted](EventProductDeleted)
+	eventcodec.Register[ProductCategoryAssigned](EventProductCategoryAssigned)
+	eventcodec.Register[ProductCategoryRemoved](EventProductCategoryRemoved)
+	eventcodec.Register[ProductImageUpdated](EventProductImageUpdated)
+}
+
 type ProductCreated struct {
 	ProductID   string    `json:"product_id"`
 	Name        string    `json:"name"`
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/example/ec-event-driven/internal/auth"
+	"github.com/example/ec-event-driven/internal/domain/aggregate"
+	"github.com/example/ec-event-driven/internal/eventcodec"
 	"github.com/example/ec-event-driven/internal/infrastructure/store"
 	"github.com/google/uuid"
 )
@@ -34,14 +36,63 @@ func isValidEmail(email string) bool {
 
 // User represents a user aggregate
 type User struct {
-	ID           string
-	Email        string
-	PasswordHash string
-	Name         string
-	Role         string
-	IsActive     bool
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	ID           string    `json:"id"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"-"`
+	Name         string    `json:"name"`
+	Role         string    `json:"role"`
+	IsActive     bool      `json:"is_active"`
+	LastLoginAt  time.Time `json:"last_login_at,omitzero"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Version      int       `json:"version"`
+}
+
+func init() {
+	// User state is read from the read model, so users are not snapshotted
+	aggregate.Register(AggregateType, func(id string) aggregate.Aggregate {
+		return &User{ID: id}
+	}, aggregate.SnapshotPolicy{Disabled: true})
+}
+
+// Implement aggregate.Aggregate interface
+func (u *User) GetID() string    { return u.ID }
+func (u *User) GetVersion() int  { return u.Version }
+func (u *User) SetVersion(v int) { u.Version = v }
+
+// ApplyEvent applies a single event to the user state (implements aggregate.Aggregate)
+func (u *User) ApplyEvent(event store.Event) error {
+	decoded, err := eventcodec.Decode(event)
+	if err != nil {
+		return err
+	}
+	switch data := decoded.(type) {
+	case UserCreated:
+		u.ID = data.UserID
+		u.Email = data.Email
+		u.PasswordHash = data.PasswordHash
+		u.Name = data.Name
+		u.Role = data.Role
+		u.IsActive = true
+		u.CreatedAt = data.CreatedAt
+		u.UpdatedAt = data.CreatedAt
+	case UserUpdated:
+		u.Name = data.Name
+		u.UpdatedAt = data.UpdatedAt
+	case UserPasswordChanged:
+		u.PasswordHash = data.PasswordHash
+		u.UpdatedAt = data.ChangedAt
+	case UserLoggedIn:
+		u.LastLoginAt = data.LoggedAt
+	case UserDeactivated:
+		u.IsActive = false
+		u.UpdatedAt = data.DeactivatedAt
+	case UserActivated:
+		u.IsActive = true
+		u.UpdatedAt = data.ActivatedAt
+	}
+	u.Version = event.Version
+	return nil
 }
 
 // Service handles user domain operations
@@ -90,7 +141,7 @@ func (s *Service) RegisterWithRole(ctx context.Context, email, password, name, r
 		CreatedAt:    now,
 	}
 
-	_, err = s.eventStore.Append(ctx, userID, AggregateType, EventUserCreated, event)
+	_, err = s.eventStore.AppendWithExpectedVersion(ctx, userID, AggregateType, EventUserCreated, 0, event)
 	if err != nil {
 		return nil, err
 	}
@@ -115,6 +166,7 @@ func (s *Service) RecordLogin(ctx context.Context, userID, sessionID, ipAddress,
 		LoggedAt:  time.Now(),
 	}
 
+	// Login events don't depend on user state, so any version is accepted
 	_, err := s.eventStore.Append(ctx, userID, AggregateType, EventUserLoggedIn, event)
 	return err
 }
@@ -137,8 +189,11 @@ func (s *Service) UpdateProfile(ctx context.Context, userID, name string) error
 		return ErrInvalidName
 	}
 
-	events := s.eventStore.GetEvents(userID)
-	if len(events) == 0 {
+	version, err := aggregate.CurrentVersion(ctx, s.eventStore, userID)
+	if err != nil {
+		return err
+	}
+	if version == 0 {
 		return ErrUserNotFound
 	}
 
@@ -148,14 +203,17 @@ func (s *Service) UpdateProfile(ctx context.Context, userID, name string) error
 		UpdatedAt: time.Now(),
 	}
 
-	_, err := s.eventStore.Append(ctx, userID, AggregateType, EventUserUpdated, event)
+	_, err = s.eventStore.AppendWithExpectedVersion(ctx, userID, AggregateType, EventUserUpdated, version, event)
 	return err
 }
 
 // ChangePassword changes user password
 func (s *Service) ChangePassword(ctx context.Context, userID, newPassword string) error {
-	events := s.eventStore.GetEvents(userID)
-	if len(events) == 0 {
+	version, err := aggregate.CurrentVersion(ctx, s.eventStore, userID)
+	if err != nil {
+		return err
+	}
+	if version == 0 {
 		return ErrUserNotFound
 	}
 
@@ -170,14 +228,17 @@ func (s *Service) ChangePassword(ctx context.Context, userID, newPassword string
 		ChangedAt:    time.Now(),
 	}
 
-	_, err = s.eventStore.Append(ctx, userID, AggregateType, EventUserPasswordChanged, event)
+	_, err = s.eventStore.AppendWithExpectedVersion(ctx, userID, AggregateType, EventUserPasswordChanged, version, event)
 	return err
 }
 
 // Deactivate deactivates a user account
 func (s *Service) Deactivate(ctx context.Context, userID string) error {
-	events := s.eventStore.GetEvents(userID)
-	if len(events) == 0 {
+	version, err := aggregate.CurrentVersion(ctx, s.eventStore, userID)
+	if err != nil {
+		return err
+	}
+	if version == 0 {
 		return ErrUserNotFound
 	}
 
@@ -186,14 +247,17 @@ func (s *Service) Deactivate(ctx context.Context, userID string) error {
 		DeactivatedAt: time.Now(),
 	}
 
-	_, err := s.eventStore.Append(ctx, userID, AggregateType, EventUserDeactivated, event)
+	_, err = s.eventStore.AppendWithExpectedVersion(ctx, userID, AggregateType, EventUserDeactivated, version, event)
 	return err
 }
 
 // Activate activates a user account
 func (s *Service) Activate(ctx context.Context, userID string) error {
-	events := s.eventStore.GetEvents(userID)
-	if len(events) == 0 {
+	version, err := aggregate.CurrentVersion(ctx, s.eventStore, userID)
+	if err != nil {
+		return err
+	}
+	if version == 0 {
 		return ErrUserNotFound
 	}
 
@@ -202,6 +266,6 @@ func (s *Service) Activate(ctx context.Context, userID string) error {
 		ActivatedAt: time.Now(),
 	}
 
-	_, err := s.eventStore.Append(ctx, userID, AggregateType, EventUserActivated, event)
+	_, err = s.eventStore.AppendWithExpectedVersion(ctx, userID, AggregateType, EventUserActivated, version, event)
 	return err
 }
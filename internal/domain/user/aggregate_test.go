@@ -2,9 +2,11 @@ package user
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 
 	"github.com/example/ec-event-driven/internal/auth"
+	"github.com/example/ec-event-driven/internal/domain/aggregate"
 	"github.com/example/ec-event-driven/internal/infrastructure/store/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -312,3 +314,35 @@ func TestService_Activate_UserNotFound(t *testing.T) {
 
 	assert.ErrorIs(t, err, ErrUserNotFound)
 }
+
+// ============================================
+// ApplyEvent Tests
+// ============================================
+
+func TestUser_LoadAggregateAt(t *testing.T) {
+	service, eventStore := newTestUserService()
+	ctx := context.Background()
+	registered, err := service.Register(ctx, "test@example.com", "password123", "Test User")
+	require.NoError(t, err)
+	require.NoError(t, service.UpdateProfile(ctx, registered.ID, "Renamed User"))
+	require.NoError(t, service.Deactivate(ctx, registered.ID))
+
+	before, _, err := aggregate.LoadAggregateAt(ctx, eventStore, registered.ID, aggregate.AsOf{Version: 2},
+		func() *User { return &User{ID: registered.ID} })
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed User", before.Name)
+	assert.Equal(t, "test@example.com", before.Email)
+	assert.True(t, before.IsActive)
+
+	latest, _, err := aggregate.LoadAggregateAt(ctx, eventStore, registered.ID, aggregate.AsOf{},
+		func() *User { return &User{ID: registered.ID} })
+	require.NoError(t, err)
+	assert.False(t, latest.IsActive)
+	assert.Equal(t, 3, latest.Version)
+
+	// The password hash never leaves the aggregate
+	require.NotEmpty(t, latest.PasswordHash)
+	state, err := json.Marshal(latest)
+	require.NoError(t, err)
+	assert.NotContains(t, string(state), latest.PasswordHash)
+}
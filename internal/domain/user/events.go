@@ -1,6 +1,10 @@
 package user
 
-import "time"
+import (
+	"time"
+
+	"github.com/example/ec-event-driven/internal/eventcodec"
+)
 
 const (
 	EventUserCreated         = "UserCreated"
@@ -12,6 +16,16 @@ const (
 	EventUserActivated       = "UserActivated"
 )
 
+func init() {
+	eventcodec.Register[UserCreated](EventUserCreated)
+	eventcodec.Register[UserUpdated](EventUserUpdated)
+	eventcodec.Register[UserPasswordChanged](EventUserPasswordChanged)
+	eventcodec.Register[UserLoggedIn](EventUserLoggedIn)
+	eventcodec.Register[UserLoggedOut](EventUserLoggedOut)
+	eventcodec.Register[UserDeactivated](EventUserDeactivated)
+	eventcodec.Register[UserActivated](EventUserActivated)
+}
+
 // UserCreated is emitted when a new user is registered
 type UserCreated struct {
 	UserID       string    `json:"user_id"`
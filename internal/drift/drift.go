@@ -0,0 +1,373 @@
+// Package drift compares read models with the state of their aggregates
+// replayed from the event store, to find read models that drifted from the
+// events (e.g. because an event was dead-lettered or a row was edited by
+// hand) and optionally repair them.
+package drift
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/example/ec-event-driven/internal/domain/aggregate"
+	"github.com/example/ec-event-driven/internal/domain/inventory"
+	"github.com/example/ec-event-driven/internal/domain/order"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/example/ec-event-driven/internal/readmodel"
+)
+
+// ErrUnsupportedType is returned for aggregate types the checker cannot check
+var ErrUnsupportedType = errors.New("unsupported aggregate type")
+
+// Kind is the kind of a drift
+type Kind string
+
+const (
+	// KindMismatch read models differ from their aggregate in some fields
+	KindMismatch Kind = "mismatch"
+	// KindMissing aggregates have no read model
+	KindMissing Kind = "missing"
+	// KindOrphan read models have no aggregate
+	KindOrphan Kind = "orphan"
+)
+
+// Diff is a read model field that differs from the aggregate
+type Diff struct {
+	Field     string `json:"field"`
+	ReadModel any    `json:"read_model"`
+	Aggregate any    `json:"aggregate"`
+}
+
+// Drift is a read model that does not match its aggregate
+type Drift struct {
+	AggregateType string `json:"aggregate_type"`
+	AggregateID   string `json:"aggregate_id"`
+	Kind          Kind   `json:"kind"`
+	Diffs         []Diff `json:"diffs,omitempty"`
+	Version       int    `json:"version"` // Version of the aggregate
+	// Lagging drifts belong to aggregates whose read model is at another
+	// version than the replayed aggregate: the projection has not applied
+	// its later events yet, or applied events appended after it was replayed.
+	// They are not repaired, as the projector (or the redrive of a
+	// dead-lettered event) is expected to catch up.
+	Lagging  bool `json:"lagging,omitempty"`
+	Repaired bool `json:"repaired,omitempty"`
+}
+
+// Report is the result of checking the read models of an aggregate type
+type Report struct {
+	AggregateType string  `json:"aggregate_type"`
+	Checked       int     `json:"checked"`
+	Drifts        []Drift `json:"drifts"`
+}
+
+// Repaired returns how many drifts were repaired
+func (r *Report) Repaired() int {
+	n := 0
+	for _, d := range r.Drifts {
+		if d.Repaired {
+			n++
+		}
+	}
+	return n
+}
+
+// checkedType checks the read models of one aggregate type
+type checkedType interface {
+	projection() string
+	readModelIDs(ctx context.Context, models *store.ReadModels) ([]string, error)
+	check(ctx context.Context, eventStore store.EventStoreInterface, models *store.ReadModels, id string) (*Drift, repairFunc, error)
+}
+
+// repairFunc replaces a drifted read model with the one expected from its aggregate
+type repairFunc func(models *store.ReadModels) error
+
+// types are the aggregate types whose read models can be checked
+var types = map[string]checkedType{
+	inventory.AggregateType: checked[*inventory.Inventory, readmodel.InventoryReadModel]{
+		projectionName: "inventory",
+		repository:     func(m *store.ReadModels) store.Repository[readmodel.InventoryReadModel] { return m.Inventory },
+		newAggregate:   func() *inventory.Inventory { return &inventory.Inventory{} },
+		readModelID:    func(r *readmodel.InventoryReadModel) string { return r.ProductID },
+		expected: func(inv *inventory.Inventory) *readmodel.InventoryReadModel {
+			return &readmodel.InventoryReadModel{
+				ProductID:      inv.ProductID,
+				TotalStock:     inv.TotalStock,
+				ReservedStock:  inv.ReservedStock,
+				AvailableStock: inv.AvailableStock(),
+			}
+		},
+	},
+	order.AggregateType: checked[*order.Order, readmodel.OrderReadModel]{
+		projectionName: "orders",
+		repository:     func(m *store.ReadModels) store.Repository[readmodel.OrderReadModel] { return m.Orders },
+		newAggregate:   func() *order.Order { return &order.Order{} },
+		readModelID:    func(r *readmodel.OrderReadModel) string { return r.ID },
+		expected: func(o *order.Order) *readmodel.OrderReadModel {
+			items := make([]readmodel.OrderItemReadModel, len(o.Items))
+			for i, item := range o.Items {
+				items[i] = readmodel.OrderItemReadModel{
+					ProductID: item.ProductID,
+					Name:      item.Name,
+					Quantity:  item.Quantity,
+					Price:     item.Price,
+				}
+			}
+			read := &readmodel.OrderReadModel{
+				ID:        o.ID,
+				UserID:    o.UserID,
+				Items:     items,
+				Total:     o.Total,
+				Status:    string(o.Status),
+				CreatedAt: o.CreatedAt,
+				UpdatedAt: o.UpdatedAt,
+			}
+			if s := o.Shipment; s != nil {
+				read.Carrier = s.Carrier
+				read.TrackingNumber = s.TrackingNumber
+				read.ShippedAt = &s.ShippedAt
+			}
+			return read
+		},
+	},
+}
+
+// Types returns the aggregate types whose read models can be checked
+func Types() []string {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Checker compares read models with their aggregates
+type Checker struct {
+	eventStore store.EventStoreInterface
+	readStore  store.ReadStoreInterface
+
+	// Repair replaces drifted read models with the ones expected from their
+	// aggregates, creates missing ones and deletes orphans
+	Repair bool
+}
+
+// NewChecker creates a Checker for the read models of readStore
+func NewChecker(eventStore store.EventStoreInterface, readStore store.ReadStoreInterface) *Checker {
+	return &Checker{eventStore: eventStore, readStore: readStore}
+}
+
+// AggregateIDs returns the IDs of the aggregates of aggregateType to check:
+// those with a read model, plus those in the event store if fromEvents is
+// set, which finds missing read models but reads the whole event store
+func (c *Checker) AggregateIDs(ctx context.Context, aggregateType string, fromEvents bool) ([]string, error) {
+	t, ok := types[aggregateType]
+	if !ok {
+		return nil, fmt.Errorf("%w %q (expected one of %s)", ErrUnsupportedType, aggregateType, strings.Join(Types(), ", "))
+	}
+	ids, err := t.readModelIDs(ctx, c.readStore.ReadModels())
+	if err != nil {
+		return nil, err
+	}
+	if fromEvents {
+		for event, err := range c.eventStore.ReadAll(ctx, 0) {
+			if err != nil {
+				return nil, err
+			}
+			if event.AggregateType == aggregateType {
+				ids = append(ids, event.AggregateID)
+			}
+		}
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids), nil
+}
+
+// Check compares the read models of the given aggregates of aggregateType
+// with the aggregates replayed from the event store
+func (c *Checker) Check(ctx context.Context, aggregateType string, ids []string) (*Report, error) {
+	t, ok := types[aggregateType]
+	if !ok {
+		return nil, fmt.Errorf("%w %q (expected one of %s)", ErrUnsupportedType, aggregateType, strings.Join(Types(), ", "))
+	}
+	checkpoints, err := c.checkpoints(ctx, t.projection(), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	models := c.readStore.ReadModels()
+	report := &Report{AggregateType: aggregateType, Drifts: []Drift{}}
+	for _, id := range ids {
+		drift, repair, err := t.check(ctx, c.eventStore, models, id)
+		if err != nil {
+			return report, err
+		}
+		report.Checked++
+		if drift == nil {
+			continue
+		}
+		drift.AggregateType = aggregateType
+		if checkpoint, ok := checkpoints[id]; ok && checkpoint < drift.Version {
+			drift.Lagging = true
+		}
+		if c.Repair && !drift.Lagging {
+			repaired, err := c.repair(ctx, t.projection(), drift, repair)
+			if err != nil {
+				return report, fmt.Errorf("failed to repair %s %s: %w", aggregateType, id, err)
+			}
+			drift.Repaired = repaired
+			drift.Lagging = !repaired
+		}
+		report.Drifts = append(report.Drifts, *drift)
+	}
+	return report, nil
+}
+
+// repair repairs a drifted read model while holding the projection's
+// checkpoint of the aggregate, if the read store keeps them, so that the
+// projector cannot apply later events in between. It reports false without
+// repairing if the projection has applied a later version than the drift's
+// meanwhile.
+func (c *Checker) repair(ctx context.Context, projection string, drift *Drift, repair repairFunc) (bool, error) {
+	transactional, ok := c.readStore.(store.TransactionalReadStore)
+	if !ok {
+		return true, repair(c.readStore.ReadModels())
+	}
+	repaired := false
+	err := transactional.UnitOfWork(ctx, func(tx store.TransactionalReadStore) error {
+		checkpoint, err := tx.LockCheckpoint(ctx, projection, drift.AggregateID, drift.Version)
+		if err != nil {
+			return err
+		}
+		if checkpoint > drift.Version {
+			return nil
+		}
+		repaired = true
+		return repair(tx.ReadModels())
+	})
+	if err != nil {
+		return false, err
+	}
+	return repaired, nil
+}
+
+// checkpoints returns the projection's checkpoints of the aggregates, if the read store keeps them
+func (c *Checker) checkpoints(ctx context.Context, projection string, ids []string) (map[string]int, error) {
+	idempotent, ok := c.readStore.(store.IdempotentReadStore)
+	if !ok || len(ids) == 0 {
+		return nil, nil
+	}
+	checkpoints, err := idempotent.Checkpoints(ctx, projection, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s checkpoints: %w", projection, err)
+	}
+	return checkpoints, nil
+}
+
+// checked checks the read models R of aggregates A
+type checked[A aggregate.Aggregate, R any] struct {
+	projectionName string // Projection that builds the read models
+	repository     func(*store.ReadModels) store.Repository[R]
+	newAggregate   func() A
+	readModelID    func(*R) string
+	expected       func(A) *R // Read model expected from the aggregate
+}
+
+func (t checked[A, R]) projection() string { return t.projectionName }
+
+func (t checked[A, R]) readModelIDs(ctx context.Context, models *store.ReadModels) ([]string, error) {
+	readModels, err := t.repository(models).List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(readModels))
+	for i, r := range readModels {
+		ids[i] = t.readModelID(r)
+	}
+	return ids, nil
+}
+
+// check returns the drift of an aggregate's read model, if any, with the function that repairs it
+func (t checked[A, R]) check(ctx context.Context, eventStore store.EventStoreInterface, models *store.ReadModels, id string) (*Drift, repairFunc, error) {
+	repo := t.repository(models)
+	agg, found, err := aggregate.LoadAggregate(ctx, eventStore, id, t.newAggregate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load aggregate %s: %w", id, err)
+	}
+	read, ok, err := repo.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case !found && !ok:
+		return nil, nil, nil
+	case !found:
+		return &Drift{AggregateID: id, Kind: KindOrphan}, func(models *store.ReadModels) error {
+			return t.repository(models).Delete(ctx, id)
+		}, nil
+	}
+
+	expected := t.expected(agg)
+	drift := &Drift{AggregateID: id, Version: agg.GetVersion()}
+	if !ok {
+		drift.Kind = KindMissing
+	} else if drift.Diffs = compare(read, expected); len(drift.Diffs) > 0 {
+		drift.Kind = KindMismatch
+	} else {
+		return nil, nil, nil
+	}
+	return drift, func(models *store.ReadModels) error {
+		return t.repository(models).Upsert(ctx, id, expected)
+	}, nil
+}
+
+// compare returns the exported fields of two read models that differ
+func compare[R any](read, expected *R) []Diff {
+	var diffs []Diff
+	rv, ev := reflect.ValueOf(read).Elem(), reflect.ValueOf(expected).Elem()
+	for i := range rv.NumField() {
+		field := rv.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		a, b := rv.Field(i).Interface(), ev.Field(i).Interface()
+		if !equal(a, b) {
+			diffs = append(diffs, Diff{Field: fieldName(field), ReadModel: a, Aggregate: b})
+		}
+	}
+	return diffs
+}
+
+// equal compares field values as stored: times to the microsecond, as
+// PostgreSQL keeps them, and nil slices as empty
+func equal(a, b any) bool {
+	if ta, ok := a.(*time.Time); ok {
+		tb, _ := b.(*time.Time)
+		if ta == nil || tb == nil {
+			return ta == tb
+		}
+		return equal(*ta, *tb)
+	}
+	if ta, ok := a.(time.Time); ok {
+		tb, _ := b.(time.Time)
+		return ta.Truncate(time.Microsecond).Equal(tb.Truncate(time.Microsecond))
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Kind() == reflect.Slice && va.Len() == 0 && vb.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// fieldName returns the JSON name of a read model field
+func fieldName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
@@ -0,0 +1,256 @@
+package drift
+
+import (
+	"context"
+	"encoding/json"
+	"iter"
+	"testing"
+
+	"github.com/example/ec-event-driven/internal/domain/inventory"
+	"github.com/example/ec-event-driven/internal/domain/order"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/example/ec-event-driven/internal/infrastructure/store/mocks"
+	"github.com/example/ec-event-driven/internal/projection"
+	"github.com/example/ec-event-driven/internal/readmodel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// project applies all stored events to the read store, as the projector does
+func project(t *testing.T, eventStore *mocks.MockEventStore, readStore *mocks.MockReadStore) {
+	t.Helper()
+	projector := projection.NewProjector(readStore)
+	for _, event := range eventStore.GetAllEvents() {
+		value, err := json.Marshal(event)
+		require.NoError(t, err)
+		require.NoError(t, projector.HandleEvent(context.Background(), []byte(event.AggregateID), value))
+	}
+}
+
+func setup(t *testing.T) (*Checker, *mocks.MockEventStore, *mocks.MockReadStore) {
+	t.Helper()
+	ctx := context.Background()
+	eventStore := mocks.NewMockEventStore()
+	readStore := mocks.NewMockReadStore()
+
+	inventorySvc := inventory.NewService(eventStore)
+	require.NoError(t, inventorySvc.AddStock(ctx, "prod-1", 10))
+	require.NoError(t, inventorySvc.Reserve(ctx, "prod-1", "order-1", 3))
+	require.NoError(t, inventorySvc.AddStock(ctx, "prod-2", 5))
+	project(t, eventStore, readStore)
+
+	return NewChecker(eventStore, readStore), eventStore, readStore
+}
+
+func TestChecker_NoDrift(t *testing.T) {
+	checker, _, _ := setup(t)
+	ctx := context.Background()
+
+	ids, err := checker.AggregateIDs(ctx, inventory.AggregateType, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prod-1", "prod-2"}, ids)
+
+	report, err := checker.Check(ctx, inventory.AggregateType, ids)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Checked)
+	assert.Empty(t, report.Drifts)
+}
+
+func TestChecker_ReportsMismatchedFields(t *testing.T) {
+	checker, _, readStore := setup(t)
+	ctx := context.Background()
+	readStore.SetData("inventory", "prod-1", &readmodel.InventoryReadModel{
+		ProductID: "prod-1", TotalStock: 10, ReservedStock: 0, AvailableStock: 10,
+	})
+
+	report, err := checker.Check(ctx, inventory.AggregateType, []string{"prod-1", "prod-2"})
+	require.NoError(t, err)
+	require.Len(t, report.Drifts, 1)
+	d := report.Drifts[0]
+	assert.Equal(t, KindMismatch, d.Kind)
+	assert.Equal(t, "prod-1", d.AggregateID)
+	assert.Equal(t, 2, d.Version)
+	assert.Equal(t, []Diff{
+		{Field: "reserved_stock", ReadModel: 0, Aggregate: 3},
+		{Field: "available_stock", ReadModel: 10, Aggregate: 7},
+	}, d.Diffs)
+	assert.False(t, d.Repaired)
+
+	// Not repaired without Repair
+	inv, _ := readStore.GetData("inventory", "prod-1")
+	assert.Equal(t, 0, inv.(*readmodel.InventoryReadModel).ReservedStock)
+}
+
+func TestChecker_RepairsDrift(t *testing.T) {
+	checker, _, readStore := setup(t)
+	ctx := context.Background()
+	checker.Repair = true
+	readStore.SetData("inventory", "prod-1", &readmodel.InventoryReadModel{ProductID: "prod-1", TotalStock: 99})
+	require.NoError(t, readStore.Delete("inventory", "prod-2"))
+	readStore.SetData("inventory", "prod-gone", &readmodel.InventoryReadModel{ProductID: "prod-gone", TotalStock: 1})
+
+	report, err := checker.Check(ctx, inventory.AggregateType, []string{"prod-1", "prod-2", "prod-gone"})
+	require.NoError(t, err)
+	require.Len(t, report.Drifts, 3)
+	assert.Equal(t, KindMismatch, report.Drifts[0].Kind)
+	assert.Equal(t, KindMissing, report.Drifts[1].Kind)
+	assert.Equal(t, KindOrphan, report.Drifts[2].Kind)
+	assert.Equal(t, 3, report.Repaired())
+
+	inv, _ := readStore.GetData("inventory", "prod-1")
+	assert.Equal(t, &readmodel.InventoryReadModel{ProductID: "prod-1", TotalStock: 10, ReservedStock: 3, AvailableStock: 7}, inv)
+	inv, ok := readStore.GetData("inventory", "prod-2")
+	require.True(t, ok)
+	assert.Equal(t, 5, inv.(*readmodel.InventoryReadModel).TotalStock)
+	_, ok = readStore.GetData("inventory", "prod-gone")
+	assert.False(t, ok)
+
+	report, err = checker.Check(ctx, inventory.AggregateType, []string{"prod-1", "prod-2", "prod-gone"})
+	require.NoError(t, err)
+	assert.Empty(t, report.Drifts)
+}
+
+func TestChecker_LaggingReadModelIsNotRepaired(t *testing.T) {
+	checker, eventStore, readStore := setup(t)
+	ctx := context.Background()
+	checker.Repair = true
+
+	// Stored but not projected yet
+	require.NoError(t, inventory.NewService(eventStore).AddStock(ctx, "prod-2", 1))
+
+	report, err := checker.Check(ctx, inventory.AggregateType, []string{"prod-2"})
+	require.NoError(t, err)
+	require.Len(t, report.Drifts, 1)
+	assert.True(t, report.Drifts[0].Lagging)
+	assert.False(t, report.Drifts[0].Repaired)
+	inv, _ := readStore.GetData("inventory", "prod-2")
+	assert.Equal(t, 5, inv.(*readmodel.InventoryReadModel).TotalStock)
+}
+
+func TestChecker_RepairDoesNotOverwriteNewerReadModel(t *testing.T) {
+	_, mockEvents, readStore := setup(t)
+	ctx := context.Background()
+	readStore.SetData("inventory", "prod-2", &readmodel.InventoryReadModel{ProductID: "prod-2", TotalStock: 99})
+
+	// The projector applies a new event after the checker replayed prod-2
+	// but before it repairs the read model
+	eventStore := &interleavedEventStore{MockEventStore: mockEvents, afterRead: func() {
+		require.NoError(t, inventory.NewService(mockEvents).AddStock(ctx, "prod-2", 1))
+		project(t, mockEvents, readStore)
+	}}
+	checker := NewChecker(eventStore, readStore)
+	checker.Repair = true
+
+	report, err := checker.Check(ctx, inventory.AggregateType, []string{"prod-2"})
+	require.NoError(t, err)
+	require.Len(t, report.Drifts, 1)
+	assert.Equal(t, 1, report.Drifts[0].Version)
+	assert.True(t, report.Drifts[0].Lagging)
+	assert.False(t, report.Drifts[0].Repaired)
+	inv, _ := readStore.GetData("inventory", "prod-2")
+	assert.Equal(t, 100, inv.(*readmodel.InventoryReadModel).TotalStock, "the read model the projector advanced is kept")
+	assert.Equal(t, 2, readStore.Checkpoint("inventory", "prod-2"))
+}
+
+// interleavedEventStore calls afterRead once, after the events of an
+// aggregate were read and before they are returned
+type interleavedEventStore struct {
+	*mocks.MockEventStore
+	afterRead func()
+}
+
+func (s *interleavedEventStore) ReadStream(ctx context.Context, aggregateID string, fromVersion int) iter.Seq2[store.Event, error] {
+	var events []store.Event
+	var readErr error
+	for event, err := range s.MockEventStore.ReadStream(ctx, aggregateID, fromVersion) {
+		if err != nil {
+			readErr = err
+			break
+		}
+		events = append(events, event)
+	}
+	if afterRead := s.afterRead; afterRead != nil {
+		s.afterRead = nil
+		afterRead()
+	}
+	return func(yield func(store.Event, error) bool) {
+		for _, event := range events {
+			if !yield(event, nil) {
+				return
+			}
+		}
+		if readErr != nil {
+			yield(store.Event{}, readErr)
+		}
+	}
+}
+
+func TestChecker_OrderStatus(t *testing.T) {
+	ctx := context.Background()
+	eventStore := mocks.NewMockEventStore()
+	readStore := mocks.NewMockReadStore()
+	orderSvc := order.NewService(eventStore)
+	o, err := orderSvc.Place(ctx, "user-1", []order.OrderItem{{ProductID: "prod-1", Name: "Widget", Quantity: 1, Price: 500}})
+	require.NoError(t, err)
+	_, err = orderSvc.CompletePayment(ctx, o.ID, order.OrderPaid{Provider: "fake", CaptureID: "cap-1", Amount: o.Total})
+	require.NoError(t, err)
+	project(t, eventStore, readStore)
+
+	checker := NewChecker(eventStore, readStore)
+	ids, err := checker.AggregateIDs(ctx, order.AggregateType, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{o.ID}, ids)
+
+	report, err := checker.Check(ctx, order.AggregateType, ids)
+	require.NoError(t, err)
+	assert.Empty(t, report.Drifts, "timestamps and items match as stored")
+
+	read, _, err := readStore.ReadModels().Orders.Get(ctx, o.ID)
+	require.NoError(t, err)
+	read.Status = "pending"
+	require.NoError(t, readStore.ReadModels().Orders.Upsert(ctx, o.ID, read))
+	report, err = checker.Check(ctx, order.AggregateType, ids)
+	require.NoError(t, err)
+	require.Len(t, report.Drifts, 1)
+	assert.Equal(t, []Diff{{Field: "status", ReadModel: "pending", Aggregate: "paid"}}, report.Drifts[0].Diffs)
+}
+
+func TestChecker_ShippedOrder(t *testing.T) {
+	ctx := context.Background()
+	eventStore := mocks.NewMockEventStore()
+	readStore := mocks.NewMockReadStore()
+	orderSvc := order.NewService(eventStore)
+	o, err := orderSvc.Place(ctx, "user-1", []order.OrderItem{{ProductID: "prod-1", Name: "Widget", Quantity: 1, Price: 500}})
+	require.NoError(t, err)
+	_, err = orderSvc.CompletePayment(ctx, o.ID, order.OrderPaid{Provider: "fake", CaptureID: "cap-1", Amount: o.Total})
+	require.NoError(t, err)
+	require.NoError(t, orderSvc.Ship(ctx, o.ID, "yamato", "1234-5678-9012"))
+	project(t, eventStore, readStore)
+
+	checker := NewChecker(eventStore, readStore)
+	report, err := checker.Check(ctx, order.AggregateType, []string{o.ID})
+	require.NoError(t, err)
+	assert.Empty(t, report.Drifts)
+
+	read, _, err := readStore.ReadModels().Orders.Get(ctx, o.ID)
+	require.NoError(t, err)
+	read.TrackingNumber = ""
+	read.ShippedAt = nil
+	require.NoError(t, readStore.ReadModels().Orders.Upsert(ctx, o.ID, read))
+	report, err = checker.Check(ctx, order.AggregateType, []string{o.ID})
+	require.NoError(t, err)
+	require.Len(t, report.Drifts, 1)
+	diffs := report.Drifts[0].Diffs
+	require.Len(t, diffs, 2)
+	assert.Equal(t, Diff{Field: "tracking_number", ReadModel: "", Aggregate: "1234-5678-9012"}, diffs[0])
+	assert.Equal(t, "shipped_at", diffs[1].Field)
+}
+
+func TestChecker_UnsupportedType(t *testing.T) {
+	checker := NewChecker(mocks.NewMockEventStore(), mocks.NewMockReadStore())
+
+	_, err := checker.Check(context.Background(), "Cart", []string{"cart-1"})
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+	_, err = checker.AggregateIDs(context.Background(), "Cart", false)
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+}
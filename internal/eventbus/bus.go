@@ -0,0 +1,266 @@
+// Package eventbus delivers the events of the event store to in-process
+// subscribers, so that the API can run the projector and the notifier itself
+// instead of relying on Kinesis and the Lambda consumers (local mode).
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/example/ec-event-driven/internal/consumer"
+	"github.com/example/ec-event-driven/internal/deadletter"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+)
+
+// Defaults for the intervals of a Bus
+const (
+	DefaultPollInterval  = 5 * time.Second
+	DefaultRetryInterval = time.Second
+)
+
+// Bus feeds the events of an event store to its subscribers in position
+// order. Each subscriber reads the store from its own saved position, so it
+// catches up on the events appended while it was not running. Appends made
+// through EventStore wake the subscribers right away; events appended by
+// other processes are picked up within PollInterval.
+type Bus struct {
+	eventStore    store.EventStoreInterface
+	positions     PositionStore
+	subscriptions []*Subscription
+
+	// PollInterval is how long a subscriber waits for new events before
+	// reading the event store again
+	PollInterval time.Duration
+	// RetryInterval is how long a subscriber waits before retrying an event
+	// that failed or a read of the event store that failed
+	RetryInterval time.Duration
+}
+
+// New creates a Bus over eventStore that saves the subscribers' positions in positions
+func New(eventStore store.EventStoreInterface, positions PositionStore) *Bus {
+	return &Bus{
+		eventStore:    eventStore,
+		positions:     positions,
+		PollInterval:  DefaultPollInterval,
+		RetryInterval: DefaultRetryInterval,
+	}
+}
+
+// Subscription is a subscriber of a Bus.
+//
+// The subscriber receives each event as the Kinesis consumers do, after the
+// events before it. An event that fails is retried, holding back the events
+// after it, until it succeeds or the guard dead-letters it.
+type Subscription struct {
+	name    string
+	handler deadletter.EventHandler
+	guard   *deadletter.Guard
+	wake    chan struct{}
+	failing string // Source ID of the event that failed last, if it is still being retried
+
+	// Checkpoints, if set, is used to detect version gaps as in
+	// consumer.BatchProcessor: an event is only handled right after the last
+	// applied version of its aggregate and fails with consumer.ErrVersionGap otherwise.
+	Checkpoints consumer.CheckpointFunc
+
+	// FromLatest makes a subscriber without a saved position start after the
+	// last stored event instead of at the beginning of the event store, e.g.
+	// so that the notifier does not send the mails of past events.
+	FromLatest bool
+}
+
+// Subscribe adds a subscriber named name, which must be unique on the bus as
+// it keys the saved position. The guard dead-letters the events that keep
+// failing. Subscribe must be called before Run.
+func (b *Bus) Subscribe(name string, handler deadletter.EventHandler, guard *deadletter.Guard) *Subscription {
+	s := &Subscription{name: name, handler: handler, guard: guard, wake: make(chan struct{}, 1)}
+	b.subscriptions = append(b.subscriptions, s)
+	return s
+}
+
+// EventStore returns the bus's event store, with appends that wake the subscribers
+func (b *Bus) EventStore() store.EventStoreInterface {
+	return &notifyingEventStore{EventStoreInterface: b.eventStore, bus: b}
+}
+
+// Notify wakes the subscribers to read the new events of the event store
+func (b *Bus) Notify() {
+	for _, s := range b.subscriptions {
+		select {
+		case s.wake <- struct{}{}:
+		default: // Already woken
+		}
+	}
+}
+
+// Run delivers events to the subscribers until ctx is done
+func (b *Bus) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, s := range b.subscriptions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.run(ctx, s)
+		}()
+	}
+	wg.Wait()
+}
+
+// run is the loop of one subscriber
+func (b *Bus) run(ctx context.Context, s *Subscription) {
+	var position int64
+	started, caughtUp := false, false
+	for {
+		if !started {
+			var err error
+			if position, err = b.start(ctx, s); err != nil {
+				log.Printf("[EventBus] %s: Failed to load position: %v", s.name, err)
+			} else {
+				started = true
+				log.Printf("[EventBus] %s: Subscribed from position %d", s.name, position)
+			}
+		}
+		if started {
+			position, caughtUp = b.catchUp(ctx, s, position)
+		}
+
+		// A wake-up does not cut short the wait before a retry
+		wait, wake := b.PollInterval, s.wake
+		if !started || !caughtUp {
+			wait, wake = b.RetryInterval, nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// start returns the position the subscriber resumes from
+func (b *Bus) start(ctx context.Context, s *Subscription) (int64, error) {
+	position, ok, err := b.positions.Position(ctx, s.name)
+	if err != nil || ok || !s.FromLatest {
+		return position, err
+	}
+	for event, err := range b.eventStore.ReadAll(ctx, 0) {
+		if err != nil {
+			return 0, err
+		}
+		position = event.Position
+	}
+	if err := b.positions.SavePosition(ctx, s.name, position); err != nil {
+		return 0, err
+	}
+	return position, nil
+}
+
+// catchUp delivers the events after position to the subscriber and returns
+// the position it reached, and whether it reached the end of the event store
+func (b *Bus) catchUp(ctx context.Context, s *Subscription, position int64) (int64, bool) {
+	for event, err := range b.eventStore.ReadAll(ctx, position) {
+		if err != nil {
+			log.Printf("[EventBus] %s: Failed to read events after position %d: %v", s.name, position, err)
+			return position, false
+		}
+		if !b.deliver(ctx, s, event) {
+			return position, false
+		}
+		position = event.Position
+		if err := b.positions.SavePosition(ctx, s.name, position); err != nil {
+			// The event is delivered again after the retry and must be handled idempotently
+			log.Printf("[EventBus] %s: %v", s.name, err)
+			return position, false
+		}
+	}
+	return position, true
+}
+
+// deliver passes event to the subscriber and reports whether it is done with
+// it, i.e. the event succeeded or was dead-lettered
+func (b *Bus) deliver(ctx context.Context, s *Subscription, event store.Event) bool {
+	sourceID := fmt.Sprintf("position-%d", event.Position)
+	eventJSON, err := json.Marshal(event)
+	if err == nil {
+		err = s.handle(ctx, event, eventJSON)
+	}
+	if err == nil {
+		if s.failing == sourceID {
+			if err := s.guard.Resolved(ctx, sourceID); err != nil {
+				log.Printf("[EventBus] %s: Failed to clear failed attempts of event %s: %v", s.name, event.ID, err)
+			}
+		}
+		s.failing = ""
+		return true
+	}
+
+	log.Printf("[EventBus] %s: Failed to process event %s (%s) of aggregate %s: %v",
+		s.name, event.ID, event.EventType, event.AggregateID, err)
+	deadLettered, dlErr := s.guard.FailedEvent(ctx, sourceID, &event, eventJSON, err)
+	if dlErr != nil {
+		log.Printf("[EventBus] %s: Failed to record failure of event %s: %v", s.name, event.ID, dlErr)
+		return false
+	}
+	if deadLettered {
+		s.failing = ""
+		return true
+	}
+	s.failing = sourceID
+	return false
+}
+
+// handle checks the event for a version gap and passes it to the handler
+func (s *Subscription) handle(ctx context.Context, event store.Event, eventJSON []byte) error {
+	if s.Checkpoints != nil {
+		checkpoints, err := s.Checkpoints(ctx, []string{event.AggregateID})
+		if err != nil {
+			return fmt.Errorf("failed to load checkpoints: %w", err)
+		}
+		if last, ok := checkpoints[event.AggregateID]; ok && event.Version > last+1 {
+			return fmt.Errorf("%w: aggregate %s is at version %d, got version %d",
+				consumer.ErrVersionGap, event.AggregateID, last, event.Version)
+		}
+	}
+	return s.handler.HandleEvent(ctx, []byte(event.AggregateID), eventJSON)
+}
+
+// notifyingEventStore wakes the subscribers of a bus after each append
+type notifyingEventStore struct {
+	store.EventStoreInterface
+	bus *Bus
+}
+
+// Append stores an event and wakes the subscribers
+func (es *notifyingEventStore) Append(ctx context.Context, aggregateID, aggregateType, eventType string, data any) (*store.Event, error) {
+	event, err := es.EventStoreInterface.Append(ctx, aggregateID, aggregateType, eventType, data)
+	if err == nil {
+		es.bus.Notify()
+	}
+	return event, err
+}
+
+// AppendWithExpectedVersion stores an event and wakes the subscribers
+func (es *notifyingEventStore) AppendWithExpectedVersion(ctx context.Context, aggregateID, aggregateType, eventType string, expectedVersion int, data any) (*store.Event, error) {
+	event, err := es.EventStoreInterface.AppendWithExpectedVersion(ctx, aggregateID, aggregateType, eventType, expectedVersion, data)
+	if err == nil {
+		es.bus.Notify()
+	}
+	return event, err
+}
+
+// AppendBatch stores events atomically and wakes the subscribers
+func (es *notifyingEventStore) AppendBatch(ctx context.Context, pending []store.PendingEvent) ([]store.Event, error) {
+	events, err := es.EventStoreInterface.AppendBatch(ctx, pending)
+	if err == nil {
+		es.bus.Notify()
+	}
+	return events, err
+}
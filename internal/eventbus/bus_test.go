@@ -0,0 +1,286 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/example/ec-event-driven/internal/consumer"
+	"github.com/example/ec-event-driven/internal/deadletter"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/example/ec-event-driven/internal/infrastructure/store/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHandler records the events it handles and fails the ones in errs
+type fakeHandler struct {
+	mu      sync.Mutex
+	handled []string
+	errs    map[string]error // event ID -> error
+}
+
+func (h *fakeHandler) HandleEvent(_ context.Context, _, value []byte) error {
+	var event store.Event
+	if err := json.Unmarshal(value, &event); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.errs[event.ID]; err != nil {
+		return err
+	}
+	h.handled = append(h.handled, event.ID)
+	return nil
+}
+
+func (h *fakeHandler) Handled() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.handled...)
+}
+
+func orderEvents(aggregateID string, versions ...int) []store.Event {
+	events := make([]store.Event, len(versions))
+	for i, v := range versions {
+		events[i] = store.Event{
+			ID:          fmt.Sprintf("%s-v%d", aggregateID, v),
+			AggregateID: aggregateID,
+			EventType:   "OrderPlaced",
+			Data:        json.RawMessage(`{}`),
+			Version:     v,
+		}
+	}
+	return events
+}
+
+func newTestBus(t *testing.T) (*Bus, *mocks.MockEventStore, *deadletter.MemoryStore) {
+	t.Helper()
+	eventStore := mocks.NewMockEventStore()
+	return New(eventStore, NewMemoryPositionStore()), eventStore, deadletter.NewMemoryStore()
+}
+
+func TestBus_CatchUpDeliversEventsInOrderAndSavesPosition(t *testing.T) {
+	ctx := context.Background()
+	bus, eventStore, dlq := newTestBus(t)
+	eventStore.SetEvents("order-1", orderEvents("order-1", 1, 2))
+	eventStore.SetEvents("order-2", orderEvents("order-2", 1))
+
+	handler := &fakeHandler{}
+	s := bus.Subscribe("projector", handler, deadletter.NewGuard(deadletter.ConsumerProjector, dlq))
+
+	position, caughtUp := bus.catchUp(ctx, s, 0)
+	assert.True(t, caughtUp)
+	assert.Equal(t, int64(3), position)
+	assert.Equal(t, []string{"order-1-v1", "order-1-v2", "order-2-v1"}, handler.Handled())
+
+	saved, ok, err := bus.positions.Position(ctx, "projector")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), saved)
+
+	// Resuming from the saved position delivers nothing again
+	_, caughtUp = bus.catchUp(ctx, s, saved)
+	assert.True(t, caughtUp)
+	assert.Len(t, handler.Handled(), 3)
+}
+
+func TestBus_FailedEventIsRetriedThenDeadLettered(t *testing.T) {
+	ctx := context.Background()
+	bus, eventStore, dlq := newTestBus(t)
+	eventStore.SetEvents("order-1", orderEvents("order-1", 1, 2))
+
+	handler := &fakeHandler{errs: map[string]error{"order-1-v1": errors.New("smtp unavailable")}}
+	guard := deadletter.NewGuard(deadletter.ConsumerNotifier, dlq)
+	guard.MaxAttempts = 2
+	s := bus.Subscribe("notifier", handler, guard)
+
+	// The failed event holds back the events after it
+	position, caughtUp := bus.catchUp(ctx, s, 0)
+	assert.False(t, caughtUp)
+	assert.Equal(t, int64(0), position)
+	assert.Empty(t, handler.Handled())
+
+	// Dead-lettered on the second attempt, so the next event is delivered
+	position, caughtUp = bus.catchUp(ctx, s, position)
+	assert.True(t, caughtUp)
+	assert.Equal(t, int64(2), position)
+	assert.Equal(t, []string{"order-1-v2"}, handler.Handled())
+
+	entries, err := dlq.List(ctx, deadletter.Filter{Status: deadletter.StatusDead})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "position-1", entries[0].SourceID)
+	assert.Equal(t, "order-1-v1", entries[0].EventID)
+}
+
+func TestBus_RecoveredEventClearsFailedAttempts(t *testing.T) {
+	ctx := context.Background()
+	bus, eventStore, dlq := newTestBus(t)
+	eventStore.SetEvents("order-1", orderEvents("order-1", 1))
+
+	handler := &fakeHandler{errs: map[string]error{"order-1-v1": errors.New("database unavailable")}}
+	s := bus.Subscribe("projector", handler, deadletter.NewGuard(deadletter.ConsumerProjector, dlq))
+
+	_, caughtUp := bus.catchUp(ctx, s, 0)
+	assert.False(t, caughtUp)
+	entries, _ := dlq.List(ctx, deadletter.Filter{})
+	assert.Len(t, entries, 1)
+
+	handler.errs = nil
+	_, caughtUp = bus.catchUp(ctx, s, 0)
+	assert.True(t, caughtUp)
+	entries, _ = dlq.List(ctx, deadletter.Filter{})
+	assert.Empty(t, entries)
+}
+
+func TestBus_VersionGapIsNotHandled(t *testing.T) {
+	ctx := context.Background()
+	bus, eventStore, dlq := newTestBus(t)
+	eventStore.SetEvents("order-1", orderEvents("order-1", 3))
+
+	handler := &fakeHandler{}
+	s := bus.Subscribe("projector", handler, deadletter.NewGuard(deadletter.ConsumerProjector, dlq))
+	s.Checkpoints = func(context.Context, []string) (map[string]int, error) {
+		return map[string]int{"order-1": 1}, nil
+	}
+
+	_, caughtUp := bus.catchUp(ctx, s, 0)
+	assert.False(t, caughtUp)
+	assert.Empty(t, handler.Handled())
+
+	entries, _ := dlq.List(ctx, deadletter.Filter{})
+	require.Len(t, entries, 1)
+	assert.Contains(t, entries[0].Error, consumer.ErrVersionGap.Error())
+}
+
+func TestBus_StartFromLatest(t *testing.T) {
+	ctx := context.Background()
+	bus, eventStore, dlq := newTestBus(t)
+	eventStore.SetEvents("order-1", orderEvents("order-1", 1, 2))
+
+	replaying := bus.Subscribe("projector", &fakeHandler{}, deadletter.NewGuard(deadletter.ConsumerProjector, dlq))
+	latest := bus.Subscribe("notifier", &fakeHandler{}, deadletter.NewGuard(deadletter.ConsumerNotifier, dlq))
+	latest.FromLatest = true
+
+	position, err := bus.start(ctx, replaying)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), position)
+
+	position, err = bus.start(ctx, latest)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), position)
+
+	// Once saved, the position is resumed from
+	require.NoError(t, bus.positions.SavePosition(ctx, "notifier", 1))
+	position, err = bus.start(ctx, latest)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), position)
+}
+
+func TestBus_RunDeliversAppendedEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	bus, _, dlq := newTestBus(t)
+	bus.PollInterval = time.Hour // Only an append can wake the subscriber in time
+
+	handler := &fakeHandler{}
+	bus.Subscribe("projector", handler, deadletter.NewGuard(deadletter.ConsumerProjector, dlq))
+
+	done := make(chan struct{})
+	go func() {
+		bus.Run(ctx)
+		close(done)
+	}()
+
+	event, err := bus.EventStore().Append(ctx, "order-1", "Order", "OrderPlaced", map[string]string{})
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		handled := handler.Handled()
+		return len(handled) == 1 && handled[0] == event.ID
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+}
+
+// checkpointingHandler is a fakeHandler that tracks the last handled or
+// skipped version of each aggregate, like the projector
+type checkpointingHandler struct {
+	fakeHandler
+	checkpoints map[string]int
+}
+
+func (h *checkpointingHandler) HandleEvent(ctx context.Context, key, value []byte) error {
+	if err := h.fakeHandler.HandleEvent(ctx, key, value); err != nil {
+		return err
+	}
+	return h.SkipEvent(ctx, key, value)
+}
+
+func (h *checkpointingHandler) SkipEvent(_ context.Context, _, value []byte) error {
+	var event store.Event
+	if err := json.Unmarshal(value, &event); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checkpoints[event.AggregateID] = max(h.checkpoints[event.AggregateID], event.Version)
+	return nil
+}
+
+func (h *checkpointingHandler) Checkpoints(_ context.Context, aggregateIDs []string) (map[string]int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	checkpoints := make(map[string]int)
+	for _, id := range aggregateIDs {
+		if v, ok := h.checkpoints[id]; ok {
+			checkpoints[id] = v
+		}
+	}
+	return checkpoints, nil
+}
+
+func TestBus_DiscardedEventIsNotAGap(t *testing.T) {
+	ctx := context.Background()
+	bus, eventStore, dlq := newTestBus(t)
+	eventStore.SetEvents("order-1", orderEvents("order-1", 1, 2))
+
+	poisonErr := errors.New("malformed event")
+	handler := &checkpointingHandler{
+		fakeHandler: fakeHandler{errs: map[string]error{"order-1-v2": poisonErr}},
+		checkpoints: map[string]int{},
+	}
+	guard := deadletter.NewGuard(deadletter.ConsumerProjector, dlq)
+	guard.IsPoison = func(err error) bool { return errors.Is(err, poisonErr) }
+	s := bus.Subscribe("projector", handler, guard)
+	s.Checkpoints = handler.Checkpoints
+
+	position, caughtUp := bus.catchUp(ctx, s, 0)
+	require.True(t, caughtUp)
+	dead, _ := dlq.List(ctx, deadletter.Filter{Status: deadletter.StatusDead})
+	require.Len(t, dead, 1)
+
+	_, err := deadletter.NewService(dlq, map[string]deadletter.EventHandler{deadletter.ConsumerProjector: handler}).
+		Discard(ctx, dead[0].ID)
+	require.NoError(t, err)
+
+	_, err = eventStore.AppendWithExpectedVersion(ctx, "order-1", "Order", "OrderPlaced", 2, map[string]string{})
+	require.NoError(t, err)
+	_, caughtUp = bus.catchUp(ctx, s, position)
+
+	assert.True(t, caughtUp)
+	handled := handler.Handled()
+	require.Len(t, handled, 2)
+	assert.Equal(t, "order-1-v1", handled[0])
+	retrying, _ := dlq.List(ctx, deadletter.Filter{Status: deadletter.StatusRetrying})
+	assert.Empty(t, retrying, "no version gap")
+}
@@ -0,0 +1,83 @@
+package eventbus
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// PositionStore keeps the position of the last event each subscriber processed
+type PositionStore interface {
+	// Position returns the subscriber's position, and false if it has none yet
+	Position(ctx context.Context, subscriber string) (int64, bool, error)
+	// SavePosition stores the subscriber's position
+	SavePosition(ctx context.Context, subscriber string, position int64) error
+}
+
+// MemoryPositionStore is an in-memory PositionStore. Its subscribers replay
+// the event store from the beginning when the process restarts.
+type MemoryPositionStore struct {
+	mu        sync.RWMutex
+	positions map[string]int64
+}
+
+// NewMemoryPositionStore creates an empty MemoryPositionStore
+func NewMemoryPositionStore() *MemoryPositionStore {
+	return &MemoryPositionStore{positions: make(map[string]int64)}
+}
+
+// Position returns the subscriber's position
+func (s *MemoryPositionStore) Position(_ context.Context, subscriber string) (int64, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	position, ok := s.positions[subscriber]
+	return position, ok, nil
+}
+
+// SavePosition stores the subscriber's position
+func (s *MemoryPositionStore) SavePosition(_ context.Context, subscriber string, position int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.positions[subscriber] = position
+	return nil
+}
+
+// PostgresPositionStore keeps positions in the event_bus_positions table
+type PostgresPositionStore struct {
+	db *sql.DB
+}
+
+// NewPostgresPositionStore creates a new PostgresPositionStore
+func NewPostgresPositionStore(db *sql.DB) *PostgresPositionStore {
+	return &PostgresPositionStore{db: db}
+}
+
+// Position returns the subscriber's position
+func (s *PostgresPositionStore) Position(ctx context.Context, subscriber string) (int64, bool, error) {
+	var position int64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT position FROM event_bus_positions WHERE subscriber = $1
+	`, subscriber).Scan(&position)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get position of %s: %w", subscriber, err)
+	}
+	return position, true, nil
+}
+
+// SavePosition stores the subscriber's position
+func (s *PostgresPositionStore) SavePosition(ctx context.Context, subscriber string, position int64) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO event_bus_positions (subscriber, position, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (subscriber) DO UPDATE SET position = EXCLUDED.position, updated_at = EXCLUDED.updated_at
+	`, subscriber, position)
+	if err != nil {
+		return fmt.Errorf("failed to save position of %s: %w", subscriber, err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package eventcodec
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+)
+
+// handlerFunc is a typed handler with its data parameter erased
+type handlerFunc func(ctx context.Context, event store.Event, data any) error
+
+// Dispatcher routes decoded events to the typed handlers registered with On
+type Dispatcher struct {
+	registry *Registry
+	handlers map[string]handlerFunc
+}
+
+// NewDispatcher creates a dispatcher decoding events with registry
+func NewDispatcher(registry *Registry) *Dispatcher {
+	return &Dispatcher{
+		registry: registry,
+		handlers: make(map[string]handlerFunc),
+	}
+}
+
+// On registers fn as the handler of the event type whose data is T.
+// It panics if T is not registered or already has a handler.
+func On[T any](d *Dispatcher, fn func(ctx context.Context, event store.Event, data T) error) {
+	var zero T
+	eventType, ok := EventType[T](d.registry)
+	if !ok {
+		panic(fmt.Sprintf("eventcodec: no event type registered for %T", zero))
+	}
+	if _, exists := d.handlers[eventType]; exists {
+		panic("eventcodec: duplicate handler for " + eventType)
+	}
+	d.handlers[eventType] = func(ctx context.Context, event store.Event, data any) error {
+		return fn(ctx, event, data.(T))
+	}
+}
+
+// Dispatch decodes event and passes it to its handler. Registered events
+// without a handler are ignored; unknown or undecodable events are errors.
+func (d *Dispatcher) Dispatch(ctx context.Context, event store.Event) error {
+	data, err := d.registry.Decode(event)
+	if err != nil {
+		return err
+	}
+	handler, ok := d.handlers[event.EventType]
+	if !ok {
+		return nil
+	}
+	return handler(ctx, event, data)
+}
+
+// EventTypes returns the event types that have a handler, sorted
+func (d *Dispatcher) EventTypes() []string {
+	types := make([]string, 0, len(d.handlers))
+	for eventType := range d.handlers {
+		types = append(types, eventType)
+	}
+	slices.Sort(types)
+	return types
+}
@@ -0,0 +1,92 @@
+// Package eventcodec maps event type names to the Go types of their data.
+// Domain packages register their events in init; aggregates, the projector and
+// the notifier decode stored events through the registry instead of switching
+// on event type strings and unmarshalling by hand.
+package eventcodec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+)
+
+var (
+	// ErrUnknownEventType is returned for events whose type is not registered
+	ErrUnknownEventType = errors.New("unknown event type")
+	// ErrUndecodableEvent is returned when event data does not match its registered type
+	ErrUndecodableEvent = errors.New("undecodable event")
+)
+
+// Registry maps event type names to Go types and back
+type Registry struct {
+	mu     sync.RWMutex
+	byName map[string]reflect.Type
+	byType map[reflect.Type]string
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		byName: make(map[string]reflect.Type),
+		byType: make(map[reflect.Type]string),
+	}
+}
+
+// Default is the registry the domain packages register their events on
+var Default = NewRegistry()
+
+// Register registers T as the data type of eventType on Default
+func Register[T any](eventType string) {
+	RegisterOn[T](Default, eventType)
+}
+
+// RegisterOn registers T as the data type of eventType on r.
+// It panics if the name or the type is already registered.
+func RegisterOn[T any](r *Registry, eventType string) {
+	t := reflect.TypeFor[T]()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.byName[eventType]; ok {
+		panic(fmt.Sprintf("eventcodec: %s is already registered as %v", eventType, existing))
+	}
+	if existing, ok := r.byType[t]; ok {
+		panic(fmt.Sprintf("eventcodec: %v is already registered as %s", t, existing))
+	}
+	r.byName[eventType] = t
+	r.byType[t] = eventType
+}
+
+// EventType returns the event type name registered for T
+func EventType[T any](r *Registry) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	name, ok := r.byType[reflect.TypeFor[T]()]
+	return name, ok
+}
+
+// Decode returns the data of event as a value of its registered type
+func (r *Registry) Decode(event store.Event) (any, error) {
+	r.mu.RLock()
+	t, ok := r.byName[event.EventType]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q (event %s)", ErrUnknownEventType, event.EventType, event.ID)
+	}
+
+	ptr := reflect.New(t)
+	if err := json.Unmarshal(event.Data, ptr.Interface()); err != nil {
+		return nil, fmt.Errorf("%w: %s (event %s): %v", ErrUndecodableEvent, event.EventType, event.ID, err)
+	}
+	return ptr.Elem().Interface(), nil
+}
+
+// Decode decodes event with the Default registry
+func Decode(event store.Event) (any, error) {
+	return Default.Decode(event)
+}
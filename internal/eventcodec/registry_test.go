@@ -0,0 +1,148 @@
+package eventcodec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPlaced struct {
+	OrderID string `json:"order_id"`
+	Total   int    `json:"total"`
+}
+
+type testPaid struct {
+	OrderID string `json:"order_id"`
+}
+
+type testNotes struct {
+	Text string `json:"text"`
+}
+
+func newTestRegistry() *Registry {
+	r := NewRegistry()
+	RegisterOn[testPlaced](r, "TestPlaced")
+	RegisterOn[testPaid](r, "TestPaid")
+	return r
+}
+
+func testEvent(eventType, data string) store.Event {
+	return store.Event{ID: "event-1", EventType: eventType, Data: json.RawMessage(data)}
+}
+
+func TestRegistry_Decode(t *testing.T) {
+	r := newTestRegistry()
+
+	decoded, err := r.Decode(testEvent("TestPlaced", `{"order_id":"order-1","total":4000}`))
+
+	require.NoError(t, err)
+	assert.Equal(t, testPlaced{OrderID: "order-1", Total: 4000}, decoded)
+}
+
+func TestRegistry_Decode_UnknownEventType(t *testing.T) {
+	r := newTestRegistry()
+
+	_, err := r.Decode(testEvent("TestShipped", `{}`))
+
+	assert.ErrorIs(t, err, ErrUnknownEventType)
+}
+
+func TestRegistry_Decode_Undecodable(t *testing.T) {
+	r := newTestRegistry()
+
+	_, err := r.Decode(testEvent("TestPlaced", `{"total":"4000"}`))
+
+	assert.ErrorIs(t, err, ErrUndecodableEvent)
+}
+
+func TestRegisterOn_Duplicate(t *testing.T) {
+	r := newTestRegistry()
+
+	assert.Panics(t, func() { RegisterOn[testNotes](r, "TestPlaced") }, "duplicate name")
+	assert.Panics(t, func() { RegisterOn[testPlaced](r, "TestPlacedAgain") }, "duplicate type")
+}
+
+func TestEventType(t *testing.T) {
+	r := newTestRegistry()
+
+	name, ok := EventType[testPaid](r)
+	assert.True(t, ok)
+	assert.Equal(t, "TestPaid", name)
+
+	_, ok = EventType[testNotes](r)
+	assert.False(t, ok)
+}
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	d := NewDispatcher(newTestRegistry())
+	var handled testPlaced
+	On(d, func(ctx context.Context, event store.Event, e testPlaced) error {
+		handled = e
+		return nil
+	})
+
+	err := d.Dispatch(context.Background(), testEvent("TestPlaced", `{"order_id":"order-1","total":4000}`))
+
+	require.NoError(t, err)
+	assert.Equal(t, testPlaced{OrderID: "order-1", Total: 4000}, handled)
+}
+
+func TestDispatcher_Dispatch_HandlerError(t *testing.T) {
+	d := NewDispatcher(newTestRegistry())
+	handlerErr := errors.New("read store unavailable")
+	On(d, func(ctx context.Context, event store.Event, e testPlaced) error {
+		return handlerErr
+	})
+
+	err := d.Dispatch(context.Background(), testEvent("TestPlaced", `{}`))
+
+	assert.ErrorIs(t, err, handlerErr)
+}
+
+func TestDispatcher_Dispatch_WithoutHandler(t *testing.T) {
+	d := NewDispatcher(newTestRegistry())
+
+	err := d.Dispatch(context.Background(), testEvent("TestPaid", `{"order_id":"order-1"}`))
+
+	assert.NoError(t, err)
+}
+
+func TestDispatcher_Dispatch_UnknownOrUndecodable(t *testing.T) {
+	d := NewDispatcher(newTestRegistry())
+	On(d, func(ctx context.Context, event store.Event, e testPlaced) error {
+		t.Fatal("handler must not be called")
+		return nil
+	})
+
+	assert.ErrorIs(t, d.Dispatch(context.Background(), testEvent("TestShipped", `{}`)), ErrUnknownEventType)
+	// Undecodable events fail even without a handler
+	assert.ErrorIs(t, d.Dispatch(context.Background(), testEvent("TestPaid", `[]`)), ErrUndecodableEvent)
+	assert.ErrorIs(t, d.Dispatch(context.Background(), testEvent("TestPlaced", `{"total":"x"}`)), ErrUndecodableEvent)
+}
+
+func TestDispatcher_EventTypes(t *testing.T) {
+	d := NewDispatcher(newTestRegistry())
+	assert.Empty(t, d.EventTypes())
+
+	On(d, func(ctx context.Context, event store.Event, e testPlaced) error { return nil })
+	On(d, func(ctx context.Context, event store.Event, e testPaid) error { return nil })
+
+	assert.Equal(t, []string{"TestPaid", "TestPlaced"}, d.EventTypes())
+}
+
+func TestOn_Panics(t *testing.T) {
+	d := NewDispatcher(newTestRegistry())
+	On(d, func(ctx context.Context, event store.Event, e testPaid) error { return nil })
+
+	assert.Panics(t, func() {
+		On(d, func(ctx context.Context, event store.Event, e testNotes) error { return nil })
+	}, "unregistered type")
+	assert.Panics(t, func() {
+		On(d, func(ctx context.Context, event store.Event, e testPaid) error { return nil })
+	}, "duplicate handler")
+}
@@ -42,6 +42,11 @@ func convertDynamoDBImage(image map[string]events.DynamoDBAttributeValue) (*stor
 		return nil, fmt.Errorf("DynamoDB image is nil")
 	}
 
+	// The position counter shares the events table but is not an event
+	if v, ok := image["aggregate_id"]; ok && v.String() == store.PositionCounterID {
+		return nil, nil
+	}
+
 	event := &store.Event{}
 
 	// Extract required fields
@@ -74,6 +79,20 @@ func convertDynamoDBImage(image map[string]events.DynamoDBAttributeValue) (*stor
 		}
 		event.Version = int(version)
 	}
+	// Position is absent on INSERT images: the sequencer assigns it afterwards
+	if v, ok := image["position"]; ok {
+		position, err := v.Integer()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse position: %w", err)
+		}
+		event.Position = position
+	}
+	// Metadata is absent on events written before it was introduced
+	if v, ok := image["metadata"]; ok && v.String() != "" {
+		if err := json.Unmarshal([]byte(v.String()), &event.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to parse metadata: %w", err)
+		}
+	}
 
 	// Validate required fields
 	if event.ID == "" || event.AggregateID == "" || event.EventType == "" {
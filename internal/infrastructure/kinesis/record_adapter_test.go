@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -63,6 +64,34 @@ func TestConvertDynamoDBImage(t *testing.T) {
 	}
 }
 
+func TestConvertDynamoDBImage_Position(t *testing.T) {
+	event, err := convertDynamoDBImage(map[string]events.DynamoDBAttributeValue{
+		"id":              events.NewStringAttribute("event-123"),
+		"aggregate_id":    events.NewStringAttribute("product-456"),
+		"aggregate_type":  events.NewStringAttribute("Product"),
+		"event_type":      events.NewStringAttribute("ProductCreated"),
+		"data":            events.NewStringAttribute(`{}`),
+		"created_at":      events.NewStringAttribute("2024-01-15T10:30:00Z"),
+		"version":         events.NewNumberAttribute("1"),
+		"position":        events.NewNumberAttribute("1042"),
+		"position_bucket": events.NewNumberAttribute("1"),
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(1042), event.Position)
+}
+
+func TestConvertDynamoDBImage_SkipsPositionCounter(t *testing.T) {
+	event, err := convertDynamoDBImage(map[string]events.DynamoDBAttributeValue{
+		"aggregate_id":  events.NewStringAttribute(store.PositionCounterID),
+		"version":       events.NewNumberAttribute("0"),
+		"last_position": events.NewNumberAttribute("1"),
+	})
+
+	require.NoError(t, err)
+	assert.Nil(t, event)
+}
+
 func TestConvertFromDynamoDBStreamRecord(t *testing.T) {
 	t.Run("INSERT event converts successfully", func(t *testing.T) {
 		record := events.DynamoDBEventRecord{
@@ -141,6 +170,39 @@ func TestConvertFromKinesisRecord(t *testing.T) {
 	})
 }
 
+func TestConvertFromKinesisRecord_Metadata(t *testing.T) {
+	dynamoRecord := events.DynamoDBEventRecord{
+		EventName: "INSERT",
+		Change: events.DynamoDBStreamRecord{
+			NewImage: map[string]events.DynamoDBAttributeValue{
+				"id":             events.NewStringAttribute("event-123"),
+				"aggregate_id":   events.NewStringAttribute("order-456"),
+				"aggregate_type": events.NewStringAttribute("Order"),
+				"event_type":     events.NewStringAttribute("OrderPlaced"),
+				"data":           events.NewStringAttribute(`{"order_id":"order-456"}`),
+				"created_at":     events.NewStringAttribute(time.Now().Format(time.RFC3339Nano)),
+				"version":        events.NewNumberAttribute("1"),
+				"metadata": events.NewStringAttribute(`{"correlation_id":"corr-1","causation_id":"req-1",` +
+					`"actor_user_id":"user-789","source_ip":"203.0.113.7","schema_version":1}`),
+			},
+		},
+	}
+	dynamoRecordJSON, err := json.Marshal(dynamoRecord)
+	require.NoError(t, err)
+
+	event, err := ConvertFromKinesisRecord(events.KinesisEventRecord{
+		Kinesis: events.KinesisRecord{Data: dynamoRecordJSON},
+	})
+
+	require.NoError(t, err)
+	require.NotNil(t, event)
+	assert.Equal(t, "corr-1", event.Metadata.CorrelationID)
+	assert.Equal(t, "req-1", event.Metadata.CausationID)
+	assert.Equal(t, "user-789", event.Metadata.ActorUserID)
+	assert.Equal(t, "203.0.113.7", event.Metadata.SourceIP)
+	assert.Equal(t, 1, event.Metadata.SchemaVersion)
+}
+
 func TestBatchConvertFromKinesisEvent(t *testing.T) {
 	t.Run("batch conversion with mixed results", func(t *testing.T) {
 		validRecord := events.DynamoDBEventRecord{
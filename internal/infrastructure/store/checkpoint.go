@@ -0,0 +1,186 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// IdempotentReadStore is a read store that applies the read model changes of
+// each event at most once per projection, so that redelivered events are skipped
+type IdempotentReadStore interface {
+	ReadStoreInterface
+	// ApplyOnce records the event's version as the checkpoint of each of the
+	// given projections that has not applied this or a later version of the
+	// event's aggregate yet, and calls apply with those projections and a
+	// read store whose writes are committed together with the checkpoints.
+	// It returns the projections the event was applied to; apply is not
+	// called if there are none. Nothing is committed if apply fails.
+	ApplyOnce(ctx context.Context, projections []string, event Event, apply func(tx ReadStoreInterface, pending []string) error) ([]string, error)
+	// Checkpoints returns the last version of each of the given aggregates
+	// applied by the projection. Aggregates it has not applied are omitted.
+	Checkpoints(ctx context.Context, projection string, aggregateIDs []string) (map[string]int, error)
+}
+
+// TransactionalReadStore is an idempotent read store that can group read
+// model changes into a unit of work
+type TransactionalReadStore interface {
+	IdempotentReadStore
+	// UnitOfWork calls fn with a read store whose changes, including the
+	// checkpoints recorded by its ApplyOnce, are committed together if fn
+	// returns nil and discarded otherwise. A unit of work started from the
+	// read store passed to fn is nested in the outer one: its changes are
+	// discarded on its own failure but only committed with the outer one.
+	UnitOfWork(ctx context.Context, fn func(tx TransactionalReadStore) error) error
+	// LockCheckpoint locks the projection's checkpoint of an aggregate until
+	// the unit of work ends, creating it at version if there is none, and
+	// returns its version. Events of the aggregate cannot be applied to the
+	// projection meanwhile, so read models written in the same unit of work
+	// are not overtaken by the projector.
+	LockCheckpoint(ctx context.Context, projection, aggregateID string, version int) (int, error)
+}
+
+// UnitOfWork runs fn in a transaction, or in a savepoint of the transaction
+// the store is bound to
+func (rs *PostgresReadStore) UnitOfWork(ctx context.Context, fn func(tx TransactionalReadStore) error) error {
+	return rs.unitOfWork(ctx, func(tx *PostgresReadStore) error { return fn(tx) })
+}
+
+func (rs *PostgresReadStore) unitOfWork(ctx context.Context, fn func(tx *PostgresReadStore) error) error {
+	if rs.conn == nil {
+		return rs.savepoint(ctx, func() error { return fn(rs) })
+	}
+
+	tx, err := rs.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(&PostgresReadStore{db: tx}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit unit of work: %w", err)
+	}
+	return nil
+}
+
+// savepoint runs fn in a savepoint of the transaction the store is bound to.
+// A failed statement aborts the whole transaction unless it is rolled back
+// to a savepoint, so this keeps the outer unit of work usable after fn fails.
+func (rs *PostgresReadStore) savepoint(ctx context.Context, fn func() error) error {
+	if _, err := rs.db.ExecContext(ctx, "SAVEPOINT unit_of_work"); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	if err := fn(); err != nil {
+		if _, rbErr := rs.db.ExecContext(ctx, "ROLLBACK TO SAVEPOINT unit_of_work"); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to roll back to savepoint: %w", rbErr))
+		}
+		return err
+	}
+	if _, err := rs.db.ExecContext(ctx, "RELEASE SAVEPOINT unit_of_work"); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return nil
+}
+
+// ApplyOnce records the event's version in projection_checkpoints and runs
+// apply in the same unit of work. The checkpoint rows stay locked until the
+// transaction ends, so concurrent deliveries of the same event are serialized
+// and only the first one is applied.
+func (rs *PostgresReadStore) ApplyOnce(ctx context.Context, projections []string, event Event, apply func(tx ReadStoreInterface, pending []string) error) ([]string, error) {
+	var applied []string
+	err := rs.unitOfWork(ctx, func(tx *PostgresReadStore) error {
+		pending, err := recordCheckpoints(ctx, tx.db, projections, event)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil // Already applied
+		}
+		if err := apply(tx, pending); err != nil {
+			return err
+		}
+		applied = pending
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// recordCheckpoints advances the checkpoints of the projections that are
+// behind the event and returns those projections
+func recordCheckpoints(ctx context.Context, tx sqlExecutor, projections []string, event Event) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+		INSERT INTO projection_checkpoints (projection, aggregate_id, last_version, last_event_id, last_position, updated_at)
+		SELECT p, $2, $3, $4, $5, NOW() FROM unnest($1::text[]) AS p
+		ON CONFLICT (projection, aggregate_id) DO UPDATE SET
+			last_version = EXCLUDED.last_version,
+			last_event_id = EXCLUDED.last_event_id,
+			last_position = EXCLUDED.last_position,
+			updated_at = EXCLUDED.updated_at
+		WHERE projection_checkpoints.last_version < EXCLUDED.last_version
+		RETURNING projection
+	`, pq.Array(projections), event.AggregateID, event.Version, event.ID, event.Position)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record checkpoint: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []string
+	for rows.Next() {
+		var projection string
+		if err := rows.Scan(&projection); err != nil {
+			return nil, fmt.Errorf("failed to record checkpoint: %w", err)
+		}
+		pending = append(pending, projection)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to record checkpoint: %w", err)
+	}
+	return pending, nil
+}
+
+// Checkpoints returns the last version of each of the given aggregates applied by the projection
+func (rs *PostgresReadStore) Checkpoints(ctx context.Context, projection string, aggregateIDs []string) (map[string]int, error) {
+	rows, err := rs.db.QueryContext(ctx, `
+		SELECT aggregate_id, last_version FROM projection_checkpoints
+		WHERE projection = $1 AND aggregate_id = ANY($2)
+	`, projection, pq.Array(aggregateIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read projection checkpoints: %w", err)
+	}
+	defer rows.Close()
+
+	checkpoints := make(map[string]int, len(aggregateIDs))
+	for rows.Next() {
+		var aggregateID string
+		var version int
+		if err := rows.Scan(&aggregateID, &version); err != nil {
+			return nil, fmt.Errorf("failed to read projection checkpoints: %w", err)
+		}
+		checkpoints[aggregateID] = version
+	}
+	return checkpoints, rows.Err()
+}
+
+// LockCheckpoint locks the checkpoint row, which ApplyOnce updates, until the
+// transaction ends
+func (rs *PostgresReadStore) LockCheckpoint(ctx context.Context, projection, aggregateID string, version int) (int, error) {
+	var locked int
+	err := rs.db.QueryRowContext(ctx, `
+		INSERT INTO projection_checkpoints (projection, aggregate_id, last_version, last_event_id, updated_at)
+		VALUES ($1, $2, $3, '', NOW())
+		ON CONFLICT (projection, aggregate_id) DO UPDATE SET last_version = projection_checkpoints.last_version
+		RETURNING last_version
+	`, projection, aggregateID, version).Scan(&locked)
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock checkpoint: %w", err)
+	}
+	return locked, nil
+}
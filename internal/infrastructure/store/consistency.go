@@ -0,0 +1,115 @@
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidConsistencyToken is returned when a consistency token cannot be
+// parsed or its signature does not match
+var ErrInvalidConsistencyToken = errors.New("invalid consistency token")
+
+// MaxConsistencyTokenAggregates is the most aggregates a consistency token may
+// hold: a command stores at most one batch of events, each for one aggregate
+const MaxConsistencyTokenAggregates = maxTransactWriteItems
+
+// ConsistencyToken identifies the writes of a command by the versions of the
+// aggregates it stored events for. Clients pass it back to a query so that
+// the query reflects those writes ("read your writes").
+type ConsistencyToken map[string]int // aggregate ID -> version
+
+// TokenFor returns the consistency token of stored events
+func TokenFor(events ...Event) ConsistencyToken {
+	token := make(ConsistencyToken)
+	for _, event := range events {
+		if event.Version > token[event.AggregateID] {
+			token[event.AggregateID] = event.Version
+		}
+	}
+	return token
+}
+
+// AggregateIDs returns the aggregates of the token in a stable order
+func (t ConsistencyToken) AggregateIDs() []string {
+	ids := make([]string, 0, len(t))
+	for id := range t {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// String encodes the token as comma-separated "<aggregate ID>@<version>" pairs
+func (t ConsistencyToken) String() string {
+	pairs := make([]string, 0, len(t))
+	for _, id := range t.AggregateIDs() {
+		pairs = append(pairs, id+"@"+strconv.Itoa(t[id]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ParseConsistencyToken decodes a token encoded by String
+func ParseConsistencyToken(s string) (ConsistencyToken, error) {
+	if n := strings.Count(s, ",") + 1; n > MaxConsistencyTokenAggregates {
+		return nil, fmt.Errorf("%w: %d aggregates (max %d)", ErrInvalidConsistencyToken, n, MaxConsistencyTokenAggregates)
+	}
+	token := make(ConsistencyToken)
+	for pair := range strings.SplitSeq(s, ",") {
+		pair = strings.TrimSpace(pair)
+		at := strings.LastIndex(pair, "@")
+		if at <= 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidConsistencyToken, pair)
+		}
+		version, err := strconv.Atoi(pair[at+1:])
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidConsistencyToken, pair)
+		}
+		id := pair[:at]
+		if version > token[id] {
+			token[id] = version
+		}
+	}
+	return token, nil
+}
+
+// ConsistencyTokenSigner signs consistency tokens for the user they are issued
+// to, so that a client can only make queries wait on tokens the API issued to it
+type ConsistencyTokenSigner struct {
+	key []byte
+}
+
+// NewConsistencyTokenSigner creates a signer with a secret key
+func NewConsistencyTokenSigner(key []byte) *ConsistencyTokenSigner {
+	return &ConsistencyTokenSigner{key: key}
+}
+
+// Sign encodes the token as "<token>.<signature>" for subject
+func (s *ConsistencyTokenSigner) Sign(token ConsistencyToken, subject string) string {
+	payload := token.String()
+	return payload + "." + s.signature(payload, subject)
+}
+
+// Verify checks that a signed token was issued to subject and decodes it
+func (s *ConsistencyTokenSigner) Verify(signed, subject string) (ConsistencyToken, error) {
+	dot := strings.LastIndex(signed, ".")
+	if dot < 0 {
+		return nil, fmt.Errorf("%w: missing signature", ErrInvalidConsistencyToken)
+	}
+	payload := signed[:dot]
+	if !hmac.Equal([]byte(signed[dot+1:]), []byte(s.signature(payload, subject))) {
+		return nil, fmt.Errorf("%w: signature mismatch", ErrInvalidConsistencyToken)
+	}
+	return ParseConsistencyToken(payload)
+}
+
+func (s *ConsistencyTokenSigner) signature(payload, subject string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte("consistency-token\x00" + subject + "\x00" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsistencyToken_RoundTrip(t *testing.T) {
+	token := TokenFor(
+		Event{AggregateID: "order-1", Version: 1},
+		Event{AggregateID: "cart-user@example.com", Version: 4},
+		Event{AggregateID: "cart-user@example.com", Version: 5},
+	)
+	assert.Equal(t, "cart-user@example.com@5,order-1@1", token.String())
+
+	parsed, err := ParseConsistencyToken(token.String())
+	require.NoError(t, err)
+	assert.Equal(t, token, parsed)
+}
+
+func TestParseConsistencyToken_Invalid(t *testing.T) {
+	for _, s := range []string{"", "order-1", "order-1@", "@3", "order-1@0", "order-1@x", "order-1@1,"} {
+		_, err := ParseConsistencyToken(s)
+		assert.ErrorIs(t, err, ErrInvalidConsistencyToken, s)
+	}
+}
+
+func TestParseConsistencyToken_TooManyAggregates(t *testing.T) {
+	token := make(ConsistencyToken)
+	for i := range MaxConsistencyTokenAggregates + 1 {
+		token[fmt.Sprintf("order-%d", i)] = 1
+	}
+
+	_, err := ParseConsistencyToken(token.String())
+	assert.ErrorIs(t, err, ErrInvalidConsistencyToken)
+}
+
+func TestConsistencyTokenSigner_RoundTrip(t *testing.T) {
+	signer := NewConsistencyTokenSigner([]byte("secret"))
+	token := ConsistencyToken{"order-1": 2, "cart-user@example.com": 5}
+
+	signed := signer.Sign(token, "user-1")
+	verified, err := signer.Verify(signed, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, token, verified)
+}
+
+func TestConsistencyTokenSigner_Rejects(t *testing.T) {
+	signer := NewConsistencyTokenSigner([]byte("secret"))
+	signed := signer.Sign(ConsistencyToken{"order-1": 2}, "user-1")
+
+	for name, tc := range map[string]struct{ signed, subject string }{
+		"other user": {signed, "user-2"},
+		"tampered":   {strings.Replace(signed, "order-1@2", "order-1@3", 1), "user-1"},
+		"other key":  {NewConsistencyTokenSigner([]byte("other")).Sign(ConsistencyToken{"order-1": 2}, "user-1"), "user-1"},
+		"unsigned":   {"order-1@2", "user-1"},
+		"empty":      {"", "user-1"},
+	} {
+		_, err := signer.Verify(tc.signed, tc.subject)
+		assert.ErrorIs(t, err, ErrInvalidConsistencyToken, name)
+	}
+}
@@ -0,0 +1,26 @@
+package store
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// NewDynamoDBClient creates a DynamoDB client with optional local endpoint
+func NewDynamoDBClient(ctx context.Context, region, endpoint string) (*dynamodb.Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+
+	if endpoint != "" {
+		// Local development with DynamoDB Local / LocalStack
+		return dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+			o.BaseEndpoint = &endpoint
+		}), nil
+	}
+
+	// Production AWS
+	return dynamodb.NewFromConfig(cfg), nil
+}
@@ -3,7 +3,11 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"iter"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -13,6 +17,34 @@ import (
 	"github.com/google/uuid"
 )
 
+// maxTransactWriteItems is the DynamoDB limit on items in one TransactWriteItems call
+const maxTransactWriteItems = 100
+
+// eventNotExistsCondition rejects a put when the (aggregate_id, version) key is already taken
+const eventNotExistsCondition = "attribute_not_exists(aggregate_id) AND attribute_not_exists(version)"
+
+// PositionCounterID is the aggregate_id of the events table item holding the
+// last global position assigned by the DynamoSequencer
+const PositionCounterID = "$position"
+
+const (
+	// positionIndexName is the GSI ordering events by global position
+	positionIndexName = "PositionIndex"
+	// positionBucketSize is the number of consecutive positions sharing one
+	// partition of the position index
+	positionBucketSize = 1000
+	// sequenceIndexName is the sparse GSI of the events that have no position yet
+	sequenceIndexName = "SequenceIndex"
+	// sequenceShards is the number of partitions of the sequence index. An
+	// aggregate's events always use the same one.
+	sequenceShards = 16
+)
+
+// ErrPositionsNotBackfilled is returned by ReadAll until the events written
+// before positions were assigned have been given positions (see
+// DynamoSequencer.Backfill), as reading would silently leave them out
+var ErrPositionsNotBackfilled = errors.New("events table has events without positions: run the position backfill")
+
 // DynamoEventStore stores events in DynamoDB.
 // Events are automatically streamed to Kinesis Data Streams via DynamoDB Kinesis integration.
 type DynamoEventStore struct {
@@ -23,14 +55,19 @@ type DynamoEventStore struct {
 
 // dynamoEvent represents the DynamoDB item structure
 type dynamoEvent struct {
-	AggregateID   string `dynamodbav:"aggregate_id"`
-	Version       int    `dynamodbav:"version"`
-	ID            string `dynamodbav:"id"`
-	AggregateType string `dynamodbav:"aggregate_type"`
-	EventType     string `dynamodbav:"event_type"`
-	Data          string `dynamodbav:"data"`
-	CreatedAt     string `dynamodbav:"created_at"`
-	GSI1PK        string `dynamodbav:"gsi1pk"`
+	AggregateID    string `dynamodbav:"aggregate_id"`
+	Version        int    `dynamodbav:"version"`
+	ID             string `dynamodbav:"id"`
+	AggregateType  string `dynamodbav:"aggregate_type"`
+	EventType      string `dynamodbav:"event_type"`
+	Data           string `dynamodbav:"data"`
+	CreatedAt      string `dynamodbav:"created_at"`
+	Position       *int64 `dynamodbav:"position,omitempty"`        // Assigned by the DynamoSequencer
+	PositionBucket *int64 `dynamodbav:"position_bucket,omitempty"` // Partition key of the position index
+	// Sequence index keys, set until the event has a position
+	SequenceShard *int   `dynamodbav:"sequence_shard,omitempty"`
+	SequenceKey   int64  `dynamodbav:"sequence_key,omitempty"` // Append time in Unix nanoseconds
+	Metadata      string `dynamodbav:"metadata,omitempty"`     // JSON-encoded EventMetadata
 }
 
 func NewDynamoEventStore(client *dynamodb.Client, tableName, snapshotTableName string) *DynamoEventStore {
@@ -41,58 +78,184 @@ func NewDynamoEventStore(client *dynamodb.Client, tableName, snapshotTableName s
 	}
 }
 
+// TableNames returns the names of the events and snapshots tables
+func (es *DynamoEventStore) TableNames() (events, snapshots string) {
+	return es.tableName, es.snapshotTableName
+}
+
 // Append stores an event in DynamoDB.
 // Events are automatically streamed to Kinesis via DynamoDB Kinesis integration.
 func (es *DynamoEventStore) Append(ctx context.Context, aggregateID, aggregateType, eventType string, data any) (*Event, error) {
-	jsonData, err := json.Marshal(data)
+	// Get current max version for the aggregate
+	version, err := es.getNextVersion(ctx, aggregateID)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get next version: %w", err)
 	}
 
-	eventID := uuid.New().String()
-	timestamp := time.Now()
+	return es.AppendWithExpectedVersion(ctx, aggregateID, aggregateType, eventType, version-1, data)
+}
 
-	// Get current max version for the aggregate
-	version, err := es.getNextVersion(ctx, aggregateID)
+// AppendWithExpectedVersion stores an event only if the aggregate is still at expectedVersion.
+// The conditional put on (aggregate_id, version) fails when another writer has
+// already stored expectedVersion+1.
+func (es *DynamoEventStore) AppendWithExpectedVersion(ctx context.Context, aggregateID, aggregateType, eventType string, expectedVersion int, data any) (*Event, error) {
+	events, err := es.appendEvents(ctx, []PendingEvent{{
+		AggregateID:     aggregateID,
+		AggregateType:   aggregateType,
+		EventType:       eventType,
+		ExpectedVersion: expectedVersion,
+		Data:            data,
+	}})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get next version: %w", err)
+		return nil, err
 	}
+	return &events[0], nil
+}
 
-	item := dynamoEvent{
-		AggregateID:   aggregateID,
-		Version:       version,
-		ID:            eventID,
-		AggregateType: aggregateType,
-		EventType:     eventType,
-		Data:          string(jsonData),
-		CreatedAt:     timestamp.Format(time.RFC3339Nano),
-		GSI1PK:        "EVENTS", // Fixed value for GSI1 to enable GetAllEvents
+// AppendBatch stores all events in a single TransactWriteItems call
+func (es *DynamoEventStore) AppendBatch(ctx context.Context, pending []PendingEvent) ([]Event, error) {
+	if len(pending) == 0 {
+		return nil, nil
+	}
+	if len(pending) > maxTransactWriteItems {
+		return nil, fmt.Errorf("event batch too large: %d events (max %d)", len(pending), maxTransactWriteItems)
 	}
+	return es.appendEvents(ctx, pending)
+}
 
-	av, err := attributevalue.MarshalMap(item)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal event: %w", err)
+// appendEvents writes the events in one transaction. Appends only touch the
+// items of their own events, so unrelated appends never contend; positions
+// are assigned afterwards by the DynamoSequencer. A taken version, or a
+// concurrent transaction writing the same version, fails with ErrConcurrencyConflict.
+func (es *DynamoEventStore) appendEvents(ctx context.Context, pending []PendingEvent) ([]Event, error) {
+	events := make([]Event, 0, len(pending))
+	items := make([]types.TransactWriteItem, 0, len(pending))
+	for _, p := range pending {
+		event, av, err := newDynamoEventItem(ctx, p.AggregateID, p.AggregateType, p.EventType, p.ExpectedVersion+1, p.Data)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+		items = append(items, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName:           aws.String(es.tableName),
+				Item:                av,
+				ConditionExpression: aws.String(eventNotExistsCondition),
+			},
+		})
 	}
 
-	// Use conditional write to prevent duplicate versions (optimistic locking)
-	_, err = es.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName:           aws.String(es.tableName),
-		Item:                av,
-		ConditionExpression: aws.String("attribute_not_exists(aggregate_id) AND attribute_not_exists(version)"),
+	_, err := es.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if err == nil {
+		return events, nil
+	}
+
+	var canceled *types.TransactionCanceledException
+	if !errors.As(err, &canceled) {
+		return nil, fmt.Errorf("failed to write events: %w", err)
+	}
+	// Reasons are reported in the same order as the items
+	for i, reason := range canceled.CancellationReasons {
+		code := aws.ToString(reason.Code)
+		if i < len(pending) && (code == "ConditionalCheckFailed" || code == "TransactionConflict") {
+			p := pending[i]
+			return nil, fmt.Errorf("%w: aggregate %s already has version %d",
+				ErrConcurrencyConflict, p.AggregateID, p.ExpectedVersion+1)
+		}
+	}
+	return nil, fmt.Errorf("failed to write events: %w", err)
+}
+
+// positionCounterKey is the key of the position counter item
+func positionCounterKey() map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"aggregate_id": &types.AttributeValueMemberS{Value: PositionCounterID},
+		"version":      &types.AttributeValueMemberN{Value: "0"},
+	}
+}
+
+// positionCounter is the position counter item
+type positionCounter struct {
+	LastPosition int64 `dynamodbav:"last_position"`
+	// Backfilled is set once every event written before positions were
+	// assigned has been queued for the sequencer, and on new tables
+	Backfilled bool `dynamodbav:"backfilled"`
+}
+
+// readPositionCounter returns the position counter (zero before the first position)
+func (es *DynamoEventStore) readPositionCounter(ctx context.Context) (positionCounter, error) {
+	var counter positionCounter
+	result, err := es.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(es.tableName),
+		Key:            positionCounterKey(),
+		ConsistentRead: aws.Bool(true),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to put event: %w", err)
+		return counter, fmt.Errorf("failed to read position counter: %w", err)
+	}
+	if result.Item == nil {
+		return counter, nil
+	}
+	if err := attributevalue.UnmarshalMap(result.Item, &counter); err != nil {
+		return counter, fmt.Errorf("failed to unmarshal position counter: %w", err)
 	}
+	return counter, nil
+}
 
-	return &Event{
-		ID:            eventID,
+// sequenceShard returns the sequence index partition of an aggregate's events
+func sequenceShard(aggregateID string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(aggregateID))
+	return int(h.Sum32() % sequenceShards)
+}
+
+// newDynamoEventItem builds the event and its DynamoDB item for the given
+// version. The item is queued in the sequence index for its position.
+func newDynamoEventItem(ctx context.Context, aggregateID, aggregateType, eventType string, version int, data any) (*Event, map[string]types.AttributeValue, error) {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metadata := NewEventMetadata(ctx, eventType)
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal event metadata: %w", err)
+	}
+
+	event := &Event{
+		ID:            uuid.New().String(),
 		AggregateID:   aggregateID,
 		AggregateType: aggregateType,
 		EventType:     eventType,
 		Data:          jsonData,
-		Timestamp:     timestamp,
+		Timestamp:     time.Now(),
 		Version:       version,
-	}, nil
+		Metadata:      metadata,
+	}
+
+	shard := sequenceShard(aggregateID)
+	item := dynamoEvent{
+		AggregateID:   aggregateID,
+		Version:       version,
+		ID:            event.ID,
+		AggregateType: aggregateType,
+		EventType:     eventType,
+		Data:          string(jsonData),
+		CreatedAt:     event.Timestamp.Format(time.RFC3339Nano),
+		SequenceShard: &shard,
+		SequenceKey:   event.Timestamp.UnixNano(),
+		Metadata:      string(metadataJSON),
+	}
+
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	return event, av, nil
 }
 
 // getNextVersion queries for the current max version and returns the next one
@@ -125,69 +288,141 @@ func (es *DynamoEventStore) getNextVersion(ctx context.Context, aggregateID stri
 	return item.Version + 1, nil
 }
 
-// GetEvents returns all events for an aggregate from DynamoDB
-func (es *DynamoEventStore) GetEvents(aggregateID string) []Event {
-	ctx := context.Background()
-
-	result, err := es.client.Query(ctx, &dynamodb.QueryInput{
+// ReadStream returns the events of an aggregate after fromVersion, following
+// LastEvaluatedKey so that streams larger than one Query page are read in full
+func (es *DynamoEventStore) ReadStream(ctx context.Context, aggregateID string, fromVersion int) iter.Seq2[Event, error] {
+	return es.queryEvents(ctx, &dynamodb.QueryInput{
 		TableName:              aws.String(es.tableName),
-		KeyConditionExpression: aws.String("aggregate_id = :aid"),
+		KeyConditionExpression: aws.String("aggregate_id = :aid AND version > :ver"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":aid": &types.AttributeValueMemberS{Value: aggregateID},
+			":ver": &types.AttributeValueMemberN{Value: strconv.Itoa(fromVersion)},
 		},
 		ScanIndexForward: aws.Bool(true), // Ascending order by version
 	})
-	if err != nil {
-		return nil
+}
+
+// ReadAll returns the events after fromPosition in position order using the
+// position index. Only events the DynamoSequencer has given a position are
+// returned. The index is eventually consistent, so reading stops at the first
+// position that is not visible yet rather than skipping it; callers read the
+// rest when they resume from the last position they saw. It fails with
+// ErrPositionsNotBackfilled on tables whose older events have no positions.
+func (es *DynamoEventStore) ReadAll(ctx context.Context, fromPosition int64) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		counter, err := es.readPositionCounter(ctx)
+		if err != nil {
+			yield(Event{}, err)
+			return
+		}
+		if !counter.Backfilled {
+			yield(Event{}, ErrPositionsNotBackfilled)
+			return
+		}
+		head := counter.LastPosition
+
+		next := fromPosition + 1
+		for next <= head {
+			bucket := next / positionBucketSize
+			events := es.queryEvents(ctx, &dynamodb.QueryInput{
+				TableName:              aws.String(es.tableName),
+				IndexName:              aws.String(positionIndexName),
+				KeyConditionExpression: aws.String("position_bucket = :bucket AND #pos >= :next"),
+				ExpressionAttributeNames: map[string]string{
+					"#pos": "position", // POSITION is a reserved word
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":bucket": &types.AttributeValueMemberN{Value: strconv.FormatInt(bucket, 10)},
+					":next":   &types.AttributeValueMemberN{Value: strconv.FormatInt(next, 10)},
+				},
+				ScanIndexForward: aws.Bool(true), // Ascending order by position
+			})
+			for event, err := range events {
+				if err != nil {
+					yield(Event{}, err)
+					return
+				}
+				if event.Position != next {
+					return // An earlier position is not in the index yet
+				}
+				if !yield(event, nil) {
+					return
+				}
+				next++
+			}
+			if next/positionBucketSize == bucket {
+				return // The bucket ended early: the index has not caught up
+			}
+		}
 	}
+}
 
-	return es.unmarshalEvents(result.Items)
+// queryEvents runs a query page by page and yields the decoded events
+func (es *DynamoEventStore) queryEvents(ctx context.Context, input *dynamodb.QueryInput) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		page := *input
+		for {
+			result, err := es.client.Query(ctx, &page)
+			if err != nil {
+				yield(Event{}, fmt.Errorf("failed to query events: %w", err))
+				return
+			}
+
+			for _, item := range result.Items {
+				event, err := unmarshalEvent(item)
+				if err == nil {
+					event, err = DefaultUpcasters.Upcast(event)
+				}
+				if err != nil {
+					yield(Event{}, err)
+					return
+				}
+				if !yield(event, nil) {
+					return
+				}
+			}
+
+			if len(result.LastEvaluatedKey) == 0 {
+				return
+			}
+			page.ExclusiveStartKey = result.LastEvaluatedKey
+		}
+	}
 }
 
-// GetAllEvents returns all events from DynamoDB using GSI1
-func (es *DynamoEventStore) GetAllEvents() []Event {
-	ctx := context.Background()
+// unmarshalEvent converts a DynamoDB item to an Event
+func unmarshalEvent(item map[string]types.AttributeValue) (Event, error) {
+	var de dynamoEvent
+	if err := attributevalue.UnmarshalMap(item, &de); err != nil {
+		return Event{}, fmt.Errorf("failed to unmarshal event: %w", err)
+	}
 
-	result, err := es.client.Query(ctx, &dynamodb.QueryInput{
-		TableName:              aws.String(es.tableName),
-		IndexName:              aws.String("GSI1"),
-		KeyConditionExpression: aws.String("gsi1pk = :pk"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":pk": &types.AttributeValueMemberS{Value: "EVENTS"},
-		},
-		ScanIndexForward: aws.Bool(true), // Ascending order by created_at
-	})
+	timestamp, err := time.Parse(time.RFC3339Nano, de.CreatedAt)
 	if err != nil {
-		return nil
+		return Event{}, fmt.Errorf("invalid created_at on event %s: %w", de.ID, err)
 	}
 
-	return es.unmarshalEvents(result.Items)
-}
-
-// unmarshalEvents converts DynamoDB items to Event slice
-func (es *DynamoEventStore) unmarshalEvents(items []map[string]types.AttributeValue) []Event {
-	events := make([]Event, 0, len(items))
-
-	for _, item := range items {
-		var de dynamoEvent
-		if err := attributevalue.UnmarshalMap(item, &de); err != nil {
-			continue
+	var metadata EventMetadata
+	if de.Metadata != "" {
+		if err := json.Unmarshal([]byte(de.Metadata), &metadata); err != nil {
+			return Event{}, fmt.Errorf("invalid metadata on event %s: %w", de.ID, err)
 		}
-
-		timestamp, _ := time.Parse(time.RFC3339Nano, de.CreatedAt)
-
-		events = append(events, Event{
-			ID:            de.ID,
-			AggregateID:   de.AggregateID,
-			AggregateType: de.AggregateType,
-			EventType:     de.EventType,
-			Data:          json.RawMessage(de.Data),
-			Timestamp:     timestamp,
-			Version:       de.Version,
-		})
 	}
 
-	return events
+	event := Event{
+		ID:            de.ID,
+		AggregateID:   de.AggregateID,
+		AggregateType: de.AggregateType,
+		EventType:     de.EventType,
+		Data:          json.RawMessage(de.Data),
+		Timestamp:     timestamp,
+		Version:       de.Version,
+		Metadata:      metadata,
+	}
+	if de.Position != nil {
+		event.Position = *de.Position
+	}
+	return event, nil
 }
 
 // dynamoSnapshot represents the DynamoDB item structure for snapshots
@@ -197,6 +432,7 @@ type dynamoSnapshot struct {
 	AggregateType string `dynamodbav:"aggregate_type"`
 	Version       int    `dynamodbav:"version"`   // Event version at snapshot time
 	State         string `dynamodbav:"state"`     // Serialized aggregate state
+	SchemaVersion int    `dynamodbav:"schema_version"`
 	CreatedAt     string `dynamodbav:"created_at"`
 }
 
@@ -212,6 +448,7 @@ func (es *DynamoEventStore) SaveSnapshot(ctx context.Context, snapshot *Snapshot
 		AggregateType: snapshot.AggregateType,
 		Version:       snapshot.Version,
 		State:         string(stateJSON),
+		SchemaVersion: snapshot.SchemaVersion,
 		CreatedAt:     snapshot.CreatedAt.Format(time.RFC3339Nano),
 	}
 
@@ -248,8 +485,63 @@ func (es *DynamoEventStore) GetSnapshot(ctx context.Context, aggregateID string)
 		return nil, nil // No snapshot exists
 	}
 
+	return unmarshalSnapshot(result.Item)
+}
+
+// ListSnapshots scans the snapshots table for the snapshots of an aggregate type
+func (es *DynamoEventStore) ListSnapshots(ctx context.Context, aggregateType string) iter.Seq2[Snapshot, error] {
+	return func(yield func(Snapshot, error) bool) {
+		input := &dynamodb.ScanInput{
+			TableName:        aws.String(es.snapshotTableName),
+			FilterExpression: aws.String("aggregate_type = :type"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":type": &types.AttributeValueMemberS{Value: aggregateType},
+			},
+		}
+		for {
+			result, err := es.client.Scan(ctx, input)
+			if err != nil {
+				yield(Snapshot{}, fmt.Errorf("failed to scan snapshots: %w", err))
+				return
+			}
+
+			for _, item := range result.Items {
+				snapshot, err := unmarshalSnapshot(item)
+				if err != nil {
+					yield(Snapshot{}, err)
+					return
+				}
+				if !yield(*snapshot, nil) {
+					return
+				}
+			}
+
+			if len(result.LastEvaluatedKey) == 0 {
+				return
+			}
+			input.ExclusiveStartKey = result.LastEvaluatedKey
+		}
+	}
+}
+
+// DeleteSnapshot removes the snapshot of an aggregate from the snapshots table
+func (es *DynamoEventStore) DeleteSnapshot(ctx context.Context, aggregateID string) error {
+	_, err := es.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(es.snapshotTableName),
+		Key: map[string]types.AttributeValue{
+			"aggregate_id": &types.AttributeValueMemberS{Value: aggregateID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete snapshot: %w", err)
+	}
+	return nil
+}
+
+// unmarshalSnapshot converts a DynamoDB item to a Snapshot
+func unmarshalSnapshot(item map[string]types.AttributeValue) (*Snapshot, error) {
 	var ds dynamoSnapshot
-	if err := attributevalue.UnmarshalMap(result.Item, &ds); err != nil {
+	if err := attributevalue.UnmarshalMap(item, &ds); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal snapshot: %w", err)
 	}
 
@@ -260,24 +552,7 @@ func (es *DynamoEventStore) GetSnapshot(ctx context.Context, aggregateID string)
 		AggregateType: ds.AggregateType,
 		Version:       ds.Version,
 		State:         json.RawMessage(ds.State),
+		SchemaVersion: ds.SchemaVersion,
 		CreatedAt:     createdAt,
 	}, nil
 }
-
-// GetEventsFromVersion returns events for an aggregate starting from a specific version
-func (es *DynamoEventStore) GetEventsFromVersion(ctx context.Context, aggregateID string, fromVersion int) []Event {
-	result, err := es.client.Query(ctx, &dynamodb.QueryInput{
-		TableName:              aws.String(es.tableName),
-		KeyConditionExpression: aws.String("aggregate_id = :aid AND version > :ver"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":aid": &types.AttributeValueMemberS{Value: aggregateID},
-			":ver": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", fromVersion)},
-		},
-		ScanIndexForward: aws.Bool(true), // Ascending order by version
-	})
-	if err != nil {
-		return nil
-	}
-
-	return es.unmarshalEvents(result.Items)
-}
@@ -0,0 +1,443 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DefaultSequencerPollInterval is how long a DynamoSequencer waits for new events by default
+const DefaultSequencerPollInterval = time.Second
+
+// errSequencerConflict is returned when another sequencer assigned positions first
+var errSequencerConflict = errors.New("position counter was advanced by another sequencer")
+
+// DynamoSequencer assigns the global positions of the events of a
+// DynamoEventStore. Appends only write their own events and queue them in the
+// sequence index; the sequencer gives the queued events the next positions,
+// each aggregate's events in version order, and advances the position counter
+// in the same transaction. Several sequencers may run: the counter update is
+// conditional, so only one of them assigns each position.
+type DynamoSequencer struct {
+	es   *DynamoEventStore
+	wake chan struct{}
+
+	// PollInterval is how long Run waits for new events before looking again
+	PollInterval time.Duration
+	// Sequenced, if set, is called after positions have been assigned, e.g. to
+	// wake the subscribers of an event bus reading ReadAll
+	Sequenced func()
+}
+
+// NewDynamoSequencer creates a sequencer for the events of es
+func NewDynamoSequencer(es *DynamoEventStore) *DynamoSequencer {
+	return &DynamoSequencer{
+		es:           es,
+		wake:         make(chan struct{}, 1),
+		PollInterval: DefaultSequencerPollInterval,
+	}
+}
+
+// Notify makes Run look for new events right away
+func (s *DynamoSequencer) Notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default: // Already woken
+	}
+}
+
+// EventStore returns the sequencer's event store, with appends that wake the sequencer
+func (s *DynamoSequencer) EventStore() EventStoreInterface {
+	return &sequencingEventStore{EventStoreInterface: s.es, sequencer: s}
+}
+
+// Run assigns positions until ctx is done
+func (s *DynamoSequencer) Run(ctx context.Context) {
+	for {
+		// Drain the queue before waiting. Losing to another sequencer is not
+		// an error: it has assigned the positions.
+		for {
+			n, err := s.SequenceOnce(ctx)
+			if err != nil && ctx.Err() == nil && !errors.Is(err, errSequencerConflict) {
+				log.Printf("[Sequencer] Failed to assign positions: %v", err)
+			}
+			if err != nil || n == 0 {
+				break
+			}
+		}
+
+		timer := time.NewTimer(s.PollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// sequenceItem is an event waiting for its position
+type sequenceItem struct {
+	AggregateID string `dynamodbav:"aggregate_id"`
+	Version     int    `dynamodbav:"version"`
+	Position    *int64 `dynamodbav:"position"`
+	SequenceKey int64  `dynamodbav:"sequence_key"`
+	// Shard is only used to tell queued events from unpositioned legacy ones
+	Shard *int `dynamodbav:"sequence_shard"`
+}
+
+// SequenceOnce assigns positions to the events queued so far and returns how
+// many it assigned. It fails with ErrPositionsNotBackfilled until Backfill has run.
+func (s *DynamoSequencer) SequenceOnce(ctx context.Context) (int, error) {
+	counter, err := s.es.readPositionCounter(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !counter.Backfilled {
+		return 0, ErrPositionsNotBackfilled
+	}
+
+	aggregateIDs, err := s.queuedAggregates(ctx)
+	if err != nil {
+		return 0, err
+	}
+	tails := make([][]sequenceItem, 0, len(aggregateIDs))
+	for _, aggregateID := range aggregateIDs {
+		tail, err := s.queuedTail(ctx, aggregateID)
+		if err != nil {
+			return 0, err
+		}
+		if len(tail) > 0 {
+			tails = append(tails, tail)
+		}
+	}
+
+	assigned, err := s.assign(ctx, counter.LastPosition, mergeTails(tails))
+	if assigned > 0 && s.Sequenced != nil {
+		s.Sequenced()
+	}
+	return assigned, err
+}
+
+// queuedAggregates returns the aggregates with events in the sequence index.
+// The index is eventually consistent: events it does not show yet are
+// sequenced in a later round.
+func (s *DynamoSequencer) queuedAggregates(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	var aggregateIDs []string
+	for shard := range sequenceShards {
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String(s.es.tableName),
+			IndexName:              aws.String(sequenceIndexName),
+			KeyConditionExpression: aws.String("sequence_shard = :shard"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":shard": &types.AttributeValueMemberN{Value: strconv.Itoa(shard)},
+			},
+		}
+		for {
+			result, err := s.es.client.Query(ctx, input)
+			if err != nil {
+				return nil, fmt.Errorf("failed to query sequence index: %w", err)
+			}
+			for _, item := range result.Items {
+				var key struct {
+					AggregateID string `dynamodbav:"aggregate_id"`
+				}
+				if err := attributevalue.UnmarshalMap(item, &key); err != nil {
+					return nil, fmt.Errorf("failed to unmarshal sequence index item: %w", err)
+				}
+				if !seen[key.AggregateID] {
+					seen[key.AggregateID] = true
+					aggregateIDs = append(aggregateIDs, key.AggregateID)
+				}
+			}
+			if len(result.LastEvaluatedKey) == 0 {
+				break
+			}
+			input.ExclusiveStartKey = result.LastEvaluatedKey
+		}
+	}
+	return aggregateIDs, nil
+}
+
+// queuedTail returns the events of an aggregate after its last positioned
+// event in version order. It reads the table itself, consistently, so that no
+// queued version is passed over while the index catches up.
+func (s *DynamoSequencer) queuedTail(ctx context.Context, aggregateID string) ([]sequenceItem, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.es.tableName),
+		KeyConditionExpression: aws.String("aggregate_id = :aid"),
+		ExpressionAttributeNames: map[string]string{
+			"#pos": "position", // POSITION is a reserved word
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":aid": &types.AttributeValueMemberS{Value: aggregateID},
+		},
+		ProjectionExpression: aws.String("aggregate_id, version, #pos, sequence_shard, sequence_key"),
+		ScanIndexForward:     aws.Bool(false), // Newest first, down to the last positioned event
+		ConsistentRead:       aws.Bool(true),
+	}
+	var tail []sequenceItem
+	for {
+		result, err := s.es.client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query events of %s: %w", aggregateID, err)
+		}
+		for _, av := range result.Items {
+			var item sequenceItem
+			if err := attributevalue.UnmarshalMap(av, &item); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal event of %s: %w", aggregateID, err)
+			}
+			if item.Position != nil {
+				slices.Reverse(tail)
+				return tail, nil
+			}
+			if item.Shard == nil {
+				return nil, fmt.Errorf("event %s/%d: %w", aggregateID, item.Version, ErrPositionsNotBackfilled)
+			}
+			tail = append(tail, item)
+		}
+		if len(result.LastEvaluatedKey) == 0 {
+			slices.Reverse(tail)
+			return tail, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// mergeTails orders the queued events of several aggregates by append time,
+// keeping the events of each aggregate in version order even when the clocks
+// of the appending processes disagree
+func mergeTails(tails [][]sequenceItem) []sequenceItem {
+	var merged []sequenceItem
+	heads := make([]int, len(tails))
+	for {
+		next := -1
+		for i, tail := range tails {
+			if heads[i] == len(tail) {
+				continue
+			}
+			if next < 0 || compareSequenceItems(tail[heads[i]], tails[next][heads[next]]) < 0 {
+				next = i
+			}
+		}
+		if next < 0 {
+			return merged
+		}
+		merged = append(merged, tails[next][heads[next]])
+		heads[next]++
+	}
+}
+
+// compareSequenceItems orders events by append time, then by key
+func compareSequenceItems(a, b sequenceItem) int {
+	return cmp.Or(
+		cmp.Compare(a.SequenceKey, b.SequenceKey),
+		cmp.Compare(a.AggregateID, b.AggregateID),
+		cmp.Compare(a.Version, b.Version),
+	)
+}
+
+// assign gives the events the positions after last in order. Each
+// transaction advances the counter from the value it read, so a transaction
+// racing another sequencer fails as a whole and the events stay queued.
+func (s *DynamoSequencer) assign(ctx context.Context, last int64, items []sequenceItem) (int, error) {
+	assigned := 0
+	for chunk := range slices.Chunk(items, maxTransactWriteItems-1) {
+		transactItems := make([]types.TransactWriteItem, 0, len(chunk)+1)
+		transactItems = append(transactItems, types.TransactWriteItem{
+			Update: &types.Update{
+				TableName:           aws.String(s.es.tableName),
+				Key:                 positionCounterKey(),
+				UpdateExpression:    aws.String("SET last_position = :next"),
+				ConditionExpression: aws.String("last_position = :last"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":last": &types.AttributeValueMemberN{Value: strconv.FormatInt(last, 10)},
+					":next": &types.AttributeValueMemberN{Value: strconv.FormatInt(last+int64(len(chunk)), 10)},
+				},
+			},
+		})
+		for i, item := range chunk {
+			position := last + int64(i) + 1
+			transactItems = append(transactItems, types.TransactWriteItem{
+				Update: &types.Update{
+					TableName: aws.String(s.es.tableName),
+					Key: map[string]types.AttributeValue{
+						"aggregate_id": &types.AttributeValueMemberS{Value: item.AggregateID},
+						"version":      &types.AttributeValueMemberN{Value: strconv.Itoa(item.Version)},
+					},
+					UpdateExpression:    aws.String("SET #pos = :pos, position_bucket = :bucket REMOVE sequence_shard, sequence_key"),
+					ConditionExpression: aws.String("attribute_exists(aggregate_id) AND attribute_not_exists(#pos)"),
+					ExpressionAttributeNames: map[string]string{
+						"#pos": "position",
+					},
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":pos":    &types.AttributeValueMemberN{Value: strconv.FormatInt(position, 10)},
+						":bucket": &types.AttributeValueMemberN{Value: strconv.FormatInt(position/positionBucketSize, 10)},
+					},
+				},
+			})
+		}
+
+		_, err := s.es.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: transactItems,
+		})
+		if err != nil {
+			var canceled *types.TransactionCanceledException
+			if errors.As(err, &canceled) {
+				return assigned, fmt.Errorf("%w: %v", errSequencerConflict, err)
+			}
+			return assigned, fmt.Errorf("failed to assign positions: %w", err)
+		}
+		last += int64(len(chunk))
+		assigned += len(chunk)
+	}
+	return assigned, nil
+}
+
+// Backfill gives positions to the events written before positions were
+// assigned, after the positions assigned so far, and then marks the table as
+// backfilled so that ReadAll and the sequencer can run. It is idempotent and
+// returns the number of events it gave a position. Events appended meanwhile
+// stay queued for the sequencer.
+func (s *DynamoSequencer) Backfill(ctx context.Context) (int, error) {
+	legacy, err := s.unpositionedEvents(ctx)
+	if err != nil {
+		return 0, err
+	}
+	// Positions follow the creation times, each aggregate's events in version order
+	byAggregate := make(map[string][]sequenceItem)
+	for _, item := range legacy {
+		byAggregate[item.AggregateID] = append(byAggregate[item.AggregateID], item)
+	}
+	tails := make([][]sequenceItem, 0, len(byAggregate))
+	for _, tail := range byAggregate {
+		slices.SortFunc(tail, func(a, b sequenceItem) int { return cmp.Compare(a.Version, b.Version) })
+		tails = append(tails, tail)
+	}
+
+	counter, err := s.es.readPositionCounter(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if counter.LastPosition == 0 {
+		// The conditional counter update of assign needs the attribute
+		if err := s.markBackfilled(ctx, counter.Backfilled); err != nil {
+			return 0, err
+		}
+	}
+	assigned, err := s.assign(ctx, counter.LastPosition, mergeTails(tails))
+	if err != nil {
+		return assigned, err
+	}
+	return assigned, s.markBackfilled(ctx, true)
+}
+
+// unpositionedEvents scans the table for events that have neither a position
+// nor a place in the sequence index
+func (s *DynamoSequencer) unpositionedEvents(ctx context.Context) ([]sequenceItem, error) {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(s.es.tableName),
+		FilterExpression: aws.String("attribute_not_exists(#pos) AND attribute_not_exists(sequence_shard) AND aggregate_id <> :counter"),
+		ExpressionAttributeNames: map[string]string{
+			"#pos": "position",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":counter": &types.AttributeValueMemberS{Value: PositionCounterID},
+		},
+		ProjectionExpression: aws.String("aggregate_id, version, created_at"),
+		ConsistentRead:       aws.Bool(true),
+	}
+	var items []sequenceItem
+	for {
+		result, err := s.es.client.Scan(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan events: %w", err)
+		}
+		for _, av := range result.Items {
+			var event struct {
+				AggregateID string `dynamodbav:"aggregate_id"`
+				Version     int    `dynamodbav:"version"`
+				CreatedAt   string `dynamodbav:"created_at"`
+			}
+			if err := attributevalue.UnmarshalMap(av, &event); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+			}
+			createdAt, err := time.Parse(time.RFC3339Nano, event.CreatedAt)
+			if err != nil {
+				return nil, fmt.Errorf("invalid created_at on event %s/%d: %w", event.AggregateID, event.Version, err)
+			}
+			items = append(items, sequenceItem{
+				AggregateID: event.AggregateID,
+				Version:     event.Version,
+				SequenceKey: createdAt.UnixNano(),
+			})
+		}
+		if len(result.LastEvaluatedKey) == 0 {
+			return items, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// markBackfilled creates the position counter if needed and sets its backfilled flag
+func (s *DynamoSequencer) markBackfilled(ctx context.Context, backfilled bool) error {
+	_, err := s.es.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(s.es.tableName),
+		Key:              positionCounterKey(),
+		UpdateExpression: aws.String("SET last_position = if_not_exists(last_position, :zero), backfilled = :backfilled"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":zero":       &types.AttributeValueMemberN{Value: "0"},
+			":backfilled": &types.AttributeValueMemberBOOL{Value: backfilled},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update position counter: %w", err)
+	}
+	return nil
+}
+
+// sequencingEventStore wakes a sequencer after each append
+type sequencingEventStore struct {
+	EventStoreInterface
+	sequencer *DynamoSequencer
+}
+
+// Append stores an event and wakes the sequencer
+func (es *sequencingEventStore) Append(ctx context.Context, aggregateID, aggregateType, eventType string, data any) (*Event, error) {
+	event, err := es.EventStoreInterface.Append(ctx, aggregateID, aggregateType, eventType, data)
+	if err == nil {
+		es.sequencer.Notify()
+	}
+	return event, err
+}
+
+// AppendWithExpectedVersion stores an event and wakes the sequencer
+func (es *sequencingEventStore) AppendWithExpectedVersion(ctx context.Context, aggregateID, aggregateType, eventType string, expectedVersion int, data any) (*Event, error) {
+	event, err := es.EventStoreInterface.AppendWithExpectedVersion(ctx, aggregateID, aggregateType, eventType, expectedVersion, data)
+	if err == nil {
+		es.sequencer.Notify()
+	}
+	return event, err
+}
+
+// AppendBatch stores events atomically and wakes the sequencer
+func (es *sequencingEventStore) AppendBatch(ctx context.Context, pending []PendingEvent) ([]Event, error) {
+	events, err := es.EventStoreInterface.AppendBatch(ctx, pending)
+	if err == nil {
+		es.sequencer.Notify()
+	}
+	return events, err
+}
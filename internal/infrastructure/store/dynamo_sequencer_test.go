@@ -0,0 +1,39 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeTails_OrdersByAppendTime(t *testing.T) {
+	merged := mergeTails([][]sequenceItem{
+		{{AggregateID: "order-1", Version: 3, SequenceKey: 10}, {AggregateID: "order-1", Version: 4, SequenceKey: 30}},
+		{{AggregateID: "cart-1", Version: 1, SequenceKey: 20}},
+	})
+
+	assert.Equal(t, []string{"order-1/3", "cart-1/1", "order-1/4"}, sequenceKeys(merged))
+}
+
+func TestMergeTails_KeepsVersionOrderOnClockSkew(t *testing.T) {
+	// Version 2 was appended by a process whose clock is behind
+	merged := mergeTails([][]sequenceItem{
+		{{AggregateID: "order-1", Version: 1, SequenceKey: 50}, {AggregateID: "order-1", Version: 2, SequenceKey: 10}},
+		{{AggregateID: "cart-1", Version: 1, SequenceKey: 20}},
+	})
+
+	assert.Equal(t, []string{"cart-1/1", "order-1/1", "order-1/2"}, sequenceKeys(merged))
+}
+
+func TestMergeTails_Empty(t *testing.T) {
+	assert.Empty(t, mergeTails(nil))
+}
+
+func sequenceKeys(items []sequenceItem) []string {
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		keys = append(keys, fmt.Sprintf("%s/%d", item.AggregateID, item.Version))
+	}
+	return keys
+}
@@ -0,0 +1,25 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseURLFromEnv(t *testing.T) {
+	t.Setenv("DATABASE_URL", "")
+	assert.Equal(t, DefaultDatabaseURL, DatabaseURLFromEnv())
+
+	t.Setenv("DATABASE_URL", "postgres://other")
+	assert.Equal(t, "postgres://other", DatabaseURLFromEnv())
+}
+
+func TestOpenEventStoreFromEnv_UnknownBackend(t *testing.T) {
+	t.Setenv("EVENT_STORE", "mysql")
+
+	_, _, err := OpenEventStoreFromEnv(context.Background(), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown EVENT_STORE "mysql"`)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"iter"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -18,6 +20,12 @@ type Event struct {
 	Data          json.RawMessage `json:"data"`
 	Timestamp     time.Time       `json:"timestamp"`
 	Version       int             `json:"version"`
+	Metadata      EventMetadata   `json:"metadata"`
+	// Position is the event's place in the global commit order. Positions
+	// start at 1 and have no gaps; pass the last one seen back to ReadAll to
+	// resume reading. The DynamoDB store assigns it after the append, so it
+	// is 0 on the events returned by its appends.
+	Position int64 `json:"position,omitempty"`
 }
 
 // MarshalJSON returns the JSON encoding of the event
@@ -26,14 +34,58 @@ func (e Event) MarshalJSON() ([]byte, error) {
 	return json.Marshal(&struct{ Alias }{Alias: Alias(e)})
 }
 
+// ErrConcurrencyConflict is returned when an event cannot be appended because
+// the aggregate was modified after the caller loaded it
+var ErrConcurrencyConflict = errors.New("concurrency conflict")
+
+// PendingEvent is an event waiting to be appended as part of a batch.
+// The event is stored at ExpectedVersion+1; when a batch holds several events
+// for the same aggregate, each one must expect the version of the previous one.
+type PendingEvent struct {
+	AggregateID     string
+	AggregateType   string
+	EventType       string
+	ExpectedVersion int
+	Data            any
+}
+
 // EventStoreInterface defines the interface for event stores
 type EventStoreInterface interface {
+	// Append stores an event at the aggregate's next version without checking
+	// what the caller last saw. Use it only for events that do not depend on
+	// aggregate state.
 	Append(ctx context.Context, aggregateID, aggregateType, eventType string, data any) (*Event, error)
-	GetEvents(aggregateID string) []Event
-	GetAllEvents() []Event
+	// AppendWithExpectedVersion stores an event at expectedVersion+1 and returns
+	// ErrConcurrencyConflict if the aggregate is no longer at expectedVersion.
+	// Use expectedVersion 0 for a new aggregate.
+	AppendWithExpectedVersion(ctx context.Context, aggregateID, aggregateType, eventType string, expectedVersion int, data any) (*Event, error)
+	// AppendBatch stores events for one or more aggregates atomically: either
+	// all of them are stored or none are. Any version mismatch fails the whole
+	// batch with ErrConcurrencyConflict.
+	AppendBatch(ctx context.Context, events []PendingEvent) ([]Event, error)
+	// ReadStream yields the events of an aggregate with a version greater than
+	// fromVersion, in version order. Use fromVersion 0 to read the whole stream.
+	// A read failure is yielded as the last element.
+	ReadStream(ctx context.Context, aggregateID string, fromVersion int) iter.Seq2[Event, error]
+	// ReadAll yields every event after fromPosition in position order, without
+	// gaps: an event is never yielded before all events with a lower position.
+	// Use fromPosition 0 to read from the beginning. A store that cannot
+	// return its whole history fails instead of yielding part of it.
+	ReadAll(ctx context.Context, fromPosition int64) iter.Seq2[Event, error]
 	SaveSnapshot(ctx context.Context, snapshot *Snapshot) error
 	GetSnapshot(ctx context.Context, aggregateID string) (*Snapshot, error)
-	GetEventsFromVersion(ctx context.Context, aggregateID string, fromVersion int) []Event
+}
+
+// CollectEvents drains an event iterator into a slice, stopping at the first error
+func CollectEvents(seq iter.Seq2[Event, error]) ([]Event, error) {
+	var events []Event
+	for event, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
 }
 
 // ConnectPostgres establishes a connection to PostgreSQL
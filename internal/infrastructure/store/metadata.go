@@ -0,0 +1,56 @@
+package store
+
+import "context"
+
+// InitialSchemaVersion is the schema version of event types without upcasters.
+// Events stored before schema versions were recorded have this version.
+const InitialSchemaVersion = 1
+
+// EventMetadata describes who or what produced an event
+type EventMetadata struct {
+	CorrelationID string `json:"correlation_id,omitempty"` // Shared by all events of one request or workflow
+	CausationID   string `json:"causation_id,omitempty"`   // ID of the request or event that caused this event
+	ActorUserID   string `json:"actor_user_id,omitempty"`  // Authenticated user who issued the command
+	SourceIP      string `json:"source_ip,omitempty"`
+	SchemaVersion int    `json:"schema_version,omitempty"` // Version of the event's data schema
+}
+
+type metadataContextKey struct{}
+
+// WithMetadata returns a context whose appended events carry md
+func WithMetadata(ctx context.Context, md EventMetadata) context.Context {
+	return context.WithValue(ctx, metadataContextKey{}, md)
+}
+
+// MetadataFromContext returns the metadata set with WithMetadata, or the zero value
+func MetadataFromContext(ctx context.Context) EventMetadata {
+	md, _ := ctx.Value(metadataContextKey{}).(EventMetadata)
+	return md
+}
+
+// WithActor returns a context whose appended events record userID as their actor
+func WithActor(ctx context.Context, userID string) context.Context {
+	md := MetadataFromContext(ctx)
+	md.ActorUserID = userID
+	return WithMetadata(ctx, md)
+}
+
+// WithCausation returns a context for handling event: follow-up events keep
+// its correlation ID and record it as their cause
+func WithCausation(ctx context.Context, event Event) context.Context {
+	md := MetadataFromContext(ctx)
+	md.CorrelationID = event.Metadata.CorrelationID
+	if md.CorrelationID == "" {
+		md.CorrelationID = event.ID
+	}
+	md.CausationID = event.ID
+	return WithMetadata(ctx, md)
+}
+
+// NewEventMetadata returns the metadata to persist for an event of eventType
+// appended with ctx, stamped with the latest schema version of the type
+func NewEventMetadata(ctx context.Context, eventType string) EventMetadata {
+	md := MetadataFromContext(ctx)
+	md.SchemaVersion = DefaultUpcasters.LatestVersion(eventType)
+	return md
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEventMetadata_FromContext(t *testing.T) {
+	ctx := WithMetadata(context.Background(), EventMetadata{
+		CorrelationID: "corr-1",
+		CausationID:   "req-1",
+		SourceIP:      "203.0.113.7",
+	})
+	ctx = WithActor(ctx, "user-123")
+
+	md := NewEventMetadata(ctx, "OrderPlaced")
+
+	assert.Equal(t, "corr-1", md.CorrelationID)
+	assert.Equal(t, "req-1", md.CausationID)
+	assert.Equal(t, "user-123", md.ActorUserID)
+	assert.Equal(t, "203.0.113.7", md.SourceIP)
+	assert.Equal(t, InitialSchemaVersion, md.SchemaVersion)
+}
+
+func TestNewEventMetadata_EmptyContext(t *testing.T) {
+	md := NewEventMetadata(context.Background(), "OrderPlaced")
+
+	assert.Equal(t, EventMetadata{SchemaVersion: InitialSchemaVersion}, md)
+}
+
+func TestWithCausation(t *testing.T) {
+	cause := Event{
+		ID:       "event-1",
+		Metadata: EventMetadata{CorrelationID: "corr-1", CausationID: "req-1", ActorUserID: "user-123"},
+	}
+
+	md := MetadataFromContext(WithCausation(context.Background(), cause))
+
+	assert.Equal(t, "corr-1", md.CorrelationID)
+	assert.Equal(t, "event-1", md.CausationID)
+	// The actor of the cause is not carried over to follow-up events
+	assert.Empty(t, md.ActorUserID)
+}
+
+func TestWithCausation_StartsCorrelationForLegacyEvents(t *testing.T) {
+	md := MetadataFromContext(WithCausation(context.Background(), Event{ID: "event-1"}))
+
+	assert.Equal(t, "event-1", md.CorrelationID)
+	assert.Equal(t, "event-1", md.CausationID)
+}
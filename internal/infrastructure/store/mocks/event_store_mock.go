@@ -3,6 +3,9 @@ package mocks
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"iter"
+	"sort"
 	"sync"
 	"time"
 
@@ -12,9 +15,10 @@ import (
 
 // MockEventStore is a mock implementation of EventStoreInterface for testing
 type MockEventStore struct {
-	mu        sync.RWMutex
-	events    map[string][]store.Event
-	snapshots map[string]*store.Snapshot
+	mu           sync.RWMutex
+	events       map[string][]store.Event
+	snapshots    map[string]*store.Snapshot
+	lastPosition int64 // Last global position assigned to a stored event
 
 	// For tracking calls in tests
 	AppendCalls       []AppendCall
@@ -22,14 +26,19 @@ type MockEventStore struct {
 	AppendCallback    func(ctx context.Context, aggregateID, aggregateType, eventType string, data any) (*store.Event, error)
 	SaveSnapshotCalls []SaveSnapshotCall
 	SaveSnapshotErr   error
+	ReadErr           error // Yielded by ReadStream and ReadAll after the stored events
+
+	// Upcasters applied by ReadStream and ReadAll; store.DefaultUpcasters if nil
+	Upcasters *store.UpcasterRegistry
 }
 
-// AppendCall records parameters passed to Append
+// AppendCall records parameters passed to Append or AppendWithExpectedVersion
 type AppendCall struct {
-	AggregateID   string
-	AggregateType string
-	EventType     string
-	Data          any
+	AggregateID     string
+	AggregateType   string
+	EventType       string
+	ExpectedVersion int // -1 for Append
+	Data            any
 }
 
 // SaveSnapshotCall records parameters passed to SaveSnapshot
@@ -49,15 +58,70 @@ func NewMockEventStore() *MockEventStore {
 
 // Append stores an event in memory
 func (m *MockEventStore) Append(ctx context.Context, aggregateID, aggregateType, eventType string, data any) (*store.Event, error) {
+	return m.append(ctx, aggregateID, aggregateType, eventType, -1, data)
+}
+
+// AppendWithExpectedVersion stores an event in memory if the aggregate is at expectedVersion
+func (m *MockEventStore) AppendWithExpectedVersion(ctx context.Context, aggregateID, aggregateType, eventType string, expectedVersion int, data any) (*store.Event, error) {
+	return m.append(ctx, aggregateID, aggregateType, eventType, expectedVersion, data)
+}
+
+func (m *MockEventStore) append(ctx context.Context, aggregateID, aggregateType, eventType string, expectedVersion int, data any) (*store.Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	event, err := m.appendLocked(ctx, aggregateID, aggregateType, eventType, expectedVersion, data, nil)
+	if err != nil || event == nil {
+		return event, err
+	}
+	m.storeLocked(event)
+	return event, nil
+}
+
+// AppendBatch stores all events or none of them. Every event is recorded in
+// AppendCalls and passed to AppendCallback, in order, until one fails.
+func (m *MockEventStore) AppendBatch(ctx context.Context, pending []store.PendingEvent) ([]store.Event, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	// Versions written earlier in the batch, not yet visible in m.events
+	batchVersions := make(map[string]int)
+	var stored []store.Event
+	for _, p := range pending {
+		event, err := m.appendLocked(ctx, p.AggregateID, p.AggregateType, p.EventType, p.ExpectedVersion, p.Data, batchVersions)
+		if err != nil {
+			return nil, err
+		}
+		if event != nil {
+			batchVersions[p.AggregateID] = event.Version
+			stored = append(stored, *event)
+		}
+	}
+
+	for i := range stored {
+		m.storeLocked(&stored[i])
+	}
+	return stored, nil
+}
+
+// storeLocked assigns the next global position to event and stores it.
+// Callers must hold m.mu.
+func (m *MockEventStore) storeLocked(event *store.Event) {
+	m.lastPosition++
+	event.Position = m.lastPosition
+	m.events[event.AggregateID] = append(m.events[event.AggregateID], *event)
+}
+
+// appendLocked records the call and builds the event without storing it.
+// Callers must hold m.mu.
+func (m *MockEventStore) appendLocked(ctx context.Context, aggregateID, aggregateType, eventType string, expectedVersion int, data any, batchVersions map[string]int) (*store.Event, error) {
 	// Record the call
 	m.AppendCalls = append(m.AppendCalls, AppendCall{
-		AggregateID:   aggregateID,
-		AggregateType: aggregateType,
-		EventType:     eventType,
-		Data:          data,
+		AggregateID:     aggregateID,
+		AggregateType:   aggregateType,
+		EventType:       eventType,
+		ExpectedVersion: expectedVersion,
+		Data:            data,
 	})
 
 	// Use callback if provided
@@ -70,35 +134,56 @@ func (m *MockEventStore) Append(ctx context.Context, aggregateID, aggregateType,
 		return nil, m.AppendErr
 	}
 
+	currentVersion := m.currentVersion(aggregateID)
+	if v, ok := batchVersions[aggregateID]; ok {
+		currentVersion = v
+	}
+	if expectedVersion >= 0 && expectedVersion != currentVersion {
+		return nil, fmt.Errorf("%w: aggregate %s is at version %d, expected %d",
+			store.ErrConcurrencyConflict, aggregateID, currentVersion, expectedVersion)
+	}
+
 	// Create event
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return nil, err
 	}
+	metadata := store.NewEventMetadata(ctx, eventType)
+	metadata.SchemaVersion = m.upcasters().LatestVersion(eventType)
 
-	version := len(m.events[aggregateID]) + 1
-	event := store.Event{
+	return &store.Event{
 		ID:            uuid.New().String(),
 		AggregateID:   aggregateID,
 		AggregateType: aggregateType,
 		EventType:     eventType,
 		Data:          jsonData,
 		Timestamp:     time.Now(),
-		Version:       version,
-	}
+		Version:       currentVersion + 1,
+		Metadata:      metadata,
+	}, nil
+}
 
-	m.events[aggregateID] = append(m.events[aggregateID], event)
-	return &event, nil
+// currentVersion returns the latest version of an aggregate, taking snapshots
+// set without their preceding events into account. Callers must hold m.mu.
+func (m *MockEventStore) currentVersion(aggregateID string) int {
+	version := 0
+	if events := m.events[aggregateID]; len(events) > 0 {
+		version = events[len(events)-1].Version
+	}
+	if snapshot := m.snapshots[aggregateID]; snapshot != nil && snapshot.Version > version {
+		version = snapshot.Version
+	}
+	return version
 }
 
-// GetEvents returns events for an aggregate
+// GetEvents returns the stored events of an aggregate (for test assertions)
 func (m *MockEventStore) GetEvents(aggregateID string) []store.Event {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	return m.events[aggregateID]
 }
 
-// GetAllEvents returns all events
+// GetAllEvents returns all stored events in position order (for test assertions)
 func (m *MockEventStore) GetAllEvents() []store.Event {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -107,26 +192,98 @@ func (m *MockEventStore) GetAllEvents() []store.Event {
 	for _, events := range m.events {
 		all = append(all, events...)
 	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Position < all[j].Position })
 	return all
 }
 
+// ReadStream yields the events of an aggregate after fromVersion, then ReadErr if set
+func (m *MockEventStore) ReadStream(ctx context.Context, aggregateID string, fromVersion int) iter.Seq2[store.Event, error] {
+	m.mu.RLock()
+	var events []store.Event
+	for _, event := range m.events[aggregateID] {
+		if event.Version > fromVersion {
+			events = append(events, event)
+		}
+	}
+	readErr := m.ReadErr
+	upcasters := m.upcasters()
+	m.mu.RUnlock()
+
+	return yieldEvents(events, upcasters, readErr)
+}
+
+// ReadAll yields all events after fromPosition in position order, then ReadErr if set
+func (m *MockEventStore) ReadAll(ctx context.Context, fromPosition int64) iter.Seq2[store.Event, error] {
+	var events []store.Event
+	for _, event := range m.GetAllEvents() {
+		if event.Position > fromPosition {
+			events = append(events, event)
+		}
+	}
+
+	m.mu.RLock()
+	readErr := m.ReadErr
+	upcasters := m.upcasters()
+	m.mu.RUnlock()
+
+	return yieldEvents(events, upcasters, readErr)
+}
+
+// upcasters returns the registry applied on reads. Callers must hold m.mu.
+func (m *MockEventStore) upcasters() *store.UpcasterRegistry {
+	if m.Upcasters != nil {
+		return m.Upcasters
+	}
+	return store.DefaultUpcasters
+}
+
+func yieldEvents(events []store.Event, upcasters *store.UpcasterRegistry, readErr error) iter.Seq2[store.Event, error] {
+	return func(yield func(store.Event, error) bool) {
+		for _, event := range events {
+			event, err := upcasters.Upcast(event)
+			if err != nil {
+				yield(store.Event{}, err)
+				return
+			}
+			if !yield(event, nil) {
+				return
+			}
+		}
+		if readErr != nil {
+			yield(store.Event{}, readErr)
+		}
+	}
+}
+
 // Reset clears all events and recorded calls
 func (m *MockEventStore) Reset() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.events = make(map[string][]store.Event)
 	m.snapshots = make(map[string]*store.Snapshot)
+	m.lastPosition = 0
 	m.AppendCalls = make([]AppendCall, 0)
 	m.SaveSnapshotCalls = make([]SaveSnapshotCall, 0)
 	m.AppendErr = nil
 	m.AppendCallback = nil
 	m.SaveSnapshotErr = nil
+	m.ReadErr = nil
+	m.Upcasters = nil
 }
 
-// SetEvents sets events directly for testing
+// SetEvents sets events directly for testing. Events without a position are
+// given the next ones, in order.
 func (m *MockEventStore) SetEvents(aggregateID string, events []store.Event) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	for i := range events {
+		if events[i].Position == 0 {
+			m.lastPosition++
+			events[i].Position = m.lastPosition
+		} else if events[i].Position > m.lastPosition {
+			m.lastPosition = events[i].Position
+		}
+	}
 	m.events[aggregateID] = events
 }
 
@@ -151,7 +308,7 @@ func (m *MockEventStore) AddEvent(aggregateID, aggregateType, eventType string,
 		Version:       version,
 	}
 
-	m.events[aggregateID] = append(m.events[aggregateID], event)
+	m.storeLocked(&event)
 	return nil
 }
 
@@ -180,24 +337,38 @@ func (m *MockEventStore) GetSnapshot(ctx context.Context, aggregateID string) (*
 	return m.snapshots[aggregateID], nil
 }
 
-// GetEventsFromVersion returns events for an aggregate starting from a specific version
-func (m *MockEventStore) GetEventsFromVersion(ctx context.Context, aggregateID string, fromVersion int) []store.Event {
+// SetSnapshot sets a snapshot directly for testing
+func (m *MockEventStore) SetSnapshot(snapshot *store.Snapshot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshots[snapshot.AggregateID] = snapshot
+}
+
+// ListSnapshots yields the snapshots of an aggregate type in aggregate ID order
+func (m *MockEventStore) ListSnapshots(ctx context.Context, aggregateType string) iter.Seq2[store.Snapshot, error] {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
+	var snapshots []store.Snapshot
+	for _, snapshot := range m.snapshots {
+		if snapshot.AggregateType == aggregateType {
+			snapshots = append(snapshots, *snapshot)
+		}
+	}
+	m.mu.RUnlock()
 
-	events := m.events[aggregateID]
-	result := make([]store.Event, 0)
-	for _, event := range events {
-		if event.Version > fromVersion {
-			result = append(result, event)
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].AggregateID < snapshots[j].AggregateID })
+	return func(yield func(store.Snapshot, error) bool) {
+		for _, snapshot := range snapshots {
+			if !yield(snapshot, nil) {
+				return
+			}
 		}
 	}
-	return result
 }
 
-// SetSnapshot sets a snapshot directly for testing
-func (m *MockEventStore) SetSnapshot(snapshot *store.Snapshot) {
+// DeleteSnapshot removes the snapshot of an aggregate
+func (m *MockEventStore) DeleteSnapshot(ctx context.Context, aggregateID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.snapshots[snapshot.AggregateID] = snapshot
+	delete(m.snapshots, aggregateID)
+	return nil
 }
@@ -1,13 +1,25 @@
 package mocks
 
 import (
+	"context"
 	"sync"
+
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
 )
 
 // MockReadStore is a mock implementation of ReadStoreInterface for testing
 type MockReadStore struct {
 	mu   sync.RWMutex
 	data map[string]map[string]any // collection -> id -> data
+	// Last applied version per projection and aggregate, recorded by ApplyOnce
+	checkpoints map[checkpointKey]int
+
+	// Errors to return for testing failure paths
+	ReadErr  error // Returned by Get, GetAll and Checkpoints
+	WriteErr error // Returned by Set, Delete, Update and Clear
+
+	// UnitsOfWork counts the units of work started with UnitOfWork
+	UnitsOfWork int
 
 	// For tracking calls in tests
 	SetCalls    []SetCall
@@ -16,6 +28,11 @@ type MockReadStore struct {
 	UpdateCalls []UpdateCall
 }
 
+type checkpointKey struct {
+	projection  string
+	aggregateID string
+}
+
 // SetCall records parameters passed to Set
 type SetCall struct {
 	Collection string
@@ -45,6 +62,7 @@ type UpdateCall struct {
 func NewMockReadStore() *MockReadStore {
 	return &MockReadStore{
 		data:        make(map[string]map[string]any),
+		checkpoints: make(map[checkpointKey]int),
 		SetCalls:    make([]SetCall, 0),
 		GetCalls:    make([]GetCall, 0),
 		DeleteCalls: make([]DeleteCall, 0),
@@ -52,6 +70,12 @@ func NewMockReadStore() *MockReadStore {
 	}
 }
 
+// ReadModels returns typed repositories over the mock's collections, so reads
+// and writes through them are recorded and fail with ReadErr and WriteErr
+func (m *MockReadStore) ReadModels() *store.ReadModels {
+	return store.CollectionReadModels(m)
+}
+
 // Set stores a read model
 func (m *MockReadStore) Set(collection, id string, data any) error {
 	m.mu.Lock()
@@ -62,6 +86,9 @@ func (m *MockReadStore) Set(collection, id string, data any) error {
 		ID:         id,
 		Data:       data,
 	})
+	if m.WriteErr != nil {
+		return m.WriteErr
+	}
 
 	if m.data[collection] == nil {
 		m.data[collection] = make(map[string]any)
@@ -79,6 +106,9 @@ func (m *MockReadStore) Get(collection, id string) (any, bool, error) {
 		Collection: collection,
 		ID:         id,
 	})
+	if m.ReadErr != nil {
+		return nil, false, m.ReadErr
+	}
 
 	if m.data[collection] == nil {
 		return nil, false, nil
@@ -91,6 +121,9 @@ func (m *MockReadStore) Get(collection, id string) (any, bool, error) {
 func (m *MockReadStore) GetAll(collection string) ([]any, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	if m.ReadErr != nil {
+		return nil, m.ReadErr
+	}
 
 	if m.data[collection] == nil {
 		return []any{}, nil
@@ -112,6 +145,9 @@ func (m *MockReadStore) Delete(collection, id string) error {
 		Collection: collection,
 		ID:         id,
 	})
+	if m.WriteErr != nil {
+		return m.WriteErr
+	}
 
 	if m.data[collection] != nil {
 		delete(m.data[collection], id)
@@ -128,6 +164,9 @@ func (m *MockReadStore) Update(collection, id string, updateFn func(current any)
 		Collection: collection,
 		ID:         id,
 	})
+	if m.WriteErr != nil {
+		return false, m.WriteErr
+	}
 
 	if m.data[collection] == nil {
 		return false, nil
@@ -140,11 +179,24 @@ func (m *MockReadStore) Update(collection, id string, updateFn func(current any)
 	return true, nil
 }
 
+// Clear removes every read model of a collection
+func (m *MockReadStore) Clear(collection string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.WriteErr != nil {
+		return m.WriteErr
+	}
+	delete(m.data, collection)
+	return nil
+}
+
 // Reset clears all data and recorded calls
 func (m *MockReadStore) Reset() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.data = make(map[string]map[string]any)
+	m.checkpoints = make(map[checkpointKey]int)
+	m.UnitsOfWork = 0
 	m.SetCalls = make([]SetCall, 0)
 	m.GetCalls = make([]GetCall, 0)
 	m.DeleteCalls = make([]DeleteCall, 0)
@@ -171,3 +223,75 @@ func (m *MockReadStore) GetData(collection, id string) (any, bool) {
 	data, ok := m.data[collection][id]
 	return data, ok
 }
+
+// ApplyOnce calls apply with the mock itself and the projections that have
+// not applied this or a later version of the event's aggregate yet. Unlike
+// the Postgres store, writes made by a failing apply are not rolled back.
+func (m *MockReadStore) ApplyOnce(ctx context.Context, projections []string, event store.Event, apply func(tx store.ReadStoreInterface, pending []string) error) ([]string, error) {
+	var pending []string
+	m.mu.RLock()
+	for _, projection := range projections {
+		if event.Version > m.checkpoints[checkpointKey{projection: projection, aggregateID: event.AggregateID}] {
+			pending = append(pending, projection)
+		}
+	}
+	m.mu.RUnlock()
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	if err := apply(m, pending); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	for _, projection := range pending {
+		m.checkpoints[checkpointKey{projection: projection, aggregateID: event.AggregateID}] = event.Version
+	}
+	m.mu.Unlock()
+	return pending, nil
+}
+
+// UnitOfWork calls fn with the mock itself. Unlike the Postgres store,
+// writes made by a failing fn are not rolled back.
+func (m *MockReadStore) UnitOfWork(ctx context.Context, fn func(tx store.TransactionalReadStore) error) error {
+	m.mu.Lock()
+	m.UnitsOfWork++
+	m.mu.Unlock()
+	return fn(m)
+}
+
+// Checkpoints returns the last version of each of the given aggregates applied by the projection
+func (m *MockReadStore) Checkpoints(ctx context.Context, projection string, aggregateIDs []string) (map[string]int, error) {
+	if m.ReadErr != nil {
+		return nil, m.ReadErr
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	checkpoints := make(map[string]int)
+	for _, id := range aggregateIDs {
+		if version, ok := m.checkpoints[checkpointKey{projection: projection, aggregateID: id}]; ok {
+			checkpoints[id] = version
+		}
+	}
+	return checkpoints, nil
+}
+
+// LockCheckpoint returns the checkpoint of an aggregate, creating it at
+// version if there is none. The mock does not lock anything.
+func (m *MockReadStore) LockCheckpoint(ctx context.Context, projection, aggregateID string, version int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := checkpointKey{projection: projection, aggregateID: aggregateID}
+	if _, ok := m.checkpoints[key]; !ok {
+		m.checkpoints[key] = version
+	}
+	return m.checkpoints[key], nil
+}
+
+// Checkpoint returns the last version of an aggregate applied by a projection (for test assertions)
+func (m *MockReadStore) Checkpoint(projection, aggregateID string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.checkpoints[checkpointKey{projection: projection, aggregateID: aggregateID}]
+}
@@ -0,0 +1,323 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// PostgresEventStore stores events in the PostgreSQL `events` table.
+// The table's UNIQUE (aggregate_id, version) constraint and the
+// check_event_version trigger guarantee sequential versions per aggregate.
+type PostgresEventStore struct {
+	db *sql.DB
+}
+
+// NewPostgresEventStore creates a new PostgreSQL-based event store
+func NewPostgresEventStore(db *sql.DB) *PostgresEventStore {
+	return &PostgresEventStore{db: db}
+}
+
+// Append stores an event with the next version for its aggregate
+func (es *PostgresEventStore) Append(ctx context.Context, aggregateID, aggregateType, eventType string, data any) (*Event, error) {
+	// Compute the next version and insert in a single statement
+	return insertEvent(ctx, es.db, `
+		WITH next AS (`+nextPositionQuery+`)
+		INSERT INTO events (id, aggregate_id, aggregate_type, event_type, data, version, created_at, metadata, position)
+		SELECT $1, $2, $3, $4, $5,
+			(SELECT COALESCE(MAX(version), 0) + 1 FROM events WHERE aggregate_id = $2), $6, $7, next.last_position
+		FROM next
+		RETURNING version, position
+	`, aggregateID, aggregateType, eventType, data)
+}
+
+// AppendWithExpectedVersion stores an event only if the aggregate is still at expectedVersion
+func (es *PostgresEventStore) AppendWithExpectedVersion(ctx context.Context, aggregateID, aggregateType, eventType string, expectedVersion int, data any) (*Event, error) {
+	return insertEvent(ctx, es.db, insertVersionedEventQuery, aggregateID, aggregateType, eventType, data, expectedVersion+1)
+}
+
+// AppendBatch stores all events in a single transaction
+func (es *PostgresEventStore) AppendBatch(ctx context.Context, pending []PendingEvent) ([]Event, error) {
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	tx, err := es.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	events := make([]Event, 0, len(pending))
+	for _, p := range pending {
+		event, err := insertEvent(ctx, tx, insertVersionedEventQuery, p.AggregateID, p.AggregateType, p.EventType, p.Data, p.ExpectedVersion+1)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+
+	if err := tx.Commit(); err != nil {
+		if isVersionConflict(err) {
+			return nil, fmt.Errorf("%w: %v", ErrConcurrencyConflict, err)
+		}
+		return nil, fmt.Errorf("failed to commit event batch: %w", err)
+	}
+
+	return events, nil
+}
+
+// nextPositionQuery takes the next global position. The counter row stays
+// locked until the appending transaction ends, so positions are committed in
+// order and a rolled back append gives its position back.
+const nextPositionQuery = `UPDATE event_position SET last_position = last_position + 1 RETURNING last_position`
+
+// insertVersionedEventQuery inserts an event at an explicit version ($8)
+const insertVersionedEventQuery = `
+	WITH next AS (` + nextPositionQuery + `)
+	INSERT INTO events (id, aggregate_id, aggregate_type, event_type, data, version, created_at, metadata, position)
+	SELECT $1, $2, $3, $4, $5, $8, $6, $7, next.last_position FROM next
+	RETURNING version, position
+`
+
+// queryRower is implemented by both *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// insertEvent runs an INSERT ... RETURNING version, position statement whose first seven
+// parameters are id, aggregate_id, aggregate_type, event_type, data, created_at and metadata
+func insertEvent(ctx context.Context, q queryRower, query, aggregateID, aggregateType, eventType string, data any, extraArgs ...any) (*Event, error) {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	eventID := uuid.New().String()
+	timestamp := time.Now()
+
+	metadata := NewEventMetadata(ctx, eventType)
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event metadata: %w", err)
+	}
+
+	args := append([]any{eventID, aggregateID, aggregateType, eventType, jsonData, timestamp, metadataJSON}, extraArgs...)
+
+	var version int
+	var position int64
+	if err := q.QueryRowContext(ctx, query, args...).Scan(&version, &position); err != nil {
+		if isVersionConflict(err) {
+			return nil, fmt.Errorf("%w: aggregate %s: %v", ErrConcurrencyConflict, aggregateID, err)
+		}
+		return nil, fmt.Errorf("failed to insert event: %w", err)
+	}
+
+	return &Event{
+		ID:            eventID,
+		AggregateID:   aggregateID,
+		AggregateType: aggregateType,
+		EventType:     eventType,
+		Data:          jsonData,
+		Timestamp:     timestamp,
+		Version:       version,
+		Metadata:      metadata,
+		Position:      position,
+	}, nil
+}
+
+// isVersionConflict reports whether err comes from the UNIQUE (aggregate_id, version)
+// constraint or the check_event_version trigger
+func isVersionConflict(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "23505" || // unique_violation
+		pqErr.Code == "P0001" // raise_exception from check_event_version
+}
+
+// readPageSize is the number of rows fetched per query while streaming events
+const readPageSize = 500
+
+// ReadStream returns the events of an aggregate after fromVersion, fetching
+// them page by page so that long streams are not held in a single result set
+func (es *PostgresEventStore) ReadStream(ctx context.Context, aggregateID string, fromVersion int) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		after := fromVersion
+		for {
+			events, err := es.queryEvents(ctx, `
+				SELECT `+eventColumns+`
+				FROM events WHERE aggregate_id = $1 AND version > $2 ORDER BY version LIMIT $3
+			`, aggregateID, after, readPageSize)
+			if err != nil {
+				yield(Event{}, fmt.Errorf("failed to read events for %s: %w", aggregateID, err))
+				return
+			}
+
+			for _, e := range events {
+				if !yield(e, nil) {
+					return
+				}
+				after = e.Version
+			}
+
+			if len(events) < readPageSize {
+				return
+			}
+		}
+	}
+}
+
+// ReadAll returns all events after fromPosition in position order. Positions
+// are committed in order (see nextPositionQuery), so a reader never sees a
+// position before the ones below it.
+func (es *PostgresEventStore) ReadAll(ctx context.Context, fromPosition int64) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		after := fromPosition
+		for {
+			events, err := es.queryEvents(ctx, `
+				SELECT `+eventColumns+`
+				FROM events WHERE position > $1 ORDER BY position LIMIT $2
+			`, after, readPageSize)
+			if err != nil {
+				yield(Event{}, fmt.Errorf("failed to read events after position %d: %w", after, err))
+				return
+			}
+
+			for _, e := range events {
+				if !yield(e, nil) {
+					return
+				}
+				after = e.Position
+			}
+
+			if len(events) < readPageSize {
+				return
+			}
+		}
+	}
+}
+
+// eventColumns are the columns scanned by queryEvents
+const eventColumns = "id, aggregate_id, aggregate_type, event_type, data, version, created_at, metadata, position"
+
+func (es *PostgresEventStore) queryEvents(ctx context.Context, query string, args ...any) ([]Event, error) {
+	rows, err := es.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		var data, metadata []byte
+		if err := rows.Scan(&e.ID, &e.AggregateID, &e.AggregateType, &e.EventType, &data, &e.Version, &e.Timestamp, &metadata, &e.Position); err != nil {
+			return nil, err
+		}
+		e.Data = json.RawMessage(data)
+		if err := json.Unmarshal(metadata, &e.Metadata); err != nil {
+			return nil, fmt.Errorf("invalid metadata on event %s: %w", e.ID, err)
+		}
+		e, err = DefaultUpcasters.Upcast(e)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// SaveSnapshot stores a snapshot, overwriting any previous snapshot of the aggregate
+func (es *PostgresEventStore) SaveSnapshot(ctx context.Context, snapshot *Snapshot) error {
+	_, err := es.db.ExecContext(ctx, `
+		INSERT INTO snapshots (aggregate_id, aggregate_type, version, state, schema_version, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (aggregate_id) DO UPDATE SET
+			aggregate_type = EXCLUDED.aggregate_type,
+			version = EXCLUDED.version,
+			state = EXCLUDED.state,
+			schema_version = EXCLUDED.schema_version,
+			created_at = EXCLUDED.created_at
+	`, snapshot.AggregateID, snapshot.AggregateType, snapshot.Version, []byte(snapshot.State), snapshot.SchemaVersion, snapshot.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	return nil
+}
+
+// GetSnapshot retrieves the latest snapshot for an aggregate
+func (es *PostgresEventStore) GetSnapshot(ctx context.Context, aggregateID string) (*Snapshot, error) {
+	s, err := scanSnapshot(es.db.QueryRowContext(ctx, `
+		SELECT aggregate_id, aggregate_type, version, state, schema_version, created_at
+		FROM snapshots WHERE aggregate_id = $1
+	`, aggregateID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // No snapshot exists
+		}
+		return nil, fmt.Errorf("failed to get snapshot: %w", err)
+	}
+	return s, nil
+}
+
+// ListSnapshots yields the snapshots of an aggregate type
+func (es *PostgresEventStore) ListSnapshots(ctx context.Context, aggregateType string) iter.Seq2[Snapshot, error] {
+	return func(yield func(Snapshot, error) bool) {
+		rows, err := es.db.QueryContext(ctx, `
+			SELECT aggregate_id, aggregate_type, version, state, schema_version, created_at
+			FROM snapshots WHERE aggregate_type = $1
+			ORDER BY aggregate_id
+		`, aggregateType)
+		if err != nil {
+			yield(Snapshot{}, fmt.Errorf("failed to list snapshots: %w", err))
+			return
+		}
+		defer func() { _ = rows.Close() }()
+
+		for rows.Next() {
+			s, err := scanSnapshot(rows)
+			if err != nil {
+				yield(Snapshot{}, fmt.Errorf("failed to list snapshots: %w", err))
+				return
+			}
+			if !yield(*s, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(Snapshot{}, fmt.Errorf("failed to list snapshots: %w", err))
+		}
+	}
+}
+
+// DeleteSnapshot removes the snapshot of an aggregate
+func (es *PostgresEventStore) DeleteSnapshot(ctx context.Context, aggregateID string) error {
+	if _, err := es.db.ExecContext(ctx, `DELETE FROM snapshots WHERE aggregate_id = $1`, aggregateID); err != nil {
+		return fmt.Errorf("failed to delete snapshot: %w", err)
+	}
+	return nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSnapshot(row rowScanner) (*Snapshot, error) {
+	var s Snapshot
+	var state []byte
+	if err := row.Scan(&s.AggregateID, &s.AggregateType, &s.Version, &state, &s.SchemaVersion, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	s.State = json.RawMessage(state)
+	return &s, nil
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -10,75 +11,89 @@ import (
 	"github.com/example/ec-event-driven/internal/readmodel"
 )
 
+// sqlExecutor is implemented by both *sql.DB and *sql.Tx
+type sqlExecutor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 // PostgresReadStore implements ReadStoreInterface using PostgreSQL
 type PostgresReadStore struct {
-	db *sql.DB
+	db   sqlExecutor
+	conn *sql.DB // Nil when the store is bound to the transaction of a unit of work
 }
 
 // NewPostgresReadStore creates a new PostgreSQL-based read store
 func NewPostgresReadStore(db *sql.DB) *PostgresReadStore {
-	return &PostgresReadStore{db: db}
+	return &PostgresReadStore{db: db, conn: db}
 }
 
 // Set stores a read model
 func (rs *PostgresReadStore) Set(collection, id string, data any) error {
+	ctx := context.Background()
 	switch collection {
 	case "products":
-		return rs.setProduct(id, data.(*readmodel.ProductReadModel))
+		return rs.setProduct(ctx, id, data.(*readmodel.ProductReadModel))
 	case "carts":
-		return rs.setCart(id, data.(*readmodel.CartReadModel))
+		return rs.setCart(ctx, id, data.(*readmodel.CartReadModel))
 	case "orders":
-		return rs.setOrder(id, data.(*readmodel.OrderReadModel))
+		return rs.setOrder(ctx, id, data.(*readmodel.OrderReadModel))
 	case "inventory":
-		return rs.setInventory(id, data.(*readmodel.InventoryReadModel))
+		return rs.setInventory(ctx, id, data.(*readmodel.InventoryReadModel))
 	case "users":
-		return rs.setUser(id, data.(*readmodel.UserReadModel))
+		return rs.setUser(ctx, id, data.(*readmodel.UserReadModel))
 	case "sessions":
-		return rs.setSession(id, data.(*readmodel.SessionReadModel))
+		return rs.setSession(ctx, id, data.(*readmodel.SessionReadModel))
 	case "categories":
-		return rs.setCategory(id, data.(*readmodel.CategoryReadModel))
+		return rs.setCategory(ctx, id, data.(*readmodel.CategoryReadModel))
 	}
 	return fmt.Errorf("unknown collection: %s", collection)
 }
 
 // Get retrieves a read model by id
 func (rs *PostgresReadStore) Get(collection, id string) (any, bool, error) {
+	ctx := context.Background()
 	switch collection {
 	case "products":
-		return rs.getProduct(id)
+		return rs.getProduct(ctx, id)
 	case "carts":
-		return rs.getCart(id)
+		return rs.getCart(ctx, id)
 	case "orders":
-		return rs.getOrder(id)
+		return rs.getOrder(ctx, id)
 	case "inventory":
-		return rs.getInventory(id)
+		return rs.getInventory(ctx, id)
 	case "users":
-		return rs.getUser(id)
+		return rs.getUser(ctx, id)
 	case "sessions":
-		return rs.getSession(id)
+		return rs.getSession(ctx, id)
 	case "categories":
-		return rs.getCategory(id)
+		return rs.getCategory(ctx, id)
 	}
 	return nil, false, fmt.Errorf("unknown collection: %s", collection)
 }
 
 // GetAll retrieves all items in a collection
 func (rs *PostgresReadStore) GetAll(collection string) ([]any, error) {
+	ctx := context.Background()
 	switch collection {
 	case "products":
-		return rs.getAllProducts()
+		return anySlice(rs.getAllProducts(ctx))
 	case "carts":
-		return rs.getAllCarts()
+		return anySlice(rs.getAllCarts(ctx))
 	case "orders":
-		return rs.getAllOrders()
+		return anySlice(rs.getAllOrders(ctx))
 	case "inventory":
-		return rs.getAllInventory()
+		return anySlice(rs.getAllInventory(ctx))
 	case "users":
-		return rs.getAllUsers()
+		return anySlice(rs.getAllUsers(ctx))
 	case "sessions":
-		return rs.getAllSessions()
+		return anySlice(rs.getAllSessions(ctx))
 	case "categories":
-		return rs.getAllCategories()
+		return anySlice(rs.getAllCategories(ctx))
 	}
 	return nil, fmt.Errorf("unknown collection: %s", collection)
 }
@@ -112,71 +127,63 @@ func (rs *PostgresReadStore) Delete(collection, id string) error {
 	return nil
 }
 
-// Update modifies a read model using an update function
-func (rs *PostgresReadStore) Update(collection, id string, updateFn func(current any) any) (bool, error) {
-	// Get current value
-	var current any
-	var found bool
-	var err error
-
+// Clear removes every read model of a collection. Clearing products also
+// clears their category assignments.
+func (rs *PostgresReadStore) Clear(collection string) error {
+	var tables []string
 	switch collection {
 	case "products":
-		current, found, err = rs.getProduct(id)
+		tables = []string{"product_categories", "read_products"}
 	case "carts":
-		current, found, err = rs.getCart(id)
+		tables = []string{"read_carts"}
 	case "orders":
-		current, found, err = rs.getOrder(id)
+		tables = []string{"read_orders"}
 	case "inventory":
-		current, found, err = rs.getInventory(id)
+		tables = []string{"read_inventory"}
 	case "users":
-		current, found, err = rs.getUser(id)
+		tables = []string{"read_users"}
 	case "sessions":
-		current, found, err = rs.getSession(id)
+		tables = []string{"user_sessions"}
 	case "categories":
-		current, found, err = rs.getCategory(id)
+		tables = []string{"read_categories"}
 	default:
-		return false, fmt.Errorf("unknown collection: %s", collection)
-	}
-
-	if err != nil {
-		return false, fmt.Errorf("get %s: %w", collection, err)
+		return fmt.Errorf("unknown collection: %s", collection)
 	}
 
-	if !found {
-		return false, nil
+	for _, table := range tables {
+		if _, err := rs.db.Exec("DELETE FROM " + table); err != nil {
+			return fmt.Errorf("clear %s: %w", collection, err)
+		}
 	}
+	return nil
+}
 
-	// Apply update function
-	updated := updateFn(current)
-
-	// Save updated value
+// Update modifies a read model using an update function
+func (rs *PostgresReadStore) Update(collection, id string, updateFn func(current any) any) (bool, error) {
+	ctx := context.Background()
+	models := rs.ReadModels()
 	switch collection {
 	case "products":
-		err = rs.setProduct(id, updated.(*readmodel.ProductReadModel))
+		return updateAny(ctx, models.Products, id, updateFn)
 	case "carts":
-		err = rs.setCart(id, updated.(*readmodel.CartReadModel))
+		return updateAny(ctx, models.Carts, id, updateFn)
 	case "orders":
-		err = rs.setOrder(id, updated.(*readmodel.OrderReadModel))
+		return updateAny(ctx, models.Orders, id, updateFn)
 	case "inventory":
-		err = rs.setInventory(id, updated.(*readmodel.InventoryReadModel))
+		return updateAny(ctx, models.Inventory, id, updateFn)
 	case "users":
-		err = rs.setUser(id, updated.(*readmodel.UserReadModel))
+		return updateAny(ctx, models.Users, id, updateFn)
 	case "sessions":
-		err = rs.setSession(id, updated.(*readmodel.SessionReadModel))
+		return updateAny(ctx, models.Sessions, id, updateFn)
 	case "categories":
-		err = rs.setCategory(id, updated.(*readmodel.CategoryReadModel))
+		return updateAny(ctx, models.Categories, id, updateFn)
 	}
-
-	if err != nil {
-		return false, fmt.Errorf("set %s: %w", collection, err)
-	}
-
-	return true, nil
+	return false, fmt.Errorf("unknown collection: %s", collection)
 }
 
 // Product operations
-func (rs *PostgresReadStore) setProduct(id string, p *readmodel.ProductReadModel) error {
-	_, err := rs.db.Exec(`
+func (rs *PostgresReadStore) setProduct(ctx context.Context, id string, p *readmodel.ProductReadModel) error {
+	_, err := rs.db.ExecContext(ctx, `
 		INSERT INTO read_products (id, name, description, price, stock, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		ON CONFLICT (id) DO UPDATE SET
@@ -189,9 +196,9 @@ func (rs *PostgresReadStore) setProduct(id string, p *readmodel.ProductReadModel
 	return err
 }
 
-func (rs *PostgresReadStore) getProduct(id string) (*readmodel.ProductReadModel, bool, error) {
+func (rs *PostgresReadStore) getProduct(ctx context.Context, id string) (*readmodel.ProductReadModel, bool, error) {
 	var p readmodel.ProductReadModel
-	err := rs.db.QueryRow(`
+	err := rs.db.QueryRowContext(ctx, `
 		SELECT id, name, description, price, stock, created_at, updated_at
 		FROM read_products WHERE id = $1
 	`, id).Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Stock, &p.CreatedAt, &p.UpdatedAt)
@@ -204,8 +211,8 @@ func (rs *PostgresReadStore) getProduct(id string) (*readmodel.ProductReadModel,
 	return &p, true, nil
 }
 
-func (rs *PostgresReadStore) getAllProducts() ([]any, error) {
-	rows, err := rs.db.Query(`
+func (rs *PostgresReadStore) getAllProducts(ctx context.Context) ([]*readmodel.ProductReadModel, error) {
+	rows, err := rs.db.QueryContext(ctx, `
 		SELECT id, name, description, price, stock, created_at, updated_at
 		FROM read_products ORDER BY created_at DESC
 	`)
@@ -214,7 +221,7 @@ func (rs *PostgresReadStore) getAllProducts() ([]any, error) {
 	}
 	defer func() { _ = rows.Close() }()
 
-	var products []any
+	var products []*readmodel.ProductReadModel
 	for rows.Next() {
 		var p readmodel.ProductReadModel
 		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Stock, &p.CreatedAt, &p.UpdatedAt); err != nil {
@@ -226,12 +233,12 @@ func (rs *PostgresReadStore) getAllProducts() ([]any, error) {
 }
 
 // Cart operations
-func (rs *PostgresReadStore) setCart(id string, c *readmodel.CartReadModel) error {
+func (rs *PostgresReadStore) setCart(ctx context.Context, id string, c *readmodel.CartReadModel) error {
 	itemsJSON, err := json.Marshal(c.Items)
 	if err != nil {
 		return err
 	}
-	_, err = rs.db.Exec(`
+	_, err = rs.db.ExecContext(ctx, `
 		INSERT INTO read_carts (id, user_id, items, total, updated_at)
 		VALUES ($1, $2, $3, $4, $5)
 		ON CONFLICT (id) DO UPDATE SET
@@ -242,10 +249,10 @@ func (rs *PostgresReadStore) setCart(id string, c *readmodel.CartReadModel) erro
 	return err
 }
 
-func (rs *PostgresReadStore) getCart(id string) (*readmodel.CartReadModel, bool, error) {
+func (rs *PostgresReadStore) getCart(ctx context.Context, id string) (*readmodel.CartReadModel, bool, error) {
 	var c readmodel.CartReadModel
 	var itemsJSON []byte
-	err := rs.db.QueryRow(`
+	err := rs.db.QueryRowContext(ctx, `
 		SELECT id, user_id, items, total FROM read_carts WHERE id = $1
 	`, id).Scan(&c.ID, &c.UserID, &itemsJSON, &c.Total)
 	if err != nil {
@@ -260,14 +267,14 @@ func (rs *PostgresReadStore) getCart(id string) (*readmodel.CartReadModel, bool,
 	return &c, true, nil
 }
 
-func (rs *PostgresReadStore) getAllCarts() ([]any, error) {
-	rows, err := rs.db.Query(`SELECT id, user_id, items, total FROM read_carts`)
+func (rs *PostgresReadStore) getAllCarts(ctx context.Context) ([]*readmodel.CartReadModel, error) {
+	rows, err := rs.db.QueryContext(ctx, `SELECT id, user_id, items, total FROM read_carts`)
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = rows.Close() }()
 
-	var carts []any
+	var carts []*readmodel.CartReadModel
 	for rows.Next() {
 		var c readmodel.CartReadModel
 		var itemsJSON []byte
@@ -283,30 +290,33 @@ func (rs *PostgresReadStore) getAllCarts() ([]any, error) {
 }
 
 // Order operations
-func (rs *PostgresReadStore) setOrder(id string, o *readmodel.OrderReadModel) error {
+func (rs *PostgresReadStore) setOrder(ctx context.Context, id string, o *readmodel.OrderReadModel) error {
 	itemsJSON, err := json.Marshal(o.Items)
 	if err != nil {
 		return err
 	}
-	_, err = rs.db.Exec(`
-		INSERT INTO read_orders (id, user_id, items, total, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	_, err = rs.db.ExecContext(ctx, `
+		INSERT INTO read_orders (id, user_id, items, total, status, carrier, tracking_number, shipped_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		ON CONFLICT (id) DO UPDATE SET
 			items = EXCLUDED.items,
 			total = EXCLUDED.total,
 			status = EXCLUDED.status,
+			carrier = EXCLUDED.carrier,
+			tracking_number = EXCLUDED.tracking_number,
+			shipped_at = EXCLUDED.shipped_at,
 			updated_at = EXCLUDED.updated_at
-	`, o.ID, o.UserID, itemsJSON, o.Total, o.Status, o.CreatedAt, o.UpdatedAt)
+	`, o.ID, o.UserID, itemsJSON, o.Total, o.Status, o.Carrier, o.TrackingNumber, o.ShippedAt, o.CreatedAt, o.UpdatedAt)
 	return err
 }
 
-func (rs *PostgresReadStore) getOrder(id string) (*readmodel.OrderReadModel, bool, error) {
+func (rs *PostgresReadStore) getOrder(ctx context.Context, id string) (*readmodel.OrderReadModel, bool, error) {
 	var o readmodel.OrderReadModel
 	var itemsJSON []byte
-	err := rs.db.QueryRow(`
-		SELECT id, user_id, items, total, status, created_at, updated_at
+	err := rs.db.QueryRowContext(ctx, `
+		SELECT id, user_id, items, total, status, carrier, tracking_number, shipped_at, created_at, updated_at
 		FROM read_orders WHERE id = $1
-	`, id).Scan(&o.ID, &o.UserID, &itemsJSON, &o.Total, &o.Status, &o.CreatedAt, &o.UpdatedAt)
+	`, id).Scan(&o.ID, &o.UserID, &itemsJSON, &o.Total, &o.Status, &o.Carrier, &o.TrackingNumber, &o.ShippedAt, &o.CreatedAt, &o.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, false, nil
@@ -319,9 +329,9 @@ func (rs *PostgresReadStore) getOrder(id string) (*readmodel.OrderReadModel, boo
 	return &o, true, nil
 }
 
-func (rs *PostgresReadStore) getAllOrders() ([]any, error) {
-	rows, err := rs.db.Query(`
-		SELECT id, user_id, items, total, status, created_at, updated_at
+func (rs *PostgresReadStore) getAllOrders(ctx context.Context) ([]*readmodel.OrderReadModel, error) {
+	rows, err := rs.db.QueryContext(ctx, `
+		SELECT id, user_id, items, total, status, carrier, tracking_number, shipped_at, created_at, updated_at
 		FROM read_orders ORDER BY created_at DESC
 	`)
 	if err != nil {
@@ -329,11 +339,11 @@ func (rs *PostgresReadStore) getAllOrders() ([]any, error) {
 	}
 	defer func() { _ = rows.Close() }()
 
-	var orders []any
+	var orders []*readmodel.OrderReadModel
 	for rows.Next() {
 		var o readmodel.OrderReadModel
 		var itemsJSON []byte
-		if err := rows.Scan(&o.ID, &o.UserID, &itemsJSON, &o.Total, &o.Status, &o.CreatedAt, &o.UpdatedAt); err != nil {
+		if err := rows.Scan(&o.ID, &o.UserID, &itemsJSON, &o.Total, &o.Status, &o.Carrier, &o.TrackingNumber, &o.ShippedAt, &o.CreatedAt, &o.UpdatedAt); err != nil {
 			return nil, err
 		}
 		if err := json.Unmarshal(itemsJSON, &o.Items); err != nil {
@@ -345,8 +355,8 @@ func (rs *PostgresReadStore) getAllOrders() ([]any, error) {
 }
 
 // Inventory operations
-func (rs *PostgresReadStore) setInventory(id string, inv *readmodel.InventoryReadModel) error {
-	_, err := rs.db.Exec(`
+func (rs *PostgresReadStore) setInventory(ctx context.Context, id string, inv *readmodel.InventoryReadModel) error {
+	_, err := rs.db.ExecContext(ctx, `
 		INSERT INTO read_inventory (product_id, total_stock, reserved_stock, available_stock, updated_at)
 		VALUES ($1, $2, $3, $4, $5)
 		ON CONFLICT (product_id) DO UPDATE SET
@@ -358,9 +368,9 @@ func (rs *PostgresReadStore) setInventory(id string, inv *readmodel.InventoryRea
 	return err
 }
 
-func (rs *PostgresReadStore) getInventory(id string) (*readmodel.InventoryReadModel, bool, error) {
+func (rs *PostgresReadStore) getInventory(ctx context.Context, id string) (*readmodel.InventoryReadModel, bool, error) {
 	var inv readmodel.InventoryReadModel
-	err := rs.db.QueryRow(`
+	err := rs.db.QueryRowContext(ctx, `
 		SELECT product_id, total_stock, reserved_stock, available_stock
 		FROM read_inventory WHERE product_id = $1
 	`, id).Scan(&inv.ProductID, &inv.TotalStock, &inv.ReservedStock, &inv.AvailableStock)
@@ -373,8 +383,8 @@ func (rs *PostgresReadStore) getInventory(id string) (*readmodel.InventoryReadMo
 	return &inv, true, nil
 }
 
-func (rs *PostgresReadStore) getAllInventory() ([]any, error) {
-	rows, err := rs.db.Query(`
+func (rs *PostgresReadStore) getAllInventory(ctx context.Context) ([]*readmodel.InventoryReadModel, error) {
+	rows, err := rs.db.QueryContext(ctx, `
 		SELECT product_id, total_stock, reserved_stock, available_stock FROM read_inventory
 	`)
 	if err != nil {
@@ -382,7 +392,7 @@ func (rs *PostgresReadStore) getAllInventory() ([]any, error) {
 	}
 	defer func() { _ = rows.Close() }()
 
-	var inventory []any
+	var inventory []*readmodel.InventoryReadModel
 	for rows.Next() {
 		var inv readmodel.InventoryReadModel
 		if err := rows.Scan(&inv.ProductID, &inv.TotalStock, &inv.ReservedStock, &inv.AvailableStock); err != nil {
@@ -394,8 +404,8 @@ func (rs *PostgresReadStore) getAllInventory() ([]any, error) {
 }
 
 // User operations
-func (rs *PostgresReadStore) setUser(id string, u *readmodel.UserReadModel) error {
-	_, err := rs.db.Exec(`
+func (rs *PostgresReadStore) setUser(ctx context.Context, id string, u *readmodel.UserReadModel) error {
+	_, err := rs.db.ExecContext(ctx, `
 		INSERT INTO read_users (id, email, password_hash, name, role, is_active, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		ON CONFLICT (id) DO UPDATE SET
@@ -409,9 +419,9 @@ func (rs *PostgresReadStore) setUser(id string, u *readmodel.UserReadModel) erro
 	return err
 }
 
-func (rs *PostgresReadStore) getUser(id string) (*readmodel.UserReadModel, bool, error) {
+func (rs *PostgresReadStore) getUser(ctx context.Context, id string) (*readmodel.UserReadModel, bool, error) {
 	var u readmodel.UserReadModel
-	err := rs.db.QueryRow(`
+	err := rs.db.QueryRowContext(ctx, `
 		SELECT id, email, password_hash, name, role, is_active, created_at, updated_at
 		FROM read_users WHERE id = $1
 	`, id).Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Name, &u.Role, &u.IsActive, &u.CreatedAt, &u.UpdatedAt)
@@ -437,8 +447,8 @@ func (rs *PostgresReadStore) GetUserByEmail(email string) (*readmodel.UserReadMo
 	return &u, true
 }
 
-func (rs *PostgresReadStore) getAllUsers() ([]any, error) {
-	rows, err := rs.db.Query(`
+func (rs *PostgresReadStore) getAllUsers(ctx context.Context) ([]*readmodel.UserReadModel, error) {
+	rows, err := rs.db.QueryContext(ctx, `
 		SELECT id, email, password_hash, name, role, is_active, created_at, updated_at
 		FROM read_users ORDER BY created_at DESC
 	`)
@@ -447,7 +457,7 @@ func (rs *PostgresReadStore) getAllUsers() ([]any, error) {
 	}
 	defer func() { _ = rows.Close() }()
 
-	var users []any
+	var users []*readmodel.UserReadModel
 	for rows.Next() {
 		var u readmodel.UserReadModel
 		if err := rows.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Name, &u.Role, &u.IsActive, &u.CreatedAt, &u.UpdatedAt); err != nil {
@@ -459,8 +469,8 @@ func (rs *PostgresReadStore) getAllUsers() ([]any, error) {
 }
 
 // Session operations
-func (rs *PostgresReadStore) setSession(id string, s *readmodel.SessionReadModel) error {
-	_, err := rs.db.Exec(`
+func (rs *PostgresReadStore) setSession(ctx context.Context, id string, s *readmodel.SessionReadModel) error {
+	_, err := rs.db.ExecContext(ctx, `
 		INSERT INTO user_sessions (id, user_id, refresh_token_hash, expires_at, created_at, ip_address, user_agent)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		ON CONFLICT (id) DO UPDATE SET
@@ -470,9 +480,9 @@ func (rs *PostgresReadStore) setSession(id string, s *readmodel.SessionReadModel
 	return err
 }
 
-func (rs *PostgresReadStore) getSession(id string) (*readmodel.SessionReadModel, bool, error) {
+func (rs *PostgresReadStore) getSession(ctx context.Context, id string) (*readmodel.SessionReadModel, bool, error) {
 	var s readmodel.SessionReadModel
-	err := rs.db.QueryRow(`
+	err := rs.db.QueryRowContext(ctx, `
 		SELECT id, user_id, refresh_token_hash, expires_at, created_at, ip_address, user_agent
 		FROM user_sessions WHERE id = $1
 	`, id).Scan(&s.ID, &s.UserID, &s.RefreshTokenHash, &s.ExpiresAt, &s.CreatedAt, &s.IPAddress, &s.UserAgent)
@@ -511,8 +521,8 @@ func (rs *PostgresReadStore) DeleteExpiredSessions() error {
 	return err
 }
 
-func (rs *PostgresReadStore) getAllSessions() ([]any, error) {
-	rows, err := rs.db.Query(`
+func (rs *PostgresReadStore) getAllSessions(ctx context.Context) ([]*readmodel.SessionReadModel, error) {
+	rows, err := rs.db.QueryContext(ctx, `
 		SELECT id, user_id, refresh_token_hash, expires_at, created_at, ip_address, user_agent
 		FROM user_sessions ORDER BY created_at DESC
 	`)
@@ -521,7 +531,7 @@ func (rs *PostgresReadStore) getAllSessions() ([]any, error) {
 	}
 	defer func() { _ = rows.Close() }()
 
-	var sessions []any
+	var sessions []*readmodel.SessionReadModel
 	for rows.Next() {
 		var s readmodel.SessionReadModel
 		if err := rows.Scan(&s.ID, &s.UserID, &s.RefreshTokenHash, &s.ExpiresAt, &s.CreatedAt, &s.IPAddress, &s.UserAgent); err != nil {
@@ -533,8 +543,8 @@ func (rs *PostgresReadStore) getAllSessions() ([]any, error) {
 }
 
 // Category operations
-func (rs *PostgresReadStore) setCategory(id string, c *readmodel.CategoryReadModel) error {
-	_, err := rs.db.Exec(`
+func (rs *PostgresReadStore) setCategory(ctx context.Context, id string, c *readmodel.CategoryReadModel) error {
+	_, err := rs.db.ExecContext(ctx, `
 		INSERT INTO read_categories (id, name, slug, description, parent_id, sort_order, is_active, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		ON CONFLICT (id) DO UPDATE SET
@@ -549,10 +559,10 @@ func (rs *PostgresReadStore) setCategory(id string, c *readmodel.CategoryReadMod
 	return err
 }
 
-func (rs *PostgresReadStore) getCategory(id string) (*readmodel.CategoryReadModel, bool, error) {
+func (rs *PostgresReadStore) getCategory(ctx context.Context, id string) (*readmodel.CategoryReadModel, bool, error) {
 	var c readmodel.CategoryReadModel
 	var parentID sql.NullString
-	err := rs.db.QueryRow(`
+	err := rs.db.QueryRowContext(ctx, `
 		SELECT id, name, slug, description, parent_id, sort_order, is_active, created_at, updated_at
 		FROM read_categories WHERE id = $1
 	`, id).Scan(&c.ID, &c.Name, &c.Slug, &c.Description, &parentID, &c.SortOrder, &c.IsActive, &c.CreatedAt, &c.UpdatedAt)
@@ -581,8 +591,8 @@ func (rs *PostgresReadStore) GetCategoryBySlug(slug string) (*readmodel.Category
 	return &c, true
 }
 
-func (rs *PostgresReadStore) getAllCategories() ([]any, error) {
-	rows, err := rs.db.Query(`
+func (rs *PostgresReadStore) getAllCategories(ctx context.Context) ([]*readmodel.CategoryReadModel, error) {
+	rows, err := rs.db.QueryContext(ctx, `
 		SELECT id, name, slug, description, parent_id, sort_order, is_active, created_at, updated_at
 		FROM read_categories WHERE is_active = true ORDER BY sort_order, name
 	`)
@@ -591,7 +601,7 @@ func (rs *PostgresReadStore) getAllCategories() ([]any, error) {
 	}
 	defer func() { _ = rows.Close() }()
 
-	var categories []any
+	var categories []*readmodel.CategoryReadModel
 	for rows.Next() {
 		var c readmodel.CategoryReadModel
 		var parentID sql.NullString
@@ -607,23 +617,19 @@ func (rs *PostgresReadStore) getAllCategories() ([]any, error) {
 // Product-Category relationship operations
 
 // AddProductCategory adds a category to a product
-func (rs *PostgresReadStore) AddProductCategory(productID, categoryID string) {
+func (rs *PostgresReadStore) AddProductCategory(productID, categoryID string) error {
 	_, err := rs.db.Exec(`
 		INSERT INTO product_categories (product_id, category_id)
 		VALUES ($1, $2)
 		ON CONFLICT DO NOTHING
 	`, productID, categoryID)
-	if err != nil {
-		log.Printf("[PostgresReadStore] Error adding product category: %v", err)
-	}
+	return err
 }
 
 // RemoveProductCategory removes a category from a product
-func (rs *PostgresReadStore) RemoveProductCategory(productID, categoryID string) {
+func (rs *PostgresReadStore) RemoveProductCategory(productID, categoryID string) error {
 	_, err := rs.db.Exec(`DELETE FROM product_categories WHERE product_id = $1 AND category_id = $2`, productID, categoryID)
-	if err != nil {
-		log.Printf("[PostgresReadStore] Error removing product category: %v", err)
-	}
+	return err
 }
 
 // GetProductCategories returns all category IDs for a product
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/example/ec-event-driven/internal/readmodel"
+)
+
+// ReadModels returns repositories over the read model tables. When the store
+// is bound to the transaction of a unit of work, so are the repositories.
+func (rs *PostgresReadStore) ReadModels() *ReadModels {
+	return &ReadModels{
+		Products: &postgresRepository[readmodel.ProductReadModel]{
+			rs: rs, table: "read_products", key: "id",
+			get: (*PostgresReadStore).getProduct, list: (*PostgresReadStore).getAllProducts, upsert: (*PostgresReadStore).setProduct,
+		},
+		Carts: &postgresRepository[readmodel.CartReadModel]{
+			rs: rs, table: "read_carts", key: "id",
+			get: (*PostgresReadStore).getCart, list: (*PostgresReadStore).getAllCarts, upsert: (*PostgresReadStore).setCart,
+		},
+		Orders: &postgresRepository[readmodel.OrderReadModel]{
+			rs: rs, table: "read_orders", key: "id",
+			get: (*PostgresReadStore).getOrder, list: (*PostgresReadStore).getAllOrders, upsert: (*PostgresReadStore).setOrder,
+		},
+		Inventory: &postgresRepository[readmodel.InventoryReadModel]{
+			rs: rs, table: "read_inventory", key: "product_id",
+			get: (*PostgresReadStore).getInventory, list: (*PostgresReadStore).getAllInventory, upsert: (*PostgresReadStore).setInventory,
+		},
+		Users: &postgresRepository[readmodel.UserReadModel]{
+			rs: rs, table: "read_users", key: "id",
+			get: (*PostgresReadStore).getUser, list: (*PostgresReadStore).getAllUsers, upsert: (*PostgresReadStore).setUser,
+		},
+		Sessions: &postgresRepository[readmodel.SessionReadModel]{
+			rs: rs, table: "user_sessions", key: "id",
+			get: (*PostgresReadStore).getSession, list: (*PostgresReadStore).getAllSessions, upsert: (*PostgresReadStore).setSession,
+		},
+		Categories: &postgresRepository[readmodel.CategoryReadModel]{
+			rs: rs, table: "read_categories", key: "id",
+			get: (*PostgresReadStore).getCategory, list: (*PostgresReadStore).getAllCategories, upsert: (*PostgresReadStore).setCategory,
+		},
+	}
+}
+
+// postgresRepository is a Repository over one read model table, built from
+// the table's row mapping functions
+type postgresRepository[T any] struct {
+	rs     *PostgresReadStore
+	table  string
+	key    string // Primary key column
+	get    func(rs *PostgresReadStore, ctx context.Context, id string) (*T, bool, error)
+	list   func(rs *PostgresReadStore, ctx context.Context) ([]*T, error)
+	upsert func(rs *PostgresReadStore, ctx context.Context, id string, model *T) error
+}
+
+// Get retrieves a read model by id
+func (r *postgresRepository[T]) Get(ctx context.Context, id string) (*T, bool, error) {
+	model, ok, err := r.get(r.rs, ctx, id)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get %s %s: %w", r.table, id, err)
+	}
+	return model, ok, nil
+}
+
+// List retrieves all read models
+func (r *postgresRepository[T]) List(ctx context.Context) ([]*T, error) {
+	models, err := r.list(r.rs, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", r.table, err)
+	}
+	return models, nil
+}
+
+// Upsert stores a read model
+func (r *postgresRepository[T]) Upsert(ctx context.Context, id string, model *T) error {
+	if err := r.upsert(r.rs, ctx, id, model); err != nil {
+		return fmt.Errorf("failed to upsert %s %s: %w", r.table, id, err)
+	}
+	return nil
+}
+
+// Delete removes a read model
+func (r *postgresRepository[T]) Delete(ctx context.Context, id string) error {
+	if _, err := r.rs.db.ExecContext(ctx, "DELETE FROM "+r.table+" WHERE "+r.key+" = $1", id); err != nil {
+		return fmt.Errorf("failed to delete %s %s: %w", r.table, id, err)
+	}
+	return nil
+}
+
+// Update modifies a read model, locking its row so that concurrent updates
+// cannot overwrite each other. Outside a unit of work it starts its own.
+func (r *postgresRepository[T]) Update(ctx context.Context, id string, fn func(model *T)) (bool, error) {
+	if r.rs.conn == nil {
+		return r.update(ctx, r.rs, id, fn)
+	}
+	var updated bool
+	err := r.rs.unitOfWork(ctx, func(tx *PostgresReadStore) error {
+		var err error
+		updated, err = r.update(ctx, tx, id, fn)
+		return err
+	})
+	return updated, err
+}
+
+// update reads, modifies and writes a read model in the transaction tx is bound to
+func (r *postgresRepository[T]) update(ctx context.Context, tx *PostgresReadStore, id string, fn func(model *T)) (bool, error) {
+	rows, err := tx.db.QueryContext(ctx, "SELECT 1 FROM "+r.table+" WHERE "+r.key+" = $1 FOR UPDATE", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to lock %s %s: %w", r.table, id, err)
+	}
+	_ = rows.Close()
+
+	model, ok, err := r.get(tx, ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to get %s %s: %w", r.table, id, err)
+	}
+	if !ok {
+		return false, nil
+	}
+	fn(model)
+	if err := r.upsert(tx, ctx, id, model); err != nil {
+		return false, fmt.Errorf("failed to upsert %s %s: %w", r.table, id, err)
+	}
+	return true, nil
+}
+
+// updateAny applies a collection-keyed update function through repo
+func updateAny[T any](ctx context.Context, repo Repository[T], id string, updateFn func(current any) any) (bool, error) {
+	var typeErr error
+	updated, err := repo.Update(ctx, id, func(model *T) {
+		result := updateFn(model)
+		next, ok := result.(*T)
+		if !ok {
+			typeErr = fmt.Errorf("%w: update of %s returned %T, expected %T", ErrUnexpectedType, id, result, next)
+			return
+		}
+		*model = *next
+	})
+	if typeErr != nil {
+		return false, typeErr
+	}
+	return updated, err
+}
+
+// anySlice converts typed read models for the collection-keyed methods
+func anySlice[T any](models []*T, err error) ([]any, error) {
+	if err != nil {
+		return nil, err
+	}
+	items := make([]any, len(models))
+	for i, model := range models {
+		items[i] = model
+	}
+	return items, nil
+}
@@ -1,7 +1,12 @@
 package store
 
-// ReadStoreInterface defines the interface for read model storage
+// ReadStoreInterface defines the interface for read model storage. The
+// collection-keyed methods hold read models as any; ReadModels gives typed
+// access to the same read models and is what application code should use.
 type ReadStoreInterface interface {
+	// ReadModels returns the typed repositories of the read models
+	ReadModels() *ReadModels
+
 	// Set stores a read model
 	Set(collection, id string, data any) error
 
@@ -17,3 +22,8 @@ type ReadStoreInterface interface {
 	// Update modifies a read model using an update function
 	Update(collection, id string, updateFn func(current any) any) (bool, error)
 }
+
+// CollectionClearer is implemented by read stores that can delete every read model of a collection
+type CollectionClearer interface {
+	Clear(collection string) error
+}
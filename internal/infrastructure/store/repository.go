@@ -0,0 +1,219 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/example/ec-event-driven/internal/readmodel"
+)
+
+// ErrUnexpectedType is returned when a read model is not of the type its repository holds
+var ErrUnexpectedType = errors.New("read model of unexpected type")
+
+// Repository stores read models of type T by id
+type Repository[T any] interface {
+	// Get retrieves a read model by id
+	Get(ctx context.Context, id string) (*T, bool, error)
+	// List retrieves all read models
+	List(ctx context.Context) ([]*T, error)
+	// Upsert stores a read model, replacing any stored under the same id
+	Upsert(ctx context.Context, id string, model *T) error
+	// Delete removes a read model
+	Delete(ctx context.Context, id string) error
+	// Update applies fn to the read model stored under id and stores the
+	// result. It reports false, without calling fn, if there is none.
+	Update(ctx context.Context, id string, fn func(model *T)) (bool, error)
+}
+
+// ReadModels holds a repository for each kind of read model
+type ReadModels struct {
+	Products   Repository[readmodel.ProductReadModel]
+	Carts      Repository[readmodel.CartReadModel]
+	Orders     Repository[readmodel.OrderReadModel]
+	Inventory  Repository[readmodel.InventoryReadModel]
+	Users      Repository[readmodel.UserReadModel]
+	Sessions   Repository[readmodel.SessionReadModel]
+	Categories Repository[readmodel.CategoryReadModel]
+}
+
+// NewMemoryReadModels creates read models held in memory
+func NewMemoryReadModels() *ReadModels {
+	return &ReadModels{
+		Products:   NewMemoryRepository[readmodel.ProductReadModel](),
+		Carts:      NewMemoryRepository[readmodel.CartReadModel](),
+		Orders:     NewMemoryRepository[readmodel.OrderReadModel](),
+		Inventory:  NewMemoryRepository[readmodel.InventoryReadModel](),
+		Users:      NewMemoryRepository[readmodel.UserReadModel](),
+		Sessions:   NewMemoryRepository[readmodel.SessionReadModel](),
+		Categories: NewMemoryRepository[readmodel.CategoryReadModel](),
+	}
+}
+
+// CollectionReadModels returns repositories over the collections of a
+// collection-keyed read store, e.g. one held in memory for tests
+func CollectionReadModels(rs ReadStoreInterface) *ReadModels {
+	return &ReadModels{
+		Products:   NewCollectionRepository[readmodel.ProductReadModel](rs, "products"),
+		Carts:      NewCollectionRepository[readmodel.CartReadModel](rs, "carts"),
+		Orders:     NewCollectionRepository[readmodel.OrderReadModel](rs, "orders"),
+		Inventory:  NewCollectionRepository[readmodel.InventoryReadModel](rs, "inventory"),
+		Users:      NewCollectionRepository[readmodel.UserReadModel](rs, "users"),
+		Sessions:   NewCollectionRepository[readmodel.SessionReadModel](rs, "sessions"),
+		Categories: NewCollectionRepository[readmodel.CategoryReadModel](rs, "categories"),
+	}
+}
+
+// MemoryRepository is an in-memory Repository. It stores copies of the read
+// models, so changing a model after Upsert or Get does not change the stored one.
+type MemoryRepository[T any] struct {
+	mu     sync.RWMutex
+	models map[string]T
+}
+
+// NewMemoryRepository creates an empty in-memory repository
+func NewMemoryRepository[T any]() *MemoryRepository[T] {
+	return &MemoryRepository[T]{models: make(map[string]T)}
+}
+
+// Get retrieves a read model by id
+func (r *MemoryRepository[T]) Get(_ context.Context, id string) (*T, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	model, ok := r.models[id]
+	if !ok {
+		return nil, false, nil
+	}
+	return &model, true, nil
+}
+
+// List retrieves all read models in no particular order
+func (r *MemoryRepository[T]) List(_ context.Context) ([]*T, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	models := make([]*T, 0, len(r.models))
+	for _, model := range r.models {
+		models = append(models, &model)
+	}
+	return models, nil
+}
+
+// Upsert stores a read model
+func (r *MemoryRepository[T]) Upsert(_ context.Context, id string, model *T) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.models[id] = *model
+	return nil
+}
+
+// Delete removes a read model
+func (r *MemoryRepository[T]) Delete(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.models, id)
+	return nil
+}
+
+// Update modifies a read model using an update function
+func (r *MemoryRepository[T]) Update(_ context.Context, id string, fn func(model *T)) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	model, ok := r.models[id]
+	if !ok {
+		return false, nil
+	}
+	fn(&model)
+	r.models[id] = model
+	return true, nil
+}
+
+// CollectionRepository is a Repository over one collection of a
+// collection-keyed read store holding *T values
+type CollectionRepository[T any] struct {
+	rs         ReadStoreInterface
+	collection string
+}
+
+// NewCollectionRepository creates a repository over a collection of rs
+func NewCollectionRepository[T any](rs ReadStoreInterface, collection string) *CollectionRepository[T] {
+	return &CollectionRepository[T]{rs: rs, collection: collection}
+}
+
+// Get retrieves a read model by id
+func (r *CollectionRepository[T]) Get(_ context.Context, id string) (*T, bool, error) {
+	data, ok, err := r.rs.Get(r.collection, id)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get %s %s: %w", r.collection, id, err)
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	model, err := r.typed(id, data)
+	if err != nil {
+		return nil, false, err
+	}
+	return model, true, nil
+}
+
+// List retrieves all read models
+func (r *CollectionRepository[T]) List(_ context.Context) ([]*T, error) {
+	items, err := r.rs.GetAll(r.collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", r.collection, err)
+	}
+	models := make([]*T, 0, len(items))
+	for _, item := range items {
+		model, ok := item.(*T)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s holds %T, expected %T", ErrUnexpectedType, r.collection, item, model)
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// Upsert stores a read model
+func (r *CollectionRepository[T]) Upsert(_ context.Context, id string, model *T) error {
+	if err := r.rs.Set(r.collection, id, model); err != nil {
+		return fmt.Errorf("failed to set %s %s: %w", r.collection, id, err)
+	}
+	return nil
+}
+
+// Delete removes a read model
+func (r *CollectionRepository[T]) Delete(_ context.Context, id string) error {
+	if err := r.rs.Delete(r.collection, id); err != nil {
+		return fmt.Errorf("failed to delete %s %s: %w", r.collection, id, err)
+	}
+	return nil
+}
+
+// Update modifies a read model using an update function
+func (r *CollectionRepository[T]) Update(_ context.Context, id string, fn func(model *T)) (bool, error) {
+	var typeErr error
+	updated, err := r.rs.Update(r.collection, id, func(current any) any {
+		model, err := r.typed(id, current)
+		if err != nil {
+			typeErr = err
+			return current
+		}
+		fn(model)
+		return model
+	})
+	if typeErr != nil {
+		return false, typeErr
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update %s %s: %w", r.collection, id, err)
+	}
+	return updated, nil
+}
+
+func (r *CollectionRepository[T]) typed(id string, data any) (*T, error) {
+	model, ok := data.(*T)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s %s is %T, expected %T", ErrUnexpectedType, r.collection, id, data, model)
+	}
+	return model, nil
+}
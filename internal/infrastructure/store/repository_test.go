@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/example/ec-event-driven/internal/readmodel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+	products := NewMemoryReadModels().Products
+
+	require.NoError(t, products.Upsert(ctx, "prod-1", &readmodel.ProductReadModel{ID: "prod-1", Name: "Widget", Stock: 5}))
+	require.NoError(t, products.Upsert(ctx, "prod-2", &readmodel.ProductReadModel{ID: "prod-2", Name: "Gadget"}))
+
+	p, ok, err := products.Get(ctx, "prod-1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "Widget", p.Name)
+
+	updated, err := products.Update(ctx, "prod-1", func(p *readmodel.ProductReadModel) { p.Stock += 3 })
+	require.NoError(t, err)
+	assert.True(t, updated)
+	p, _, _ = products.Get(ctx, "prod-1")
+	assert.Equal(t, 8, p.Stock)
+
+	updated, err = products.Update(ctx, "missing", func(p *readmodel.ProductReadModel) { t.Fatal("called for a missing read model") })
+	require.NoError(t, err)
+	assert.False(t, updated)
+
+	require.NoError(t, products.Delete(ctx, "prod-2"))
+	all, err := products.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, "prod-1", all[0].ID)
+}
+
+func TestMemoryRepository_StoresCopies(t *testing.T) {
+	ctx := context.Background()
+	orders := NewMemoryRepository[readmodel.OrderReadModel]()
+
+	o := &readmodel.OrderReadModel{ID: "order-1", Status: "pending"}
+	require.NoError(t, orders.Upsert(ctx, o.ID, o))
+	o.Status = "paid"
+
+	got, _, _ := orders.Get(ctx, "order-1")
+	assert.Equal(t, "pending", got.Status)
+	got.Status = "cancelled"
+
+	got, _, _ = orders.Get(ctx, "order-1")
+	assert.Equal(t, "pending", got.Status, "changes are stored by Upsert or Update only")
+}
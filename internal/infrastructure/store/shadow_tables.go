@@ -0,0 +1,341 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// readModelTables lists the tables backing each projection whose read models
+// can be rebuilt from events. read_users is left out because it also holds
+// users without events (the seeded admin), and sessions are not projected.
+var readModelTables = map[string][]string{
+	"products":   {"read_products", "product_categories"},
+	"carts":      {"read_carts"},
+	"orders":     {"read_orders"},
+	"inventory":  {"read_inventory"},
+	"categories": {"read_categories"},
+}
+
+var schemaNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// ShadowTables are empty copies of live read model tables kept in a separate
+// schema. A rebuild fills them and Swap replaces the live tables with them.
+type ShadowTables struct {
+	db     *sql.DB
+	schema string
+	tables []string
+}
+
+// NewShadowTables prepares shadow copies of the tables of the given projections in schema
+func NewShadowTables(db *sql.DB, schema string, projections []string) (*ShadowTables, error) {
+	if !schemaNamePattern.MatchString(schema) || schema == "public" {
+		return nil, fmt.Errorf("invalid shadow schema %q", schema)
+	}
+	var tables []string
+	for _, projection := range projections {
+		t, ok := readModelTables[projection]
+		if !ok {
+			return nil, fmt.Errorf("projection %s has no rebuildable tables", projection)
+		}
+		tables = append(tables, t...)
+	}
+	return &ShadowTables{db: db, schema: schema, tables: tables}, nil
+}
+
+// Tables returns the live tables that Swap replaces
+func (s *ShadowTables) Tables() []string {
+	return s.tables
+}
+
+// Create (re)creates the shadow schema with an empty copy of every table.
+// CREATE TABLE ... LIKE copies columns, defaults, indexes and checks, so the
+// foreign keys and triggers of the live tables are copied from the catalog;
+// foreign keys between swapped tables point at the shadow copies.
+func (s *ShadowTables) Create(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Catalog definitions name the live tables without their schema
+	if _, err := tx.ExecContext(ctx, "SET LOCAL search_path = public"); err != nil {
+		return fmt.Errorf("failed to create shadow tables: %w", err)
+	}
+	schema := pq.QuoteIdentifier(s.schema)
+	statements := []string{
+		"DROP SCHEMA IF EXISTS " + schema + " CASCADE",
+		"CREATE SCHEMA " + schema,
+	}
+	for _, table := range s.tables {
+		statements = append(statements,
+			fmt.Sprintf("CREATE TABLE %s (LIKE public.%s INCLUDING ALL)", s.shadowName(table), pq.QuoteIdentifier(table)))
+	}
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to create shadow tables: %w", err)
+		}
+	}
+
+	// Foreign keys are added once every shadow table they may reference exists
+	for _, table := range s.tables {
+		copies, err := s.copyConstraintsAndTriggers(ctx, tx, table)
+		if err != nil {
+			return err
+		}
+		for _, stmt := range copies {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("failed to copy constraints of %s: %w", table, err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// shadowName returns the schema-qualified name of the shadow copy of table
+func (s *ShadowTables) shadowName(table string) string {
+	return pq.QuoteIdentifier(s.schema) + "." + pq.QuoteIdentifier(table)
+}
+
+// copyConstraintsAndTriggers returns the statements that add the foreign keys
+// and triggers of the live table to its shadow copy
+func (s *ShadowTables) copyConstraintsAndTriggers(ctx context.Context, tx *sql.Tx, table string) ([]string, error) {
+	live := "public." + pq.QuoteIdentifier(table)
+	var statements []string
+
+	foreignKeys, err := tx.QueryContext(ctx, `
+		SELECT c.conname, ref.relname, pg_get_constraintdef(c.oid)
+		FROM pg_constraint c
+		JOIN pg_class ref ON ref.oid = c.confrelid
+		WHERE c.contype = 'f' AND c.conrelid = $1::regclass
+		ORDER BY c.conname
+	`, live)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read foreign keys of %s: %w", table, err)
+	}
+	for foreignKeys.Next() {
+		var name, referenced, def string
+		if err := foreignKeys.Scan(&name, &referenced, &def); err != nil {
+			_ = foreignKeys.Close()
+			return nil, fmt.Errorf("failed to read foreign keys of %s: %w", table, err)
+		}
+		if slices.Contains(s.tables, referenced) {
+			if def, err = retarget(def, "REFERENCES ", referenced, "(", s.shadowName(referenced)); err != nil {
+				_ = foreignKeys.Close()
+				return nil, err
+			}
+		}
+		statements = append(statements,
+			fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s", s.shadowName(table), pq.QuoteIdentifier(name), def))
+	}
+	if err := foreignKeys.Close(); err != nil {
+		return nil, fmt.Errorf("failed to read foreign keys of %s: %w", table, err)
+	}
+
+	triggers, err := tx.QueryContext(ctx, `
+		SELECT pg_get_triggerdef(t.oid, true)
+		FROM pg_trigger t
+		WHERE t.tgrelid = $1::regclass AND NOT t.tgisinternal
+		ORDER BY t.tgname
+	`, live)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read triggers of %s: %w", table, err)
+	}
+	defer func() { _ = triggers.Close() }()
+	for triggers.Next() {
+		var def string
+		if err := triggers.Scan(&def); err != nil {
+			return nil, fmt.Errorf("failed to read triggers of %s: %w", table, err)
+		}
+		if def, err = retarget(def, " ON ", table, " ", s.shadowName(table)); err != nil {
+			return nil, err
+		}
+		statements = append(statements, def)
+	}
+	return statements, triggers.Err()
+}
+
+// retarget replaces the table named between prefix and suffix in a catalog
+// definition with replacement
+func retarget(def, prefix, table, suffix, replacement string) (string, error) {
+	pattern := regexp.MustCompile(regexp.QuoteMeta(prefix) + `(?:public\.)?(?:` +
+		regexp.QuoteMeta(table) + `|` + regexp.QuoteMeta(pq.QuoteIdentifier(table)) + `)` + regexp.QuoteMeta(suffix))
+	loc := pattern.FindStringIndex(def)
+	if loc == nil {
+		return "", fmt.Errorf("cannot find table %s in definition %q", table, def)
+	}
+	return def[:loc[0]] + prefix + replacement + suffix + def[loc[1]:], nil
+}
+
+// Drop removes the shadow schema and everything in it
+func (s *ShadowTables) Drop(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, "DROP SCHEMA IF EXISTS "+pq.QuoteIdentifier(s.schema)+" CASCADE"); err != nil {
+		return fmt.Errorf("failed to drop shadow tables: %w", err)
+	}
+	return nil
+}
+
+// ProjectionCheckpoints maps a projection name to the last version of each
+// aggregate it has applied
+type ProjectionCheckpoints map[string]map[string]int
+
+// Checkpoints returns the checkpoints of the given live projections
+func (s *ShadowTables) Checkpoints(ctx context.Context, projections []string) (ProjectionCheckpoints, error) {
+	return queryCheckpoints(ctx, s.db, projections)
+}
+
+// Swap replaces the live tables with the shadow tables in one transaction.
+// It first locks projection_checkpoints, which waits for in-flight projections
+// and holds off new ones, and calls catchUp with the checkpoints at that
+// moment so that the shadow tables can be brought level with them.
+func (s *ShadowTables) Swap(ctx context.Context, projections []string, catchUp func(checkpoints ProjectionCheckpoints) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE projection_checkpoints IN EXCLUSIVE MODE"); err != nil {
+		return fmt.Errorf("failed to lock projection checkpoints: %w", err)
+	}
+	checkpoints, err := queryCheckpoints(ctx, tx, projections)
+	if err != nil {
+		return err
+	}
+	if err := catchUp(checkpoints); err != nil {
+		return err
+	}
+
+	// Foreign keys of other tables would follow the live tables into the
+	// retired schema and be dropped with it, so they are moved over too
+	if _, err := tx.ExecContext(ctx, "SET LOCAL search_path = public"); err != nil {
+		return fmt.Errorf("failed to swap shadow tables: %w", err)
+	}
+	inbound, err := s.inboundForeignKeys(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	schema := pq.QuoteIdentifier(s.schema)
+	retired := pq.QuoteIdentifier(s.schema + "_retired")
+	statements := []string{
+		"DROP SCHEMA IF EXISTS " + retired + " CASCADE",
+		"CREATE SCHEMA " + retired,
+	}
+	for _, fk := range inbound {
+		statements = append(statements, "ALTER TABLE "+fk.table+" DROP CONSTRAINT "+pq.QuoteIdentifier(fk.name))
+	}
+	for _, table := range s.tables {
+		t := pq.QuoteIdentifier(table)
+		statements = append(statements,
+			"ALTER TABLE public."+t+" SET SCHEMA "+retired,
+			"ALTER TABLE "+schema+"."+t+" SET SCHEMA public")
+	}
+	for _, fk := range inbound {
+		statements = append(statements, "ALTER TABLE "+fk.table+" ADD CONSTRAINT "+pq.QuoteIdentifier(fk.name)+" "+fk.def)
+	}
+	statements = append(statements,
+		"DROP SCHEMA "+retired+" CASCADE",
+		"DROP SCHEMA "+schema+" CASCADE")
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to swap shadow tables: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// foreignKey is a foreign key constraint as defined in the catalog
+type foreignKey struct {
+	table string
+	name  string
+	def   string
+}
+
+// inboundForeignKeys returns the foreign keys of tables that are not swapped
+// which reference a swapped table
+func (s *ShadowTables) inboundForeignKeys(ctx context.Context, tx *sql.Tx) ([]foreignKey, error) {
+	live := make([]string, 0, len(s.tables))
+	for _, table := range s.tables {
+		live = append(live, "public."+pq.QuoteIdentifier(table))
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT c.conrelid::regclass::text, c.conname, pg_get_constraintdef(c.oid)
+		FROM pg_constraint c
+		WHERE c.contype = 'f'
+			AND c.confrelid = ANY($1::regclass[])
+			AND NOT c.conrelid = ANY($1::regclass[])
+		ORDER BY 1, 2
+	`, pq.Array(live))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read foreign keys referencing %v: %w", s.tables, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var foreignKeys []foreignKey
+	for rows.Next() {
+		var fk foreignKey
+		if err := rows.Scan(&fk.table, &fk.name, &fk.def); err != nil {
+			return nil, fmt.Errorf("failed to read foreign keys referencing %v: %w", s.tables, err)
+		}
+		foreignKeys = append(foreignKeys, fk)
+	}
+	return foreignKeys, rows.Err()
+}
+
+// queryContexter is implemented by both *sql.DB and *sql.Tx
+type queryContexter interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func queryCheckpoints(ctx context.Context, q queryContexter, projections []string) (ProjectionCheckpoints, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT projection, aggregate_id, last_version FROM projection_checkpoints WHERE projection = ANY($1)
+	`, pq.Array(projections))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read projection checkpoints: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	checkpoints := make(ProjectionCheckpoints, len(projections))
+	for _, projection := range projections {
+		checkpoints[projection] = make(map[string]int)
+	}
+	for rows.Next() {
+		var projection, aggregateID string
+		var version int
+		if err := rows.Scan(&projection, &aggregateID, &version); err != nil {
+			return nil, fmt.Errorf("failed to read projection checkpoints: %w", err)
+		}
+		checkpoints[projection][aggregateID] = version
+	}
+	return checkpoints, rows.Err()
+}
+
+// WithSearchPath returns connStr with the search_path set to schema, then
+// public, so that unqualified table names resolve to schema's tables first
+func WithSearchPath(connStr, schema string) (string, error) {
+	if !schemaNamePattern.MatchString(schema) {
+		return "", fmt.Errorf("invalid schema %q", schema)
+	}
+	searchPath := schema + ",public"
+
+	if strings.HasPrefix(connStr, "postgres://") || strings.HasPrefix(connStr, "postgresql://") {
+		u, err := url.Parse(connStr)
+		if err != nil {
+			return "", fmt.Errorf("invalid connection URL: %w", err)
+		}
+		q := u.Query()
+		q.Set("search_path", searchPath)
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+	return strings.TrimSpace(connStr) + " search_path=" + searchPath, nil
+}
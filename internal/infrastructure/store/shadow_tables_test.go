@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetarget(t *testing.T) {
+	def, err := retarget("FOREIGN KEY (product_id) REFERENCES read_products(id) ON DELETE CASCADE",
+		"REFERENCES ", "read_products", "(", `"shadow"."read_products"`)
+	require.NoError(t, err)
+	assert.Equal(t, `FOREIGN KEY (product_id) REFERENCES "shadow"."read_products"(id) ON DELETE CASCADE`, def)
+
+	def, err = retarget("CREATE TRIGGER product_search_update BEFORE INSERT OR UPDATE ON public.read_products FOR EACH ROW EXECUTE FUNCTION update_product_search_vector()",
+		" ON ", "read_products", " ", `"shadow"."read_products"`)
+	require.NoError(t, err)
+	assert.Equal(t, `CREATE TRIGGER product_search_update BEFORE INSERT OR UPDATE ON "shadow"."read_products" FOR EACH ROW EXECUTE FUNCTION update_product_search_vector()`, def)
+
+	_, err = retarget("FOREIGN KEY (category_id) REFERENCES read_categories(id)", "REFERENCES ", "read_products", "(", "x")
+	assert.Error(t, err)
+}
+
+func TestShadowTables_MatchLiveCatalog(t *testing.T) {
+	db := testPostgres(t)
+	ctx := context.Background()
+
+	// init.sql has no foreign keys between read model tables, so add one
+	// between swapped tables and one from a table that is not swapped
+	mustExec(t, db, `ALTER TABLE product_categories ADD CONSTRAINT shadow_test_product_fk
+		FOREIGN KEY (product_id) REFERENCES read_products(id) ON DELETE CASCADE NOT VALID`)
+	mustExec(t, db, `CREATE TABLE shadow_test_refs (product_id VARCHAR(255) REFERENCES read_products(id))`)
+	t.Cleanup(func() {
+		_, _ = db.Exec("DROP TABLE IF EXISTS shadow_test_refs")
+		_, _ = db.Exec("ALTER TABLE product_categories DROP CONSTRAINT IF EXISTS shadow_test_product_fk")
+	})
+
+	projections := []string{"products", "categories"}
+	shadow, err := NewShadowTables(db, "shadow_test", projections)
+	require.NoError(t, err)
+	live := tableCatalog(t, db, "public", shadow.Tables())
+
+	require.NoError(t, shadow.Create(ctx))
+	t.Cleanup(func() { _ = shadow.Drop(ctx) })
+	assert.Equal(t, live, tableCatalog(t, db, "shadow_test", shadow.Tables()))
+
+	require.NoError(t, shadow.Swap(ctx, projections, func(ProjectionCheckpoints) error { return nil }))
+	assert.Equal(t, live, tableCatalog(t, db, "public", shadow.Tables()))
+
+	var referenced []string
+	rows, err := db.Query(`SELECT confrelid::regclass::text FROM pg_constraint
+		WHERE conrelid = 'shadow_test_refs'::regclass AND contype = 'f'`)
+	require.NoError(t, err)
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var table string
+		require.NoError(t, rows.Scan(&table))
+		referenced = append(referenced, table)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"read_products"}, referenced, "the foreign key follows the swapped-in table")
+}
+
+// testPostgres connects to TEST_DATABASE_URL, which must be a disposable
+// database initialized with init.sql
+func testPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+	connStr := os.Getenv("TEST_DATABASE_URL")
+	if connStr == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := ConnectPostgres(connStr)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func mustExec(t *testing.T, db *sql.DB, query string) {
+	t.Helper()
+	_, err := db.Exec(query)
+	require.NoError(t, err)
+}
+
+// tableCatalog lists the constraints, triggers and indexes of each table in
+// schema, with the schema and index names left out so that copies compare equal
+func tableCatalog(t *testing.T, db *sql.DB, schema string, tables []string) map[string][]string {
+	t.Helper()
+	catalog := make(map[string][]string, len(tables))
+	for _, table := range tables {
+		rows, err := db.Query(`
+			SELECT 'constraint ' || contype || ' ' || pg_get_constraintdef(oid)
+			FROM pg_constraint WHERE conrelid = $1::regclass
+			UNION ALL
+			SELECT 'trigger ' || pg_get_triggerdef(oid, true)
+			FROM pg_trigger WHERE tgrelid = $1::regclass AND NOT tgisinternal
+			UNION ALL
+			SELECT 'index ' || regexp_replace(pg_get_indexdef(indexrelid), 'INDEX \S+ ON', 'INDEX ON')
+			FROM pg_index WHERE indrelid = $1::regclass
+		`, pq.QuoteIdentifier(schema)+"."+pq.QuoteIdentifier(table))
+		require.NoError(t, err)
+		for rows.Next() {
+			var def string
+			require.NoError(t, rows.Scan(&def))
+			def = strings.ReplaceAll(def, schema+".", "")
+			catalog[table] = append(catalog[table], strings.ReplaceAll(def, "public.", ""))
+		}
+		require.NoError(t, rows.Err())
+		_ = rows.Close()
+		slices.Sort(catalog[table])
+	}
+	return catalog
+}
@@ -1,11 +1,13 @@
 package store
 
 import (
+	"context"
 	"encoding/json"
+	"iter"
 	"time"
 )
 
-// SnapshotThreshold defines the number of events after which a snapshot is created
+// SnapshotThreshold defines the default number of events after which a snapshot is created
 const SnapshotThreshold = 10
 
 // Snapshot represents a point-in-time state of an aggregate
@@ -14,5 +16,14 @@ type Snapshot struct {
 	AggregateType string          `json:"aggregate_type"`
 	Version       int             `json:"version"`    // Event version at snapshot time
 	State         json.RawMessage `json:"state"`      // Serialized aggregate state
+	SchemaVersion int             `json:"schema_version"` // Version of the state's layout; 0 for legacy snapshots
 	CreatedAt     time.Time       `json:"created_at"`
 }
+
+// SnapshotAdmin is implemented by event stores that support snapshot maintenance
+type SnapshotAdmin interface {
+	// ListSnapshots yields the snapshots of an aggregate type
+	ListSnapshots(ctx context.Context, aggregateType string) iter.Seq2[Snapshot, error]
+	// DeleteSnapshot removes the snapshot of an aggregate, if any
+	DeleteSnapshot(ctx context.Context, aggregateID string) error
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Upcaster transforms the data of an event from one schema version to the next
+type Upcaster func(data json.RawMessage) (json.RawMessage, error)
+
+// UpcasterRegistry holds the upcasters of each event type, keyed by the schema
+// version they upgrade from. Events are upcast through the chain until they
+// reach the latest version of their type.
+type UpcasterRegistry struct {
+	mu        sync.RWMutex
+	upcasters map[string]map[int]Upcaster // event type -> from version -> upcaster
+}
+
+// NewUpcasterRegistry creates an empty registry
+func NewUpcasterRegistry() *UpcasterRegistry {
+	return &UpcasterRegistry{upcasters: make(map[string]map[int]Upcaster)}
+}
+
+// DefaultUpcasters is the registry used by the event stores, the projector and
+// the notifier. Domain packages register their upcasters in init.
+var DefaultUpcasters = NewUpcasterRegistry()
+
+// RegisterUpcaster registers fn on DefaultUpcasters
+func RegisterUpcaster(eventType string, fromVersion int, fn Upcaster) {
+	DefaultUpcasters.Register(eventType, fromVersion, fn)
+}
+
+// Register adds the upcaster from fromVersion to fromVersion+1 of eventType.
+// It panics on invalid or duplicate registrations, which are programming errors.
+func (r *UpcasterRegistry) Register(eventType string, fromVersion int, fn Upcaster) {
+	if fromVersion < 1 {
+		panic(fmt.Sprintf("store: invalid upcaster version %d for %s", fromVersion, eventType))
+	}
+	if fn == nil {
+		panic("store: nil upcaster for " + eventType)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byVersion := r.upcasters[eventType]
+	if byVersion == nil {
+		byVersion = make(map[int]Upcaster)
+		r.upcasters[eventType] = byVersion
+	}
+	if _, exists := byVersion[fromVersion]; exists {
+		panic(fmt.Sprintf("store: duplicate upcaster for %s v%d", eventType, fromVersion))
+	}
+	byVersion[fromVersion] = fn
+}
+
+// LatestVersion returns the schema version newly appended events of eventType carry
+func (r *UpcasterRegistry) LatestVersion(eventType string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.latestVersionLocked(eventType)
+}
+
+// latestVersionLocked is LatestVersion for callers holding r.mu
+func (r *UpcasterRegistry) latestVersionLocked(eventType string) int {
+	latest := InitialSchemaVersion
+	for from := range r.upcasters[eventType] {
+		if from+1 > latest {
+			latest = from + 1
+		}
+	}
+	return latest
+}
+
+// Upcast upgrades the data of event to the latest schema version of its type.
+// Events stored before schema versions were recorded are treated as version 1.
+func (r *UpcasterRegistry) Upcast(event Event) (Event, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	version := event.Metadata.SchemaVersion
+	if version == 0 {
+		version = InitialSchemaVersion
+	}
+
+	byVersion := r.upcasters[event.EventType]
+	latest := r.latestVersionLocked(event.EventType)
+
+	data := event.Data
+	for version < latest {
+		upcast, ok := byVersion[version]
+		if !ok {
+			return event, fmt.Errorf("no upcaster for %s v%d", event.EventType, version)
+		}
+		upcasted, err := upcast(data)
+		if err != nil {
+			return event, fmt.Errorf("upcast %s v%d (event %s): %w", event.EventType, version, event.ID, err)
+		}
+		data = upcasted
+		version++
+	}
+
+	event.Data = data
+	event.Metadata.SchemaVersion = version
+	return event, nil
+}
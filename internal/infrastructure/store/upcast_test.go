@@ -0,0 +1,124 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// renameField returns an upcaster that moves the value of from to to
+func renameField(from, to string) Upcaster {
+	return func(data json.RawMessage) (json.RawMessage, error) {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		fields[to] = fields[from]
+		delete(fields, from)
+		return json.Marshal(fields)
+	}
+}
+
+func newTestRegistry() *UpcasterRegistry {
+	registry := NewUpcasterRegistry()
+	registry.Register("OrderPlaced", 1, renameField("amount", "subtotal"))
+	registry.Register("OrderPlaced", 2, renameField("subtotal", "total"))
+	return registry
+}
+
+func TestUpcasterRegistry_LatestVersion(t *testing.T) {
+	registry := newTestRegistry()
+
+	assert.Equal(t, 3, registry.LatestVersion("OrderPlaced"))
+	assert.Equal(t, InitialSchemaVersion, registry.LatestVersion("OrderPaid"))
+}
+
+func TestUpcasterRegistry_Upcast_AppliesChain(t *testing.T) {
+	registry := newTestRegistry()
+	event := Event{
+		ID:        "event-1",
+		EventType: "OrderPlaced",
+		Data:      json.RawMessage(`{"order_id":"order-1","amount":4000}`),
+		Metadata:  EventMetadata{SchemaVersion: 1},
+	}
+
+	upcasted, err := registry.Upcast(event)
+
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"order_id":"order-1","total":4000}`, string(upcasted.Data))
+	assert.Equal(t, 3, upcasted.Metadata.SchemaVersion)
+}
+
+func TestUpcasterRegistry_Upcast_LegacyEventsAreVersionOne(t *testing.T) {
+	registry := newTestRegistry()
+	event := Event{
+		EventType: "OrderPlaced",
+		Data:      json.RawMessage(`{"amount":4000}`),
+	}
+
+	upcasted, err := registry.Upcast(event)
+
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"total":4000}`, string(upcasted.Data))
+	assert.Equal(t, 3, upcasted.Metadata.SchemaVersion)
+}
+
+func TestUpcasterRegistry_Upcast_StartsFromStoredVersion(t *testing.T) {
+	registry := newTestRegistry()
+	event := Event{
+		EventType: "OrderPlaced",
+		Data:      json.RawMessage(`{"subtotal":4000}`),
+		Metadata:  EventMetadata{SchemaVersion: 2},
+	}
+
+	upcasted, err := registry.Upcast(event)
+
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"total":4000}`, string(upcasted.Data))
+}
+
+func TestUpcasterRegistry_Upcast_LatestVersionUnchanged(t *testing.T) {
+	registry := newTestRegistry()
+	event := Event{
+		EventType: "OrderPlaced",
+		Data:      json.RawMessage(`{"total":4000}`),
+		Metadata:  EventMetadata{SchemaVersion: 3},
+	}
+
+	upcasted, err := registry.Upcast(event)
+
+	require.NoError(t, err)
+	assert.Equal(t, event, upcasted)
+}
+
+func TestUpcasterRegistry_Upcast_MissingStep(t *testing.T) {
+	registry := NewUpcasterRegistry()
+	registry.Register("OrderPlaced", 2, renameField("subtotal", "total"))
+
+	_, err := registry.Upcast(Event{EventType: "OrderPlaced", Data: json.RawMessage(`{}`)})
+
+	assert.ErrorContains(t, err, "no upcaster for OrderPlaced v1")
+}
+
+func TestUpcasterRegistry_Upcast_UpcasterError(t *testing.T) {
+	upcastErr := errors.New("bad payload")
+	registry := NewUpcasterRegistry()
+	registry.Register("OrderPlaced", 1, func(json.RawMessage) (json.RawMessage, error) {
+		return nil, upcastErr
+	})
+
+	_, err := registry.Upcast(Event{ID: "event-1", EventType: "OrderPlaced"})
+
+	assert.ErrorIs(t, err, upcastErr)
+}
+
+func TestUpcasterRegistry_Register_Duplicate(t *testing.T) {
+	registry := newTestRegistry()
+
+	assert.Panics(t, func() {
+		registry.Register("OrderPlaced", 1, renameField("amount", "total"))
+	})
+}
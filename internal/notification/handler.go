@@ -5,24 +5,32 @@ import (
 	"encoding/json"
 	"log"
 
+	_ "github.com/example/ec-event-driven/internal/domain/all" // Every event on the stream must decode
 	"github.com/example/ec-event-driven/internal/domain/order"
 	"github.com/example/ec-event-driven/internal/email"
+	"github.com/example/ec-event-driven/internal/eventcodec"
 	"github.com/example/ec-event-driven/internal/infrastructure/store"
-	"github.com/example/ec-event-driven/internal/readmodel"
 )
 
 // Handler processes events for sending notifications
 type Handler struct {
 	emailService *email.Service
-	readStore    store.ReadStoreInterface
+	readModels   *store.ReadModels
+	upcasters    *store.UpcasterRegistry
+	dispatcher   *eventcodec.Dispatcher
 }
 
 // NewHandler creates a new notification handler
-func NewHandler(emailSvc *email.Service, readStore store.ReadStoreInterface) *Handler {
-	return &Handler{
+func NewHandler(emailSvc *email.Service, readModels *store.ReadModels) *Handler {
+	h := &Handler{
 		emailService: emailSvc,
-		readStore:    readStore,
+		readModels:   readModels,
+		upcasters:    store.DefaultUpcasters,
+		dispatcher:   eventcodec.NewDispatcher(eventcodec.Default),
 	}
+	// Only OrderPlaced events send notifications
+	eventcodec.On(h.dispatcher, h.handleOrderPlaced)
+	return h
 }
 
 // HandleEvent processes an event from Kafka
@@ -33,25 +41,24 @@ func (h *Handler) HandleEvent(ctx context.Context, key, value []byte) error {
 		return err
 	}
 
-	// Only process OrderPlaced events
-	if event.EventType == order.EventOrderPlaced {
-		return h.handleOrderPlaced(event)
+	event, err := h.upcasters.Upcast(event)
+	if err != nil {
+		log.Printf("[Notifier] Failed to upcast event: %v", err)
+		return err
 	}
 
-	return nil
-}
-
-func (h *Handler) handleOrderPlaced(event store.Event) error {
-	var e order.OrderPlaced
-	if err := json.Unmarshal(event.Data, &e); err != nil {
-		log.Printf("[Notifier] Failed to unmarshal OrderPlaced event: %v", err)
+	if err := h.dispatcher.Dispatch(ctx, event); err != nil {
+		log.Printf("[Notifier] Failed to handle event %s: %v", event.ID, err)
 		return err
 	}
+	return nil
+}
 
+func (h *Handler) handleOrderPlaced(ctx context.Context, _ store.Event, e order.OrderPlaced) error {
 	log.Printf("[Notifier] Processing OrderPlaced event for order %s, user %s", e.OrderID, e.UserID)
 
 	// Get user information from read store
-	userData, exists, err := h.readStore.Get("users", e.UserID)
+	user, exists, err := h.readModels.Users.Get(ctx, e.UserID)
 	if err != nil {
 		log.Printf("[Notifier] Error getting user %s: %v", e.UserID, err)
 		return nil
@@ -61,21 +68,13 @@ func (h *Handler) handleOrderPlaced(event store.Event) error {
 		return nil
 	}
 
-	user, ok := userData.(*readmodel.UserReadModel)
-	if !ok {
-		log.Printf("[Notifier] Invalid user data type for user: %s", e.UserID)
-		return nil
-	}
-
 	// Convert order items to email items
 	emailItems := make([]email.OrderItem, len(e.Items))
 	for i, item := range e.Items {
 		// Try to get product name from read store
 		productName := item.ProductID
-		if productData, exists, _ := h.readStore.Get("products", item.ProductID); exists {
-			if product, ok := productData.(*readmodel.ProductReadModel); ok {
-				productName = product.Name
-			}
+		if product, exists, _ := h.readModels.Products.Get(ctx, item.ProductID); exists {
+			productName = product.Name
 		}
 
 		emailItems[i] = email.OrderItem{
@@ -0,0 +1,149 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// FakeProvider is the name of the fake gateway
+const FakeProvider = "fake"
+
+// Payment methods that make the fake gateway fail, to try out failed payments.
+// Any other method succeeds.
+const (
+	FakeMethodDeclined      = "fake_declined"       // The authorization is declined
+	FakeMethodCaptureFailed = "fake_capture_failed" // The authorization succeeds, the capture is declined
+)
+
+// FakeGateway is a deterministic in-memory gateway for local use and tests.
+// The outcome of a payment only depends on its method and amounts, and IDs
+// are derived from the idempotency key.
+type FakeGateway struct {
+	mu             sync.Mutex
+	authorizations map[string]*fakeAuthorization
+	captures       map[string]*fakeCapture
+}
+
+type fakeAuthorization struct {
+	Authorization
+	key     string
+	method  string
+	capture *Capture
+	voided  bool
+}
+
+type fakeCapture struct {
+	Capture
+	key      string
+	refunded int
+	refunds  int
+}
+
+// NewFakeGateway creates a FakeGateway
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{
+		authorizations: make(map[string]*fakeAuthorization),
+		captures:       make(map[string]*fakeCapture),
+	}
+}
+
+func (g *FakeGateway) Name() string { return FakeProvider }
+
+func (g *FakeGateway) Authorize(_ context.Context, req AuthorizeRequest) (*Authorization, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidAmount, req.Amount)
+	}
+	if req.Method == FakeMethodDeclined {
+		return nil, fmt.Errorf("%w: card declined by the fake provider", ErrDeclined)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	id := "fake_auth_" + req.IdempotencyKey
+	if auth, ok := g.authorizations[id]; ok {
+		auth := auth.Authorization
+		return &auth, nil
+	}
+	auth := &fakeAuthorization{
+		Authorization: Authorization{ID: id, Amount: req.Amount},
+		key:           req.IdempotencyKey,
+		method:        req.Method,
+	}
+	g.authorizations[id] = auth
+	result := auth.Authorization
+	return &result, nil
+}
+
+func (g *FakeGateway) Capture(_ context.Context, authorizationID string, amount int) (*Capture, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	auth, ok := g.authorizations[authorizationID]
+	if !ok {
+		return nil, fmt.Errorf("%w: authorization %s", ErrNotFound, authorizationID)
+	}
+	if auth.capture != nil {
+		capture := *auth.capture
+		return &capture, nil
+	}
+	if auth.voided {
+		return nil, fmt.Errorf("%w: authorization %s", ErrVoided, authorizationID)
+	}
+	if amount <= 0 || amount > auth.Amount {
+		return nil, fmt.Errorf("%w: %d (authorized %d)", ErrInvalidAmount, amount, auth.Amount)
+	}
+	if auth.method == FakeMethodCaptureFailed {
+		return nil, fmt.Errorf("%w: capture declined by the fake provider", ErrDeclined)
+	}
+
+	capture := &fakeCapture{
+		Capture: Capture{ID: "fake_cap_" + auth.key, AuthorizationID: authorizationID, Amount: amount},
+		key:     auth.key,
+	}
+	auth.capture = &capture.Capture
+	g.captures[capture.ID] = capture
+	result := capture.Capture
+	return &result, nil
+}
+
+func (g *FakeGateway) Void(_ context.Context, authorizationID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	auth, ok := g.authorizations[authorizationID]
+	if !ok {
+		return fmt.Errorf("%w: authorization %s", ErrNotFound, authorizationID)
+	}
+	if auth.capture != nil {
+		return fmt.Errorf("%w: authorization %s", ErrCaptured, authorizationID)
+	}
+	auth.voided = true
+	return nil
+}
+
+// Voided reports whether an authorization was voided
+func (g *FakeGateway) Voided(authorizationID string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	auth, ok := g.authorizations[authorizationID]
+	return ok && auth.voided
+}
+
+func (g *FakeGateway) Refund(_ context.Context, captureID string, amount int) (*Refund, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	capture, ok := g.captures[captureID]
+	if !ok {
+		return nil, fmt.Errorf("%w: capture %s", ErrNotFound, captureID)
+	}
+	if amount <= 0 || capture.refunded+amount > capture.Amount {
+		return nil, fmt.Errorf("%w: %d (captured %d, refunded %d)", ErrInvalidAmount, amount, capture.Amount, capture.refunded)
+	}
+
+	capture.refunded += amount
+	capture.refunds++
+	return &Refund{
+		ID:        fmt.Sprintf("fake_ref_%s_%d", capture.key, capture.refunds),
+		CaptureID: captureID,
+		Amount:    amount,
+	}, nil
+}
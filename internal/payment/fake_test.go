@@ -0,0 +1,89 @@
+package payment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeGateway_AuthorizeCaptureRefund(t *testing.T) {
+	g := NewFakeGateway()
+	ctx := context.Background()
+
+	auth, err := g.Authorize(ctx, AuthorizeRequest{OrderID: "order-1", Amount: 3000, Method: "card", IdempotencyKey: "attempt-1"})
+	require.NoError(t, err)
+	assert.Equal(t, &Authorization{ID: "fake_auth_attempt-1", Amount: 3000}, auth)
+
+	capture, err := g.Capture(ctx, auth.ID, 3000)
+	require.NoError(t, err)
+	assert.Equal(t, &Capture{ID: "fake_cap_attempt-1", AuthorizationID: auth.ID, Amount: 3000}, capture)
+
+	refund, err := g.Refund(ctx, capture.ID, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, &Refund{ID: "fake_ref_attempt-1_1", CaptureID: capture.ID, Amount: 1000}, refund)
+
+	_, err = g.Refund(ctx, capture.ID, 2001)
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+}
+
+func TestFakeGateway_IsIdempotent(t *testing.T) {
+	g := NewFakeGateway()
+	ctx := context.Background()
+	req := AuthorizeRequest{OrderID: "order-1", Amount: 3000, IdempotencyKey: "attempt-1"}
+
+	first, err := g.Authorize(ctx, req)
+	require.NoError(t, err)
+	second, err := g.Authorize(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	capture, err := g.Capture(ctx, first.ID, 3000)
+	require.NoError(t, err)
+	again, err := g.Capture(ctx, first.ID, 3000)
+	require.NoError(t, err)
+	assert.Equal(t, capture, again)
+}
+
+func TestFakeGateway_Failures(t *testing.T) {
+	g := NewFakeGateway()
+	ctx := context.Background()
+
+	_, err := g.Authorize(ctx, AuthorizeRequest{Amount: 3000, Method: FakeMethodDeclined, IdempotencyKey: "a"})
+	assert.ErrorIs(t, err, ErrDeclined)
+
+	_, err = g.Authorize(ctx, AuthorizeRequest{Amount: 0, IdempotencyKey: "b"})
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+
+	auth, err := g.Authorize(ctx, AuthorizeRequest{Amount: 3000, Method: FakeMethodCaptureFailed, IdempotencyKey: "c"})
+	require.NoError(t, err)
+	_, err = g.Capture(ctx, auth.ID, 3000)
+	assert.ErrorIs(t, err, ErrDeclined)
+
+	_, err = g.Capture(ctx, "fake_auth_unknown", 3000)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = g.Refund(ctx, "fake_cap_unknown", 3000)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFakeGateway_Void(t *testing.T) {
+	g := NewFakeGateway()
+	ctx := context.Background()
+
+	auth, err := g.Authorize(ctx, AuthorizeRequest{Amount: 3000, IdempotencyKey: "a"})
+	require.NoError(t, err)
+	require.NoError(t, g.Void(ctx, auth.ID))
+	require.NoError(t, g.Void(ctx, auth.ID), "voiding twice is a no-op")
+	assert.True(t, g.Voided(auth.ID))
+	_, err = g.Capture(ctx, auth.ID, 3000)
+	assert.ErrorIs(t, err, ErrVoided)
+
+	captured, err := g.Authorize(ctx, AuthorizeRequest{Amount: 3000, IdempotencyKey: "b"})
+	require.NoError(t, err)
+	_, err = g.Capture(ctx, captured.ID, 3000)
+	require.NoError(t, err)
+	assert.ErrorIs(t, g.Void(ctx, captured.ID), ErrCaptured)
+
+	assert.ErrorIs(t, g.Void(ctx, "fake_auth_unknown"), ErrNotFound)
+}
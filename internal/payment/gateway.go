@@ -0,0 +1,73 @@
+// Package payment charges orders through a payment provider
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrDeclined is returned when the provider declines a payment
+	ErrDeclined = errors.New("payment declined")
+	// ErrInvalidAmount is returned for amounts the provider cannot charge or refund
+	ErrInvalidAmount = errors.New("invalid payment amount")
+	// ErrNotFound is returned for unknown authorizations and captures
+	ErrNotFound = errors.New("payment not found")
+	// ErrVoided is returned when capturing an authorization that was voided
+	ErrVoided = errors.New("payment authorization voided")
+	// ErrCaptured is returned when voiding an authorization that was captured
+	ErrCaptured = errors.New("payment already captured")
+)
+
+// AuthorizeRequest is a request to authorize a payment
+type AuthorizeRequest struct {
+	OrderID string
+	Amount  int    // In the smallest currency unit (yen)
+	Method  string // Payment method token from the client
+	// IdempotencyKey identifies the payment attempt, so that retrying a
+	// request with the same key never charges twice
+	IdempotencyKey string
+}
+
+// Authorization is an authorized payment that has not been captured yet
+type Authorization struct {
+	ID     string
+	Amount int
+}
+
+// Capture is a captured (charged) payment
+type Capture struct {
+	ID              string
+	AuthorizationID string
+	Amount          int
+}
+
+// Refund is a refunded part of a captured payment
+type Refund struct {
+	ID        string
+	CaptureID string
+	Amount    int
+}
+
+// Gateway charges payments through a payment provider
+type Gateway interface {
+	// Name identifies the provider in the recorded payment events
+	Name() string
+	Authorize(ctx context.Context, req AuthorizeRequest) (*Authorization, error)
+	Capture(ctx context.Context, authorizationID string, amount int) (*Capture, error)
+	// Void releases an authorization that will not be captured, so that the
+	// customer's funds are not held until it expires. Voiding twice is a no-op.
+	Void(ctx context.Context, authorizationID string) error
+	Refund(ctx context.Context, captureID string, amount int) (*Refund, error)
+}
+
+// NewGateway returns the gateway of a provider
+func NewGateway(provider string) (Gateway, error) {
+	switch provider {
+	case FakeProvider:
+		return NewFakeGateway(), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q (expected %q)", provider, FakeProvider)
+	}
+}
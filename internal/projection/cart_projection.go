@@ -0,0 +1,120 @@
+package projection
+
+import (
+	"context"
+
+	"github.com/example/ec-event-driven/internal/domain/cart"
+	"github.com/example/ec-event-driven/internal/eventcodec"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/example/ec-event-driven/internal/readmodel"
+)
+
+func init() {
+	Register(cartProjection{})
+}
+
+// cartProjection builds the carts read models. Item names are taken from the
+// products read models.
+type cartProjection struct {
+	models *store.ReadModels
+}
+
+func (cartProjection) Name() string { return "carts" }
+
+func (p cartProjection) EventTypes() []string { return p.dispatcher().EventTypes() }
+
+func (cartProjection) Handle(ctx context.Context, rs store.ReadStoreInterface, event store.Event) error {
+	return cartProjection{models: rs.ReadModels()}.dispatcher().Dispatch(ctx, event)
+}
+
+func (cartProjection) Reset(_ context.Context, rs store.ReadStoreInterface) error {
+	return clearCollections(rs, "carts")
+}
+
+func (p cartProjection) dispatcher() *eventcodec.Dispatcher {
+	d := eventcodec.NewDispatcher(eventcodec.Default)
+	eventcodec.On(d, p.onItemAddedToCart)
+	eventcodec.On(d, p.onItemRemovedFromCart)
+	eventcodec.On(d, p.onCartCleared)
+	return d
+}
+
+func (p cartProjection) onItemAddedToCart(ctx context.Context, _ store.Event, e cart.ItemAddedToCart) error {
+	// Get product name
+	productName := ""
+	prod, ok, err := p.models.Products.Get(ctx, e.ProductID)
+	if err != nil {
+		return err
+	}
+	if ok {
+		productName = prod.Name
+	}
+
+	_, ok, err = p.models.Carts.Get(ctx, e.CartID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// Create new cart
+		return p.models.Carts.Upsert(ctx, e.CartID, &readmodel.CartReadModel{
+			ID:     e.CartID,
+			UserID: e.UserID,
+			Items: []readmodel.CartItemReadModel{
+				{ProductID: e.ProductID, Name: productName, Quantity: e.Quantity, Price: e.Price},
+			},
+			Total: e.Price * e.Quantity,
+		})
+	}
+
+	// Update existing cart
+	return update(ctx, p.models.Carts, e.CartID, func(c *readmodel.CartReadModel) {
+		// Check if item already exists
+		found := false
+		for i, item := range c.Items {
+			if item.ProductID == e.ProductID {
+				c.Items[i].Quantity += e.Quantity
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.Items = append(c.Items, readmodel.CartItemReadModel{
+				ProductID: e.ProductID,
+				Name:      productName,
+				Quantity:  e.Quantity,
+				Price:     e.Price,
+			})
+		}
+		c.Total = calculateCartTotal(c.Items)
+	})
+}
+
+func (p cartProjection) onItemRemovedFromCart(ctx context.Context, _ store.Event, e cart.ItemRemovedFromCart) error {
+	return update(ctx, p.models.Carts, e.CartID, func(c *readmodel.CartReadModel) {
+		newItems := make([]readmodel.CartItemReadModel, 0)
+		for _, item := range c.Items {
+			if item.ProductID != e.ProductID {
+				newItems = append(newItems, item)
+			}
+		}
+		c.Items = newItems
+		c.Total = calculateCartTotal(c.Items)
+	})
+}
+
+func (p cartProjection) onCartCleared(ctx context.Context, _ store.Event, e cart.CartCleared) error {
+	return p.models.Carts.Upsert(ctx, e.CartID, &readmodel.CartReadModel{
+		ID:     e.CartID,
+		UserID: e.UserID,
+		Items:  []readmodel.CartItemReadModel{},
+		Total:  0,
+	})
+}
+
+func calculateCartTotal(items []readmodel.CartItemReadModel) int {
+	total := 0
+	for _, item := range items {
+		total += item.Price * item.Quantity
+	}
+	return total
+}
@@ -0,0 +1,72 @@
+package projection
+
+import (
+	"context"
+
+	"github.com/example/ec-event-driven/internal/domain/category"
+	"github.com/example/ec-event-driven/internal/eventcodec"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/example/ec-event-driven/internal/readmodel"
+)
+
+func init() {
+	Register(categoryProjection{})
+}
+
+// categoryProjection builds the categories read models
+type categoryProjection struct {
+	models *store.ReadModels
+}
+
+func (categoryProjection) Name() string { return "categories" }
+
+func (p categoryProjection) EventTypes() []string { return p.dispatcher().EventTypes() }
+
+func (categoryProjection) Handle(ctx context.Context, rs store.ReadStoreInterface, event store.Event) error {
+	return categoryProjection{models: rs.ReadModels()}.dispatcher().Dispatch(ctx, event)
+}
+
+func (categoryProjection) Reset(_ context.Context, rs store.ReadStoreInterface) error {
+	return clearCollections(rs, "categories")
+}
+
+func (p categoryProjection) dispatcher() *eventcodec.Dispatcher {
+	d := eventcodec.NewDispatcher(eventcodec.Default)
+	eventcodec.On(d, p.onCategoryCreated)
+	eventcodec.On(d, p.onCategoryUpdated)
+	eventcodec.On(d, p.onCategoryDeleted)
+	return d
+}
+
+func (p categoryProjection) onCategoryCreated(ctx context.Context, _ store.Event, e category.CategoryCreated) error {
+	return p.models.Categories.Upsert(ctx, e.CategoryID, &readmodel.CategoryReadModel{
+		ID:          e.CategoryID,
+		Name:        e.Name,
+		Slug:        e.Slug,
+		Description: e.Description,
+		ParentID:    e.ParentID,
+		SortOrder:   e.SortOrder,
+		IsActive:    true,
+		CreatedAt:   e.CreatedAt,
+		UpdatedAt:   e.CreatedAt,
+	})
+}
+
+func (p categoryProjection) onCategoryUpdated(ctx context.Context, _ store.Event, e category.CategoryUpdated) error {
+	return update(ctx, p.models.Categories, e.CategoryID, func(c *readmodel.CategoryReadModel) {
+		c.Name = e.Name
+		c.Slug = e.Slug
+		c.Description = e.Description
+		c.ParentID = e.ParentID
+		c.SortOrder = e.SortOrder
+		c.UpdatedAt = e.UpdatedAt
+	})
+}
+
+func (p categoryProjection) onCategoryDeleted(ctx context.Context, _ store.Event, e category.CategoryDeleted) error {
+	// Soft delete by marking as inactive
+	return update(ctx, p.models.Categories, e.CategoryID, func(c *readmodel.CategoryReadModel) {
+		c.IsActive = false
+		c.UpdatedAt = e.DeletedAt
+	})
+}
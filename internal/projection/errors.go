@@ -0,0 +1,26 @@
+package projection
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/example/ec-event-driven/internal/eventcodec"
+)
+
+// ErrPoisonEvent marks an event that can never be projected as it is, such as
+// malformed JSON or a read model of an unexpected type. Retrying it only
+// blocks the events behind it.
+var ErrPoisonEvent = errors.New("poison event")
+
+// IsPoison reports whether err returned by the Projector is permanent. Any
+// other error (e.g. the read store being unavailable) is retryable.
+func IsPoison(err error) bool {
+	return errors.Is(err, ErrPoisonEvent) ||
+		errors.Is(err, eventcodec.ErrUnknownEventType) ||
+		errors.Is(err, eventcodec.ErrUndecodableEvent)
+}
+
+// poison marks err as permanent
+func poison(err error) error {
+	return fmt.Errorf("%w: %w", ErrPoisonEvent, err)
+}
@@ -0,0 +1,75 @@
+package projection
+
+import (
+	"context"
+
+	"github.com/example/ec-event-driven/internal/domain/inventory"
+	"github.com/example/ec-event-driven/internal/eventcodec"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/example/ec-event-driven/internal/readmodel"
+)
+
+func init() {
+	Register(inventoryProjection{})
+}
+
+// inventoryProjection builds the total, reserved and available stock of each product
+type inventoryProjection struct {
+	models *store.ReadModels
+}
+
+func (inventoryProjection) Name() string { return "inventory" }
+
+func (p inventoryProjection) EventTypes() []string { return p.dispatcher().EventTypes() }
+
+func (inventoryProjection) Handle(ctx context.Context, rs store.ReadStoreInterface, event store.Event) error {
+	return inventoryProjection{models: rs.ReadModels()}.dispatcher().Dispatch(ctx, event)
+}
+
+func (inventoryProjection) Reset(_ context.Context, rs store.ReadStoreInterface) error {
+	return clearCollections(rs, "inventory")
+}
+
+func (p inventoryProjection) dispatcher() *eventcodec.Dispatcher {
+	d := eventcodec.NewDispatcher(eventcodec.Default)
+	eventcodec.On(d, p.onStockAdded)
+	eventcodec.On(d, p.onStockReserved)
+	eventcodec.On(d, p.onStockReleased)
+	eventcodec.On(d, p.onStockDeducted)
+	return d
+}
+
+func (p inventoryProjection) onStockAdded(ctx context.Context, _ store.Event, e inventory.StockAdded) error {
+	inv, ok, err := p.models.Inventory.Get(ctx, e.ProductID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		inv = &readmodel.InventoryReadModel{ProductID: e.ProductID}
+	}
+	inv.TotalStock += e.Quantity
+	inv.AvailableStock = inv.TotalStock - inv.ReservedStock
+	return p.models.Inventory.Upsert(ctx, e.ProductID, inv)
+}
+
+func (p inventoryProjection) onStockReserved(ctx context.Context, _ store.Event, e inventory.StockReserved) error {
+	return update(ctx, p.models.Inventory, e.ProductID, func(inv *readmodel.InventoryReadModel) {
+		inv.ReservedStock += e.Quantity
+		inv.AvailableStock = inv.TotalStock - inv.ReservedStock
+	})
+}
+
+func (p inventoryProjection) onStockReleased(ctx context.Context, _ store.Event, e inventory.StockReleased) error {
+	return update(ctx, p.models.Inventory, e.ProductID, func(inv *readmodel.InventoryReadModel) {
+		inv.ReservedStock -= e.Quantity
+		inv.AvailableStock = inv.TotalStock - inv.ReservedStock
+	})
+}
+
+func (p inventoryProjection) onStockDeducted(ctx context.Context, _ store.Event, e inventory.StockDeducted) error {
+	return update(ctx, p.models.Inventory, e.ProductID, func(inv *readmodel.InventoryReadModel) {
+		inv.TotalStock -= e.Quantity
+		inv.ReservedStock -= e.Quantity
+		inv.AvailableStock = inv.TotalStock - inv.ReservedStock
+	})
+}
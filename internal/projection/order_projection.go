@@ -0,0 +1,85 @@
+package projection
+
+import (
+	"context"
+
+	"github.com/example/ec-event-driven/internal/domain/order"
+	"github.com/example/ec-event-driven/internal/eventcodec"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/example/ec-event-driven/internal/readmodel"
+)
+
+func init() {
+	Register(orderProjection{})
+}
+
+// orderProjection builds the orders read models
+type orderProjection struct {
+	models *store.ReadModels
+}
+
+func (orderProjection) Name() string { return "orders" }
+
+func (p orderProjection) EventTypes() []string { return p.dispatcher().EventTypes() }
+
+func (orderProjection) Handle(ctx context.Context, rs store.ReadStoreInterface, event store.Event) error {
+	return orderProjection{models: rs.ReadModels()}.dispatcher().Dispatch(ctx, event)
+}
+
+func (orderProjection) Reset(_ context.Context, rs store.ReadStoreInterface) error {
+	return clearCollections(rs, "orders")
+}
+
+func (p orderProjection) dispatcher() *eventcodec.Dispatcher {
+	d := eventcodec.NewDispatcher(eventcodec.Default)
+	eventcodec.On(d, p.onOrderPlaced)
+	eventcodec.On(d, p.onOrderPaid)
+	eventcodec.On(d, p.onOrderShipped)
+	eventcodec.On(d, p.onOrderCancelled)
+	return d
+}
+
+func (p orderProjection) onOrderPlaced(ctx context.Context, _ store.Event, e order.OrderPlaced) error {
+	items := make([]readmodel.OrderItemReadModel, len(e.Items))
+	for i, item := range e.Items {
+		items[i] = readmodel.OrderItemReadModel{
+			ProductID: item.ProductID,
+			Name:      item.Name,
+			Quantity:  item.Quantity,
+			Price:     item.Price,
+		}
+	}
+	return p.models.Orders.Upsert(ctx, e.OrderID, &readmodel.OrderReadModel{
+		ID:        e.OrderID,
+		UserID:    e.UserID,
+		Items:     items,
+		Total:     e.Total,
+		Status:    "pending",
+		CreatedAt: e.PlacedAt,
+		UpdatedAt: e.PlacedAt,
+	})
+}
+
+func (p orderProjection) onOrderPaid(ctx context.Context, _ store.Event, e order.OrderPaid) error {
+	return update(ctx, p.models.Orders, e.OrderID, func(o *readmodel.OrderReadModel) {
+		o.Status = "paid"
+		o.UpdatedAt = e.PaidAt
+	})
+}
+
+func (p orderProjection) onOrderShipped(ctx context.Context, _ store.Event, e order.OrderShipped) error {
+	return update(ctx, p.models.Orders, e.OrderID, func(o *readmodel.OrderReadModel) {
+		o.Status = "shipped"
+		o.Carrier = e.Carrier
+		o.TrackingNumber = e.TrackingNumber
+		o.ShippedAt = &e.ShippedAt
+		o.UpdatedAt = e.ShippedAt
+	})
+}
+
+func (p orderProjection) onOrderCancelled(ctx context.Context, _ store.Event, e order.OrderCancelled) error {
+	return update(ctx, p.models.Orders, e.OrderID, func(o *readmodel.OrderReadModel) {
+		o.Status = "cancelled"
+		o.UpdatedAt = e.CancelledAt
+	})
+}
@@ -0,0 +1,124 @@
+package projection
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/example/ec-event-driven/internal/domain/inventory"
+	"github.com/example/ec-event-driven/internal/domain/product"
+	"github.com/example/ec-event-driven/internal/eventcodec"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/example/ec-event-driven/internal/readmodel"
+)
+
+func init() {
+	Register(productProjection{})
+}
+
+// productProjection builds the products read models, including the stock
+// shown on each product and its category assignments
+type productProjection struct {
+	rs     store.ReadStoreInterface
+	models *store.ReadModels
+}
+
+func (productProjection) Name() string { return "products" }
+
+func (p productProjection) EventTypes() []string { return p.dispatcher().EventTypes() }
+
+func (productProjection) Handle(ctx context.Context, rs store.ReadStoreInterface, event store.Event) error {
+	return productProjection{rs: rs, models: rs.ReadModels()}.dispatcher().Dispatch(ctx, event)
+}
+
+func (productProjection) Reset(_ context.Context, rs store.ReadStoreInterface) error {
+	return clearCollections(rs, "products")
+}
+
+func (p productProjection) dispatcher() *eventcodec.Dispatcher {
+	d := eventcodec.NewDispatcher(eventcodec.Default)
+	eventcodec.On(d, p.onProductCreated)
+	eventcodec.On(d, p.onProductUpdated)
+	eventcodec.On(d, p.onProductDeleted)
+	eventcodec.On(d, p.onProductCategoryAssigned)
+	eventcodec.On(d, p.onProductCategoryRemoved)
+	eventcodec.On(d, p.onProductImageUpdated)
+	eventcodec.On(d, p.onStockAdded)
+	eventcodec.On(d, p.onStockReserved)
+	eventcodec.On(d, p.onStockReleased)
+	return d
+}
+
+func (p productProjection) onProductCreated(ctx context.Context, _ store.Event, e product.ProductCreated) error {
+	// Stock is managed by Inventory aggregate, so start with 0 here
+	// StockAdded event will set the actual stock value
+	return p.models.Products.Upsert(ctx, e.ProductID, &readmodel.ProductReadModel{
+		ID:          e.ProductID,
+		Name:        e.Name,
+		Description: e.Description,
+		Price:       e.Price,
+		Stock:       0,
+		CreatedAt:   e.CreatedAt,
+		UpdatedAt:   e.CreatedAt,
+	})
+}
+
+func (p productProjection) onProductUpdated(ctx context.Context, _ store.Event, e product.ProductUpdated) error {
+	return update(ctx, p.models.Products, e.ProductID, func(prod *readmodel.ProductReadModel) {
+		prod.Name = e.Name
+		prod.Description = e.Description
+		prod.Price = e.Price
+		prod.UpdatedAt = e.UpdatedAt
+	})
+}
+
+func (p productProjection) onProductDeleted(ctx context.Context, _ store.Event, e product.ProductDeleted) error {
+	return p.models.Products.Delete(ctx, e.ProductID)
+}
+
+func (p productProjection) onProductCategoryAssigned(_ context.Context, _ store.Event, e product.ProductCategoryAssigned) error {
+	// Only read stores with a product_categories table track category assignments
+	if pgStore, ok := p.rs.(productCategoryWriter); ok {
+		if err := pgStore.AddProductCategory(e.ProductID, e.CategoryID); err != nil {
+			return fmt.Errorf("failed to add category %s to product %s: %w", e.CategoryID, e.ProductID, err)
+		}
+	}
+	return nil
+}
+
+func (p productProjection) onProductCategoryRemoved(_ context.Context, _ store.Event, e product.ProductCategoryRemoved) error {
+	if pgStore, ok := p.rs.(productCategoryWriter); ok {
+		if err := pgStore.RemoveProductCategory(e.ProductID, e.CategoryID); err != nil {
+			return fmt.Errorf("failed to remove category %s from product %s: %w", e.CategoryID, e.ProductID, err)
+		}
+	}
+	return nil
+}
+
+func (p productProjection) onProductImageUpdated(ctx context.Context, _ store.Event, e product.ProductImageUpdated) error {
+	return update(ctx, p.models.Products, e.ProductID, func(prod *readmodel.ProductReadModel) {
+		prod.ImageURL = e.ImageURL
+		prod.UpdatedAt = e.UpdatedAt
+	})
+}
+
+func (p productProjection) onStockAdded(ctx context.Context, _ store.Event, e inventory.StockAdded) error {
+	return update(ctx, p.models.Products, e.ProductID, func(prod *readmodel.ProductReadModel) {
+		prod.Stock += e.Quantity
+		prod.UpdatedAt = time.Now()
+	})
+}
+
+func (p productProjection) onStockReserved(ctx context.Context, _ store.Event, e inventory.StockReserved) error {
+	return update(ctx, p.models.Products, e.ProductID, func(prod *readmodel.ProductReadModel) {
+		prod.Stock -= e.Quantity
+		prod.UpdatedAt = time.Now()
+	})
+}
+
+func (p productProjection) onStockReleased(ctx context.Context, _ store.Event, e inventory.StockReleased) error {
+	return update(ctx, p.models.Products, e.ProductID, func(prod *readmodel.ProductReadModel) {
+		prod.Stock += e.Quantity
+		prod.UpdatedAt = time.Now()
+	})
+}
@@ -0,0 +1,109 @@
+package projection
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+)
+
+// Projection builds a set of read models from the events it handles. Each
+// projection keeps its own checkpoints, so it can be enabled, rebuilt and
+// tested on its own.
+type Projection interface {
+	// Name identifies the projection in checkpoints and configuration
+	Name() string
+	// EventTypes returns the event types the projection handles
+	EventTypes() []string
+	// Handle applies an event of one of its types to the read models in rs
+	Handle(ctx context.Context, rs store.ReadStoreInterface, event store.Event) error
+	// Reset removes the projection's read models from rs before a rebuild
+	Reset(ctx context.Context, rs store.ReadStoreInterface) error
+}
+
+// Registry holds projections by name
+type Registry struct {
+	projections []Projection
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Default is the registry of the built-in projections, which register
+// themselves when the package is loaded
+var Default = NewRegistry()
+
+// Register adds p to the Default registry
+func Register(p Projection) {
+	Default.Register(p)
+}
+
+// Register adds p to the registry. It panics if a projection with the same
+// name is already registered.
+func (r *Registry) Register(p Projection) {
+	if _, exists := r.Get(p.Name()); exists {
+		panic("projection: duplicate projection " + p.Name())
+	}
+	r.projections = append(r.projections, p)
+}
+
+// Get returns the projection registered under name
+func (r *Registry) Get(name string) (Projection, bool) {
+	for _, p := range r.projections {
+		if p.Name() == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// All returns the registered projections in registration order
+func (r *Registry) All() []Projection {
+	return slices.Clone(r.projections)
+}
+
+// Names returns the names of the registered projections
+func (r *Registry) Names() []string {
+	names := make([]string, len(r.projections))
+	for i, p := range r.projections {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+// Select returns the projections with the given names
+func (r *Registry) Select(names []string) ([]Projection, error) {
+	projections := make([]Projection, 0, len(names))
+	for _, name := range names {
+		p, ok := r.Get(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown projection %q (expected one of %v)", name, r.Names())
+		}
+		projections = append(projections, p)
+	}
+	return projections, nil
+}
+
+// update applies fn to the read model stored under id in repo. A missing
+// read model is left alone.
+func update[T any](ctx context.Context, repo store.Repository[T], id string, fn func(*T)) error {
+	_, err := repo.Update(ctx, id, fn)
+	return err
+}
+
+// clearCollections removes every read model of the given collections
+func clearCollections(rs store.ReadStoreInterface, collections ...string) error {
+	clearer, ok := rs.(store.CollectionClearer)
+	if !ok {
+		return fmt.Errorf("read store %T cannot clear collections", rs)
+	}
+	for _, collection := range collections {
+		if err := clearer.Clear(collection); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", collection, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package projection
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/example/ec-event-driven/internal/domain/inventory"
+	"github.com/example/ec-event-driven/internal/domain/order"
+	"github.com/example/ec-event-driven/internal/domain/product"
+	"github.com/example/ec-event-driven/internal/domain/user"
+	"github.com/example/ec-event-driven/internal/eventcodec"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/example/ec-event-driven/internal/infrastructure/store/mocks"
+	"github.com/example/ec-event-driven/internal/readmodel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// salesProjection is a read model added outside the built-in projections:
+// the total amount of placed orders per user
+type salesProjection struct{}
+
+type salesReadModel struct {
+	Total int
+}
+
+func (salesProjection) Name() string { return "sales" }
+
+func (salesProjection) EventTypes() []string { return []string{order.EventOrderPlaced} }
+
+func (salesProjection) Handle(ctx context.Context, rs store.ReadStoreInterface, event store.Event) error {
+	var e order.OrderPlaced
+	if err := json.Unmarshal(event.Data, &e); err != nil {
+		return poison(err)
+	}
+	sales := store.NewCollectionRepository[salesReadModel](rs, "sales")
+	current, ok, err := sales.Get(ctx, e.UserID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		current = &salesReadModel{}
+	}
+	return sales.Upsert(ctx, e.UserID, &salesReadModel{Total: current.Total + e.Total})
+}
+
+func (salesProjection) Reset(_ context.Context, rs store.ReadStoreInterface) error {
+	return clearCollections(rs, "sales")
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	assert.ElementsMatch(t, []string{"products", "carts", "orders", "inventory", "users", "categories"}, Default.Names())
+
+	for _, p := range Default.All() {
+		assert.NotEmpty(t, p.EventTypes(), p.Name())
+	}
+	p, ok := Default.Get("inventory")
+	require.True(t, ok)
+	assert.Equal(t, []string{
+		inventory.EventStockAdded, inventory.EventStockDeducted, inventory.EventStockReleased, inventory.EventStockReserved,
+	}, p.EventTypes())
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.Register(salesProjection{})
+
+	assert.Panics(t, func() { r.Register(salesProjection{}) }, "duplicate projection")
+
+	projections, err := r.Select([]string{"sales"})
+	require.NoError(t, err)
+	assert.Equal(t, []Projection{salesProjection{}}, projections)
+
+	_, err = r.Select([]string{"sales", "products"})
+	assert.ErrorContains(t, err, `unknown projection "products"`)
+}
+
+func TestProjection_HandleOnItsOwn(t *testing.T) {
+	readStore := mocks.NewMockReadStore()
+	ctx := context.Background()
+	p := inventoryProjection{}
+
+	data, _ := json.Marshal(inventory.StockAdded{ProductID: "prod-1", Quantity: 10, AddedAt: time.Now()})
+	event := store.Event{AggregateID: "prod-1", AggregateType: inventory.AggregateType, EventType: inventory.EventStockAdded, Data: data}
+
+	require.NoError(t, p.Handle(ctx, readStore, event))
+	inv, ok := readStore.GetData("inventory", "prod-1")
+	require.True(t, ok)
+	assert.Equal(t, 10, inv.(*readmodel.InventoryReadModel).AvailableStock)
+
+	require.NoError(t, p.Reset(ctx, readStore))
+	_, ok = readStore.GetData("inventory", "prod-1")
+	assert.False(t, ok)
+}
+
+func TestProjector_OnlyEnabledProjections(t *testing.T) {
+	readStore := mocks.NewMockReadStore()
+	projector := NewProjector(readStore, inventoryProjection{})
+	ctx := context.Background()
+	readStore.SetData("products", "prod-1", &readmodel.ProductReadModel{ID: "prod-1"})
+
+	value := makeVersionedEvent("prod-1", 2, inventory.AggregateType, inventory.EventStockAdded,
+		inventory.StockAdded{ProductID: "prod-1", Quantity: 10, AddedAt: time.Now()})
+	require.NoError(t, projector.HandleEvent(ctx, nil, value))
+
+	inv, ok := readStore.GetData("inventory", "prod-1")
+	require.True(t, ok)
+	assert.Equal(t, 10, inv.(*readmodel.InventoryReadModel).TotalStock)
+	prod, _ := readStore.GetData("products", "prod-1")
+	assert.Equal(t, 0, prod.(*readmodel.ProductReadModel).Stock)
+	assert.Equal(t, 2, readStore.Checkpoint("inventory", "prod-1"))
+	assert.Equal(t, 0, readStore.Checkpoint("products", "prod-1"))
+}
+
+func TestProjector_IndependentCheckpoints(t *testing.T) {
+	readStore := mocks.NewMockReadStore()
+	ctx := context.Background()
+	readStore.SetData("products", "prod-1", &readmodel.ProductReadModel{ID: "prod-1"})
+	value := makeVersionedEvent("prod-1", 2, inventory.AggregateType, inventory.EventStockAdded,
+		inventory.StockAdded{ProductID: "prod-1", Quantity: 10, AddedAt: time.Now()})
+
+	// The products projection has applied the event before inventory was enabled
+	require.NoError(t, NewProjector(readStore, productProjection{}).HandleEvent(ctx, nil, value))
+	require.NoError(t, NewProjector(readStore).HandleEvent(ctx, nil, value))
+
+	prod, _ := readStore.GetData("products", "prod-1")
+	assert.Equal(t, 10, prod.(*readmodel.ProductReadModel).Stock, "applied once to products")
+	inv, _ := readStore.GetData("inventory", "prod-1")
+	assert.Equal(t, 10, inv.(*readmodel.InventoryReadModel).TotalStock)
+}
+
+func TestProjector_ApplyEventReportsSkippedEvents(t *testing.T) {
+	readStore := mocks.NewMockReadStore()
+	projector := NewProjector(readStore)
+	ctx := context.Background()
+	value := makeVersionedEvent("prod-1", 2, inventory.AggregateType, inventory.EventStockAdded,
+		inventory.StockAdded{ProductID: "prod-1", Quantity: 10, AddedAt: time.Now()})
+
+	applied, err := projector.ApplyEvent(ctx, nil, value)
+	require.NoError(t, err)
+	assert.True(t, applied)
+
+	applied, err = projector.ApplyEvent(ctx, nil, value)
+	require.NoError(t, err)
+	assert.False(t, applied, "already applied")
+}
+
+func TestProjector_SkipEventAdvancesCheckpoints(t *testing.T) {
+	readStore := mocks.NewMockReadStore()
+	projector := NewProjector(readStore)
+	ctx := context.Background()
+	value := makeVersionedEvent("prod-1", 1, inventory.AggregateType, inventory.EventStockAdded,
+		inventory.StockAdded{ProductID: "prod-1", Quantity: 10, AddedAt: time.Now()})
+
+	require.NoError(t, projector.SkipEvent(ctx, nil, value))
+
+	_, ok := readStore.GetData("inventory", "prod-1")
+	assert.False(t, ok, "not applied")
+	checkpoints, err := projector.Checkpoints(ctx, []string{"prod-1"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"prod-1": 1}, checkpoints)
+}
+
+func TestProjector_UnhandledEventsAdvanceCheckpoints(t *testing.T) {
+	readStore := mocks.NewMockReadStore()
+	projector := NewProjector(readStore)
+	ctx := context.Background()
+
+	require.NoError(t, projector.HandleEvent(ctx, nil, makeVersionedEvent("user-1", 1, user.AggregateType, user.EventUserCreated,
+		user.UserCreated{UserID: "user-1", Email: "a@example.com", CreatedAt: time.Now()})))
+	require.NoError(t, projector.HandleEvent(ctx, nil, makeVersionedEvent("user-1", 2, user.AggregateType, user.EventUserLoggedIn,
+		user.UserLoggedIn{UserID: "user-1"})))
+
+	for _, name := range Default.Names() {
+		assert.Equal(t, 2, readStore.Checkpoint(name, "user-1"), name)
+	}
+	checkpoints, err := projector.Checkpoints(ctx, []string{"user-1", "user-2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"user-1": 2}, checkpoints)
+}
+
+func TestProjector_CheckpointsAreTheLowestAmongProjections(t *testing.T) {
+	readStore := mocks.NewMockReadStore()
+	ctx := context.Background()
+	created := makeVersionedEvent("prod-1", 1, product.AggregateType, product.EventProductCreated,
+		product.ProductCreated{ProductID: "prod-1", Name: "Widget", CreatedAt: time.Now()})
+	stockAdded := makeVersionedEvent("prod-1", 2, inventory.AggregateType, inventory.EventStockAdded,
+		inventory.StockAdded{ProductID: "prod-1", Quantity: 10, AddedAt: time.Now()})
+
+	// The inventory projection lags behind the products projection
+	require.NoError(t, NewProjector(readStore, inventoryProjection{}).HandleEvent(ctx, nil, created))
+	products := NewProjector(readStore, productProjection{})
+	require.NoError(t, products.HandleEvent(ctx, nil, created))
+	require.NoError(t, products.HandleEvent(ctx, nil, stockAdded))
+
+	checkpoints, err := NewProjector(readStore, productProjection{}, inventoryProjection{}).Checkpoints(ctx, []string{"prod-1"})
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"prod-1": 1}, checkpoints)
+}
+
+func TestProjector_CustomProjection(t *testing.T) {
+	readStore := mocks.NewMockReadStore()
+	projector := NewProjector(readStore, salesProjection{})
+	ctx := context.Background()
+
+	for i, total := range []int{1000, 2500} {
+		orderID := fmt.Sprintf("order-%d", i)
+		value := makeVersionedEvent(orderID, 1, order.AggregateType, order.EventOrderPlaced,
+			order.OrderPlaced{OrderID: orderID, UserID: "user-1", Total: total, PlacedAt: time.Now()})
+		require.NoError(t, projector.HandleEvent(ctx, nil, value))
+	}
+
+	sales, ok := readStore.GetData("sales", "user-1")
+	require.True(t, ok)
+	assert.Equal(t, 3500, sales.(*salesReadModel).Total)
+	_, ok = readStore.GetData("orders", "order-0")
+	assert.False(t, ok, "built-in projections are not enabled")
+
+	// Events of types no enabled projection handles are still validated
+	err := projector.HandleEvent(ctx, nil, makeEvent("UnknownAggregate", "UnknownEvent", struct{}{}))
+	assert.ErrorIs(t, err, eventcodec.ErrUnknownEventType)
+}
+
+func TestProjector_UnitOfWork(t *testing.T) {
+	readStore := mocks.NewMockReadStore()
+	projector := NewProjector(readStore)
+	ctx := context.Background()
+
+	err := projector.UnitOfWork(ctx, func(tx *Projector) error {
+		for version, quantity := range []int{10, 5} {
+			value := makeVersionedEvent("prod-1", version+1, inventory.AggregateType, inventory.EventStockAdded,
+				inventory.StockAdded{ProductID: "prod-1", Quantity: quantity, AddedAt: time.Now()})
+			if err := tx.HandleEvent(ctx, nil, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, readStore.UnitsOfWork)
+	inv, _ := readStore.GetData("inventory", "prod-1")
+	assert.Equal(t, 15, inv.(*readmodel.InventoryReadModel).TotalStock)
+	assert.Equal(t, 2, readStore.Checkpoint("inventory", "prod-1"))
+}
+
+func TestProjector_UnversionedEventIsAppliedInUnitOfWork(t *testing.T) {
+	readStore := mocks.NewMockReadStore()
+	projector := NewProjector(readStore)
+
+	value := makeEvent(inventory.AggregateType, inventory.EventStockAdded,
+		inventory.StockAdded{ProductID: "prod-1", Quantity: 10, AddedAt: time.Now()})
+	require.NoError(t, projector.HandleEvent(context.Background(), nil, value))
+
+	assert.Equal(t, 1, readStore.UnitsOfWork)
+	_, ok := readStore.GetData("inventory", "prod-1")
+	assert.True(t, ok)
+}
@@ -3,570 +3,186 @@ package projection
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
-	"time"
+	"slices"
 
-	"github.com/example/ec-event-driven/internal/domain/cart"
-	"github.com/example/ec-event-driven/internal/domain/category"
-	"github.com/example/ec-event-driven/internal/domain/inventory"
-	"github.com/example/ec-event-driven/internal/domain/order"
-	"github.com/example/ec-event-driven/internal/domain/product"
-	"github.com/example/ec-event-driven/internal/domain/user"
+	"github.com/example/ec-event-driven/internal/eventcodec"
 	"github.com/example/ec-event-driven/internal/infrastructure/store"
-	"github.com/example/ec-event-driven/internal/readmodel"
 )
 
-type Projector struct {
-	readStore store.ReadStoreInterface
+// productCategoryWriter is implemented by read stores that track product category assignments
+type productCategoryWriter interface {
+	AddProductCategory(productID, categoryID string) error
+	RemoveProductCategory(productID, categoryID string) error
 }
 
-func NewProjector(readStore store.ReadStoreInterface) *Projector {
-	return &Projector{readStore: readStore}
+// Projector applies events to a set of projections
+type Projector struct {
+	readStore   store.ReadStoreInterface
+	upcasters   *store.UpcasterRegistry
+	names       []string
+	byEventType map[string][]Projection
 }
 
-func (p *Projector) HandleEvent(ctx context.Context, key, value []byte) error {
-	var event store.Event
-	if err := json.Unmarshal(value, &event); err != nil {
-		return err
+// NewProjector creates a projector applying events to the given projections,
+// or to every projection in the Default registry if none are given
+func NewProjector(readStore store.ReadStoreInterface, projections ...Projection) *Projector {
+	if len(projections) == 0 {
+		projections = Default.All()
 	}
-
-	log.Printf("[Projector] Received event: %s (aggregate: %s)", event.EventType, event.AggregateType)
-
-	switch event.AggregateType {
-	case product.AggregateType:
-		return p.handleProductEvent(event)
-	case cart.AggregateType:
-		return p.handleCartEvent(event)
-	case order.AggregateType:
-		return p.handleOrderEvent(event)
-	case inventory.AggregateType:
-		return p.handleInventoryEvent(event)
-	case user.AggregateType:
-		return p.handleUserEvent(event)
-	case category.AggregateType:
-		return p.handleCategoryEvent(event)
+	p := &Projector{
+		readStore:   readStore,
+		upcasters:   store.DefaultUpcasters,
+		byEventType: make(map[string][]Projection),
 	}
-
-	return nil
-}
-
-func (p *Projector) handleProductEvent(event store.Event) error {
-	switch event.EventType {
-	case product.EventProductCreated:
-		var e product.ProductCreated
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
+	for _, projection := range projections {
+		p.names = append(p.names, projection.Name())
+		for _, eventType := range projection.EventTypes() {
+			p.byEventType[eventType] = append(p.byEventType[eventType], projection)
 		}
-		// Stock is managed by Inventory aggregate, so start with 0 here
-		// StockAdded event will set the actual stock value
-		_ = p.readStore.Set("products", e.ProductID, &readmodel.ProductReadModel{
-			ID:          e.ProductID,
-			Name:        e.Name,
-			Description: e.Description,
-			Price:       e.Price,
-			Stock:       0,
-			CreatedAt:   e.CreatedAt,
-			UpdatedAt:   e.CreatedAt,
-		})
-
-	case product.EventProductUpdated:
-		var e product.ProductUpdated
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		_, _ = p.readStore.Update("products", e.ProductID, func(current any) any {
-			prod, ok := current.(*readmodel.ProductReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for ProductReadModel (id: %s)", e.ProductID)
-				return current
-			}
-			prod.Name = e.Name
-			prod.Description = e.Description
-			prod.Price = e.Price
-			prod.UpdatedAt = e.UpdatedAt
-			return prod
-		})
-
-	case product.EventProductDeleted:
-		var e product.ProductDeleted
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		_ = p.readStore.Delete("products", e.ProductID)
-
-	case product.EventProductCategoryAssigned:
-		var e product.ProductCategoryAssigned
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		// Use type assertion to access PostgresReadStore methods
-		if pgStore, ok := p.readStore.(*store.PostgresReadStore); ok {
-			pgStore.AddProductCategory(e.ProductID, e.CategoryID)
-		}
-
-	case product.EventProductCategoryRemoved:
-		var e product.ProductCategoryRemoved
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		if pgStore, ok := p.readStore.(*store.PostgresReadStore); ok {
-			pgStore.RemoveProductCategory(e.ProductID, e.CategoryID)
-		}
-
-	case product.EventProductImageUpdated:
-		var e product.ProductImageUpdated
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		_, _ = p.readStore.Update("products", e.ProductID, func(current any) any {
-			prod, ok := current.(*readmodel.ProductReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for ProductReadModel (id: %s)", e.ProductID)
-				return current
-			}
-			prod.ImageURL = e.ImageURL
-			prod.UpdatedAt = e.UpdatedAt
-			return prod
-		})
 	}
-
-	return nil
+	return p
 }
 
-func (p *Projector) handleCartEvent(event store.Event) error {
-	switch event.EventType {
-	case cart.EventItemAdded:
-		var e cart.ItemAddedToCart
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-
-		// Get product name
-		productName := ""
-		if prod, ok, _ := p.readStore.Get("products", e.ProductID); ok {
-			if p, ok := prod.(*readmodel.ProductReadModel); ok {
-				productName = p.Name
-			}
-		}
-
-		_, ok, _ := p.readStore.Get("carts", e.CartID)
-		if !ok {
-			// Create new cart
-			_ = p.readStore.Set("carts", e.CartID, &readmodel.CartReadModel{
-				ID:     e.CartID,
-				UserID: e.UserID,
-				Items: []readmodel.CartItemReadModel{
-					{ProductID: e.ProductID, Name: productName, Quantity: e.Quantity, Price: e.Price},
-				},
-				Total: e.Price * e.Quantity,
-			})
-		} else {
-			// Update existing cart
-			_, _ = p.readStore.Update("carts", e.CartID, func(current any) any {
-				c, ok := current.(*readmodel.CartReadModel)
-				if !ok {
-					log.Printf("[Projector] Type assertion failed for CartReadModel (id: %s)", e.CartID)
-					return current
-				}
-				// Check if item already exists
-				found := false
-				for i, item := range c.Items {
-					if item.ProductID == e.ProductID {
-						c.Items[i].Quantity += e.Quantity
-						found = true
-						break
-					}
-				}
-				if !found {
-					c.Items = append(c.Items, readmodel.CartItemReadModel{
-						ProductID: e.ProductID,
-						Name:      productName,
-						Quantity:  e.Quantity,
-						Price:     e.Price,
-					})
-				}
-				c.Total = calculateCartTotal(c.Items)
-				return c
-			})
-		}
-
-	case cart.EventItemRemoved:
-		var e cart.ItemRemovedFromCart
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		_, _ = p.readStore.Update("carts", e.CartID, func(current any) any {
-			c, ok := current.(*readmodel.CartReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for CartReadModel (id: %s)", e.CartID)
-				return current
-			}
-			newItems := make([]readmodel.CartItemReadModel, 0)
-			for _, item := range c.Items {
-				if item.ProductID != e.ProductID {
-					newItems = append(newItems, item)
-				}
-			}
-			c.Items = newItems
-			c.Total = calculateCartTotal(c.Items)
-			return c
-		})
-
-	case cart.EventCartCleared:
-		var e cart.CartCleared
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		_ = p.readStore.Set("carts", e.CartID, &readmodel.CartReadModel{
-			ID:     e.CartID,
-			UserID: e.UserID,
-			Items:  []readmodel.CartItemReadModel{},
-			Total:  0,
-		})
-	}
-
-	return nil
+// Names returns the names of the projections the projector applies events to
+func (p *Projector) Names() []string {
+	return slices.Clone(p.names)
 }
 
-func (p *Projector) handleOrderEvent(event store.Event) error {
-	switch event.EventType {
-	case order.EventOrderPlaced:
-		var e order.OrderPlaced
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		items := make([]readmodel.OrderItemReadModel, len(e.Items))
-		for i, item := range e.Items {
-			items[i] = readmodel.OrderItemReadModel{
-				ProductID: item.ProductID,
-				Name:      item.Name,
-				Quantity:  item.Quantity,
-				Price:     item.Price,
-			}
-		}
-		_ = p.readStore.Set("orders", e.OrderID, &readmodel.OrderReadModel{
-			ID:        e.OrderID,
-			UserID:    e.UserID,
-			Items:     items,
-			Total:     e.Total,
-			Status:    "pending",
-			CreatedAt: e.PlacedAt,
-			UpdatedAt: e.PlacedAt,
-		})
-
-	case order.EventOrderPaid:
-		var e order.OrderPaid
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		_, _ = p.readStore.Update("orders", e.OrderID, func(current any) any {
-			o, ok := current.(*readmodel.OrderReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for OrderReadModel (id: %s)", e.OrderID)
-				return current
-			}
-			o.Status = "paid"
-			o.UpdatedAt = e.PaidAt
-			return o
-		})
+// HandleEvent applies a JSON-encoded event to the projections that handle
+// its type. Errors for which IsPoison is true will fail again on every retry;
+// any other error (e.g. the read store being unavailable) may succeed when retried.
+func (p *Projector) HandleEvent(ctx context.Context, key, value []byte) error {
+	_, err := p.ApplyEvent(ctx, key, value)
+	return err
+}
 
-	case order.EventOrderShipped:
-		var e order.OrderShipped
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		_, _ = p.readStore.Update("orders", e.OrderID, func(current any) any {
-			o, ok := current.(*readmodel.OrderReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for OrderReadModel (id: %s)", e.OrderID)
-				return current
-			}
-			o.Status = "shipped"
-			o.UpdatedAt = e.ShippedAt
-			return o
-		})
+// ApplyEvent is HandleEvent that also reports whether the event was applied:
+// it is false, without an error, when every projection's checkpoint already
+// was at or past the event's version, so the event was skipped
+func (p *Projector) ApplyEvent(ctx context.Context, key, value []byte) (bool, error) {
+	var event store.Event
+	if err := json.Unmarshal(value, &event); err != nil {
+		return false, poison(err)
+	}
 
-	case order.EventOrderCancelled:
-		var e order.OrderCancelled
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		_, _ = p.readStore.Update("orders", e.OrderID, func(current any) any {
-			o, ok := current.(*readmodel.OrderReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for OrderReadModel (id: %s)", e.OrderID)
-				return current
-			}
-			o.Status = "cancelled"
-			o.UpdatedAt = e.CancelledAt
-			return o
-		})
+	// Events reach the projector as they were stored, so bring them to the current schema
+	event, err := p.upcasters.Upcast(event)
+	if err != nil {
+		return false, poison(err)
 	}
 
-	return nil
-}
+	log.Printf("[Projector] Received event: %s (aggregate: %s)", event.EventType, event.AggregateType)
 
-func (p *Projector) handleInventoryEvent(event store.Event) error {
-	switch event.EventType {
-	case inventory.EventStockAdded:
-		var e inventory.StockAdded
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		existing, ok, _ := p.readStore.Get("inventory", e.ProductID)
-		if !ok {
-			_ = p.readStore.Set("inventory", e.ProductID, &readmodel.InventoryReadModel{
-				ProductID:      e.ProductID,
-				TotalStock:     e.Quantity,
-				ReservedStock:  0,
-				AvailableStock: e.Quantity,
+	// Kinesis delivers at least once: apply each versioned event only once per
+	// projection. The checkpoints of projections that do not handle the event
+	// advance as well, so that every projection's checkpoint is the last
+	// version of the aggregate it has seen.
+	idempotent, ok := p.readStore.(store.IdempotentReadStore)
+	if !ok || event.Version <= 0 {
+		// Without a checkpoint, the event's writes are still committed together
+		if uow, ok := p.readStore.(store.TransactionalReadStore); ok {
+			return true, uow.UnitOfWork(ctx, func(tx store.TransactionalReadStore) error {
+				return p.apply(ctx, tx, p.byEventType[event.EventType], event)
 			})
-		} else {
-			inv, ok := existing.(*readmodel.InventoryReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for InventoryReadModel (productId: %s)", e.ProductID)
-				return nil
-			}
-			inv.TotalStock += e.Quantity
-			inv.AvailableStock = inv.TotalStock - inv.ReservedStock
-			_ = p.readStore.Set("inventory", e.ProductID, inv)
 		}
-
-		// Also update product stock
-		_, _ = p.readStore.Update("products", e.ProductID, func(current any) any {
-			prod, ok := current.(*readmodel.ProductReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for ProductReadModel (id: %s)", e.ProductID)
-				return current
-			}
-			prod.Stock += e.Quantity
-			prod.UpdatedAt = time.Now()
-			return prod
-		})
-
-	case inventory.EventStockReserved:
-		var e inventory.StockReserved
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		_, _ = p.readStore.Update("inventory", e.ProductID, func(current any) any {
-			inv, ok := current.(*readmodel.InventoryReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for InventoryReadModel (productId: %s)", e.ProductID)
-				return current
-			}
-			inv.ReservedStock += e.Quantity
-			inv.AvailableStock = inv.TotalStock - inv.ReservedStock
-			return inv
-		})
-		_, _ = p.readStore.Update("products", e.ProductID, func(current any) any {
-			prod, ok := current.(*readmodel.ProductReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for ProductReadModel (id: %s)", e.ProductID)
-				return current
-			}
-			prod.Stock -= e.Quantity
-			prod.UpdatedAt = time.Now()
-			return prod
-		})
-
-	case inventory.EventStockReleased:
-		var e inventory.StockReleased
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		_, _ = p.readStore.Update("inventory", e.ProductID, func(current any) any {
-			inv, ok := current.(*readmodel.InventoryReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for InventoryReadModel (productId: %s)", e.ProductID)
-				return current
-			}
-			inv.ReservedStock -= e.Quantity
-			inv.AvailableStock = inv.TotalStock - inv.ReservedStock
-			return inv
-		})
-		_, _ = p.readStore.Update("products", e.ProductID, func(current any) any {
-			prod, ok := current.(*readmodel.ProductReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for ProductReadModel (id: %s)", e.ProductID)
-				return current
+		return true, p.apply(ctx, p.readStore, p.byEventType[event.EventType], event)
+	}
+	applied, err := idempotent.ApplyOnce(ctx, p.names, event, func(tx store.ReadStoreInterface, pending []string) error {
+		var projections []Projection
+		for _, projection := range p.byEventType[event.EventType] {
+			if slices.Contains(pending, projection.Name()) {
+				projections = append(projections, projection)
 			}
-			prod.Stock += e.Quantity
-			prod.UpdatedAt = time.Now()
-			return prod
-		})
-
-	case inventory.EventStockDeducted:
-		var e inventory.StockDeducted
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
 		}
-		_, _ = p.readStore.Update("inventory", e.ProductID, func(current any) any {
-			inv, ok := current.(*readmodel.InventoryReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for InventoryReadModel (productId: %s)", e.ProductID)
-				return current
-			}
-			inv.TotalStock -= e.Quantity
-			inv.ReservedStock -= e.Quantity
-			inv.AvailableStock = inv.TotalStock - inv.ReservedStock
-			return inv
-		})
+		return p.apply(ctx, tx, projections, event)
+	})
+	if err != nil {
+		return false, err
 	}
-
-	return nil
+	if len(applied) == 0 {
+		log.Printf("[Projector] Skipping duplicate event: %s %s v%d", event.EventType, event.AggregateID, event.Version)
+		return false, nil
+	}
+	return true, nil
 }
 
-func calculateCartTotal(items []readmodel.CartItemReadModel) int {
-	total := 0
-	for _, item := range items {
-		total += item.Price * item.Quantity
+// SkipEvent advances the checkpoints of the projections to a JSON-encoded
+// event without applying it, e.g. when it is discarded from the dead-letter
+// queue, so that the later events of its aggregate are no longer version gaps
+func (p *Projector) SkipEvent(ctx context.Context, key, value []byte) error {
+	var event store.Event
+	if err := json.Unmarshal(value, &event); err != nil {
+		return poison(err)
+	}
+	idempotent, ok := p.readStore.(store.IdempotentReadStore)
+	if !ok || event.Version <= 0 {
+		return nil // Without checkpoints there are no gaps to close
+	}
+	_, err := idempotent.ApplyOnce(ctx, p.names, event, func(store.ReadStoreInterface, []string) error {
+		return nil
+	})
+	if err == nil {
+		log.Printf("[Projector] Skipped event: %s %s v%d", event.EventType, event.AggregateID, event.Version)
 	}
-	return total
+	return err
 }
 
-func (p *Projector) handleUserEvent(event store.Event) error {
-	switch event.EventType {
-	case user.EventUserCreated:
-		var e user.UserCreated
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		_ = p.readStore.Set("users", e.UserID, &readmodel.UserReadModel{
-			ID:           e.UserID,
-			Email:        e.Email,
-			PasswordHash: e.PasswordHash,
-			Name:         e.Name,
-			Role:         e.Role,
-			IsActive:     true,
-			CreatedAt:    e.CreatedAt,
-			UpdatedAt:    e.CreatedAt,
-		})
-
-	case user.EventUserUpdated:
-		var e user.UserUpdated
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		_, _ = p.readStore.Update("users", e.UserID, func(current any) any {
-			u, ok := current.(*readmodel.UserReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for UserReadModel (id: %s)", e.UserID)
-				return current
-			}
-			u.Name = e.Name
-			u.UpdatedAt = e.UpdatedAt
-			return u
-		})
-
-	case user.EventUserPasswordChanged:
-		var e user.UserPasswordChanged
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		_, _ = p.readStore.Update("users", e.UserID, func(current any) any {
-			u, ok := current.(*readmodel.UserReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for UserReadModel (id: %s)", e.UserID)
-				return current
-			}
-			u.PasswordHash = e.PasswordHash
-			u.UpdatedAt = e.ChangedAt
-			return u
-		})
+// UnitOfWork calls fn with a projector whose events are applied in one unit of
+// work of the read store, committed when fn returns nil. Each event is still
+// applied on its own within it, so a failing event only discards its own
+// changes. Without a transactional read store, fn is called with p.
+func (p *Projector) UnitOfWork(ctx context.Context, fn func(tx *Projector) error) error {
+	uow, ok := p.readStore.(store.TransactionalReadStore)
+	if !ok {
+		return fn(p)
+	}
+	return uow.UnitOfWork(ctx, func(tx store.TransactionalReadStore) error {
+		bound := *p
+		bound.readStore = tx
+		return fn(&bound)
+	})
+}
 
-	case user.EventUserDeactivated:
-		var e user.UserDeactivated
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		_, _ = p.readStore.Update("users", e.UserID, func(current any) any {
-			u, ok := current.(*readmodel.UserReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for UserReadModel (id: %s)", e.UserID)
-				return current
+// apply passes event to each of projections. Events that no projection
+// handles are still decoded, so that unknown and undecodable events are reported.
+func (p *Projector) apply(ctx context.Context, rs store.ReadStoreInterface, projections []Projection, event store.Event) error {
+	if len(projections) == 0 {
+		_, err := eventcodec.Decode(event)
+		return err
+	}
+	for _, projection := range projections {
+		if err := projection.Handle(ctx, rs, event); err != nil {
+			if errors.Is(err, store.ErrUnexpectedType) {
+				err = poison(err)
 			}
-			u.IsActive = false
-			u.UpdatedAt = e.DeactivatedAt
-			return u
-		})
-
-	case user.EventUserActivated:
-		var e user.UserActivated
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
+			return fmt.Errorf("%s projection: %w", projection.Name(), err)
 		}
-		_, _ = p.readStore.Update("users", e.UserID, func(current any) any {
-			u, ok := current.(*readmodel.UserReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for UserReadModel (id: %s)", e.UserID)
-				return current
-			}
-			u.IsActive = true
-			u.UpdatedAt = e.ActivatedAt
-			return u
-		})
 	}
-
 	return nil
 }
 
-func (p *Projector) handleCategoryEvent(event store.Event) error {
-	switch event.EventType {
-	case category.EventCategoryCreated:
-		var e category.CategoryCreated
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
-		}
-		_ = p.readStore.Set("categories", e.CategoryID, &readmodel.CategoryReadModel{
-			ID:          e.CategoryID,
-			Name:        e.Name,
-			Slug:        e.Slug,
-			Description: e.Description,
-			ParentID:    e.ParentID,
-			SortOrder:   e.SortOrder,
-			IsActive:    true,
-			CreatedAt:   e.CreatedAt,
-			UpdatedAt:   e.CreatedAt,
-		})
-
-	case category.EventCategoryUpdated:
-		var e category.CategoryUpdated
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
+// Checkpoints returns the last version of each of the given aggregates
+// applied by the projector, i.e. the lowest checkpoint among its projections
+// that have one. It returns no checkpoints if the read store does not keep them.
+func (p *Projector) Checkpoints(ctx context.Context, aggregateIDs []string) (map[string]int, error) {
+	checkpoints := make(map[string]int)
+	idempotent, ok := p.readStore.(store.IdempotentReadStore)
+	if !ok {
+		return checkpoints, nil
+	}
+	for _, name := range p.names {
+		projected, err := idempotent.Checkpoints(ctx, name, aggregateIDs)
+		if err != nil {
+			return nil, err
 		}
-		_, _ = p.readStore.Update("categories", e.CategoryID, func(current any) any {
-			c, ok := current.(*readmodel.CategoryReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for CategoryReadModel (id: %s)", e.CategoryID)
-				return current
+		for id, version := range projected {
+			if last, ok := checkpoints[id]; !ok || version < last {
+				checkpoints[id] = version
 			}
-			c.Name = e.Name
-			c.Slug = e.Slug
-			c.Description = e.Description
-			c.ParentID = e.ParentID
-			c.SortOrder = e.SortOrder
-			c.UpdatedAt = e.UpdatedAt
-			return c
-		})
-
-	case category.EventCategoryDeleted:
-		var e category.CategoryDeleted
-		if err := json.Unmarshal(event.Data, &e); err != nil {
-			return err
 		}
-		// Soft delete by marking as inactive
-		_, _ = p.readStore.Update("categories", e.CategoryID, func(current any) any {
-			c, ok := current.(*readmodel.CategoryReadModel)
-			if !ok {
-				log.Printf("[Projector] Type assertion failed for CategoryReadModel (id: %s)", e.CategoryID)
-				return current
-			}
-			c.IsActive = false
-			c.UpdatedAt = e.DeletedAt
-			return c
-		})
 	}
-
-	return nil
+	return checkpoints, nil
 }
@@ -3,6 +3,10 @@ package projection
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -12,6 +16,7 @@ import (
 	"github.com/example/ec-event-driven/internal/domain/order"
 	"github.com/example/ec-event-driven/internal/domain/product"
 	"github.com/example/ec-event-driven/internal/domain/user"
+	"github.com/example/ec-event-driven/internal/eventcodec"
 	"github.com/example/ec-event-driven/internal/infrastructure/store"
 	"github.com/example/ec-event-driven/internal/infrastructure/store/mocks"
 	"github.com/example/ec-event-driven/internal/readmodel"
@@ -187,8 +192,10 @@ func TestProjector_HandleOrderShipped(t *testing.T) {
 	})
 
 	eventData := order.OrderShipped{
-		OrderID:   "order-123",
-		ShippedAt: time.Now(),
+		OrderID:        "order-123",
+		ShippedAt:      time.Now(),
+		Carrier:        "yamato",
+		TrackingNumber: "1234-5678-9012",
 	}
 
 	value := makeEvent(order.AggregateType, order.EventOrderShipped, eventData)
@@ -199,6 +206,10 @@ func TestProjector_HandleOrderShipped(t *testing.T) {
 	data, _ := readStore.GetData("orders", "order-123")
 	o := data.(*readmodel.OrderReadModel)
 	assert.Equal(t, "shipped", o.Status)
+	assert.Equal(t, "yamato", o.Carrier)
+	assert.Equal(t, "1234-5678-9012", o.TrackingNumber)
+	require.NotNil(t, o.ShippedAt)
+	assert.True(t, eventData.ShippedAt.Equal(*o.ShippedAt))
 }
 
 func TestProjector_HandleOrderCancelled(t *testing.T) {
@@ -882,8 +893,287 @@ func TestProjector_HandleUnknownEventType(t *testing.T) {
 
 	value := makeEvent("UnknownAggregate", "UnknownEvent", struct{}{})
 
-	// Should not error on unknown event types
+	// Unknown event types are reported instead of silently skipped
+	err := projector.HandleEvent(ctx, nil, value)
+
+	assert.ErrorIs(t, err, eventcodec.ErrUnknownEventType)
+}
+
+func TestProjector_HandleUndecodableEvent(t *testing.T) {
+	projector, readStore := newTestProjector()
+	ctx := context.Background()
+
+	value := makeEvent(order.AggregateType, order.EventOrderPlaced, map[string]any{"order_id": 123})
+
+	err := projector.HandleEvent(ctx, nil, value)
+
+	assert.ErrorIs(t, err, eventcodec.ErrUndecodableEvent)
+	_, ok := readStore.GetData("orders", "123")
+	assert.False(t, ok)
+}
+
+func TestProjector_IgnoresUnprojectedEvents(t *testing.T) {
+	projector, _ := newTestProjector()
+	ctx := context.Background()
+
+	value := makeEvent(user.AggregateType, user.EventUserLoggedIn, user.UserLoggedIn{UserID: "user-123"})
+
 	err := projector.HandleEvent(ctx, nil, value)
 
 	assert.NoError(t, err)
 }
+
+// ============================================
+// Historical Event Replay Tests
+// ============================================
+
+// loadFixture returns the raw events stored in testdata/name
+func loadFixture(t *testing.T, name string) []json.RawMessage {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+
+	if data[0] != '[' {
+		return []json.RawMessage{data}
+	}
+	var events []json.RawMessage
+	require.NoError(t, json.Unmarshal(data, &events))
+	return events
+}
+
+// upcastLegacyOrderPlaced converts the pre-release OrderPlaced layout, which
+// had "amount" and per-item "unit_price", to the current one
+func upcastLegacyOrderPlaced(data json.RawMessage) (json.RawMessage, error) {
+	var legacy struct {
+		OrderID string `json:"order_id"`
+		UserID  string `json:"user_id"`
+		Items   []struct {
+			ProductID string `json:"product_id"`
+			Name      string `json:"name"`
+			Quantity  int    `json:"quantity"`
+			UnitPrice int    `json:"unit_price"`
+		} `json:"items"`
+		Amount   int       `json:"amount"`
+		PlacedAt time.Time `json:"placed_at"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, err
+	}
+
+	items := make([]order.OrderItem, len(legacy.Items))
+	for i, item := range legacy.Items {
+		items[i] = order.OrderItem{ProductID: item.ProductID, Name: item.Name, Quantity: item.Quantity, Price: item.UnitPrice}
+	}
+	return json.Marshal(order.OrderPlaced{
+		OrderID:  legacy.OrderID,
+		UserID:   legacy.UserID,
+		Items:    items,
+		Total:    legacy.Amount,
+		PlacedAt: legacy.PlacedAt,
+	})
+}
+
+func TestProjector_ReplaysHistoricalEvents(t *testing.T) {
+	projector, readStore := newTestProjector()
+	ctx := context.Background()
+
+	// Events stored before metadata and schema versions were recorded
+	for _, value := range loadFixture(t, "historical_events.json") {
+		require.NoError(t, projector.HandleEvent(ctx, nil, value))
+	}
+
+	data, ok := readStore.GetData("products", "prod-1")
+	require.True(t, ok)
+	prod := data.(*readmodel.ProductReadModel)
+	assert.Equal(t, "Coffee Beans", prod.Name)
+	assert.Equal(t, 30, prod.Stock)
+
+	data, ok = readStore.GetData("orders", "order-1")
+	require.True(t, ok)
+	o := data.(*readmodel.OrderReadModel)
+	assert.Equal(t, "paid", o.Status)
+	assert.Equal(t, 2400, o.Total)
+	require.Len(t, o.Items, 1)
+	assert.Equal(t, 1200, o.Items[0].Price)
+}
+
+func TestProjector_UpcastsLegacyEvents(t *testing.T) {
+	projector, readStore := newTestProjector()
+	projector.upcasters = store.NewUpcasterRegistry()
+	projector.upcasters.Register(order.EventOrderPlaced, 1, upcastLegacyOrderPlaced)
+	ctx := context.Background()
+
+	for _, value := range loadFixture(t, "order_placed_legacy.json") {
+		require.NoError(t, projector.HandleEvent(ctx, nil, value))
+	}
+
+	data, ok := readStore.GetData("orders", "order-2")
+	require.True(t, ok)
+	o := data.(*readmodel.OrderReadModel)
+	assert.Equal(t, 1200, o.Total)
+	require.Len(t, o.Items, 1)
+	assert.Equal(t, 1200, o.Items[0].Price)
+	assert.Equal(t, "pending", o.Status)
+}
+
+func TestProjector_UpcastError(t *testing.T) {
+	projector, readStore := newTestProjector()
+	projector.upcasters = store.NewUpcasterRegistry()
+	// A gap in the upcaster chain must not project a half-migrated event
+	projector.upcasters.Register(order.EventOrderPlaced, 2, upcastLegacyOrderPlaced)
+	ctx := context.Background()
+
+	err := projector.HandleEvent(ctx, nil, loadFixture(t, "order_placed_legacy.json")[0])
+
+	assert.Error(t, err)
+	_, ok := readStore.GetData("orders", "order-2")
+	assert.False(t, ok)
+}
+
+// ============================================
+// Idempotency Tests
+// ============================================
+
+// makeVersionedEvent builds a stored event of aggregate aggregateID at version
+func makeVersionedEvent(aggregateID string, version int, aggregateType, eventType string, data any) []byte {
+	jsonData, _ := json.Marshal(data)
+	event := store.Event{
+		ID:            fmt.Sprintf("%s-v%d", aggregateID, version),
+		AggregateID:   aggregateID,
+		AggregateType: aggregateType,
+		EventType:     eventType,
+		Data:          jsonData,
+		Timestamp:     time.Now(),
+		Version:       version,
+	}
+	result, _ := json.Marshal(event)
+	return result
+}
+
+func TestProjector_SkipsRedeliveredStockAdded(t *testing.T) {
+	projector, readStore := newTestProjector()
+	ctx := context.Background()
+	readStore.SetData("products", "prod-123", &readmodel.ProductReadModel{ID: "prod-123", Name: "Test Product"})
+
+	value := makeVersionedEvent("prod-123", 2, inventory.AggregateType, inventory.EventStockAdded,
+		inventory.StockAdded{ProductID: "prod-123", Quantity: 100, AddedAt: time.Now()})
+
+	// Kinesis redelivers the record after a failed batch
+	require.NoError(t, projector.HandleEvent(ctx, nil, value))
+	require.NoError(t, projector.HandleEvent(ctx, nil, value))
+
+	data, _ := readStore.GetData("inventory", "prod-123")
+	assert.Equal(t, 100, data.(*readmodel.InventoryReadModel).TotalStock)
+	data, _ = readStore.GetData("products", "prod-123")
+	assert.Equal(t, 100, data.(*readmodel.ProductReadModel).Stock)
+	assert.Equal(t, 2, readStore.Checkpoint("inventory", "prod-123"))
+	assert.Equal(t, 2, readStore.Checkpoint("products", "prod-123"))
+}
+
+func TestProjector_SkipsRedeliveredItemAddedToCart(t *testing.T) {
+	projector, readStore := newTestProjector()
+	ctx := context.Background()
+
+	first := makeVersionedEvent("cart-123", 1, cart.AggregateType, cart.EventItemAdded,
+		cart.ItemAddedToCart{CartID: "cart-123", UserID: "user-123", ProductID: "prod-1", Quantity: 2, Price: 500})
+	second := makeVersionedEvent("cart-123", 2, cart.AggregateType, cart.EventItemAdded,
+		cart.ItemAddedToCart{CartID: "cart-123", UserID: "user-123", ProductID: "prod-1", Quantity: 1, Price: 500})
+
+	// The first event is redelivered after the second one was applied
+	for _, value := range [][]byte{first, second, first, second} {
+		require.NoError(t, projector.HandleEvent(ctx, nil, value))
+	}
+
+	data, _ := readStore.GetData("carts", "cart-123")
+	c := data.(*readmodel.CartReadModel)
+	require.Len(t, c.Items, 1)
+	assert.Equal(t, 3, c.Items[0].Quantity)
+	assert.Equal(t, 1500, c.Total)
+}
+
+func TestProjector_FailedEventIsNotCheckpointed(t *testing.T) {
+	projector, readStore := newTestProjector()
+	ctx := context.Background()
+
+	value := makeVersionedEvent("order-123", 1, order.AggregateType, order.EventOrderPlaced, json.RawMessage(`{"total":"x"}`))
+
+	err := projector.HandleEvent(ctx, nil, value)
+
+	assert.ErrorIs(t, err, eventcodec.ErrUndecodableEvent)
+	assert.Equal(t, 0, readStore.Checkpoint("orders", "order-123"))
+}
+
+// ============================================
+// Error Classification Tests
+// ============================================
+
+func TestProjector_ReadStoreWriteErrorIsRetryable(t *testing.T) {
+	projector, readStore := newTestProjector()
+	ctx := context.Background()
+	readStore.WriteErr = errors.New("connection refused")
+
+	value := makeVersionedEvent("order-123", 1, order.AggregateType, order.EventOrderPlaced,
+		order.OrderPlaced{OrderID: "order-123", UserID: "user-123", Total: 1000, PlacedAt: time.Now()})
+
+	err := projector.HandleEvent(ctx, nil, value)
+
+	assert.ErrorIs(t, err, readStore.WriteErr)
+	assert.False(t, IsPoison(err))
+	assert.Equal(t, 0, readStore.Checkpoint("orders", "order-123"))
+
+	// The retry succeeds once the read store is back
+	readStore.WriteErr = nil
+	require.NoError(t, projector.HandleEvent(ctx, nil, value))
+	_, ok := readStore.GetData("orders", "order-123")
+	assert.True(t, ok)
+}
+
+func TestProjector_ReadStoreReadErrorIsRetryable(t *testing.T) {
+	projector, readStore := newTestProjector()
+	ctx := context.Background()
+	readStore.ReadErr = errors.New("connection refused")
+
+	value := makeEvent(inventory.AggregateType, inventory.EventStockAdded,
+		inventory.StockAdded{ProductID: "prod-123", Quantity: 10, AddedAt: time.Now()})
+
+	err := projector.HandleEvent(ctx, nil, value)
+
+	assert.ErrorIs(t, err, readStore.ReadErr)
+	assert.False(t, IsPoison(err))
+	_, ok := readStore.GetData("inventory", "prod-123")
+	assert.False(t, ok)
+}
+
+func TestProjector_UnexpectedReadModelTypeIsPoison(t *testing.T) {
+	projector, readStore := newTestProjector()
+	ctx := context.Background()
+	readStore.SetData("orders", "order-123", &readmodel.CartReadModel{ID: "order-123"})
+
+	value := makeEvent(order.AggregateType, order.EventOrderPaid,
+		order.OrderPaid{OrderID: "order-123", PaidAt: time.Now()})
+
+	err := projector.HandleEvent(ctx, nil, value)
+
+	assert.ErrorIs(t, err, ErrPoisonEvent)
+	assert.True(t, IsPoison(err))
+}
+
+func TestIsPoison(t *testing.T) {
+	projector, _ := newTestProjector()
+	ctx := context.Background()
+
+	tests := map[string][]byte{
+		"invalid JSON":       []byte(`{invalid json`),
+		"unknown event type": makeEvent("UnknownAggregate", "UnknownEvent", struct{}{}),
+		"undecodable event":  makeEvent(order.AggregateType, order.EventOrderPlaced, map[string]any{"order_id": 123}),
+	}
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := projector.HandleEvent(ctx, nil, value)
+			assert.True(t, IsPoison(err), "%v", err)
+		})
+	}
+
+	assert.False(t, IsPoison(nil))
+	assert.False(t, IsPoison(errors.New("timeout")))
+}
@@ -0,0 +1,143 @@
+package projection
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+)
+
+// RebuildableProjections are the projections whose tables cmd/replay can rebuild.
+// Users are not rebuilt because read_users also holds users without events.
+var RebuildableProjections = []string{"products", "carts", "orders", "inventory", "categories"}
+
+// Rebuilder replays events from the event store through a set of projections
+// into a separate read store
+type Rebuilder struct {
+	eventStore  store.EventStoreInterface
+	target      store.ReadStoreInterface
+	projections []Projection
+	handles     map[string][]string       // Event types handled per projection
+	applied     map[string]map[string]int // Last version replayed per projection and aggregate
+
+	// ProgressInterval is the number of events between progress logs (0: no progress logs)
+	ProgressInterval int
+}
+
+// NewRebuilder creates a Rebuilder writing the read models of the given
+// projections to target. Reads of other read models (e.g. products when
+// adding items to a cart) go to target unchanged.
+func NewRebuilder(eventStore store.EventStoreInterface, target store.ReadStoreInterface, projections []Projection) (*Rebuilder, error) {
+	if len(projections) == 0 {
+		return nil, fmt.Errorf("no projections to rebuild")
+	}
+	handles := make(map[string][]string, len(projections))
+	applied := make(map[string]map[string]int, len(projections))
+	for _, p := range projections {
+		handles[p.Name()] = p.EventTypes()
+		applied[p.Name()] = make(map[string]int)
+	}
+	return &Rebuilder{
+		eventStore:       eventStore,
+		target:           target,
+		projections:      projections,
+		handles:          handles,
+		applied:          applied,
+		ProgressInterval: 1000,
+	}, nil
+}
+
+// Names returns the names of the projections being rebuilt
+func (r *Rebuilder) Names() []string {
+	names := make([]string, len(r.projections))
+	for i, p := range r.projections {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+// Replay resets the projections' read models in the target and applies every
+// event in the event store in position order. It returns the number of events
+// applied. Aggregates with a checkpoint in a projection are replayed only up
+// to it, so the result matches what the live projection has applied so far;
+// CatchUp brings them level later.
+func (r *Rebuilder) Replay(ctx context.Context, checkpoints store.ProjectionCheckpoints) (int, error) {
+	for _, p := range r.projections {
+		if err := p.Reset(ctx, r.target); err != nil {
+			return 0, fmt.Errorf("failed to reset %s projection: %w", p.Name(), err)
+		}
+	}
+
+	n := 0
+	for event, err := range r.eventStore.ReadAll(ctx, 0) {
+		if err != nil {
+			return n, fmt.Errorf("failed to read events: %w", err)
+		}
+		replayed := false
+		for _, p := range r.projections {
+			if last, ok := checkpoints[p.Name()][event.AggregateID]; ok && event.Version > last {
+				continue
+			}
+			if err := r.apply(ctx, p, event); err != nil {
+				return n, err
+			}
+			replayed = true
+		}
+		if !replayed {
+			continue
+		}
+		n++
+		if r.ProgressInterval > 0 && n%r.ProgressInterval == 0 {
+			log.Printf("[Replay] Applied %d events (position %d)", n, event.Position)
+		}
+	}
+	return n, nil
+}
+
+// CatchUp applies the events between the last version replayed for each
+// projection and aggregate and its checkpoint, and returns the number of
+// events applied
+func (r *Rebuilder) CatchUp(ctx context.Context, checkpoints store.ProjectionCheckpoints) (int, error) {
+	n := 0
+	for _, p := range r.projections {
+		applied := r.applied[p.Name()]
+		ids := make([]string, 0)
+		for id, last := range checkpoints[p.Name()] {
+			if last > applied[id] {
+				ids = append(ids, id)
+			}
+		}
+		slices.Sort(ids)
+
+		for _, id := range ids {
+			last := checkpoints[p.Name()][id]
+			for event, err := range r.eventStore.ReadStream(ctx, id, applied[id]) {
+				if err != nil {
+					return n, fmt.Errorf("failed to read events for %s: %w", id, err)
+				}
+				if event.Version > last {
+					break
+				}
+				if err := r.apply(ctx, p, event); err != nil {
+					return n, err
+				}
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+// apply passes event to p if p handles its type
+func (r *Rebuilder) apply(ctx context.Context, p Projection, event store.Event) error {
+	if slices.Contains(r.handles[p.Name()], event.EventType) {
+		if err := p.Handle(ctx, r.target, event); err != nil {
+			return fmt.Errorf("failed to project event %s (%s %s v%d) to %s: %w",
+				event.ID, event.EventType, event.AggregateID, event.Version, p.Name(), err)
+		}
+	}
+	r.applied[p.Name()][event.AggregateID] = event.Version
+	return nil
+}
@@ -0,0 +1,127 @@
+package projection
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/example/ec-event-driven/internal/domain/cart"
+	"github.com/example/ec-event-driven/internal/domain/inventory"
+	"github.com/example/ec-event-driven/internal/domain/product"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/example/ec-event-driven/internal/infrastructure/store/mocks"
+	"github.com/example/ec-event-driven/internal/readmodel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// appendShopHistory stores a product with stock and a cart holding it
+func appendShopHistory(t *testing.T, eventStore *mocks.MockEventStore) {
+	ctx := context.Background()
+	now := time.Now()
+	_, err := eventStore.Append(ctx, "prod-1", product.AggregateType, product.EventProductCreated,
+		product.ProductCreated{ProductID: "prod-1", Name: "Widget", Price: 500, CreatedAt: now})
+	require.NoError(t, err)
+	_, err = eventStore.Append(ctx, "prod-1", inventory.AggregateType, inventory.EventStockAdded,
+		inventory.StockAdded{ProductID: "prod-1", Quantity: 10, AddedAt: now})
+	require.NoError(t, err)
+	_, err = eventStore.Append(ctx, "prod-1", inventory.AggregateType, inventory.EventStockAdded,
+		inventory.StockAdded{ProductID: "prod-1", Quantity: 5, AddedAt: now})
+	require.NoError(t, err)
+	_, err = eventStore.Append(ctx, "cart-1", cart.AggregateType, cart.EventItemAdded,
+		cart.ItemAddedToCart{CartID: "cart-1", UserID: "user-1", ProductID: "prod-1", Quantity: 2, Price: 500, AddedAt: now})
+	require.NoError(t, err)
+}
+
+func TestRebuilder_ReplaysAllCollections(t *testing.T) {
+	eventStore := mocks.NewMockEventStore()
+	appendShopHistory(t, eventStore)
+	shadow := mocks.NewMockReadStore()
+
+	projections, err := Default.Select(RebuildableProjections)
+	require.NoError(t, err)
+	r, err := NewRebuilder(eventStore, shadow, projections)
+	require.NoError(t, err)
+	n, err := r.Replay(context.Background(), nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	inv, ok := shadow.GetData("inventory", "prod-1")
+	require.True(t, ok)
+	assert.Equal(t, 15, inv.(*readmodel.InventoryReadModel).TotalStock)
+	prod, ok := shadow.GetData("products", "prod-1")
+	require.True(t, ok)
+	assert.Equal(t, 15, prod.(*readmodel.ProductReadModel).Stock)
+	c, ok := shadow.GetData("carts", "cart-1")
+	require.True(t, ok)
+	assert.Equal(t, 1000, c.(*readmodel.CartReadModel).Total)
+}
+
+func TestRebuilder_OnlySelectedProjections(t *testing.T) {
+	eventStore := mocks.NewMockEventStore()
+	appendShopHistory(t, eventStore)
+	shadow := mocks.NewMockReadStore()
+
+	r, err := NewRebuilder(eventStore, shadow, []Projection{inventoryProjection{}})
+	require.NoError(t, err)
+	_, err = r.Replay(context.Background(), nil)
+
+	require.NoError(t, err)
+	inv, ok := shadow.GetData("inventory", "prod-1")
+	require.True(t, ok)
+	assert.Equal(t, 15, inv.(*readmodel.InventoryReadModel).TotalStock)
+	_, ok = shadow.GetData("products", "prod-1")
+	assert.False(t, ok)
+	_, ok = shadow.GetData("carts", "cart-1")
+	assert.False(t, ok)
+}
+
+func TestRebuilder_StopsAtCheckpointsAndCatchesUp(t *testing.T) {
+	eventStore := mocks.NewMockEventStore()
+	appendShopHistory(t, eventStore)
+	shadow := mocks.NewMockReadStore()
+	ctx := context.Background()
+
+	r, err := NewRebuilder(eventStore, shadow, []Projection{inventoryProjection{}})
+	require.NoError(t, err)
+
+	// The live projection has only applied the first StockAdded (version 2) so far
+	n, err := r.Replay(ctx, store.ProjectionCheckpoints{"inventory": {"prod-1": 2}})
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	inv, _ := shadow.GetData("inventory", "prod-1")
+	assert.Equal(t, 10, inv.(*readmodel.InventoryReadModel).TotalStock)
+
+	// By the time of the swap it has applied the second one as well
+	n, err = r.CatchUp(ctx, store.ProjectionCheckpoints{"inventory": {"prod-1": 3, "cart-1": 1}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	inv, _ = shadow.GetData("inventory", "prod-1")
+	assert.Equal(t, 15, inv.(*readmodel.InventoryReadModel).TotalStock)
+}
+
+func TestRebuilder_ResetsTarget(t *testing.T) {
+	eventStore := mocks.NewMockEventStore()
+	appendShopHistory(t, eventStore)
+	target := mocks.NewMockReadStore()
+	target.SetData("inventory", "stale", &readmodel.InventoryReadModel{ProductID: "stale"})
+	target.SetData("carts", "cart-2", &readmodel.CartReadModel{ID: "cart-2"})
+
+	r, err := NewRebuilder(eventStore, target, []Projection{inventoryProjection{}})
+	require.NoError(t, err)
+	_, err = r.Replay(context.Background(), nil)
+
+	require.NoError(t, err)
+	_, ok := target.GetData("inventory", "stale")
+	assert.False(t, ok)
+	_, ok = target.GetData("inventory", "prod-1")
+	assert.True(t, ok)
+	// Read models of other projections are left alone
+	_, ok = target.GetData("carts", "cart-2")
+	assert.True(t, ok)
+}
+
+func TestNewRebuilder_RequiresProjections(t *testing.T) {
+	_, err := NewRebuilder(mocks.NewMockEventStore(), mocks.NewMockReadStore(), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,83 @@
+package projection
+
+import (
+	"context"
+
+	"github.com/example/ec-event-driven/internal/domain/user"
+	"github.com/example/ec-event-driven/internal/eventcodec"
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
+	"github.com/example/ec-event-driven/internal/readmodel"
+)
+
+func init() {
+	Register(userProjection{})
+}
+
+// userProjection builds the users read models used for login and notifications
+type userProjection struct {
+	models *store.ReadModels
+}
+
+func (userProjection) Name() string { return "users" }
+
+func (p userProjection) EventTypes() []string { return p.dispatcher().EventTypes() }
+
+func (userProjection) Handle(ctx context.Context, rs store.ReadStoreInterface, event store.Event) error {
+	return userProjection{models: rs.ReadModels()}.dispatcher().Dispatch(ctx, event)
+}
+
+func (userProjection) Reset(_ context.Context, rs store.ReadStoreInterface) error {
+	return clearCollections(rs, "users")
+}
+
+// dispatcher registers the user handlers. UserLoggedIn does not change the read models.
+func (p userProjection) dispatcher() *eventcodec.Dispatcher {
+	d := eventcodec.NewDispatcher(eventcodec.Default)
+	eventcodec.On(d, p.onUserCreated)
+	eventcodec.On(d, p.onUserUpdated)
+	eventcodec.On(d, p.onUserPasswordChanged)
+	eventcodec.On(d, p.onUserDeactivated)
+	eventcodec.On(d, p.onUserActivated)
+	return d
+}
+
+func (p userProjection) onUserCreated(ctx context.Context, _ store.Event, e user.UserCreated) error {
+	return p.models.Users.Upsert(ctx, e.UserID, &readmodel.UserReadModel{
+		ID:           e.UserID,
+		Email:        e.Email,
+		PasswordHash: e.PasswordHash,
+		Name:         e.Name,
+		Role:         e.Role,
+		IsActive:     true,
+		CreatedAt:    e.CreatedAt,
+		UpdatedAt:    e.CreatedAt,
+	})
+}
+
+func (p userProjection) onUserUpdated(ctx context.Context, _ store.Event, e user.UserUpdated) error {
+	return update(ctx, p.models.Users, e.UserID, func(u *readmodel.UserReadModel) {
+		u.Name = e.Name
+		u.UpdatedAt = e.UpdatedAt
+	})
+}
+
+func (p userProjection) onUserPasswordChanged(ctx context.Context, _ store.Event, e user.UserPasswordChanged) error {
+	return update(ctx, p.models.Users, e.UserID, func(u *readmodel.UserReadModel) {
+		u.PasswordHash = e.PasswordHash
+		u.UpdatedAt = e.ChangedAt
+	})
+}
+
+func (p userProjection) onUserDeactivated(ctx context.Context, _ store.Event, e user.UserDeactivated) error {
+	return update(ctx, p.models.Users, e.UserID, func(u *readmodel.UserReadModel) {
+		u.IsActive = false
+		u.UpdatedAt = e.DeactivatedAt
+	})
+}
+
+func (p userProjection) onUserActivated(ctx context.Context, _ store.Event, e user.UserActivated) error {
+	return update(ctx, p.models.Users, e.UserID, func(u *readmodel.UserReadModel) {
+		u.IsActive = true
+		u.UpdatedAt = e.ActivatedAt
+	})
+}
@@ -1,50 +1,109 @@
 package query
 
 import (
+	"context"
 	"log"
+	"time"
 
 	"github.com/example/ec-event-driven/internal/domain/cart"
 	"github.com/example/ec-event-driven/internal/infrastructure/store"
 )
 
+// Defaults for waiting on consistency tokens
+const (
+	DefaultConsistencyTimeout      = 2 * time.Second
+	DefaultConsistencyPollInterval = 50 * time.Millisecond
+)
+
+// CheckpointFunc returns the last version of each of the given aggregates
+// applied to the read models. Aggregates that were never applied are omitted.
+type CheckpointFunc func(ctx context.Context, aggregateIDs []string) (map[string]int, error)
+
 type Handler struct {
-	readStore store.ReadStoreInterface
+	readModels *store.ReadModels
+
+	// Checkpoints, if set, lets WaitFor check how far the read models are.
+	// Without it, no consistency token is ever reached.
+	Checkpoints CheckpointFunc
+	// ConsistencyTimeout is how long WaitFor waits at most
+	ConsistencyTimeout time.Duration
+	// ConsistencyPollInterval is how often WaitFor checks the checkpoints
+	ConsistencyPollInterval time.Duration
+}
+
+func NewHandler(readModels *store.ReadModels) *Handler {
+	return &Handler{
+		readModels:              readModels,
+		ConsistencyTimeout:      DefaultConsistencyTimeout,
+		ConsistencyPollInterval: DefaultConsistencyPollInterval,
+	}
+}
+
+// WaitFor waits until the read models reflect the writes of token, for at
+// most ConsistencyTimeout, and reports whether they do
+func (h *Handler) WaitFor(ctx context.Context, token store.ConsistencyToken) bool {
+	if len(token) == 0 {
+		return true
+	}
+	if h.Checkpoints == nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.ConsistencyTimeout)
+	defer cancel()
+	ticker := time.NewTicker(h.ConsistencyPollInterval)
+	defer ticker.Stop()
+
+	aggregateIDs := token.AggregateIDs()
+	for {
+		checkpoints, err := h.Checkpoints(ctx, aggregateIDs)
+		if err == nil && reached(token, checkpoints) {
+			return true
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[Query] Error getting checkpoints of %v: %v", aggregateIDs, err)
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
 }
 
-func NewHandler(readStore store.ReadStoreInterface) *Handler {
-	return &Handler{readStore: readStore}
+// reached reports whether every aggregate of token is applied up to its version
+func reached(token store.ConsistencyToken, checkpoints map[string]int) bool {
+	for id, version := range token {
+		if checkpoints[id] < version {
+			return false
+		}
+	}
+	return true
 }
 
 // Products
-func (h *Handler) GetProduct(id string) (*ProductReadModel, bool) {
-	data, ok, err := h.readStore.Get("products", id)
+func (h *Handler) GetProduct(ctx context.Context, id string) (*ProductReadModel, bool) {
+	p, ok, err := h.readModels.Products.Get(ctx, id)
 	if err != nil {
 		log.Printf("[Query] Error getting product %s: %v", id, err)
 		return nil, false
 	}
-	if !ok {
-		return nil, false
-	}
-	return data.(*ProductReadModel), true
+	return p, ok
 }
 
-func (h *Handler) ListProducts() []*ProductReadModel {
-	items, err := h.readStore.GetAll("products")
+func (h *Handler) ListProducts(ctx context.Context) []*ProductReadModel {
+	products, err := h.readModels.Products.List(ctx)
 	if err != nil {
 		log.Printf("[Query] Error listing products: %v", err)
 		return nil
 	}
-	products := make([]*ProductReadModel, 0, len(items))
-	for _, item := range items {
-		products = append(products, item.(*ProductReadModel))
-	}
 	return products
 }
 
 // Cart
-func (h *Handler) GetCart(userID string) (*CartReadModel, bool) {
+func (h *Handler) GetCart(ctx context.Context, userID string) (*CartReadModel, bool) {
 	cartID := cart.GetCartID(userID)
-	data, ok, err := h.readStore.Get("carts", cartID)
+	c, ok, err := h.readModels.Carts.Get(ctx, cartID)
 	if err != nil {
 		log.Printf("[Query] Error getting cart %s: %v", cartID, err)
 		return nil, false
@@ -58,31 +117,27 @@ func (h *Handler) GetCart(userID string) (*CartReadModel, bool) {
 			Total:  0,
 		}, true
 	}
-	return data.(*CartReadModel), true
+	return c, true
 }
 
 // Orders
-func (h *Handler) GetOrder(id string) (*OrderReadModel, bool) {
-	data, ok, err := h.readStore.Get("orders", id)
+func (h *Handler) GetOrder(ctx context.Context, id string) (*OrderReadModel, bool) {
+	o, ok, err := h.readModels.Orders.Get(ctx, id)
 	if err != nil {
 		log.Printf("[Query] Error getting order %s: %v", id, err)
 		return nil, false
 	}
-	if !ok {
-		return nil, false
-	}
-	return data.(*OrderReadModel), true
+	return o, ok
 }
 
-func (h *Handler) ListOrdersByUser(userID string) []*OrderReadModel {
-	items, err := h.readStore.GetAll("orders")
+func (h *Handler) ListOrdersByUser(ctx context.Context, userID string) []*OrderReadModel {
+	all, err := h.readModels.Orders.List(ctx)
 	if err != nil {
 		log.Printf("[Query] Error listing orders: %v", err)
 		return nil
 	}
 	orders := make([]*OrderReadModel, 0)
-	for _, item := range items {
-		o := item.(*OrderReadModel)
+	for _, o := range all {
 		if o.UserID == userID {
 			orders = append(orders, o)
 		}
@@ -91,28 +146,21 @@ func (h *Handler) ListOrdersByUser(userID string) []*OrderReadModel {
 }
 
 // ListAllOrders returns all orders (for admin use)
-func (h *Handler) ListAllOrders() []*OrderReadModel {
-	items, err := h.readStore.GetAll("orders")
+func (h *Handler) ListAllOrders(ctx context.Context) []*OrderReadModel {
+	orders, err := h.readModels.Orders.List(ctx)
 	if err != nil {
 		log.Printf("[Query] Error listing all orders: %v", err)
 		return nil
 	}
-	orders := make([]*OrderReadModel, 0, len(items))
-	for _, item := range items {
-		orders = append(orders, item.(*OrderReadModel))
-	}
 	return orders
 }
 
 // Inventory
-func (h *Handler) GetInventory(productID string) (*InventoryReadModel, bool) {
-	data, ok, err := h.readStore.Get("inventory", productID)
+func (h *Handler) GetInventory(ctx context.Context, productID string) (*InventoryReadModel, bool) {
+	inv, ok, err := h.readModels.Inventory.Get(ctx, productID)
 	if err != nil {
 		log.Printf("[Query] Error getting inventory %s: %v", productID, err)
 		return nil, false
 	}
-	if !ok {
-		return nil, false
-	}
-	return data.(*InventoryReadModel), true
+	return inv, ok
 }
@@ -1,16 +1,19 @@
 package query
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/example/ec-event-driven/internal/infrastructure/store"
 	"github.com/example/ec-event-driven/internal/infrastructure/store/mocks"
 	"github.com/stretchr/testify/assert"
 )
 
 func newTestQueryHandler() (*Handler, *mocks.MockReadStore) {
 	readStore := mocks.NewMockReadStore()
-	handler := NewHandler(readStore)
+	handler := NewHandler(readStore.ReadModels())
 	return handler, readStore
 }
 
@@ -31,7 +34,7 @@ func TestHandler_GetProduct_Found(t *testing.T) {
 	}
 	readStore.SetData("products", "prod-123", expectedProduct)
 
-	product, found := handler.GetProduct("prod-123")
+	product, found := handler.GetProduct(context.Background(), "prod-123")
 
 	assert.True(t, found)
 	assert.Equal(t, expectedProduct.ID, product.ID)
@@ -42,7 +45,7 @@ func TestHandler_GetProduct_Found(t *testing.T) {
 func TestHandler_GetProduct_NotFound(t *testing.T) {
 	handler, _ := newTestQueryHandler()
 
-	product, found := handler.GetProduct("non-existent")
+	product, found := handler.GetProduct(context.Background(), "non-existent")
 
 	assert.False(t, found)
 	assert.Nil(t, product)
@@ -55,7 +58,7 @@ func TestHandler_ListProducts_WithProducts(t *testing.T) {
 	readStore.SetData("products", "prod-2", &ProductReadModel{ID: "prod-2", Name: "Product 2"})
 	readStore.SetData("products", "prod-3", &ProductReadModel{ID: "prod-3", Name: "Product 3"})
 
-	products := handler.ListProducts()
+	products := handler.ListProducts(context.Background())
 
 	assert.Len(t, products, 3)
 }
@@ -63,11 +66,21 @@ func TestHandler_ListProducts_WithProducts(t *testing.T) {
 func TestHandler_ListProducts_Empty(t *testing.T) {
 	handler, _ := newTestQueryHandler()
 
-	products := handler.ListProducts()
+	products := handler.ListProducts(context.Background())
 
 	assert.Empty(t, products)
 }
 
+func TestHandler_ListProducts_ReadError(t *testing.T) {
+	handler, readStore := newTestQueryHandler()
+	readStore.SetData("products", "prod-1", &ProductReadModel{ID: "prod-1"})
+	readStore.ReadErr = errors.New("connection refused")
+
+	products := handler.ListProducts(context.Background())
+
+	assert.Nil(t, products)
+}
+
 // ============================================
 // Cart Query Tests
 // ============================================
@@ -85,7 +98,7 @@ func TestHandler_GetCart_Found(t *testing.T) {
 	}
 	readStore.SetData("carts", "cart-user-123", expectedCart)
 
-	cart, found := handler.GetCart("user-123")
+	cart, found := handler.GetCart(context.Background(), "user-123")
 
 	assert.True(t, found)
 	assert.Equal(t, expectedCart.ID, cart.ID)
@@ -97,7 +110,7 @@ func TestHandler_GetCart_Found(t *testing.T) {
 func TestHandler_GetCart_NotFound_ReturnsEmptyCart(t *testing.T) {
 	handler, _ := newTestQueryHandler()
 
-	cart, found := handler.GetCart("user-with-no-cart")
+	cart, found := handler.GetCart(context.Background(), "user-with-no-cart")
 
 	// GetCart returns an empty cart when not found
 	assert.True(t, found)
@@ -126,7 +139,7 @@ func TestHandler_GetOrder_Found(t *testing.T) {
 	}
 	readStore.SetData("orders", "order-123", expectedOrder)
 
-	order, found := handler.GetOrder("order-123")
+	order, found := handler.GetOrder(context.Background(), "order-123")
 
 	assert.True(t, found)
 	assert.Equal(t, expectedOrder.ID, order.ID)
@@ -136,7 +149,7 @@ func TestHandler_GetOrder_Found(t *testing.T) {
 func TestHandler_GetOrder_NotFound(t *testing.T) {
 	handler, _ := newTestQueryHandler()
 
-	order, found := handler.GetOrder("non-existent")
+	order, found := handler.GetOrder(context.Background(), "non-existent")
 
 	assert.False(t, found)
 	assert.Nil(t, order)
@@ -149,7 +162,7 @@ func TestHandler_ListOrdersByUser_WithOrders(t *testing.T) {
 	readStore.SetData("orders", "order-2", &OrderReadModel{ID: "order-2", UserID: "user-123"})
 	readStore.SetData("orders", "order-3", &OrderReadModel{ID: "order-3", UserID: "user-456"})
 
-	orders := handler.ListOrdersByUser("user-123")
+	orders := handler.ListOrdersByUser(context.Background(), "user-123")
 
 	assert.Len(t, orders, 2)
 	for _, order := range orders {
@@ -160,7 +173,7 @@ func TestHandler_ListOrdersByUser_WithOrders(t *testing.T) {
 func TestHandler_ListOrdersByUser_NoOrders(t *testing.T) {
 	handler, _ := newTestQueryHandler()
 
-	orders := handler.ListOrdersByUser("user-with-no-orders")
+	orders := handler.ListOrdersByUser(context.Background(), "user-with-no-orders")
 
 	assert.Empty(t, orders)
 }
@@ -171,7 +184,7 @@ func TestHandler_ListAllOrders_WithOrders(t *testing.T) {
 	readStore.SetData("orders", "order-1", &OrderReadModel{ID: "order-1", UserID: "user-123"})
 	readStore.SetData("orders", "order-2", &OrderReadModel{ID: "order-2", UserID: "user-456"})
 
-	orders := handler.ListAllOrders()
+	orders := handler.ListAllOrders(context.Background())
 
 	assert.Len(t, orders, 2)
 }
@@ -179,7 +192,7 @@ func TestHandler_ListAllOrders_WithOrders(t *testing.T) {
 func TestHandler_ListAllOrders_Empty(t *testing.T) {
 	handler, _ := newTestQueryHandler()
 
-	orders := handler.ListAllOrders()
+	orders := handler.ListAllOrders(context.Background())
 
 	assert.Empty(t, orders)
 }
@@ -199,7 +212,7 @@ func TestHandler_GetInventory_Found(t *testing.T) {
 	}
 	readStore.SetData("inventory", "prod-123", expectedInventory)
 
-	inventory, found := handler.GetInventory("prod-123")
+	inventory, found := handler.GetInventory(context.Background(), "prod-123")
 
 	assert.True(t, found)
 	assert.Equal(t, expectedInventory.ProductID, inventory.ProductID)
@@ -210,7 +223,7 @@ func TestHandler_GetInventory_Found(t *testing.T) {
 func TestHandler_GetInventory_NotFound(t *testing.T) {
 	handler, _ := newTestQueryHandler()
 
-	inventory, found := handler.GetInventory("non-existent")
+	inventory, found := handler.GetInventory(context.Background(), "non-existent")
 
 	assert.False(t, found)
 	assert.Nil(t, inventory)
@@ -236,8 +249,54 @@ func TestCartTotal(t *testing.T) {
 	}
 	readStore.SetData("carts", "cart-user-123", cart)
 
-	result, found := handler.GetCart("user-123")
+	result, found := handler.GetCart(context.Background(), "user-123")
 
 	assert.True(t, found)
 	assert.Equal(t, 5500, result.Total)
 }
+
+// ============================================
+// Consistency Token Tests
+// ============================================
+
+func TestHandler_WaitFor_ReachedAfterProjectorCatchesUp(t *testing.T) {
+	handler, _ := newTestQueryHandler()
+	handler.ConsistencyPollInterval = time.Millisecond
+
+	var calls int
+	handler.Checkpoints = func(_ context.Context, aggregateIDs []string) (map[string]int, error) {
+		assert.Equal(t, []string{"cart-user-1", "order-1"}, aggregateIDs)
+		calls++
+		if calls < 3 {
+			return map[string]int{"cart-user-1": 4}, nil // The order is not projected yet
+		}
+		return map[string]int{"cart-user-1": 5, "order-1": 1}, nil
+	}
+
+	reached := handler.WaitFor(context.Background(), store.ConsistencyToken{"cart-user-1": 5, "order-1": 1})
+
+	assert.True(t, reached)
+	assert.Equal(t, 3, calls)
+}
+
+func TestHandler_WaitFor_TimesOut(t *testing.T) {
+	handler, _ := newTestQueryHandler()
+	handler.ConsistencyTimeout = 20 * time.Millisecond
+	handler.ConsistencyPollInterval = time.Millisecond
+	handler.Checkpoints = func(context.Context, []string) (map[string]int, error) {
+		return nil, errors.New("database unavailable")
+	}
+
+	start := time.Now()
+	reached := handler.WaitFor(context.Background(), store.ConsistencyToken{"order-1": 1})
+
+	assert.False(t, reached)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestHandler_WaitFor_WithoutCheckpoints(t *testing.T) {
+	handler, _ := newTestQueryHandler()
+
+	assert.True(t, handler.WaitFor(context.Background(), nil), "an empty token is always reached")
+	assert.False(t, handler.WaitFor(context.Background(), store.ConsistencyToken{"order-1": 1}))
+}
@@ -41,13 +41,16 @@ type OrderItemReadModel struct {
 
 // OrderReadModel is the read model for orders
 type OrderReadModel struct {
-	ID        string               `json:"id"`
-	UserID    string               `json:"user_id"`
-	Items     []OrderItemReadModel `json:"items"`
-	Total     int                  `json:"total"`
-	Status    string               `json:"status"`
-	CreatedAt time.Time            `json:"created_at"`
-	UpdatedAt time.Time            `json:"updated_at"`
+	ID             string               `json:"id"`
+	UserID         string               `json:"user_id"`
+	Items          []OrderItemReadModel `json:"items"`
+	Total          int                  `json:"total"`
+	Status         string               `json:"status"`
+	Carrier        string               `json:"carrier,omitempty"`
+	TrackingNumber string               `json:"tracking_number,omitempty"`
+	ShippedAt      *time.Time           `json:"shipped_at,omitempty"`
+	CreatedAt      time.Time            `json:"created_at"`
+	UpdatedAt      time.Time            `json:"updated_at"`
 }
 
 // InventoryReadModel is the read model for inventory
@@ -19,20 +19,34 @@ fi
 
 # DynamoDB Events Table
 echo "Creating DynamoDB Events table: ${EVENTS_TABLE_NAME}"
-$AWS_CMD dynamodb create-table \
+if $AWS_CMD dynamodb create-table \
   --table-name "${EVENTS_TABLE_NAME}" \
   --attribute-definitions \
     AttributeName=aggregate_id,AttributeType=S \
     AttributeName=version,AttributeType=N \
-    AttributeName=gsi1pk,AttributeType=S \
-    AttributeName=created_at,AttributeType=S \
+    AttributeName=position_bucket,AttributeType=N \
+    AttributeName=position,AttributeType=N \
+    AttributeName=sequence_shard,AttributeType=N \
+    AttributeName=sequence_key,AttributeType=N \
   --key-schema \
     AttributeName=aggregate_id,KeyType=HASH \
     AttributeName=version,KeyType=RANGE \
   --global-secondary-indexes \
-    "[{\"IndexName\":\"GSI1\",\"KeySchema\":[{\"AttributeName\":\"gsi1pk\",\"KeyType\":\"HASH\"},{\"AttributeName\":\"created_at\",\"KeyType\":\"RANGE\"}],\"Projection\":{\"ProjectionType\":\"ALL\"}}]" \
+    "[{\"IndexName\":\"PositionIndex\",\"KeySchema\":[{\"AttributeName\":\"position_bucket\",\"KeyType\":\"HASH\"},{\"AttributeName\":\"position\",\"KeyType\":\"RANGE\"}],\"Projection\":{\"ProjectionType\":\"ALL\"}},{\"IndexName\":\"SequenceIndex\",\"KeySchema\":[{\"AttributeName\":\"sequence_shard\",\"KeyType\":\"HASH\"},{\"AttributeName\":\"sequence_key\",\"KeyType\":\"RANGE\"}],\"Projection\":{\"ProjectionType\":\"KEYS_ONLY\"}}]" \
   --billing-mode PAY_PER_REQUEST \
-  --region "${AWS_REGION}" || echo "Events table might already exist"
+  --region "${AWS_REGION}"; then
+  # A new table has no events without positions, so it needs no backfill
+  $AWS_CMD dynamodb wait table-exists \
+    --table-name "${EVENTS_TABLE_NAME}" \
+    --region "${AWS_REGION}"
+  $AWS_CMD dynamodb put-item \
+    --table-name "${EVENTS_TABLE_NAME}" \
+    --item '{"aggregate_id":{"S":"$position"},"version":{"N":"0"},"last_position":{"N":"0"},"backfilled":{"BOOL":true}}' \
+    --condition-expression "attribute_not_exists(aggregate_id)" \
+    --region "${AWS_REGION}"
+else
+  echo "Events table might already exist"
+fi
 
 # DynamoDB Snapshots Table
 echo "Creating DynamoDB Snapshots table: ${SNAPSHOTS_TABLE_NAME}"